@@ -762,12 +762,11 @@ class SuperBizAgentApp {
                                 return;
                             }
                             
-                            // 只处理message事件的数据
+                            // 只处理message事件的数据，data 为 {"content": "..."} 的 JSON
                             if (currentEvent === 'message') {
-                                // 如果 data 为空字符串，认为是换行
-                                if (data === '') {
-                                    fullResponse += '\n';
-                                } else {
+                                try {
+                                    fullResponse += JSON.parse(data).content || '';
+                                } catch (e) {
                                     fullResponse += data;
                                 }
                                 
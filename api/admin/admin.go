@@ -36,4 +36,22 @@ type IAdminV1 interface {
 
 	RuntimeStatus(ctx context.Context, req *v1.RuntimeStatusReq) (res *v1.RuntimeStatusRes, err error)
 	RuntimeLogs(ctx context.Context, req *v1.RuntimeLogsReq) (res *v1.RuntimeLogsRes, err error)
+
+	Tools(ctx context.Context, req *v1.ToolsReq) (res *v1.ToolsRes, err error)
+	UpdateTool(ctx context.Context, req *v1.UpdateToolReq) (res *v1.UpdateToolRes, err error)
+	ResetTool(ctx context.Context, req *v1.ResetToolReq) (res *v1.ResetToolRes, err error)
+
+	LLMProviders(ctx context.Context, req *v1.LLMProvidersReq) (res *v1.LLMProvidersRes, err error)
+
+	Usage(ctx context.Context, req *v1.UsageReq) (res *v1.UsageRes, err error)
+
+	Prompts(ctx context.Context, req *v1.PromptsReq) (res *v1.PromptsRes, err error)
+	PromptVersions(ctx context.Context, req *v1.PromptVersionsReq) (res *v1.PromptVersionsRes, err error)
+	SavePrompt(ctx context.Context, req *v1.SavePromptReq) (res *v1.SavePromptRes, err error)
+	RollbackPrompt(ctx context.Context, req *v1.RollbackPromptReq) (res *v1.RollbackPromptRes, err error)
+	PreviewPrompt(ctx context.Context, req *v1.PreviewPromptReq) (res *v1.PreviewPromptRes, err error)
+
+	AgentRuns(ctx context.Context, req *v1.AgentRunsReq) (res *v1.AgentRunsRes, err error)
+	AgentRun(ctx context.Context, req *v1.AgentRunReq) (res *v1.AgentRunRes, err error)
+	ReplayAgentRun(ctx context.Context, req *v1.ReplayAgentRunReq) (res *v1.ReplayAgentRunRes, err error)
 }
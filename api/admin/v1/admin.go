@@ -362,3 +362,301 @@ type RuntimeLogsRes struct {
 	Path    string `json:"path"`
 	Content string `json:"content"`
 }
+
+// =================
+// AI Tools
+// =================
+
+type ToolsReq struct {
+	g.Meta    `path:"/admin/tools" method:"get" summary:"AI 工具列表"`
+	Category  string `json:"category" in:"query"`
+	AgentType string `json:"agent_type" in:"query"`
+}
+
+type ToolItem struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Category    string   `json:"category"`
+	Enabled     bool     `json:"enabled"`
+	AgentTypes  []string `json:"agent_types"`
+	CacheTTL    int64    `json:"cache_ttl_seconds"`    // 0: results are never cached
+	Schema      any      `json:"schema,omitempty"`     // JSON schema of the tool arguments
+	Overridden  bool     `json:"overridden,omitempty"` // has a persisted admin override
+}
+
+type ToolsRes struct {
+	Items []ToolItem `json:"items"`
+	Total int        `json:"total"`
+}
+
+type UpdateToolReq struct {
+	g.Meta     `path:"/admin/tools/{name}" method:"patch" summary:"启用/禁用工具或修改可用的 Agent"`
+	Name       string   `json:"name" in:"path"`
+	Enabled    *bool    `json:"enabled"`
+	AgentTypes []string `json:"agent_types"`
+}
+
+type UpdateToolRes struct {
+	Item ToolItem `json:"item"`
+}
+
+type ResetToolReq struct {
+	g.Meta `path:"/admin/tools/{name}/override" method:"delete" summary:"删除工具覆盖配置，恢复注册时的默认值"`
+	Name   string `json:"name" in:"path"`
+}
+
+type ResetToolRes struct {
+	Item ToolItem `json:"item"`
+}
+
+// =================
+// LLM Gateway
+// =================
+
+type LLMProvidersReq struct {
+	g.Meta `path:"/admin/llm/providers" method:"get" summary:"模型网关 provider 健康状态与路由"`
+}
+
+type LLMProviderItem struct {
+	Name                string  `json:"name"`
+	Type                string  `json:"type"`
+	Model               string  `json:"model"`
+	State               string  `json:"state"` // closed | half-open | open
+	Requests            int64   `json:"requests"`
+	Failures            int64   `json:"failures"`
+	ConsecutiveFailures int     `json:"consecutive_failures"`
+	AvgLatencyMs        float64 `json:"avg_latency_ms"`
+	LastLatencyMs       float64 `json:"last_latency_ms"`
+	LastError           string  `json:"last_error,omitempty"`
+	LastErrorAt         string  `json:"last_error_at,omitempty"`
+	LastSuccessAt       string  `json:"last_success_at,omitempty"`
+}
+
+type LLMProvidersRes struct {
+	Items  []LLMProviderItem   `json:"items"`
+	Routes map[string][]string `json:"routes"`
+}
+
+// =================
+// LLM Usage
+// =================
+
+type UsageReq struct {
+	g.Meta  `path:"/admin/usage" method:"get" summary:"LLM token 用量报表"`
+	From    string `json:"from" in:"query"`     // YYYY-MM-DD, default 6 days before to
+	To      string `json:"to" in:"query"`       // YYYY-MM-DD, default today (UTC)
+	GroupBy string `json:"group_by" in:"query"` // day | user | subject | model
+	UserID  int64  `json:"user_id" in:"query"`
+	Subject string `json:"subject" in:"query"` // e.g. user:1, incident:xxx, cli:chat_cmd, system
+	Model   string `json:"model" in:"query"`
+}
+
+type UsageItem struct {
+	Key              string  `json:"key"`
+	UserID           *int64  `json:"user_id,omitempty"`
+	Username         string  `json:"username,omitempty"`
+	APIQuota         *int64  `json:"api_quota,omitempty"`
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+type UsageRes struct {
+	Items   []UsageItem `json:"items"`
+	Total   UsageItem   `json:"total"`
+	From    string      `json:"from"`
+	To      string      `json:"to"`
+	GroupBy string      `json:"group_by"`
+}
+
+// =================
+// Prompt Templates
+// =================
+
+type PromptMatch struct {
+	AlertNames []string          `json:"alertnames,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"` // 值支持通配符，如 "resume-*"
+}
+
+type PromptTemplateItem struct {
+	Kind        string      `json:"kind"` // diagnosis | aiops | chat_system
+	Key         string      `json:"key"`
+	Version     int         `json:"version"`
+	Description string      `json:"description"`
+	Match       PromptMatch `json:"match"`
+	Priority    int         `json:"priority"`
+	Body        string      `json:"body"`
+	Disabled    bool        `json:"disabled"`
+	Source      string      `json:"source"` // embedded | file | db
+	CreatedBy   string      `json:"created_by"`
+	CreatedAt   string      `json:"created_at"`
+}
+
+type PromptsReq struct {
+	g.Meta `path:"/admin/prompts" method:"get" summary:"当前生效的提示词模板"`
+	Kind   string `json:"kind" in:"query"`
+}
+
+type PromptsRes struct {
+	Items []PromptTemplateItem `json:"items"`
+	Total int                  `json:"total"`
+}
+
+type PromptVersionsReq struct {
+	g.Meta `path:"/admin/prompts/{kind}/{key}/versions" method:"get" summary:"提示词模板的历史版本"`
+	Kind   string `json:"kind" in:"path"`
+	Key    string `json:"key" in:"path"`
+}
+
+type PromptVersionsRes struct {
+	Items []PromptTemplateItem `json:"items"`
+}
+
+type SavePromptReq struct {
+	g.Meta      `path:"/admin/prompts" method:"post" summary:"保存提示词模板（新增一个版本并立即生效）"`
+	Kind        string      `json:"kind" v:"required"`
+	Key         string      `json:"key" v:"required"`
+	Description string      `json:"description"`
+	Match       PromptMatch `json:"match"`
+	Priority    int         `json:"priority"`
+	Body        string      `json:"body" v:"required"`
+	Disabled    bool        `json:"disabled"`
+}
+
+type SavePromptRes struct {
+	Item PromptTemplateItem `json:"item"`
+}
+
+type RollbackPromptReq struct {
+	g.Meta  `path:"/admin/prompts/{kind}/{key}/rollback" method:"post" summary:"回滚提示词模板到指定版本"`
+	Kind    string `json:"kind" in:"path"`
+	Key     string `json:"key" in:"path"`
+	Version int    `json:"version" v:"required|min:1"`
+}
+
+type RollbackPromptRes struct {
+	Item PromptTemplateItem `json:"item"`
+}
+
+// PromptSampleIncident 预览用的样例告警，不填则使用内置样例。
+type PromptSampleIncident struct {
+	AlertName   string            `json:"alertname"`
+	Severity    string            `json:"severity"`
+	Summary     string            `json:"summary"`
+	Description string            `json:"description"`
+	Labels      map[string]string `json:"labels"`
+	StartedAt   string            `json:"started_at"` // RFC3339，默认 10 分钟前
+}
+
+type PreviewPromptReq struct {
+	g.Meta   `path:"/admin/prompts/preview" method:"post" summary:"按样例告警渲染提示词模板"`
+	Kind     string                `json:"kind" v:"required"`
+	Key      string                `json:"key"`  // 不填则按样例告警选择模板
+	Body     string                `json:"body"` // 草稿内容，填写时直接渲染草稿
+	Incident *PromptSampleIncident `json:"incident"`
+}
+
+type PreviewPromptRes struct {
+	Kind     string `json:"kind"`
+	Key      string `json:"key"`
+	Version  int    `json:"version"`
+	Source   string `json:"source"`
+	Rendered string `json:"rendered"`
+}
+
+// =================
+// Agent Runs
+// =================
+
+type AgentRunsReq struct {
+	g.Meta   `path:"/admin/agent-runs" method:"get" summary:"Agent 运行记录"`
+	Page     int    `json:"page" in:"query"`
+	PageSize int    `json:"page_size" in:"query"`
+	Kind     string `json:"kind" in:"query"`    // diagnosis, aiops, chat_deep, cli
+	Subject  string `json:"subject" in:"query"` // incident id, chat session id, ...
+	Status   string `json:"status" in:"query"`  // succeeded, failed
+	ReplayOf string `json:"replay_of" in:"query"`
+}
+
+type AgentRunItem struct {
+	ID         string `json:"id"`
+	Kind       string `json:"kind"`
+	Subject    string `json:"subject"`
+	ReplayOf   string `json:"replay_of,omitempty"`
+	Status     string `json:"status"`
+	Output     string `json:"output"`
+	Error      string `json:"error,omitempty"`
+	StepCount  int    `json:"step_count"`
+	DurationMs int64  `json:"duration_ms"`
+	StartedAt  string `json:"started_at"`
+}
+
+type AgentRunsRes struct {
+	Items    []AgentRunItem `json:"items"`
+	Total    int64          `json:"total"`
+	Page     int            `json:"page"`
+	PageSize int            `json:"page_size"`
+}
+
+type AgentRunReq struct {
+	g.Meta `path:"/admin/agent-runs/{id}" method:"get" summary:"Agent 运行详情（输入、模型调用、工具调用与耗时），可作为 replay_cmd 的导出文件"`
+	ID     string `json:"id" in:"path"`
+}
+
+type AgentRunStepItem struct {
+	Seq        int    `json:"seq"`
+	Type       string `json:"type"` // model, tool, event
+	Agent      string `json:"agent,omitempty"`
+	Name       string `json:"name,omitempty"`
+	CallID     string `json:"call_id,omitempty"`
+	Input      string `json:"input,omitempty"`
+	Output     string `json:"output,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+	StartedAt  string `json:"started_at"`
+	Truncated  bool   `json:"truncated,omitempty"`
+}
+
+// AgentRunRes 与 replay_cmd 的导出文件格式一致。
+type AgentRunRes struct {
+	ID         string             `json:"id"`
+	Kind       string             `json:"kind"`
+	Subject    string             `json:"subject,omitempty"`
+	ReplayOf   string             `json:"replay_of,omitempty"`
+	Status     string             `json:"status"`
+	Input      any                `json:"input"` // []schema.Message
+	Tools      any                `json:"tools,omitempty"`
+	Output     string             `json:"output"`
+	Error      string             `json:"error,omitempty"`
+	DurationMs int64              `json:"duration_ms"`
+	StartedAt  string             `json:"started_at"`
+	FinishedAt string             `json:"finished_at"`
+	StepCount  int                `json:"step_count"`
+	Steps      []AgentRunStepItem `json:"steps"`
+}
+
+type ReplayAgentRunReq struct {
+	g.Meta   `path:"/admin/agent-runs/{id}/replay" method:"post" summary:"用录制的工具输出回放 Agent 运行"`
+	ID       string `json:"id" in:"path"`
+	Model    string `json:"model"`    // recorded (default): 使用录制的模型输出; live: 调用当前配置的模型
+	Question string `json:"question"` // 替换输入中最后一条用户消息，用于验证提示词修改
+}
+
+type ReplayDiff struct {
+	OutputMatches      bool     `json:"output_matches"`
+	ToolCallsMatch     bool     `json:"tool_calls_match"`
+	RecordedToolCalls  []string `json:"recorded_tool_calls"`
+	ReplayedToolCalls  []string `json:"replayed_tool_calls"`
+	UnmatchedToolCalls int      `json:"unmatched_tool_calls"`
+	RecordedModelCalls int      `json:"recorded_model_calls"`
+	ReplayedModelCalls int      `json:"replayed_model_calls"`
+	ChangedModelInputs []int    `json:"changed_model_inputs"` // 输入与录制不同的模型调用 seq
+	TruncatedSteps     []int    `json:"truncated_steps"`      // 录制时被截断的步骤 seq，回放无法还原
+}
+
+type ReplayAgentRunRes struct {
+	Run  AgentRunItem `json:"run"`
+	Diff ReplayDiff   `json:"diff"`
+}
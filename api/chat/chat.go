@@ -15,4 +15,11 @@ type IChatV1 interface {
 	ChatStream(ctx context.Context, req *v1.ChatStreamReq) (res *v1.ChatStreamRes, err error)
 	FileUpload(ctx context.Context, req *v1.FileUploadReq) (res *v1.FileUploadRes, err error)
 	AIOps(ctx context.Context, req *v1.AIOpsReq) (res *v1.AIOpsRes, err error)
+	Approvals(ctx context.Context, req *v1.ApprovalsReq) (res *v1.ApprovalsRes, err error)
+	ResolveApproval(ctx context.Context, req *v1.ResolveApprovalReq) (res *v1.ResolveApprovalRes, err error)
+	Sessions(ctx context.Context, req *v1.SessionsReq) (res *v1.SessionsRes, err error)
+	CreateSession(ctx context.Context, req *v1.CreateSessionReq) (res *v1.CreateSessionRes, err error)
+	SessionMessages(ctx context.Context, req *v1.SessionMessagesReq) (res *v1.SessionMessagesRes, err error)
+	RenameSession(ctx context.Context, req *v1.RenameSessionReq) (res *v1.RenameSessionRes, err error)
+	DeleteSession(ctx context.Context, req *v1.DeleteSessionReq) (res *v1.DeleteSessionRes, err error)
 }
@@ -6,18 +6,44 @@ import (
 
 type ChatReq struct {
 	g.Meta   `path:"/chat" method:"post" summary:"对话"`
-	Id       string
+	Id       string // 会话 id，为空时新建会话
 	Question string
+	Mode     string `dc:"quick（ReAct）| deep（计划-执行-重规划，默认）"`
+}
+
+type Citation struct {
+	Index   int     `json:"index"`
+	ID      string  `json:"id,omitempty"`
+	Source  string  `json:"source,omitempty"` // 知识库文件路径
+	Score   float64 `json:"score,omitempty"`
+	Snippet string  `json:"snippet"`
+}
+
+type ToolEvidence struct {
+	CallID     string `json:"call_id,omitempty"`
+	Tool       string `json:"tool"`
+	Arguments  string `json:"arguments,omitempty"`
+	Result     string `json:"result,omitempty"`
+	Truncated  bool   `json:"truncated,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms,omitempty"`
 }
 
 type ChatRes struct {
-	Answer string `json:"answer"`
+	Answer    string         `json:"answer"`
+	SessionID string         `json:"session_id"`
+	Title     string         `json:"title,omitempty"`
+	Mode      string         `json:"mode"`
+	Citations []Citation     `json:"citations"`
+	Evidence  []ToolEvidence `json:"evidence"`
+	Steps     []string       `json:"steps,omitempty"`
 }
 
 type ChatStreamReq struct {
 	g.Meta   `path:"/chat_stream" method:"post" summary:"流式对话"`
-	Id       string
+	Id       string // 会话 id，为空时新建会话
 	Question string
+	Mode     string `dc:"quick（默认）| deep"`
 }
 
 type ChatStreamRes struct {
@@ -41,3 +67,102 @@ type AIOpsRes struct {
 	Result string   `json:"result"`
 	Detail []string `json:"detail"`
 }
+
+type ApprovalsReq struct {
+	g.Meta `path:"/approvals" method:"get" summary:"待审批的工具调用"`
+}
+
+type ApprovalItem struct {
+	ApprovalID  string         `json:"approval_id"`
+	RunID       string         `json:"run_id"`
+	Tool        string         `json:"tool"`
+	Arguments   map[string]any `json:"arguments"`
+	RequestedBy string         `json:"requested_by,omitempty"`
+	CreatedAt   string         `json:"created_at"`
+	ExpiresAt   string         `json:"expires_at"`
+}
+
+type ApprovalsRes struct {
+	Items []ApprovalItem `json:"items"`
+}
+
+type ResolveApprovalReq struct {
+	g.Meta     `path:"/approvals/{approvalId}" method:"post" summary:"审批工具调用"`
+	ApprovalID string `json:"approvalId" in:"path"`
+	Approved   bool   `json:"approved"`
+	Comment    string `json:"comment"`
+}
+
+type ResolveApprovalRes struct {
+	ApprovalID string `json:"approval_id"`
+	Approved   bool   `json:"approved"`
+}
+
+type SessionItem struct {
+	ID           string `json:"id"`
+	Title        string `json:"title"`
+	MessageCount int64  `json:"message_count"`
+	Summary      string `json:"summary,omitempty"`
+	CreatedAt    string `json:"created_at,omitempty"`
+	UpdatedAt    string `json:"updated_at,omitempty"`
+}
+
+type SessionsReq struct {
+	g.Meta   `path:"/sessions" method:"get" summary:"我的会话列表"`
+	Page     int `json:"page" in:"query"`
+	PageSize int `json:"page_size" in:"query"`
+}
+
+type SessionsRes struct {
+	Items    []SessionItem `json:"items"`
+	Total    int64         `json:"total"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"page_size"`
+}
+
+type CreateSessionReq struct {
+	g.Meta `path:"/sessions" method:"post" summary:"新建会话"`
+	Title  string `json:"title"`
+}
+
+type CreateSessionRes struct {
+	Item SessionItem `json:"item"`
+}
+
+type SessionMessagesReq struct {
+	g.Meta    `path:"/sessions/{sessionId}/messages" method:"get" summary:"会话消息"`
+	SessionID string `json:"sessionId" in:"path"`
+	Limit     int    `json:"limit" in:"query"`
+}
+
+type SessionMessageItem struct {
+	ID        int64  `json:"id"`
+	Role      string `json:"role"` // user | assistant
+	Content   string `json:"content"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+type SessionMessagesRes struct {
+	Session SessionItem          `json:"session"`
+	Items   []SessionMessageItem `json:"items"`
+}
+
+type RenameSessionReq struct {
+	g.Meta    `path:"/sessions/{sessionId}" method:"put" summary:"重命名会话"`
+	SessionID string `json:"sessionId" in:"path"`
+	Title     string `json:"title"`
+}
+
+type RenameSessionRes struct {
+	ID    string `json:"id"`
+	Title string `json:"title"`
+}
+
+type DeleteSessionReq struct {
+	g.Meta    `path:"/sessions/{sessionId}" method:"delete" summary:"删除会话"`
+	SessionID string `json:"sessionId" in:"path"`
+}
+
+type DeleteSessionRes struct {
+	ID string `json:"id"`
+}
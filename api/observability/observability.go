@@ -10,5 +10,6 @@ type IObservabilityV1 interface {
 	Health(ctx context.Context, req *v1.HealthReq) (res *v1.HealthRes, err error)
 	LokiQueryRange(ctx context.Context, req *v1.LokiQueryRangeReq) (res *v1.LokiQueryRangeRes, err error)
 	PromQuery(ctx context.Context, req *v1.PromQueryReq) (res *v1.PromQueryRes, err error)
+	Changes(ctx context.Context, req *v1.ChangesReq) (res *v1.ChangesRes, err error)
 }
 
@@ -53,3 +53,28 @@ type PromQueryRes struct {
 	Query  string         `json:"query"`
 	Result map[string]any `json:"result"`
 }
+
+type ChangesReq struct {
+	g.Meta  `path:"/observability/changes" method:"get" summary:"变更事件时间线"`
+	Service string `json:"service" in:"query"`
+	Kind    string `json:"kind" in:"query"`             // deploy, config, commit, restart, rollback
+	Minutes int    `json:"minutes" in:"query" d:"1440"` // look-back window
+	Limit   int    `json:"limit" in:"query" d:"100"`
+}
+
+type ChangeItem struct {
+	ID         int64          `json:"id"`
+	Service    string         `json:"service"`
+	Kind       string         `json:"kind"`
+	Source     string         `json:"source"`
+	Version    string         `json:"version,omitempty"`
+	Summary    string         `json:"summary,omitempty"`
+	Author     string         `json:"author,omitempty"`
+	URL        string         `json:"url,omitempty"`
+	Metadata   map[string]any `json:"metadata,omitempty"`
+	OccurredAt string         `json:"occurred_at"`
+}
+
+type ChangesRes struct {
+	Items []ChangeItem `json:"items"`
+}
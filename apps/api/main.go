@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/WyRainBow/ops-portal/internal/ai/usage"
 	"github.com/WyRainBow/ops-portal/internal/controller/admin"
 	"github.com/WyRainBow/ops-portal/internal/controller/auth"
 	"github.com/WyRainBow/ops-portal/internal/controller/chat"
@@ -18,6 +19,7 @@ import (
 
 func main() {
 	g.Log().SetLevel(glog.LEVEL_WARN)
+	usage.Init()
 
 	s := g.Server()
 
@@ -255,8 +255,8 @@ export async function lokiQueryRange(token: string, payload: any) {
   })
 }
 
-export async function chat(token: string, payload: any) {
-  return request<any>(`/api/chat`, {
+export async function chat(token: string, payload: { question: string; id?: string; mode?: 'quick' | 'deep' }) {
+  return request<any>(`/api/chat/chat`, {
     method: 'POST',
     headers: { Authorization: `Bearer ${token}` },
     body: JSON.stringify(payload),
@@ -265,23 +265,34 @@ export async function chat(token: string, payload: any) {
 
 /**
  * Stream chat response using Server-Sent Events.
+ * Events carry JSON payloads: message {content}, thinking {step, delta|content},
+ * tool_call {tool, arguments}, tool_result {tool, result|error}, citation
+ * {source, snippet}, session {session_id}, error {message}, and done with the
+ * full response {session_id, mode, answer, citations, evidence}.
  * Returns a promise that resolves when the stream ends.
  */
 export async function streamChat(
   token: string,
-  payload: { question: string; id?: string },
+  payload: { question: string; id?: string; mode?: 'quick' | 'deep' },
   callbacks: {
     onMessage?: (chunk: string) => void
+    onEvent?: (event: string, data: any, id?: string) => void
     onDone?: () => void
     onError?: (error: string) => void
-  }
+  },
+  lastEventId?: string
 ): Promise<void> {
+  const headers: Record<string, string> = {
+    'Content-Type': 'application/json',
+    'Authorization': `Bearer ${token}`,
+  }
+  // Resume an interrupted run of the same session instead of asking again
+  if (lastEventId) {
+    headers['Last-Event-ID'] = lastEventId
+  }
   const response = await fetch('/api/chat/chat_stream', {
     method: 'POST',
-    headers: {
-      'Content-Type': 'application/json',
-      'Authorization': `Bearer ${token}`,
-    },
+    headers,
     body: JSON.stringify(payload),
   })
 
@@ -300,41 +311,56 @@ export async function streamChat(
   }
 
   let buffer = ''
+  let finished = false
+
+  // SSE format: blocks of "id: / event: / data:" lines separated by a blank line
+  const dispatch = (block: string) => {
+    let id: string | undefined
+    let event = 'message'
+    const data: string[] = []
+    for (const line of block.split('\n')) {
+      if (line.startsWith('id:')) id = line.slice(3).trim()
+      else if (line.startsWith('event:')) event = line.slice(6).trim()
+      else if (line.startsWith('data:')) data.push(line.slice(5).replace(/^ /, ''))
+    }
+    if (data.length === 0) return
+    let parsed: any = data.join('\n')
+    try {
+      parsed = JSON.parse(parsed)
+    } catch {
+      // plain text payload
+    }
+    callbacks.onEvent?.(event, parsed, id)
+    switch (event) {
+      case 'message':
+        callbacks.onMessage?.(typeof parsed === 'string' ? parsed : parsed?.content || '')
+        break
+      case 'done':
+        finished = true
+        callbacks.onDone?.()
+        break
+      case 'error':
+        finished = true
+        callbacks.onError?.(typeof parsed === 'string' ? parsed : parsed?.message || 'stream error')
+        break
+    }
+  }
 
   try {
     while (true) {
       const { done, value } = await reader.read()
 
       if (done) {
-        callbacks.onDone?.()
+        if (buffer.trim()) dispatch(buffer)
+        if (!finished) callbacks.onDone?.()
         break
       }
 
-      // Decode and process SSE data
-      buffer += decoder.decode(value, { stream: true })
-
-      // Split by lines and process complete SSE messages
-      const lines = buffer.split('\n')
-      buffer = lines.pop() || '' // Keep incomplete line in buffer
-
-      for (const line of lines) {
-        const trimmed = line.trim()
-
-        // SSE format: event: xxx, data: yyy, id: zzz
-        if (trimmed.startsWith('data:')) {
-          const data = trimmed.slice(5).trim()
-
-          // Parse JSON data
-          try {
-            // The backend sends simple string chunks, not JSON
-            if (data) {
-              callbacks.onMessage?.(data)
-            }
-          } catch {
-            // If not JSON, just send as-is
-            callbacks.onMessage?.(data)
-          }
-        }
+      buffer += decoder.decode(value, { stream: true }).replace(/\r\n/g, '\n')
+      const blocks = buffer.split('\n\n')
+      buffer = blocks.pop() || '' // Keep incomplete block in buffer
+      for (const block of blocks) {
+        dispatch(block)
       }
     }
   } catch (error: any) {
@@ -0,0 +1,241 @@
+package chat_pipeline
+
+import (
+	"context"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/utility/textutil"
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/flow/agent"
+	"github.com/cloudwego/eino/schema"
+	template "github.com/cloudwego/eino/utils/callbacks"
+)
+
+// Typed events of a chat stream.
+const (
+	EventToolCall   = "tool_call"
+	EventToolResult = "tool_result"
+	EventThinking   = "thinking"
+	EventMessage    = "message"
+	EventCitation   = "citation"
+	EventDone       = "done"
+	EventError      = "error"
+)
+
+// maxEventResult caps the tool output carried by a tool_result event.
+const maxEventResult = 2000
+
+// Emitter receives typed chat events; payload is JSON encodable.
+type Emitter func(event string, payload any)
+
+type ToolCallEvent struct {
+	Step      int64  `json:"step"`
+	CallID    string `json:"call_id,omitempty"`
+	Tool      string `json:"tool"`
+	Arguments string `json:"arguments"`
+}
+
+type ToolResultEvent struct {
+	Step       int64  `json:"step"`
+	CallID     string `json:"call_id,omitempty"`
+	Tool       string `json:"tool"`
+	Result     string `json:"result,omitempty"`
+	Truncated  bool   `json:"truncated,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// ThinkingEvent carries reasoning deltas of a model call (Delta) or the text
+// the model wrote alongside its tool calls (Content).
+type ThinkingEvent struct {
+	Step    int64  `json:"step"`
+	Delta   string `json:"delta,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+type MessageEvent struct {
+	Content string `json:"content"`
+}
+
+type CitationEvent struct {
+	Index   int     `json:"index"`
+	ID      string  `json:"id,omitempty"`
+	Source  string  `json:"source,omitempty"`
+	Score   float64 `json:"score,omitempty"`
+	Snippet string  `json:"snippet"`
+}
+
+type ErrorEvent struct {
+	Message string `json:"message"`
+}
+
+type toolStartKey struct{}
+
+// StreamOptions reports the work of the chat agent to emit: model and tool
+// callbacks on the ReactAgent node become thinking / tool_call / tool_result
+// events, the documents of MilvusRetriever become citation events. message,
+// done and error events are up to the caller, which reads the final answer.
+func StreamOptions(emit Emitter) []compose.Option {
+	return []compose.Option{
+		compose.WithLambdaOption(agent.WithComposeOptions(compose.WithCallbacks(agentHandler(emit)))).DesignateNode(ReactAgent),
+		compose.WithCallbacks(citationHandler(emit)).DesignateNode(MilvusRetriever),
+	}
+}
+
+// agentHandler turns the model and tool callbacks inside the ReAct agent
+// into thinking / tool_call / tool_result events.
+func agentHandler(emit Emitter) callbacks.Handler {
+	var step atomic.Int64
+
+	modelHandler := &template.ModelCallbackHandler{
+		OnStart: func(ctx context.Context, _ *callbacks.RunInfo, _ *model.CallbackInput) context.Context {
+			step.Add(1)
+			return ctx
+		},
+		OnEnd: func(ctx context.Context, _ *callbacks.RunInfo, out *model.CallbackOutput) context.Context {
+			if out == nil || out.Message == nil {
+				return ctx
+			}
+			n := step.Load()
+			if out.Message.ReasoningContent != "" {
+				emit(EventThinking, ThinkingEvent{Step: n, Delta: out.Message.ReasoningContent})
+			}
+			if len(out.Message.ToolCalls) > 0 && strings.TrimSpace(out.Message.Content) != "" {
+				emit(EventThinking, ThinkingEvent{Step: n, Content: out.Message.Content})
+			}
+			return ctx
+		},
+		OnEndWithStreamOutput: func(ctx context.Context, _ *callbacks.RunInfo, sr *schema.StreamReader[*model.CallbackOutput]) context.Context {
+			n := step.Load()
+			// 回调拿到的是流的副本，在后台读取，不阻塞最终回答的流式输出
+			go func() {
+				defer sr.Close()
+				var content strings.Builder
+				toolCalls := false
+				for {
+					out, err := sr.Recv()
+					if err == io.EOF {
+						break
+					}
+					if err != nil {
+						return
+					}
+					if out == nil || out.Message == nil {
+						continue
+					}
+					if out.Message.ReasoningContent != "" {
+						emit(EventThinking, ThinkingEvent{Step: n, Delta: out.Message.ReasoningContent})
+					}
+					content.WriteString(out.Message.Content)
+					toolCalls = toolCalls || len(out.Message.ToolCalls) > 0
+				}
+				if toolCalls && strings.TrimSpace(content.String()) != "" {
+					emit(EventThinking, ThinkingEvent{Step: n, Content: content.String()})
+				}
+			}()
+			return ctx
+		},
+	}
+
+	toolResult := func(ctx context.Context, info *callbacks.RunInfo) ToolResultEvent {
+		ev := ToolResultEvent{Step: step.Load(), CallID: compose.GetToolCallID(ctx)}
+		if info != nil {
+			ev.Tool = info.Name
+		}
+		if start, ok := ctx.Value(toolStartKey{}).(time.Time); ok {
+			ev.DurationMs = time.Since(start).Milliseconds()
+		}
+		return ev
+	}
+	toolHandler := &template.ToolCallbackHandler{
+		OnStart: func(ctx context.Context, info *callbacks.RunInfo, in *tool.CallbackInput) context.Context {
+			ev := ToolCallEvent{Step: step.Load(), CallID: compose.GetToolCallID(ctx)}
+			if info != nil {
+				ev.Tool = info.Name
+			}
+			if in != nil {
+				ev.Arguments = in.ArgumentsInJSON
+			}
+			emit(EventToolCall, ev)
+			return context.WithValue(ctx, toolStartKey{}, time.Now())
+		},
+		OnEnd: func(ctx context.Context, info *callbacks.RunInfo, out *tool.CallbackOutput) context.Context {
+			ev := toolResult(ctx, info)
+			if out != nil {
+				ev.Result, ev.Truncated = textutil.Truncate(out.Response, maxEventResult)
+			}
+			emit(EventToolResult, ev)
+			return ctx
+		},
+		OnEndWithStreamOutput: func(ctx context.Context, info *callbacks.RunInfo, sr *schema.StreamReader[*tool.CallbackOutput]) context.Context {
+			ev := toolResult(ctx, info)
+			// 工具输出在下一轮模型调用前总要读完，这里同步读取，保证 tool_result 先于回答结束
+			defer sr.Close()
+			var b strings.Builder
+			for {
+				out, err := sr.Recv()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					ev.Error = err.Error()
+					break
+				}
+				if out != nil {
+					b.WriteString(out.Response)
+				}
+			}
+			ev.Result, ev.Truncated = textutil.Truncate(b.String(), maxEventResult)
+			emit(EventToolResult, ev)
+			return ctx
+		},
+		OnError: func(ctx context.Context, info *callbacks.RunInfo, err error) context.Context {
+			ev := toolResult(ctx, info)
+			ev.Error = err.Error()
+			emit(EventToolResult, ev)
+			return ctx
+		},
+	}
+
+	return template.NewHandlerHelper().ChatModel(modelHandler).Tool(toolHandler).Handler()
+}
+
+// Citations describes retrieved documents, numbered from 1; Source is the
+// path of the indexed file.
+func Citations(docs []*schema.Document) []CitationEvent {
+	out := make([]CitationEvent, 0, len(docs))
+	for _, doc := range docs {
+		if doc == nil {
+			continue
+		}
+		ev := CitationEvent{Index: len(out) + 1, ID: doc.ID, Score: doc.Score()}
+		if src, ok := doc.MetaData["_source"].(string); ok {
+			ev.Source = src
+		}
+		ev.Snippet, _ = textutil.Truncate(strings.TrimSpace(doc.Content), 200)
+		out = append(out, ev)
+	}
+	return out
+}
+
+// citationHandler emits a citation event per retrieved document.
+func citationHandler(emit Emitter) callbacks.Handler {
+	return template.NewHandlerHelper().Retriever(&template.RetrieverCallbackHandler{
+		OnEnd: func(ctx context.Context, _ *callbacks.RunInfo, out *retriever.CallbackOutput) context.Context {
+			if out == nil {
+				return ctx
+			}
+			for _, ev := range Citations(out.Docs) {
+				emit(EventCitation, ev)
+			}
+			return ctx
+		},
+	}).Handler()
+}
@@ -0,0 +1,117 @@
+package chat_pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool/utils"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/flow/agent/react"
+	"github.com/cloudwego/eino/schema"
+)
+
+// scriptedModel asks for one tool call, then answers.
+type scriptedModel struct{ calls int }
+
+func (m *scriptedModel) next() *schema.Message {
+	m.calls++
+	if m.calls == 1 {
+		msg := schema.AssistantMessage("先查一下错误日志", []schema.ToolCall{{
+			ID:       "call-1",
+			Function: schema.FunctionCall{Name: "query_logs", Arguments: `{"service":"api"}`},
+		}})
+		msg.ReasoningContent = "需要日志"
+		return msg
+	}
+	return schema.AssistantMessage("没有发现错误", nil)
+}
+
+func (m *scriptedModel) Generate(context.Context, []*schema.Message, ...model.Option) (*schema.Message, error) {
+	return m.next(), nil
+}
+
+func (m *scriptedModel) Stream(context.Context, []*schema.Message, ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return schema.StreamReaderFromArray([]*schema.Message{m.next()}), nil
+}
+
+func (m *scriptedModel) WithTools([]*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+func TestStreamOptions(t *testing.T) {
+	ctx := context.Background()
+	type logInput struct {
+		Service string `json:"service"`
+	}
+	logs, err := utils.InferTool("query_logs", "query logs", func(_ context.Context, in logInput) (string, error) {
+		return "0 errors in " + in.Service, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &react.AgentConfig{ToolCallingModel: &scriptedModel{}, MaxStep: 5}
+	cfg.ToolsConfig.Tools = append(cfg.ToolsConfig.Tools, logs)
+	ra, err := react.NewAgent(ctx, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lba, err := compose.AnyLambda(ra.Generate, ra.Stream, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := compose.NewGraph[[]*schema.Message, *schema.Message]()
+	_ = g.AddLambdaNode(ReactAgent, lba)
+	_ = g.AddEdge(compose.START, ReactAgent)
+	_ = g.AddEdge(ReactAgent, compose.END)
+	r, err := g.Compile(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	got := map[string][]string{}
+	emit := func(event string, payload any) {
+		b, _ := json.Marshal(payload)
+		mu.Lock()
+		got[event] = append(got[event], string(b))
+		mu.Unlock()
+	}
+	// the test graph has no retriever node, so only the ReactAgent option applies
+	sr, err := r.Stream(ctx, []*schema.Message{schema.UserMessage("api 有错误吗")}, StreamOptions(emit)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := sr.Recv(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// thinking of streamed model output is emitted in the background
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got[EventToolCall]) != 1 || len(got[EventToolResult]) != 1 {
+		t.Fatalf("tool events: %v", got)
+	}
+	var call ToolCallEvent
+	_ = json.Unmarshal([]byte(got[EventToolCall][0]), &call)
+	if call.Tool != "query_logs" || call.CallID != "call-1" || call.Arguments != `{"service":"api"}` {
+		t.Errorf("tool_call = %+v", call)
+	}
+	var res ToolResultEvent
+	_ = json.Unmarshal([]byte(got[EventToolResult][0]), &res)
+	if res.Tool != "query_logs" || res.Result == "" {
+		t.Errorf("tool_result = %+v", res)
+	}
+	if len(got[EventThinking]) != 2 {
+		t.Errorf("thinking = %v, want reasoning and pre-tool text", got[EventThinking])
+	}
+}
@@ -3,6 +3,8 @@ package chat_pipeline
 import (
 	"context"
 
+	"github.com/WyRainBow/ops-portal/internal/ai/agentrun"
+	"github.com/WyRainBow/ops-portal/internal/ai/models"
 	"github.com/WyRainBow/ops-portal/internal/ai/registry"
 	"github.com/cloudwego/eino/compose"
 	"github.com/cloudwego/eino/flow/agent/react"
@@ -17,11 +19,15 @@ func newReactAgentLambda(ctx context.Context) (lba *compose.Lambda, err error) {
 	if err != nil {
 		return nil, err
 	}
-	config.ToolCallingModel = chatModelIns11
+	// 模型和工具调用记录到 ctx 中的 agent run（快速模式对话）
+	config.ToolCallingModel = agentrun.WrapModel(models.RoleChat, chatModelIns11)
 
 	// Get tools from registry instead of hardcoding
 	// This allows dynamic tool management at runtime
-	allTools := registry.Global().GetAll("chat")
+	allTools, err := agentrun.WrapTools(ctx, registry.Global().GetAll("chat"))
+	if err != nil {
+		return nil, err
+	}
 	config.ToolsConfig.Tools = allTools
 
 	ins, err := react.NewAgent(ctx, config)
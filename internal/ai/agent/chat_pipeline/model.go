@@ -8,7 +8,7 @@ import (
 )
 
 func newChatModel(ctx context.Context) (cm model.ToolCallingChatModel, err error) {
-	cm, err = models.OpenAIForDeepSeekV3Quick(ctx)
+	cm, err = models.ForRole(ctx, models.RoleChat)
 	if err != nil {
 		return nil, err
 	}
@@ -7,14 +7,16 @@ import (
 	"github.com/cloudwego/eino/schema"
 )
 
+// node keys of the ChatAgent graph; StreamOptions designates callbacks to them
+const (
+	InputToRag      = "InputToRag"
+	ChatTemplate    = "ChatTemplate"
+	ReactAgent      = "ReactAgent"
+	MilvusRetriever = "MilvusRetriever"
+	InputToChat     = "InputToChat"
+)
+
 func BuildChatAgent(ctx context.Context) (r compose.Runnable[*UserMessage, *schema.Message], err error) {
-	const (
-		InputToRag      = "InputToRag"
-		ChatTemplate    = "ChatTemplate"
-		ReactAgent      = "ReactAgent"
-		MilvusRetriever = "MilvusRetriever"
-		InputToChat     = "InputToChat"
-	)
 	g := compose.NewGraph[*UserMessage, *schema.Message]()
 	_ = g.AddLambdaNode(InputToRag, compose.InvokableLambdaWithOption(newInputToRagLambda), compose.WithNodeName("UserMessageToRag"))
 	chatTemplateKeyOfChatTemplate, err := newChatTemplate(ctx)
@@ -3,6 +3,7 @@ package chat_pipeline
 import (
 	"context"
 
+	"github.com/WyRainBow/ops-portal/internal/ai/prompts"
 	"github.com/cloudwego/eino/components/prompt"
 	"github.com/cloudwego/eino/schema"
 )
@@ -14,6 +15,13 @@ type ChatTemplateConfig struct {
 
 // newChatTemplate component initialization function of node 'ChatTemplate' in graph 'EinoAgent'
 func newChatTemplate(ctx context.Context) (ctp prompt.ChatTemplate, err error) {
+	// 系统提示词来自可在线编辑的 chat_system 模板；{date} / {documents} 由 FString 填充，
+	// 模板里其它的花括号（如 PromQL 示例）需转义，否则格式化时报 could not find key
+	systemPrompt, _, err := prompts.Render(ctx, prompts.KindChatSystem, nil)
+	if err != nil {
+		return nil, err
+	}
+	systemPrompt = prompts.EscapeFString(systemPrompt, "date", "documents")
 	config := &ChatTemplateConfig{
 		FormatType: schema.FString,
 		Templates: []schema.MessagesTemplate{
@@ -25,32 +33,3 @@ func newChatTemplate(ctx context.Context) (ctp prompt.ChatTemplate, err error) {
 	ctp = prompt.FromMessages(config.FormatType, config.Templates...)
 	return ctp, nil
 }
-
-var systemPrompt = `
-# 角色：对话小助手
-## 核心能力
-- 上下文理解与对话
-- 搜索网络获得信息
-## 互动指南
-- 在回复前，请确保你：
-  • 完全理解用户的需求和问题，如果有不清楚的地方，要向用户确认
-  • 考虑最合适的解决方案方法
-  • 日志主题地域：ap-guangzhou；日志主题id：869830db-a055-4479-963b-3c898d27e755
-- 提供帮助时：
-  • 语言清晰简洁
-  • 适当的时候提供实际例子
-  • 有帮助时参考文档
-  • 适用时建议改进或下一步操作
-- 如果请求超出了你的能力范围：
-  • 清晰地说明你的局限性，如果可能的话，建议其他方法
-- 如果问题是复合或复杂的，你需要一步步思考，避免直接给出质量不高的回答。
-## 输出要求：
-  • 易读，结构良好，必要时换行
-  • 输出不能包含markdown的语法，输出需要纯文本
-## 上下文信息
-- 当前日期：{date}
-- 相关文档：|-
-==== 文档开始 ====
-  {documents}
-==== 文档结束 ====
-`
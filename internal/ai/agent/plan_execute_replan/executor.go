@@ -1,37 +1,59 @@
 package plan_execute_replan
 
 import (
+	"github.com/WyRainBow/ops-portal/internal/ai/agentrun"
+	aierrors "github.com/WyRainBow/ops-portal/internal/ai/errors"
 	"github.com/WyRainBow/ops-portal/internal/ai/models"
-	"github.com/WyRainBow/ops-portal/internal/ai/tools"
+	"github.com/WyRainBow/ops-portal/internal/ai/registry"
 	"context"
+	"fmt"
+	"sync"
 
 	"github.com/cloudwego/eino/adk"
 	"github.com/cloudwego/eino/adk/prebuilt/planexecute"
+	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/compose"
 )
 
+// registerMu serialises the on-demand registration of the standard tools.
+var registerMu sync.Mutex
+
+// executorTools returns the plan_execute tools of the registry, so tool
+// policies, instrumentation and caching apply. Processes that did not
+// register the standard tools (CLIs) get them registered here.
+func executorTools(ctx context.Context) ([]tool.BaseTool, error) {
+	if stub := agentrun.StubFromContext(ctx); stub != nil {
+		// 回放：工具返回录制的输出，不访问真实系统
+		return stub.Tools(), nil
+	}
+	registerMu.Lock()
+	if registry.Global().Count() == 0 {
+		if err := registry.RegisterStandardTools(ctx); err != nil {
+			aierrors.Warn("plan_execute", fmt.Sprintf("register standard tools: %v", err))
+		}
+	}
+	registerMu.Unlock()
+	toolList := registry.Global().GetAll("plan_execute")
+	if len(toolList) == 0 {
+		return nil, fmt.Errorf("no tools are enabled for plan_execute")
+	}
+	return toolList, nil
+}
+
 func NewExecutor(ctx context.Context) (adk.Agent, error) {
-	// log
-	mcpTool, err := tools.GetLogMcpTool()
+	toolList, err := executorTools(ctx)
 	if err != nil {
 		return nil, err
 	}
-	toolList := mcpTool
-	// alerts
-	toolList = append(toolList, tools.NewPrometheusAlertsQueryTool())
-	// file
-	toolList = append(toolList, tools.NewQueryInternalDocsTool())
-	// db (readonly)
-	toolList = append(toolList, tools.NewDBReadonlyQueryTool())
-	// time
-	toolList = append(toolList, tools.NewGetCurrentTimeTool())
-	// Try DashScope Qwen first (Aliyun), fallback to DeepSeek
-	execModel, err := models.OpenAIForDashScopeQwen(ctx)
+	// 记录每次工具调用的参数和输出
+	toolList, err = agentrun.WrapTools(ctx, toolList)
 	if err != nil {
-		execModel, err = models.OpenAIForDeepSeekV3Quick(ctx)
-		if err != nil {
-			return nil, err
-		}
+		return nil, err
+	}
+	// Provider order and failover come from the model gateway (llm_gateway.routes.executor)
+	execModel, err := roleModel(ctx, models.RoleExecutor)
+	if err != nil {
+		return nil, err
 	}
 	return planexecute.NewExecutor(ctx, &planexecute.ExecutorConfig{
 		Model: execModel,
@@ -0,0 +1,34 @@
+package plan_execute_replan
+
+import (
+	"context"
+	"testing"
+)
+
+func TestExecutorToolsKeepBaselineSet(t *testing.T) {
+	ctx := context.Background()
+	toolList, err := executorTools(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := map[string]bool{}
+	for _, tl := range toolList {
+		info, err := tl.Info(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names[info.Name] = true
+	}
+	// The tools the executor had before it read them from the registry
+	for _, want := range []string{
+		"query_loki_logs",
+		"query_prometheus_alerts",
+		"query_internal_docs",
+		"db_readonly_query",
+		"get_current_time",
+	} {
+		if !names[want] {
+			t.Errorf("executor is missing %s", want)
+		}
+	}
+}
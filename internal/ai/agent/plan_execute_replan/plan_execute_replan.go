@@ -4,12 +4,29 @@ import (
 	"context"
 	"fmt"
 
-	"github.com/cloudwego/eino-examples/adk/common/prints"
+	"github.com/WyRainBow/ops-portal/internal/ai/agentrun"
+	"github.com/WyRainBow/ops-portal/internal/ai/models"
+	"github.com/WyRainBow/ops-portal/internal/ai/policy"
 	"github.com/cloudwego/eino/adk"
 	"github.com/cloudwego/eino/adk/prebuilt/planexecute"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
 )
 
 func BuildPlanAgent(ctx context.Context, query string) (string, []string, error) {
+	return RunPlanAgent(ctx, []adk.Message{schema.UserMessage(query)}, nil)
+}
+
+// RunPlanAgent runs plan-execute-replan on messages (history followed by the
+// question) and returns the final answer and the output of every step.
+// onEvent, if not nil, sees each agent event as it arrives.
+func RunPlanAgent(ctx context.Context, messages []adk.Message, onEvent func(*adk.AgentEvent)) (answer string, detail []string, err error) {
+	// tool call budgets are per run
+	ctx = policy.EnsureRun(ctx)
+	// 记录本次运行（模型调用、工具调用、各 Agent 的输出），可在 /api/admin/agent-runs 查看和回放
+	ctx, rec := agentrun.Start(ctx, messages)
+	defer func() { rec.Finish(ctx, answer, err) }()
+
 	planAgent, err := NewPlanner(ctx)
 	if err != nil {
 		return "", []string{}, err
@@ -34,19 +51,26 @@ func BuildPlanAgent(ctx context.Context, query string) (string, []string, error)
 	r := adk.NewRunner(ctx, adk.RunnerConfig{
 		Agent: planExecuteAgent,
 	})
-	iter := r.Query(ctx, query)
+	iter := r.Run(ctx, messages)
 	var lastMessage adk.Message
-	var detail []string
 	for {
 		event, ok := iter.Next()
 		if !ok {
 			break
 		}
-		fmt.Println("------------- Event -------------")
-		prints.Event(event)
+		if onEvent != nil {
+			onEvent(event)
+		}
+		if event.Err != nil {
+			rec.Event(event.AgentName, nil, event.Err)
+		}
 		if event.Output != nil {
-			lastMessage, _, err = adk.GetMessage(event)
-			detail = append(detail, lastMessage.String())
+			var msgErr error
+			lastMessage, _, msgErr = adk.GetMessage(event)
+			rec.Event(event.AgentName, lastMessage, msgErr)
+			if lastMessage != nil {
+				detail = append(detail, lastMessage.String())
+			}
 		}
 	}
 	if lastMessage == nil {
@@ -54,3 +78,16 @@ func BuildPlanAgent(ctx context.Context, query string) (string, []string, error)
 	}
 	return lastMessage.Content, detail, nil
 }
+
+// roleModel returns the gateway model of role, recording its calls into the
+// agent run of ctx; replays get the recorded outputs instead.
+func roleModel(ctx context.Context, role string) (model.ToolCallingChatModel, error) {
+	if m := agentrun.StubFromContext(ctx).Model(role); m != nil {
+		return agentrun.WrapModel(role, m), nil
+	}
+	m, err := models.ForRole(ctx, role)
+	if err != nil {
+		return nil, err
+	}
+	return agentrun.WrapModel(role, m), nil
+}
@@ -9,13 +9,10 @@ import (
 )
 
 func NewPlanner(ctx context.Context) (adk.Agent, error) {
-	// Try DashScope Qwen first (Aliyun), fallback to DeepSeek
-	planModel, err := models.OpenAIForDashScopeQwen(ctx)
+	// Provider order and failover come from the model gateway (llm_gateway.routes.planner)
+	planModel, err := roleModel(ctx, models.RolePlanner)
 	if err != nil {
-		planModel, err = models.OpenAIForDeepSeekV31Think(ctx)
-		if err != nil {
-			return nil, err
-		}
+		return nil, err
 	}
 	return planexecute.NewPlanner(ctx, &planexecute.PlannerConfig{
 		ToolCallingChatModel: planModel,
@@ -9,13 +9,10 @@ import (
 )
 
 func NewRePlanAgent(ctx context.Context) (adk.Agent, error) {
-	// Try DashScope Qwen first (Aliyun), fallback to DeepSeek
-	model, err := models.OpenAIForDashScopeQwen(ctx)
+	// Provider order and failover come from the model gateway (llm_gateway.routes.replanner)
+	model, err := roleModel(ctx, models.RoleReplanner)
 	if err != nil {
-		model, err = models.OpenAIForDeepSeekV31Think(ctx)
-		if err != nil {
-			return nil, err
-		}
+		return nil, err
 	}
 	return planexecute.NewReplanner(ctx, &planexecute.ReplannerConfig{
 		ChatModel: model,
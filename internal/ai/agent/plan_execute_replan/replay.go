@@ -0,0 +1,28 @@
+package plan_execute_replan
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/WyRainBow/ops-portal/internal/ai/agentrun"
+)
+
+// Replay re-runs a recorded run against its recorded tool outputs, with the
+// recorded model outputs unless opts.LiveModel, and compares the new run
+// (recorded as a replay of run) with it.
+func Replay(ctx context.Context, run *agentrun.Run, opts agentrun.ReplayOptions) (*agentrun.Run, *agentrun.Diff, error) {
+	if run.Kind == agentrun.KindChatQuick {
+		return nil, nil, fmt.Errorf("run %s is a quick-mode chat run; only plan-execute runs can be replayed", run.ID)
+	}
+	if len(run.Input) == 0 {
+		return nil, nil, fmt.Errorf("run %s has no recorded input", run.ID)
+	}
+	var replayed *agentrun.Run
+	ctx, input, stub := agentrun.PrepareReplay(ctx, run, opts, func(r *agentrun.Run) { replayed = r })
+	_, _, err := RunPlanAgent(ctx, input, nil)
+	if replayed == nil {
+		return nil, nil, err
+	}
+	// 运行失败也返回对比结果，失败本身就是回归
+	return replayed, agentrun.Compare(run, replayed, stub.Unmatched()), nil
+}
@@ -0,0 +1,62 @@
+package plan_execute_replan
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/WyRainBow/ops-portal/internal/ai/agentrun"
+	"github.com/WyRainBow/ops-portal/internal/ai/models"
+	"github.com/cloudwego/eino/schema"
+)
+
+func toolCallMsg(id, name, args string) string {
+	b, _ := json.Marshal(schema.AssistantMessage("", []schema.ToolCall{{ID: id, Type: "function", Function: schema.FunctionCall{Name: name, Arguments: args}}}))
+	return string(b)
+}
+
+func TestReplayRecordedRun(t *testing.T) {
+	answer, _ := json.Marshal(schema.AssistantMessage("日志显示 502 激增", nil))
+	run := &agentrun.Run{
+		ID:     "orig",
+		Kind:   "diagnosis",
+		Input:  []*schema.Message{schema.UserMessage("诊断 HighErrorRate")},
+		Tools:  []*schema.ToolInfo{{Name: "query_loki_logs", Desc: "query logs"}},
+		Output: "根因：连接池耗尽",
+		Steps: []agentrun.Step{
+			{Seq: 1, Type: agentrun.StepModel, Agent: models.RolePlanner, Output: toolCallMsg("p1", "Plan", `{"steps":["查询错误日志"]}`)},
+			{Seq: 2, Type: agentrun.StepModel, Agent: models.RoleExecutor, Output: toolCallMsg("c1", "query_loki_logs", `{"query":"{job=\"api\"}"}`)},
+			{Seq: 3, Type: agentrun.StepTool, Name: "query_loki_logs", CallID: "c1", Input: `{"query": "{job=\"api\"}"}`, Output: "502 x 120"},
+			{Seq: 4, Type: agentrun.StepModel, Agent: models.RoleExecutor, Output: string(answer)},
+			{Seq: 5, Type: agentrun.StepModel, Agent: models.RoleReplanner, Output: toolCallMsg("r1", "Respond", `{"response":"根因：连接池耗尽"}`)},
+		},
+	}
+
+	replayed, diff, err := Replay(context.Background(), run, agentrun.ReplayOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.ReplayOf != "orig" || replayed.Kind != "diagnosis" {
+		t.Errorf("replay not labelled: %+v", replayed)
+	}
+	if !diff.ToolCallsMatch || diff.UnmatchedToolCalls != 0 {
+		t.Errorf("tool calls differ: %+v", diff)
+	}
+	if diff.ReplayedModelCalls != 4 {
+		t.Errorf("replayed %d model calls, want 4", diff.ReplayedModelCalls)
+	}
+	if !strings.Contains(replayed.Output, "连接池耗尽") {
+		t.Errorf("output = %q", replayed.Output)
+	}
+
+	var toolOut string
+	for _, s := range replayed.Steps {
+		if s.Type == agentrun.StepTool {
+			toolOut = s.Output
+		}
+	}
+	if toolOut != "502 x 120" {
+		t.Errorf("tool not answered from the recording: %q", toolOut)
+	}
+}
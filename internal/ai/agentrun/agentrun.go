@@ -0,0 +1,421 @@
+// Package agentrun records agent runs — the input, every model call, tool
+// call and agent event with timing — into ops_agent_runs /
+// ops_agent_run_steps, and replays a recorded run against its recorded tool
+// outputs with a stubbed (or live) model, so prompt and model changes can be
+// regression-tested offline.
+//
+// A run is recorded when the agent is built with a context returned by
+// Start: models are wrapped with WrapModel and tools with WrapTools, both
+// find the recorder in the call context.
+package agentrun
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	aierrors "github.com/WyRainBow/ops-portal/internal/ai/errors"
+	"github.com/WyRainBow/ops-portal/internal/store"
+	"github.com/WyRainBow/ops-portal/utility/textutil"
+	"github.com/cloudwego/eino/schema"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/util/guid"
+	"gorm.io/gorm"
+)
+
+// Step types.
+const (
+	StepModel = "model"
+	StepTool  = "tool"
+	StepEvent = "event"
+)
+
+// Chat run kinds. KindChatQuick labels quick-mode chat runs (the ReAct chat
+// pipeline); they are recorded for inspection, only plan-execute runs such
+// as KindChatDeep can be replayed.
+const (
+	KindChatQuick = "chat_quick"
+	KindChatDeep  = "chat_deep"
+)
+
+// Run statuses.
+const (
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+)
+
+// ErrNotFound is returned for an unknown run id.
+var ErrNotFound = errors.New("agent run not found")
+
+// maxPayload caps each recorded input / output.
+const maxPayload = 256 << 10
+
+// Step is a recorded model call, tool call or agent event.
+type Step struct {
+	Seq        int       `json:"seq"`
+	Type       string    `json:"type"`
+	Agent      string    `json:"agent,omitempty"`
+	Name       string    `json:"name,omitempty"`
+	CallID     string    `json:"call_id,omitempty"`
+	Input      string    `json:"input,omitempty"`
+	Output     string    `json:"output,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	StartedAt  time.Time `json:"started_at"`
+	Truncated  bool      `json:"truncated,omitempty"` // input or output was clipped to maxPayload
+}
+
+// Run is a recorded run; it is also the export format read by replay_cmd.
+type Run struct {
+	ID         string             `json:"id"`
+	Kind       string             `json:"kind"`
+	Subject    string             `json:"subject,omitempty"`
+	ReplayOf   string             `json:"replay_of,omitempty"`
+	Status     string             `json:"status"`
+	Input      []*schema.Message  `json:"input"`
+	Tools      []*schema.ToolInfo `json:"tools,omitempty"`
+	Output     string             `json:"output"`
+	Error      string             `json:"error,omitempty"`
+	DurationMs int64              `json:"duration_ms"`
+	StartedAt  time.Time          `json:"started_at"`
+	FinishedAt time.Time          `json:"finished_at"`
+	StepCount  int                `json:"step_count"`
+	Steps      []Step             `json:"steps,omitempty"`
+}
+
+// Recorder collects the steps of a running agent. All methods accept a nil
+// receiver, which records nothing.
+type Recorder struct {
+	mu      sync.Mutex
+	run     Run
+	seq     int
+	tools   map[string]bool
+	pending sync.WaitGroup // streamed model outputs still being read
+}
+
+type labelKey struct{}
+type recorderKey struct{}
+
+type label struct {
+	kind, subject, replayOf string
+}
+
+// WithLabel names the runs started with ctx, e.g. ("diagnosis", incidentID).
+func WithLabel(ctx context.Context, kind, subject string) context.Context {
+	l, _ := ctx.Value(labelKey{}).(label)
+	l.kind, l.subject = kind, subject
+	return context.WithValue(ctx, labelKey{}, l)
+}
+
+func withReplayOf(ctx context.Context, id string) context.Context {
+	l, _ := ctx.Value(labelKey{}).(label)
+	l.replayOf = id
+	return context.WithValue(ctx, labelKey{}, l)
+}
+
+// Enabled reports whether runs are recorded (agent_runs.enabled, default true).
+func Enabled(ctx context.Context) bool {
+	v, err := g.Cfg().Get(ctx, "agent_runs.enabled")
+	if err != nil || v.IsNil() {
+		return true
+	}
+	return v.Bool()
+}
+
+// Start begins recording a run of input. Replays are always recorded; other
+// runs only when Enabled.
+func Start(ctx context.Context, input []*schema.Message) (context.Context, *Recorder) {
+	l, _ := ctx.Value(labelKey{}).(label)
+	if l.replayOf == "" && !Enabled(ctx) {
+		return ctx, nil
+	}
+	if l.kind == "" {
+		l.kind = "plan_execute"
+	}
+	r := &Recorder{
+		run: Run{
+			ID:        guid.S(),
+			Kind:      l.kind,
+			Subject:   l.subject,
+			ReplayOf:  l.replayOf,
+			Input:     input,
+			StartedAt: time.Now(),
+		},
+		tools: map[string]bool{},
+	}
+	return context.WithValue(ctx, recorderKey{}, r), r
+}
+
+// FromContext returns the recorder of ctx, or nil.
+func FromContext(ctx context.Context) *Recorder {
+	r, _ := ctx.Value(recorderKey{}).(*Recorder)
+	return r
+}
+
+// ID returns the run id, "" for a nil recorder.
+func (r *Recorder) ID() string {
+	if r == nil {
+		return ""
+	}
+	return r.run.ID
+}
+
+// clip makes s valid UTF-8 and cuts it to maxPayload bytes on a rune
+// boundary; Postgres rejects invalid UTF-8, which would lose the whole run.
+func clip(s string) (string, bool) {
+	s, cut := textutil.TruncateBytes(s, maxPayload)
+	if cut {
+		s += "…(truncated)"
+	}
+	return s, cut
+}
+
+func toJSON(v any) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
+
+// add appends s, numbering it.
+func (r *Recorder) add(s Step) {
+	if r == nil {
+		return
+	}
+	var inClipped, outClipped bool
+	s.Input, inClipped = clip(s.Input)
+	s.Output, outClipped = clip(s.Output)
+	s.Truncated = inClipped || outClipped
+	s.Error, _ = clip(s.Error)
+	r.mu.Lock()
+	r.seq++
+	s.Seq = r.seq
+	r.run.Steps = append(r.run.Steps, s)
+	r.mu.Unlock()
+}
+
+func (r *Recorder) addTool(info *schema.ToolInfo) {
+	if r == nil || info == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.tools[info.Name] {
+		r.tools[info.Name] = true
+		r.run.Tools = append(r.run.Tools, info)
+	}
+}
+
+// Event records a message an agent emitted, e.g. the plan of the planner or
+// the result of an executor step.
+func (r *Recorder) Event(agent string, msg *schema.Message, err error) {
+	if r == nil {
+		return
+	}
+	s := Step{Type: StepEvent, Agent: agent, StartedAt: time.Now()}
+	if msg != nil {
+		s.Name = string(msg.Role)
+		s.CallID = msg.ToolCallID
+		s.Output = msg.Content
+		if len(msg.ToolCalls) > 0 {
+			s.Input = toJSON(msg.ToolCalls)
+		}
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	r.add(s)
+}
+
+// Finish completes the run and stores it; storage failures are only logged so
+// that recording never fails a run. It returns the recorded run.
+func (r *Recorder) Finish(ctx context.Context, output string, runErr error) *Run {
+	if r == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		r.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+	}
+
+	r.mu.Lock()
+	run := r.run
+	run.Steps = append([]Step(nil), r.run.Steps...)
+	r.mu.Unlock()
+
+	run.FinishedAt = time.Now()
+	run.DurationMs = run.FinishedAt.Sub(run.StartedAt).Milliseconds()
+	run.Output = output
+	run.Status = StatusSucceeded
+	if runErr != nil {
+		run.Status = StatusFailed
+		run.Error = runErr.Error()
+	}
+	run.StepCount = len(run.Steps)
+	if err := save(context.WithoutCancel(ctx), &run); err != nil {
+		aierrors.Warn("agentrun", fmt.Sprintf("store run %s: %v", run.ID, err))
+	}
+	if done, ok := ctx.Value(finishKey{}).(func(*Run)); ok {
+		done(&run)
+	}
+	return &run
+}
+
+// save stores run with its steps; tests replace it.
+var save = func(ctx context.Context, run *Run) error {
+	db, err := store.DB(ctx)
+	if err != nil {
+		return err
+	}
+	tools, err := json.Marshal(run.Tools)
+	if err != nil {
+		return err
+	}
+	row := &store.AgentRun{
+		ID:         run.ID,
+		Kind:       run.Kind,
+		Subject:    run.Subject,
+		ReplayOf:   run.ReplayOf,
+		Status:     run.Status,
+		Input:      toJSON(run.Input),
+		Tools:      tools,
+		Output:     run.Output,
+		Error:      run.Error,
+		Steps:      run.StepCount,
+		DurationMs: run.DurationMs,
+		StartedAt:  &run.StartedAt,
+		FinishedAt: &run.FinishedAt,
+	}
+	steps := stepRows(run)
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(row).Error; err != nil {
+			return err
+		}
+		if len(steps) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(steps, 200).Error
+	})
+}
+
+// stepRows converts the steps of run to their table rows.
+func stepRows(run *Run) []store.AgentRunStep {
+	steps := make([]store.AgentRunStep, 0, len(run.Steps))
+	for _, s := range run.Steps {
+		started := s.StartedAt
+		steps = append(steps, store.AgentRunStep{
+			RunID: run.ID, Seq: s.Seq, Type: s.Type, Agent: s.Agent, Name: s.Name, CallID: s.CallID,
+			Input: s.Input, Output: s.Output, Error: s.Error, DurationMs: s.DurationMs, StartedAt: &started,
+			Truncated: s.Truncated,
+		})
+	}
+	return steps
+}
+
+// fromStepRow is the inverse of stepRows for one row.
+func fromStepRow(s *store.AgentRunStep) Step {
+	st := Step{
+		Seq: s.Seq, Type: s.Type, Agent: s.Agent, Name: s.Name, CallID: s.CallID,
+		Input: s.Input, Output: s.Output, Error: s.Error, DurationMs: s.DurationMs, Truncated: s.Truncated,
+	}
+	if s.StartedAt != nil {
+		st.StartedAt = *s.StartedAt
+	}
+	return st
+}
+
+func fromRow(row *store.AgentRun) *Run {
+	run := &Run{
+		ID: row.ID, Kind: row.Kind, Subject: row.Subject, ReplayOf: row.ReplayOf, Status: row.Status,
+		Output: row.Output, Error: row.Error, DurationMs: row.DurationMs, StepCount: row.Steps,
+	}
+	_ = json.Unmarshal([]byte(row.Input), &run.Input)
+	if len(row.Tools) > 0 {
+		_ = json.Unmarshal(row.Tools, &run.Tools)
+	}
+	if row.StartedAt != nil {
+		run.StartedAt = *row.StartedAt
+	}
+	if row.FinishedAt != nil {
+		run.FinishedAt = *row.FinishedAt
+	}
+	return run
+}
+
+// Query filters List.
+type Query struct {
+	Kind     string
+	Subject  string
+	Status   string
+	ReplayOf string
+	Offset   int
+	Limit    int
+}
+
+// List returns runs without their steps, newest first.
+func List(ctx context.Context, q Query) ([]*Run, int64, error) {
+	db, err := store.DB(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	tx := db.WithContext(ctx).Model(&store.AgentRun{})
+	if q.Kind != "" {
+		tx = tx.Where("kind = ?", q.Kind)
+	}
+	if q.Subject != "" {
+		tx = tx.Where("subject = ?", q.Subject)
+	}
+	if q.Status != "" {
+		tx = tx.Where("status = ?", q.Status)
+	}
+	if q.ReplayOf != "" {
+		tx = tx.Where("replay_of = ?", q.ReplayOf)
+	}
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var rows []store.AgentRun
+	if err := tx.Order("started_at DESC").Offset(q.Offset).Limit(q.Limit).Find(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+	out := make([]*Run, 0, len(rows))
+	for i := range rows {
+		out = append(out, fromRow(&rows[i]))
+	}
+	return out, total, nil
+}
+
+// Load returns run id with its steps.
+func Load(ctx context.Context, id string) (*Run, error) {
+	db, err := store.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var row store.AgentRun
+	err = db.WithContext(ctx).Where("id = ?", id).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var steps []store.AgentRunStep
+	if err := db.WithContext(ctx).Where("run_id = ?", id).Order("seq").Find(&steps).Error; err != nil {
+		return nil, err
+	}
+	run := fromRow(&row)
+	for i := range steps {
+		run.Steps = append(run.Steps, fromStepRow(&steps[i]))
+	}
+	return run, nil
+}
@@ -0,0 +1,177 @@
+package agentrun
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+type echoModel struct{}
+
+func (echoModel) Generate(_ context.Context, in []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	return schema.AssistantMessage("echo: "+in[len(in)-1].Content, nil), nil
+}
+
+func (echoModel) Stream(_ context.Context, in []*schema.Message, _ ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	return schema.StreamReaderFromArray([]*schema.Message{
+		schema.AssistantMessage("echo: ", nil),
+		schema.AssistantMessage(in[len(in)-1].Content, nil),
+	}), nil
+}
+
+func (m echoModel) WithTools([]*schema.ToolInfo) (model.ToolCallingChatModel, error) { return m, nil }
+
+func noSave(t *testing.T) {
+	orig := save
+	save = func(context.Context, *Run) error { return nil }
+	t.Cleanup(func() { save = orig })
+}
+
+func TestRecordModelCalls(t *testing.T) {
+	noSave(t)
+	ctx, rec := Start(WithLabel(context.Background(), "aiops", ""), []*schema.Message{schema.UserMessage("hi")})
+	m := WrapModel("executor", echoModel{})
+	if _, err := m.Generate(ctx, []*schema.Message{schema.UserMessage("a")}); err != nil {
+		t.Fatal(err)
+	}
+	sr, err := m.Stream(ctx, []*schema.Message{schema.UserMessage("b")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for {
+		if _, err := sr.Recv(); err == io.EOF {
+			break
+		}
+	}
+	run := rec.Finish(ctx, "done", nil)
+	if run.Kind != "aiops" || run.Status != StatusSucceeded || len(run.Steps) != 2 {
+		t.Fatalf("run = %+v", run)
+	}
+	if run.Steps[1].Type != StepModel || run.Steps[1].Agent != "executor" || run.Steps[1].Output == "" {
+		t.Errorf("streamed call not recorded: %+v", run.Steps[1])
+	}
+	stub := NewStub(run, false)
+	out, err := stub.Model("executor").Generate(ctx, nil)
+	if err != nil || out.Content != "echo: a" {
+		t.Errorf("stub replayed %v, %v", out, err)
+	}
+}
+
+func TestStubToolsAndCompare(t *testing.T) {
+	recorded := &Run{
+		Output: "x",
+		Tools:  []*schema.ToolInfo{{Name: "get_current_time"}, {Name: "query_loki_logs"}},
+		Steps: []Step{
+			{Seq: 1, Type: StepModel, Agent: "planner", Input: "p1"},
+			{Seq: 2, Type: StepTool, Name: "query_loki_logs", Input: `{"b":1,"a":2}`, Output: "logs-a"},
+			{Seq: 3, Type: StepTool, Name: "query_loki_logs", Input: `{"a":3}`, Output: "logs-b"},
+		},
+	}
+	stub := NewStub(recorded, true)
+	if stub.Model("planner") != nil {
+		t.Error("live replay stubbed the model")
+	}
+	tools := stub.Tools()
+	if len(tools) != 2 {
+		t.Fatalf("tools = %d", len(tools))
+	}
+	loki := tools[1].(*stubTool)
+	// 参数顺序不同也能匹配；参数不同时按顺序取下一条
+	if out, _ := loki.InvokableRun(context.Background(), `{"a":3}`); out != "logs-b" {
+		t.Errorf("matched %q", out)
+	}
+	if out, _ := loki.InvokableRun(context.Background(), `{"a":9}`); out != "logs-a" {
+		t.Errorf("fallback %q", out)
+	}
+	loki.InvokableRun(context.Background(), `{}`)
+	if stub.Unmatched() != 1 {
+		t.Errorf("unmatched = %d", stub.Unmatched())
+	}
+
+	replayed := &Run{
+		Output: "y",
+		Steps: []Step{
+			{Seq: 1, Type: StepModel, Agent: "planner", Input: "p2"},
+			{Seq: 2, Type: StepTool, Name: "query_loki_logs", Input: `{"a":2,"b":1}`},
+		},
+	}
+	d := Compare(recorded, replayed, stub.Unmatched())
+	if d.OutputMatches || d.ToolCallsMatch || len(d.ChangedModelInputs) != 1 || d.ChangedModelInputs[0] != 1 {
+		t.Errorf("diff = %+v", d)
+	}
+}
+
+func TestClippedStepsStayValidAndAreReported(t *testing.T) {
+	noSave(t)
+	ctx, rec := Start(context.Background(), nil)
+	// 中文工具输出超过上限时按字符截断
+	big := strings.Repeat("连接池耗尽", maxPayload/10)
+	m := WrapModel("executor", echoModel{})
+	if _, err := m.Generate(ctx, []*schema.Message{schema.UserMessage(big)}); err != nil {
+		t.Fatal(err)
+	}
+	run := rec.Finish(ctx, "", nil)
+	st := run.Steps[0]
+	if !st.Truncated || !utf8.ValidString(st.Input) || !utf8.ValidString(st.Output) {
+		t.Fatalf("step not clipped on a rune boundary: truncated=%v", st.Truncated)
+	}
+	if _, err := NewStub(run, false).Model("executor").Generate(ctx, nil); err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Errorf("replaying a clipped output: %v", err)
+	}
+	if d := Compare(run, &Run{}, 0); len(d.TruncatedSteps) != 1 || d.TruncatedSteps[0] != st.Seq {
+		t.Errorf("truncated steps = %v", d.TruncatedSteps)
+	}
+}
+
+func TestStepRowsRoundTrip(t *testing.T) {
+	noSave(t)
+	ctx, rec := Start(context.Background(), nil)
+	big := strings.Repeat("连接池耗尽", maxPayload/10)
+	if _, err := WrapModel("executor", echoModel{}).Generate(ctx, []*schema.Message{schema.UserMessage(big)}); err != nil {
+		t.Fatal(err)
+	}
+	run := rec.Finish(ctx, "", nil)
+
+	// 经 save/Load 的行结构往返后截断标记仍在
+	loaded := &Run{}
+	rows := stepRows(run)
+	for i := range rows {
+		loaded.Steps = append(loaded.Steps, fromStepRow(&rows[i]))
+	}
+	if len(loaded.Steps) != 1 || !loaded.Steps[0].Truncated || loaded.Steps[0].Output != run.Steps[0].Output {
+		t.Fatalf("loaded steps = %+v", loaded.Steps)
+	}
+	if !loaded.Steps[0].StartedAt.Equal(run.Steps[0].StartedAt) {
+		t.Errorf("started_at = %v, want %v", loaded.Steps[0].StartedAt, run.Steps[0].StartedAt)
+	}
+	if _, err := NewStub(loaded, false).Model("executor").Generate(ctx, nil); err == nil || !strings.Contains(err.Error(), "truncated") {
+		t.Errorf("replaying a loaded clipped output: %v", err)
+	}
+	if d := Compare(loaded, &Run{}, 0); len(d.TruncatedSteps) != 1 {
+		t.Errorf("truncated steps = %v", d.TruncatedSteps)
+	}
+}
+
+func TestParseRetention(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":      defaultRetention,
+		"0":     0,
+		"72h":   72 * time.Hour,
+		"30d":   30 * 24 * time.Hour,
+		"-1h":   defaultRetention,
+		"soon":  defaultRetention,
+		"1.5d":  defaultRetention,
+		" 7d  ": 7 * 24 * time.Hour,
+	}
+	for in, want := range cases {
+		if got := parseRetention(in); got != want {
+			t.Errorf("parseRetention(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
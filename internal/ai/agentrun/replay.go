@@ -0,0 +1,296 @@
+package agentrun
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	aierrors "github.com/WyRainBow/ops-portal/internal/ai/errors"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// ReplayOptions controls a replay.
+type ReplayOptions struct {
+	LiveModel bool   // call the configured models instead of replaying the recorded outputs
+	Question  string // replaces the content of the last user message of the input
+}
+
+// Stub serves the recorded model outputs and tool outputs of a run.
+type Stub struct {
+	live bool
+
+	mu        sync.Mutex
+	models    map[string][]modelCall // role -> calls in recorded order
+	tools     []*toolCall
+	infos     []*schema.ToolInfo
+	unmatched int
+}
+
+type modelCall struct {
+	out *schema.Message
+	err string
+}
+
+type toolCall struct {
+	name, args, out, err string
+	used                 bool
+}
+
+// NewStub builds the stub of run.
+func NewStub(run *Run, liveModel bool) *Stub {
+	s := &Stub{live: liveModel, models: map[string][]modelCall{}, infos: run.Tools}
+	for _, st := range run.Steps {
+		switch st.Type {
+		case StepModel:
+			call := modelCall{err: st.Error}
+			if st.Output != "" {
+				var msg schema.Message
+				if err := json.Unmarshal([]byte(st.Output), &msg); err == nil {
+					call.out = &msg
+				} else if st.Truncated {
+					call.err = fmt.Sprintf("recorded output of step %d was truncated", st.Seq)
+				}
+			}
+			s.models[st.Agent] = append(s.models[st.Agent], call)
+		case StepTool:
+			s.tools = append(s.tools, &toolCall{name: st.Name, args: canonical(st.Input), out: st.Output, err: st.Error})
+		}
+	}
+	return s
+}
+
+type stubKey struct{}
+
+// StubFromContext returns the replay stub of ctx, or nil outside replays.
+func StubFromContext(ctx context.Context) *Stub {
+	s, _ := ctx.Value(stubKey{}).(*Stub)
+	return s
+}
+
+// Model returns the stubbed model of role, or nil when the replay uses live
+// models.
+func (s *Stub) Model(role string) model.ToolCallingChatModel {
+	if s == nil || s.live {
+		return nil
+	}
+	return &stubModel{stub: s, role: role}
+}
+
+// Tools returns tools with the recorded schemas that answer with the
+// recorded outputs.
+func (s *Stub) Tools() []tool.BaseTool {
+	out := make([]tool.BaseTool, 0, len(s.infos))
+	for _, info := range s.infos {
+		out = append(out, &stubTool{stub: s, info: info})
+	}
+	return out
+}
+
+// Unmatched returns the number of tool calls without a recorded output.
+func (s *Stub) Unmatched() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.unmatched
+}
+
+func (s *Stub) nextModel(role string) (modelCall, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	calls := s.models[role]
+	if len(calls) == 0 {
+		return modelCall{}, false
+	}
+	s.models[role] = calls[1:]
+	return calls[0], true
+}
+
+// toolOutput returns the recorded output of the call with the same tool and
+// arguments, else the next unused output of the tool.
+func (s *Stub) toolOutput(name, args string) (*toolCall, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	args = canonical(args)
+	var fallback *toolCall
+	for _, c := range s.tools {
+		if c.used || c.name != name {
+			continue
+		}
+		if c.args == args {
+			c.used = true
+			return c, true
+		}
+		if fallback == nil {
+			fallback = c
+		}
+	}
+	if fallback != nil {
+		fallback.used = true
+		return fallback, true
+	}
+	s.unmatched++
+	return nil, false
+}
+
+func canonical(args string) string {
+	var v any
+	if err := json.Unmarshal([]byte(args), &v); err != nil {
+		return args
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+type stubModel struct {
+	stub *Stub
+	role string
+}
+
+func (m *stubModel) Generate(_ context.Context, _ []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	call, ok := m.stub.nextModel(m.role)
+	if !ok {
+		return nil, fmt.Errorf("replay: no recorded %s response left", m.role)
+	}
+	if call.out == nil {
+		return nil, fmt.Errorf("replay: recorded %s call failed: %s", m.role, call.err)
+	}
+	return call.out, nil
+}
+
+func (m *stubModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	out, err := m.Generate(ctx, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.Message{out}), nil
+}
+
+func (m *stubModel) WithTools([]*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return m, nil
+}
+
+func (m *stubModel) GetType() string { return "ReplayStub" }
+
+type stubTool struct {
+	stub *Stub
+	info *schema.ToolInfo
+}
+
+func (t *stubTool) Info(context.Context) (*schema.ToolInfo, error) {
+	return t.info, nil
+}
+
+func (t *stubTool) InvokableRun(_ context.Context, argumentsInJSON string, _ ...tool.Option) (string, error) {
+	call, ok := t.stub.toolOutput(t.info.Name, argumentsInJSON)
+	if !ok {
+		// 以工具结果返回，让 Agent 继续，回放报告里计入未匹配的调用
+		return aierrors.NewToolError(t.info.Name, "replay: no recorded output for this call", nil).ToJSON(), nil
+	}
+	if call.err != "" {
+		return call.out, errors.New(call.err)
+	}
+	return call.out, nil
+}
+
+func (t *stubTool) GetType() string { return "ReplayStub" }
+
+type finishKey struct{}
+
+// PrepareReplay returns the context and input to re-run run with: it carries
+// the stub, labels the new run as a replay of run and hands the recorded
+// replay to done when it finishes.
+func PrepareReplay(ctx context.Context, run *Run, opts ReplayOptions, done func(*Run)) (context.Context, []*schema.Message, *Stub) {
+	stub := NewStub(run, opts.LiveModel)
+	ctx = WithLabel(ctx, run.Kind, run.Subject)
+	ctx = withReplayOf(ctx, run.ID)
+	ctx = context.WithValue(ctx, stubKey{}, stub)
+	if done != nil {
+		ctx = context.WithValue(ctx, finishKey{}, done)
+	}
+
+	input := make([]*schema.Message, 0, len(run.Input))
+	for _, m := range run.Input {
+		c := *m
+		input = append(input, &c)
+	}
+	if opts.Question != "" {
+		for i := len(input) - 1; i >= 0; i-- {
+			if input[i].Role == schema.User {
+				input[i].Content = opts.Question
+				break
+			}
+		}
+	}
+	return ctx, input, stub
+}
+
+// Diff compares a replay with the recorded run.
+type Diff struct {
+	OutputMatches      bool     `json:"output_matches"`
+	ToolCallsMatch     bool     `json:"tool_calls_match"`
+	RecordedToolCalls  []string `json:"recorded_tool_calls"`
+	ReplayedToolCalls  []string `json:"replayed_tool_calls"`
+	UnmatchedToolCalls int      `json:"unmatched_tool_calls"`
+	RecordedModelCalls int      `json:"recorded_model_calls"`
+	ReplayedModelCalls int      `json:"replayed_model_calls"`
+	// ChangedModelInputs lists the replayed model steps (seq) whose input
+	// differs from the recorded call at the same position of the same role,
+	// e.g. after a prompt change.
+	ChangedModelInputs []int `json:"changed_model_inputs"`
+	// TruncatedSteps lists the recorded steps (seq) clipped to maxPayload;
+	// a clipped model output cannot be replayed.
+	TruncatedSteps []int `json:"truncated_steps"`
+}
+
+// Compare diffs replayed against recorded; unmatched comes from the stub.
+func Compare(recorded, replayed *Run, unmatched int) *Diff {
+	d := &Diff{
+		OutputMatches:      recorded.Output == replayed.Output,
+		UnmatchedToolCalls: unmatched,
+		RecordedToolCalls:  toolCalls(recorded),
+		ReplayedToolCalls:  toolCalls(replayed),
+		ChangedModelInputs: []int{},
+		TruncatedSteps:     []int{},
+	}
+	d.ToolCallsMatch = len(d.RecordedToolCalls) == len(d.ReplayedToolCalls)
+	for i := 0; d.ToolCallsMatch && i < len(d.RecordedToolCalls); i++ {
+		d.ToolCallsMatch = d.RecordedToolCalls[i] == d.ReplayedToolCalls[i]
+	}
+
+	inputs := map[string][]string{}
+	for _, s := range recorded.Steps {
+		if s.Truncated {
+			d.TruncatedSteps = append(d.TruncatedSteps, s.Seq)
+		}
+		if s.Type == StepModel {
+			d.RecordedModelCalls++
+			inputs[s.Agent] = append(inputs[s.Agent], s.Input)
+		}
+	}
+	pos := map[string]int{}
+	for _, s := range replayed.Steps {
+		if s.Type != StepModel {
+			continue
+		}
+		d.ReplayedModelCalls++
+		i := pos[s.Agent]
+		pos[s.Agent]++
+		if i >= len(inputs[s.Agent]) || inputs[s.Agent][i] != s.Input {
+			d.ChangedModelInputs = append(d.ChangedModelInputs, s.Seq)
+		}
+	}
+	return d
+}
+
+func toolCalls(run *Run) []string {
+	out := []string{}
+	for _, s := range run.Steps {
+		if s.Type == StepTool {
+			out = append(out, s.Name+" "+canonical(s.Input))
+		}
+	}
+	return out
+}
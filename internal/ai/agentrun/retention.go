@@ -0,0 +1,101 @@
+package agentrun
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	aierrors "github.com/WyRainBow/ops-portal/internal/ai/errors"
+	"github.com/WyRainBow/ops-portal/internal/store"
+	"github.com/gogf/gf/v2/frame/g"
+	"gorm.io/gorm"
+)
+
+// defaultRetention applies when agent_runs.retention is unset.
+const defaultRetention = 14 * 24 * time.Hour
+
+// purgeInterval is how often StartPurger deletes expired runs.
+var purgeInterval = time.Hour
+
+// parseRetention parses agent_runs.retention: a Go duration ("336h") or a
+// number of days ("14d"). "0" keeps runs forever; empty or invalid values
+// fall back to defaultRetention.
+func parseRetention(s string) time.Duration {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return defaultRetention
+	}
+	if s == "0" {
+		return 0
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return defaultRetention
+		}
+		return time.Duration(n) * 24 * time.Hour
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return defaultRetention
+	}
+	return d
+}
+
+// Retention returns how long recorded runs are kept (agent_runs.retention,
+// default 14 days, 0 = forever).
+func Retention(ctx context.Context) time.Duration {
+	v, err := g.Cfg().Get(ctx, "agent_runs.retention")
+	if err != nil || v.IsNil() {
+		return defaultRetention
+	}
+	return parseRetention(v.String())
+}
+
+// StartPurger deletes runs older than Retention, with their steps, now and
+// every purgeInterval until ctx is done. Replicas may purge concurrently;
+// the deletes are idempotent.
+func StartPurger(ctx context.Context) {
+	retention := Retention(ctx)
+	if retention <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(purgeInterval)
+		defer ticker.Stop()
+		for {
+			if n, err := Purge(ctx, time.Now().Add(-retention)); err != nil {
+				aierrors.Warn("agentrun", fmt.Sprintf("purge expired runs: %v", err))
+			} else if n > 0 {
+				aierrors.Info("agentrun", fmt.Sprintf("purged %d runs older than %s", n, retention))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Purge deletes the runs started before cutoff and their steps, returning
+// the number of runs deleted.
+func Purge(ctx context.Context, cutoff time.Time) (int64, error) {
+	db, err := store.DB(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var n int64
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		expired := tx.Model(&store.AgentRun{}).Select("id").Where("started_at < ?", cutoff)
+		if err := tx.Where("run_id IN (?)", expired).Delete(&store.AgentRunStep{}).Error; err != nil {
+			return err
+		}
+		res := tx.Where("started_at < ?", cutoff).Delete(&store.AgentRun{})
+		n = res.RowsAffected
+		return res.Error
+	})
+	return n, err
+}
@@ -0,0 +1,163 @@
+package agentrun
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+)
+
+// recordedModel records the calls of a model into the recorder of the call
+// context.
+type recordedModel struct {
+	role  string
+	inner model.ToolCallingChatModel
+}
+
+// WrapModel records the calls of m as model steps of role.
+func WrapModel(role string, m model.ToolCallingChatModel) model.ToolCallingChatModel {
+	return &recordedModel{role: role, inner: m}
+}
+
+func (m *recordedModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	rec := FromContext(ctx)
+	start := time.Now()
+	out, err := m.inner.Generate(ctx, input, opts...)
+	m.record(rec, start, input, out, err)
+	return out, err
+}
+
+func (m *recordedModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	rec := FromContext(ctx)
+	start := time.Now()
+	sr, err := m.inner.Stream(ctx, input, opts...)
+	if err != nil || rec == nil {
+		m.record(rec, start, input, nil, err)
+		return sr, err
+	}
+	// 复制一份流在后台拼接，读完后记录完整输出
+	copies := sr.Copy(2)
+	rec.pending.Add(1)
+	go func() {
+		defer rec.pending.Done()
+		defer copies[1].Close()
+		var chunks []*schema.Message
+		var streamErr error
+		for {
+			chunk, err := copies[1].Recv()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				streamErr = err
+				break
+			}
+			chunks = append(chunks, chunk)
+		}
+		var out *schema.Message
+		if len(chunks) > 0 {
+			out, _ = schema.ConcatMessages(chunks)
+		}
+		m.record(rec, start, input, out, streamErr)
+	}()
+	return copies[0], nil
+}
+
+func (m *recordedModel) record(rec *Recorder, start time.Time, input []*schema.Message, out *schema.Message, err error) {
+	if rec == nil {
+		return
+	}
+	s := Step{Type: StepModel, Agent: m.role, Name: m.role, Input: toJSON(input), StartedAt: start, DurationMs: time.Since(start).Milliseconds()}
+	if out != nil {
+		s.Output = toJSON(out)
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	rec.add(s)
+}
+
+func (m *recordedModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	inner, err := m.inner.WithTools(tools)
+	if err != nil {
+		return nil, err
+	}
+	return &recordedModel{role: m.role, inner: inner}, nil
+}
+
+func (m *recordedModel) GetType() string {
+	if t, ok := components.GetType(m.inner); ok {
+		return t
+	}
+	return "Recorded"
+}
+
+// IsCallbacksEnabled follows the wrapped model, so callbacks are reported
+// exactly once.
+func (m *recordedModel) IsCallbacksEnabled() bool {
+	return components.IsCallbacksEnabled(m.inner)
+}
+
+// recordedTool records the calls of a tool into the recorder of the call
+// context.
+type recordedTool struct {
+	name  string
+	inner tool.InvokableTool
+}
+
+// WrapTools records the calls of the invokable tools in list as tool steps
+// and their schemas as the tools of the run of ctx.
+func WrapTools(ctx context.Context, list []tool.BaseTool) ([]tool.BaseTool, error) {
+	rec := FromContext(ctx)
+	out := make([]tool.BaseTool, 0, len(list))
+	for _, t := range list {
+		info, err := t.Info(ctx)
+		if err != nil {
+			return nil, err
+		}
+		rec.addTool(info)
+		inv, ok := t.(tool.InvokableTool)
+		if !ok {
+			out = append(out, t)
+			continue
+		}
+		out = append(out, &recordedTool{name: info.Name, inner: inv})
+	}
+	return out, nil
+}
+
+func (t *recordedTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return t.inner.Info(ctx)
+}
+
+func (t *recordedTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	start := time.Now()
+	out, err := t.inner.InvokableRun(ctx, argumentsInJSON, opts...)
+	if rec := FromContext(ctx); rec != nil {
+		s := Step{
+			Type: StepTool, Name: t.name, CallID: compose.GetToolCallID(ctx),
+			Input: argumentsInJSON, Output: out, StartedAt: start, DurationMs: time.Since(start).Milliseconds(),
+		}
+		if err != nil {
+			s.Error = err.Error()
+		}
+		rec.add(s)
+	}
+	return out, err
+}
+
+func (t *recordedTool) GetType() string {
+	if typ, ok := components.GetType(t.inner); ok {
+		return typ
+	}
+	return t.name
+}
+
+func (t *recordedTool) IsCallbacksEnabled() bool {
+	return components.IsCallbacksEnabled(t.inner)
+}
@@ -7,13 +7,23 @@ import (
 	"time"
 
 	"github.com/WyRainBow/ops-portal/internal/ai/agent/plan_execute_replan"
+	"github.com/WyRainBow/ops-portal/internal/ai/agentrun"
+	"github.com/WyRainBow/ops-portal/internal/ai/policy"
+	"github.com/WyRainBow/ops-portal/internal/ai/prompts"
+	"github.com/WyRainBow/ops-portal/internal/ai/usage"
+	"github.com/WyRainBow/ops-portal/internal/notification/feishu"
+	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/schema"
 )
 
 // DiagnosisResult represents the result of an AI diagnosis
 type DiagnosisResult struct {
 	IncidentID string
-	Result     string
+	Result     string // markdown, rendered from Report when it is valid
 	Detail     []string
+	Report     *Report    // nil when the answer could not be structured
+	ReportErr  string     // why Report is nil
+	ToolCalls  []ToolCall // tool calls the evidence of Report links to
 	Error      error
 	CreatedAt  time.Time
 }
@@ -62,43 +72,62 @@ func (s *DiagnosisService) diagnose(incident *Incident) {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 	defer cancel()
+	ctx = usage.WithSubject(ctx, usage.Subject{Incident: incident.ID})
+	ctx = agentrun.WithLabel(ctx, "diagnosis", incident.ID)
+	// 告警诊断没有门户用户，以系统身份调用工具（如 run_host_diagnostic）
+	ctx = policy.WithSystem(ctx)
 
 	startTime := time.Now()
 
-	// Build diagnosis query
-	query := fmt.Sprintf(`
-"1. 你是一个智能的服务告警分析助手。"
-"2. 告警名称：%s"
-"3. 告警级别：%s"
-"4. 告警描述：%s"
-"5. 请调用工具query_internal_docs获取该告警的处理方案。"
-"6. 涉及到时间的参数都需要先通过工具get_current_time获取当前时间。"
-"7. 涉及到日志的查询,使用工具query_loki_logs从 Loki 查询日志。"
-"8. 最后生成告警运维分析报告，格式如下：
-告警分析报告
----
-# 告警处理详情
-## 告警信息
-## 根因分析
-## 处理建议
-"
-`, incident.AlertName, incident.Severity, incident.Description)
-
-	result, detail, err := plan_execute_replan.BuildPlanAgent(ctx, query)
+	// 诊断提示词按告警名/标签选择模板渲染（internal/ai/prompts），管理员可在线编辑
+	query, tpl, err := prompts.Render(ctx, prompts.KindDiagnosis, &prompts.Incident{
+		ID:          incident.ID,
+		AlertName:   incident.AlertName,
+		Status:      incident.Status,
+		Severity:    incident.Severity,
+		Summary:     incident.Summary,
+		Description: incident.Description,
+		Labels:      incident.Labels,
+		StartedAt:   incident.StartedAt,
+	})
+	if err != nil {
+		s.finish(incident, startTime, &DiagnosisResult{Error: err})
+		return
+	}
+	fmt.Printf("[INFO] AI diagnosis for %s uses prompt %s/%s v%d (%s)\n", incident.ID, tpl.Kind, tpl.Key, tpl.Version, tpl.Source)
+
+	rec := newToolCallRecorder()
+	answer, detail, err := plan_execute_replan.RunPlanAgent(ctx, []adk.Message{schema.UserMessage(query)}, rec.onEvent)
+	if err != nil {
+		s.finish(incident, startTime, &DiagnosisResult{Detail: detail, ToolCalls: rec.calls, Error: err})
+		return
+	}
+
+	// 最后一步把自由文本结论整理成结构化报告，校验失败时保留原文
+	res := &DiagnosisResult{Result: answer, Detail: detail, ToolCalls: rec.calls}
+	report, err := buildReport(ctx, incident, answer, rec.calls)
+	if err != nil {
+		res.ReportErr = err.Error()
+		fmt.Printf("[WARN] AI diagnosis report for %s kept as free text: %v\n", incident.ID, err)
+	} else {
+		res.Report = report
+		res.Result = report.Markdown(incident)
+	}
+	s.finish(incident, startTime, res)
+	notifyReport(ctx, incident, res)
+}
+
+// finish stores the diagnosis result of incident.
+func (s *DiagnosisService) finish(incident *Incident, startTime time.Time, diagnosisResult *DiagnosisResult) {
+	err := diagnosisResult.Error
+	diagnosisResult.IncidentID = incident.ID
+	diagnosisResult.CreatedAt = time.Now()
 
 	// Store result
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	delete(s.pending, incident.ID)
-
-	diagnosisResult := &DiagnosisResult{
-		IncidentID: incident.ID,
-		Result:     result,
-		Detail:     detail,
-		Error:      err,
-		CreatedAt:  time.Now(),
-	}
 	s.results[incident.ID] = diagnosisResult
 
 	duration := time.Since(startTime)
@@ -142,3 +171,32 @@ func (s *DiagnosisService) GetResultsCount() int {
 
 	return len(s.results)
 }
+
+// notifyReport sends the diagnosis of incident to the Feishu alert chat, if
+// configured.
+func notifyReport(ctx context.Context, incident *Incident, res *DiagnosisResult) {
+	n := &feishu.DiagnosticReportNotification{
+		IncidentID:      incident.ID,
+		AlertName:       incident.AlertName,
+		ExecutionStatus: "success",
+	}
+	if r := res.Report; r != nil {
+		n.Summary = r.Summary
+		n.RootCause = r.RootCause.Description
+		n.Confidence = r.RootCause.Confidence
+		for _, a := range r.RecommendedActions {
+			n.Recommendations = append(n.Recommendations, a.Description)
+			if a.PlaybookID != "" {
+				n.Actions = append(n.Actions, "playbook "+a.PlaybookID)
+			}
+		}
+		n.Actions = append(n.Actions, r.FollowUps...)
+	} else if rs := []rune(res.Result); len(rs) > 500 {
+		n.Summary = string(rs[:500]) + "…"
+	} else {
+		n.Summary = res.Result
+	}
+	if err := feishu.GlobalNotifier().SendReport(ctx, n); err != nil {
+		fmt.Printf("[WARN] send diagnosis report of %s to feishu failed: %v\n", incident.ID, err)
+	}
+}
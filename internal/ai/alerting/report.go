@@ -0,0 +1,290 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/WyRainBow/ops-portal/internal/ai/models"
+	"github.com/WyRainBow/ops-portal/internal/ops/playbook"
+	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/schema"
+)
+
+// Report is the structured result of a diagnosis. The plan-execute agent
+// answers in free text; a final step turns that answer and the recorded tool
+// calls into a Report, which is validated and rendered back to markdown.
+type Report struct {
+	Summary            string              `json:"summary"`
+	RootCause          RootCause           `json:"root_cause"`
+	Evidence           []Evidence          `json:"evidence"`
+	RecommendedActions []RecommendedAction `json:"recommended_actions"`
+	FollowUps          []string            `json:"follow_ups"`
+}
+
+// RootCause is the most likely cause with the model's confidence (0-1).
+type RootCause struct {
+	Description string  `json:"description"`
+	Confidence  float64 `json:"confidence"`
+}
+
+// Evidence is a fact supporting the root cause; ToolCallID links it to the
+// tool call it came from.
+type Evidence struct {
+	Description string `json:"description"`
+	ToolCallID  string `json:"tool_call_id,omitempty"`
+	Tool        string `json:"tool,omitempty"`
+}
+
+// RecommendedAction is a remediation step; PlaybookID names a registered
+// playbook that performs it.
+type RecommendedAction struct {
+	Description string         `json:"description"`
+	PlaybookID  string         `json:"playbook_id,omitempty"`
+	Parameters  map[string]any `json:"parameters,omitempty"`
+	Risk        string         `json:"risk,omitempty"` // low, medium, high
+}
+
+// ToolCall is a tool call made during a diagnosis.
+type ToolCall struct {
+	ID        string `json:"id"`
+	Tool      string `json:"tool"`
+	Arguments string `json:"arguments,omitempty"`
+	Result    string `json:"result,omitempty"`
+}
+
+const maxToolResult = 1500
+
+// toolCallRecorder collects the tool calls of plan-execute-replan events.
+type toolCallRecorder struct {
+	calls []ToolCall
+	index map[string]int
+}
+
+func newToolCallRecorder() *toolCallRecorder {
+	return &toolCallRecorder{index: map[string]int{}}
+}
+
+func (r *toolCallRecorder) onEvent(event *adk.AgentEvent) {
+	if event.Err != nil || event.Output == nil || event.Output.MessageOutput == nil {
+		return
+	}
+	msg := event.Output.MessageOutput.Message
+	if msg == nil {
+		return
+	}
+	switch msg.Role {
+	case schema.Assistant:
+		for _, tc := range msg.ToolCalls {
+			r.index[tc.ID] = len(r.calls)
+			r.calls = append(r.calls, ToolCall{ID: tc.ID, Tool: tc.Function.Name, Arguments: tc.Function.Arguments})
+		}
+	case schema.Tool:
+		result := msg.Content
+		if rs := []rune(result); len(rs) > maxToolResult {
+			result = string(rs[:maxToolResult]) + "…"
+		}
+		if i, ok := r.index[msg.ToolCallID]; ok {
+			r.calls[i].Result = result
+			return
+		}
+		r.index[msg.ToolCallID] = len(r.calls)
+		r.calls = append(r.calls, ToolCall{ID: msg.ToolCallID, Tool: msg.ToolName, Result: result})
+	}
+}
+
+// lookupPlaybook returns the enabled playbook id; tests replace it.
+var lookupPlaybook = func(id string) (*playbook.Playbook, bool) {
+	if e := playbook.GlobalExecutor(); e != nil {
+		return e.Get(id)
+	}
+	return nil, false
+}
+
+// Validate checks the report against the schema and the tool calls it cites.
+func (r *Report) Validate(calls []ToolCall) error {
+	var errs []string
+	if strings.TrimSpace(r.Summary) == "" {
+		errs = append(errs, "summary is required")
+	}
+	if strings.TrimSpace(r.RootCause.Description) == "" {
+		errs = append(errs, "root_cause.description is required")
+	}
+	if r.RootCause.Confidence < 0 || r.RootCause.Confidence > 1 {
+		errs = append(errs, fmt.Sprintf("root_cause.confidence %v is not within [0, 1]", r.RootCause.Confidence))
+	}
+	known := map[string]string{}
+	for _, c := range calls {
+		known[c.ID] = c.Tool
+	}
+	for i := range r.Evidence {
+		ev := &r.Evidence[i]
+		if strings.TrimSpace(ev.Description) == "" {
+			errs = append(errs, fmt.Sprintf("evidence[%d].description is required", i))
+		}
+		if ev.ToolCallID == "" {
+			continue
+		}
+		tool, ok := known[ev.ToolCallID]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("evidence[%d].tool_call_id %q is not one of the recorded tool calls", i, ev.ToolCallID))
+			continue
+		}
+		ev.Tool = tool
+	}
+	for i, a := range r.RecommendedActions {
+		if strings.TrimSpace(a.Description) == "" {
+			errs = append(errs, fmt.Sprintf("recommended_actions[%d].description is required", i))
+		}
+		if a.PlaybookID != "" {
+			if pb, ok := lookupPlaybook(a.PlaybookID); !ok {
+				errs = append(errs, fmt.Sprintf("recommended_actions[%d].playbook_id %q is not a registered playbook", i, a.PlaybookID))
+			} else if err := pb.ValidateParameters(a.Parameters); err != nil {
+				errs = append(errs, fmt.Sprintf("recommended_actions[%d].parameters: %v", i, err))
+			}
+		}
+		switch a.Risk {
+		case "", "low", "medium", "high":
+		default:
+			errs = append(errs, fmt.Sprintf("recommended_actions[%d].risk %q must be low, medium or high", i, a.Risk))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Markdown renders the report in the layout of the old free-form reports.
+func (r *Report) Markdown(incident *Incident) string {
+	var b strings.Builder
+	b.WriteString("# 告警分析报告\n\n## 告警信息\n")
+	if incident != nil {
+		fmt.Fprintf(&b, "- 告警名称：%s\n- 告警级别：%s\n", incident.AlertName, incident.Severity)
+	}
+	fmt.Fprintf(&b, "- 摘要：%s\n\n## 根因分析\n%s（置信度 %.0f%%）\n", r.Summary, r.RootCause.Description, r.RootCause.Confidence*100)
+	if len(r.Evidence) > 0 {
+		b.WriteString("\n### 证据\n")
+		for i, ev := range r.Evidence {
+			fmt.Fprintf(&b, "%d. %s", i+1, ev.Description)
+			if ev.Tool != "" {
+				fmt.Fprintf(&b, "（来自 %s", ev.Tool)
+				if ev.ToolCallID != "" {
+					fmt.Fprintf(&b, " #%s", ev.ToolCallID)
+				}
+				b.WriteString("）")
+			}
+			b.WriteString("\n")
+		}
+	}
+	b.WriteString("\n## 处理建议\n")
+	for i, a := range r.RecommendedActions {
+		fmt.Fprintf(&b, "%d. %s", i+1, a.Description)
+		if a.PlaybookID != "" {
+			fmt.Fprintf(&b, "（playbook: %s）", a.PlaybookID)
+		}
+		if a.Risk != "" {
+			fmt.Fprintf(&b, " [风险: %s]", a.Risk)
+		}
+		b.WriteString("\n")
+	}
+	if len(r.FollowUps) > 0 {
+		b.WriteString("\n## 后续跟进\n")
+		for _, f := range r.FollowUps {
+			b.WriteString("- " + f + "\n")
+		}
+	}
+	return b.String()
+}
+
+const reportSchema = `{
+  "summary": "一句话结论",
+  "root_cause": {"description": "最可能的根因", "confidence": 0.0},
+  "evidence": [{"description": "支撑根因的事实", "tool_call_id": "来源工具调用的 id，可省略"}],
+  "recommended_actions": [{"description": "处理动作", "playbook_id": "可执行该动作的 playbook id，可省略", "parameters": {}, "risk": "low|medium|high"}],
+  "follow_ups": ["后续需要跟进的事项"]
+}`
+
+// structure asks the model for the JSON report; tests replace it.
+var structure = func(ctx context.Context, messages []*schema.Message) (string, error) {
+	cm, err := models.ForRole(ctx, models.RoleSummarizer)
+	if err != nil {
+		return "", err
+	}
+	out, err := cm.Generate(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	return out.Content, nil
+}
+
+// buildReport turns the answer of the diagnosis agent into a validated
+// Report. An invalid reply is sent back once with the validation errors.
+func buildReport(ctx context.Context, incident *Incident, answer string, calls []ToolCall) (*Report, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "告警：%s（%s）\n%s\n\n诊断结论：\n%s\n\n工具调用记录：\n", incident.AlertName, incident.Severity, incident.Description, answer)
+	if len(calls) == 0 {
+		b.WriteString("（无）\n")
+	}
+	for _, c := range calls {
+		fmt.Fprintf(&b, "- id=%s tool=%s args=%s\n  result: %s\n", c.ID, c.Tool, c.Arguments, c.Result)
+	}
+	b.WriteString("\n可用 playbook：\n")
+	if e := playbook.GlobalExecutor(); e != nil {
+		for _, pb := range e.List() {
+			fmt.Fprintf(&b, "- %s：%s", pb.ID, pb.Description)
+			var required []string
+			for _, p := range pb.Parameters {
+				if p.Required {
+					required = append(required, p.Name)
+				}
+			}
+			if len(required) > 0 {
+				fmt.Fprintf(&b, "（必填参数：%s）", strings.Join(required, ", "))
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	messages := []*schema.Message{
+		schema.SystemMessage("你负责把告警诊断结论整理成结构化报告。只输出一个 JSON 对象，不要输出其它内容，格式如下：\n" + reportSchema +
+			"\n要求：只使用诊断结论和工具调用记录中的事实；evidence 的 tool_call_id 必须是工具调用记录中的 id；playbook_id 必须是可用 playbook 之一，没有合适的就省略；confidence 取 0 到 1。"),
+		schema.UserMessage(b.String()),
+	}
+	var lastErr error
+	for attempt := 0; attempt < 2; attempt++ {
+		content, err := structure(ctx, messages)
+		if err != nil {
+			return nil, err
+		}
+		report, err := parseReport(content)
+		if err == nil {
+			err = report.Validate(calls)
+		}
+		if err == nil {
+			return report, nil
+		}
+		lastErr = err
+		messages = append(messages, schema.AssistantMessage(content, nil),
+			schema.UserMessage("报告不符合要求："+err.Error()+"。请修正后重新输出完整的 JSON。"))
+	}
+	return nil, fmt.Errorf("invalid diagnosis report: %v", lastErr)
+}
+
+// parseReport decodes the JSON object in content, tolerating code fences and
+// text around it.
+func parseReport(content string) (*Report, error) {
+	start, end := strings.Index(content, "{"), strings.LastIndex(content, "}")
+	if start < 0 || end < start {
+		return nil, errors.New("no JSON object in reply")
+	}
+	dec := json.NewDecoder(strings.NewReader(content[start : end+1]))
+	dec.DisallowUnknownFields()
+	var r Report
+	if err := dec.Decode(&r); err != nil {
+		return nil, fmt.Errorf("decode report: %v", err)
+	}
+	return &r, nil
+}
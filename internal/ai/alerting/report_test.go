@@ -0,0 +1,89 @@
+package alerting
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/WyRainBow/ops-portal/internal/ops/playbook"
+	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestToolCallRecorder(t *testing.T) {
+	rec := newToolCallRecorder()
+	call := schema.AssistantMessage("", []schema.ToolCall{{ID: "c1", Function: schema.FunctionCall{Name: "query_loki_logs", Arguments: `{"q":"x"}`}}})
+	rec.onEvent(adk.EventFromMessage(call, nil, schema.Assistant, ""))
+	rec.onEvent(adk.EventFromMessage(schema.ToolMessage("502 x 120", "c1", schema.WithToolName("query_loki_logs")), nil, schema.Tool, "query_loki_logs"))
+	if len(rec.calls) != 1 || rec.calls[0].Result != "502 x 120" || rec.calls[0].Arguments != `{"q":"x"}` {
+		t.Errorf("calls = %+v", rec.calls)
+	}
+}
+
+func TestBuildReportRetriesInvalidReply(t *testing.T) {
+	origStructure, origLookup := structure, lookupPlaybook
+	t.Cleanup(func() { structure, lookupPlaybook = origStructure, origLookup })
+	lookupPlaybook = func(id string) (*playbook.Playbook, bool) {
+		return &playbook.Playbook{ID: id}, id == "restart-service"
+	}
+
+	replies := []string{
+		// 引用了不存在的工具调用和 playbook
+		`{"summary":"s","root_cause":{"description":"db pool","confidence":0.8},"evidence":[{"description":"e","tool_call_id":"c9"}],"recommended_actions":[{"description":"r","playbook_id":"rm-rf"}]}`,
+		"```json\n" + `{"summary":"5xx 来自连接池耗尽","root_cause":{"description":"db pool","confidence":0.8},"evidence":[{"description":"502 激增","tool_call_id":"c1"}],"recommended_actions":[{"description":"重启服务","playbook_id":"restart-service","risk":"medium"}],"follow_ups":["调大连接池"]}` + "\n```",
+	}
+	var calls int
+	structure = func(_ context.Context, msgs []*schema.Message) (string, error) {
+		if calls > 0 && !strings.Contains(msgs[len(msgs)-1].Content, "c9") {
+			t.Errorf("validation errors not fed back: %q", msgs[len(msgs)-1].Content)
+		}
+		calls++
+		return replies[calls-1], nil
+	}
+
+	inc := &Incident{ID: "i1", AlertName: "HighErrorRate", Severity: "critical"}
+	report, err := buildReport(context.Background(), inc, "answer", []ToolCall{{ID: "c1", Tool: "query_loki_logs"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("structure called %d times", calls)
+	}
+	if report.Evidence[0].Tool != "query_loki_logs" {
+		t.Errorf("evidence not linked to its tool call: %+v", report.Evidence[0])
+	}
+	md := report.Markdown(inc)
+	for _, want := range []string{"置信度 80%", "playbook: restart-service", "query_loki_logs #c1", "调大连接池"} {
+		if !strings.Contains(md, want) {
+			t.Errorf("markdown missing %q:\n%s", want, md)
+		}
+	}
+}
+
+func TestReportValidate(t *testing.T) {
+	r := &Report{Summary: "s", RootCause: RootCause{Description: "d", Confidence: 1.5},
+		RecommendedActions: []RecommendedAction{{Description: "a", Risk: "extreme"}}}
+	err := r.Validate(nil)
+	if err == nil || !strings.Contains(err.Error(), "confidence") || !strings.Contains(err.Error(), "risk") {
+		t.Errorf("Validate = %v", err)
+	}
+
+	origLookup := lookupPlaybook
+	t.Cleanup(func() { lookupPlaybook = origLookup })
+	lookupPlaybook = func(id string) (*playbook.Playbook, bool) {
+		return &playbook.Playbook{ID: id, Parameters: []playbook.Parameter{{Name: "service", Required: true}}}, true
+	}
+	r = &Report{Summary: "s", RootCause: RootCause{Description: "d", Confidence: 0.5},
+		RecommendedActions: []RecommendedAction{{Description: "a", PlaybookID: "restart-service"}}}
+	if err := r.Validate(nil); err == nil || !strings.Contains(err.Error(), "missing required parameters: service") {
+		t.Errorf("Validate = %v, want missing parameter error", err)
+	}
+	r.RecommendedActions[0].Parameters = map[string]any{"service": "api"}
+	if err := r.Validate(nil); err != nil {
+		t.Errorf("Validate = %v", err)
+	}
+
+	if _, err := parseReport(`{"summary":"s","extra":1}`); err == nil {
+		t.Error("unknown field accepted")
+	}
+}
@@ -0,0 +1,323 @@
+// Package assistant is the chat service behind /api/chat/chat and
+// /api/chat/chat_stream. A conversation belongs to a persisted session
+// (internal/ai/session); each question runs in quick mode (the ReAct chat
+// pipeline with knowledge-base retrieval) or deep mode (plan-execute-replan),
+// and returns the answer with the cited documents and the tool calls it was
+// based on.
+package assistant
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/WyRainBow/ops-portal/internal/ai/agent/chat_pipeline"
+	"github.com/WyRainBow/ops-portal/internal/ai/agent/plan_execute_replan"
+	"github.com/WyRainBow/ops-portal/internal/ai/agentrun"
+	aierrors "github.com/WyRainBow/ops-portal/internal/ai/errors"
+	"github.com/WyRainBow/ops-portal/internal/ai/policy"
+	"github.com/WyRainBow/ops-portal/internal/ai/retriever"
+	"github.com/WyRainBow/ops-portal/internal/ai/session"
+	"github.com/WyRainBow/ops-portal/internal/ai/usage"
+	"github.com/WyRainBow/ops-portal/internal/store"
+	"github.com/WyRainBow/ops-portal/utility/log_call_back"
+	"github.com/WyRainBow/ops-portal/utility/textutil"
+	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+)
+
+// Modes of a chat run.
+const (
+	ModeQuick = "quick" // ReAct agent, streams tokens
+	ModeDeep  = "deep"  // plan-execute-replan, for multi-step investigations
+)
+
+// EventSession announces a newly created session to stream clients.
+const EventSession = "session"
+
+// deepPreamble keeps deep mode read-only, as /api/chat always was.
+const deepPreamble = `你是一个只读的智能运维助手。
+你必须在回答前按需调用工具获取事实（例如 Loki 日志、Prometheus 告警、只读数据库查询），避免凭空猜测。
+你不能执行任何修复动作，只能输出诊断结论与建议的下一步命令（供人工执行）。`
+
+// Request is one question of a conversation.
+type Request struct {
+	SessionID string // empty: start a new session
+	Question  string
+	Mode      string // quick (default) or deep
+}
+
+// ToolEvidence is a tool call the answer is based on.
+type ToolEvidence struct {
+	CallID     string `json:"call_id,omitempty"`
+	Tool       string `json:"tool"`
+	Arguments  string `json:"arguments,omitempty"`
+	Result     string `json:"result,omitempty"`
+	Truncated  bool   `json:"truncated,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms,omitempty"`
+}
+
+// Response is the structured result of a run; it is also the payload of the
+// done event of a stream.
+type Response struct {
+	SessionID string                        `json:"session_id"`
+	Title     string                        `json:"title,omitempty"`
+	Mode      string                        `json:"mode"`
+	Answer    string                        `json:"answer"`
+	Citations []chat_pipeline.CitationEvent `json:"citations"`
+	Evidence  []ToolEvidence                `json:"evidence"`
+	Steps     []string                      `json:"steps,omitempty"`
+}
+
+// Conversation is a prepared question: the session is resolved and the
+// history loaded, so stream callers can key the event stream by session id
+// before running.
+type Conversation struct {
+	Session  *store.ChatSession
+	Created  bool
+	Mode     string
+	question string
+	history  []*schema.Message
+}
+
+// NormalizeMode returns mode, or def when mode is empty.
+func NormalizeMode(mode, def string) (string, error) {
+	switch m := strings.ToLower(strings.TrimSpace(mode)); m {
+	case "":
+		return def, nil
+	case ModeQuick, ModeDeep:
+		return m, nil
+	default:
+		return "", fmt.Errorf("invalid mode %q (quick, deep)", mode)
+	}
+}
+
+// Prepare checks the quota of the JWT user and resolves the session of req,
+// creating it when needed.
+func Prepare(ctx context.Context, req Request) (*Conversation, error) {
+	question := strings.TrimSpace(req.Question)
+	if question == "" {
+		return nil, errors.New("question is required")
+	}
+	mode, err := NormalizeMode(req.Mode, ModeQuick)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := session.UserID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := usage.CheckQuota(ctx); err != nil {
+		return nil, err
+	}
+	sess, created, err := session.Ensure(ctx, userID, strings.TrimSpace(req.SessionID), question)
+	if err != nil {
+		return nil, err
+	}
+	history, err := session.History(ctx, sess)
+	if err != nil {
+		return nil, err
+	}
+	return &Conversation{Session: sess, Created: created, Mode: mode, question: question, history: history}, nil
+}
+
+// Run answers the question, passing typed events to emit (may be nil), and
+// stores the turn in the session. On error the partial response is returned
+// with the error.
+func (c *Conversation) Run(ctx context.Context, emit chat_pipeline.Emitter) (*Response, error) {
+	ctx = policy.EnsureRun(ctx)
+	rec := newRecorder(emit)
+	if c.Created {
+		rec.emit(EventSession, map[string]string{"session_id": c.Session.ID, "title": c.Session.Title})
+	}
+
+	var answer string
+	var steps []string
+	var err error
+	switch c.Mode {
+	case ModeDeep:
+		answer, steps, err = c.runDeep(ctx, rec)
+	default:
+		answer, err = c.runQuick(ctx, rec)
+	}
+	if answer != "" {
+		if err := saveTurn(ctx, c.Session, c.question, answer); err != nil {
+			aierrors.Warn("assistant", fmt.Sprintf("save chat turn of session %s: %v", c.Session.ID, err))
+		}
+	}
+
+	res := rec.response()
+	res.SessionID = c.Session.ID
+	res.Title = c.Session.Title
+	res.Mode = c.Mode
+	res.Answer = answer
+	res.Steps = steps
+	return res, err
+}
+
+func (c *Conversation) runQuick(ctx context.Context, rec *recorder) (answer string, err error) {
+	// 记录本次 ReAct 运行；模型和工具在构建对话链路时包装，需在 Build 之前开始
+	ctx = agentrun.WithLabel(ctx, agentrun.KindChatQuick, c.Session.ID)
+	ctx, run := agentrun.Start(ctx, append(append([]*schema.Message{}, c.history...), schema.UserMessage(c.question)))
+	defer func() { run.Finish(ctx, answer, err) }()
+
+	runner, err := chat_pipeline.BuildChatAgent(ctx)
+	if err != nil {
+		return "", err
+	}
+	opts := append(chat_pipeline.StreamOptions(rec.emit), compose.WithCallbacks(log_call_back.LogCallback(nil)))
+	sr, err := runner.Stream(ctx, &chat_pipeline.UserMessage{
+		ID:      c.Session.ID,
+		Query:   c.question,
+		History: c.history,
+	}, opts...)
+	if err != nil {
+		return "", err
+	}
+	defer sr.Close()
+
+	var out strings.Builder
+	for {
+		chunk, err := sr.Recv()
+		if errors.Is(err, io.EOF) {
+			return out.String(), nil
+		}
+		if err != nil {
+			return out.String(), err
+		}
+		if chunk.Content == "" {
+			continue
+		}
+		out.WriteString(chunk.Content)
+		rec.emit(chat_pipeline.EventMessage, chat_pipeline.MessageEvent{Content: chunk.Content})
+	}
+}
+
+// retrieve looks the question up in the knowledge base; tests replace it.
+var retrieve = func(ctx context.Context, question string) ([]*schema.Document, error) {
+	r, err := retriever.NewMilvusRetriever(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return r.Retrieve(ctx, question)
+}
+
+// runPlan runs plan-execute-replan; tests replace it.
+var runPlan = plan_execute_replan.RunPlanAgent
+
+// saveTurn stores the question and answer in the session; tests replace it.
+var saveTurn = session.AppendTurn
+
+func (c *Conversation) runDeep(ctx context.Context, rec *recorder) (string, []string, error) {
+	var query strings.Builder
+	query.WriteString(deepPreamble + "\n")
+	docs, err := retrieve(ctx, c.question)
+	if err != nil {
+		aierrors.Warn("assistant", "knowledge retrieval failed, answering without documents: "+err.Error())
+	}
+	if citations := chat_pipeline.Citations(docs); len(citations) > 0 {
+		query.WriteString("相关文档（引用时标注编号）：\n")
+		for _, ct := range citations {
+			rec.emit(chat_pipeline.EventCitation, ct)
+			fmt.Fprintf(&query, "[%d] %s：%s\n", ct.Index, ct.Source, ct.Snippet)
+		}
+	}
+	query.WriteString("用户问题如下：\n" + c.question)
+
+	messages := append(append([]adk.Message{}, c.history...), schema.UserMessage(query.String()))
+	var step int64
+	var pending string
+	ctx = agentrun.WithLabel(ctx, agentrun.KindChatDeep, c.Session.ID)
+	answer, steps, err := runPlan(ctx, messages, func(event *adk.AgentEvent) {
+		if event.Err != nil || event.Output == nil || event.Output.MessageOutput == nil {
+			return
+		}
+		msg := event.Output.MessageOutput.Message
+		if msg == nil {
+			return
+		}
+		switch msg.Role {
+		case schema.Tool:
+			res := chat_pipeline.ToolResultEvent{Step: step, CallID: msg.ToolCallID, Tool: msg.ToolName}
+			res.Result, res.Truncated = textutil.Truncate(msg.Content, maxEvidenceResult)
+			rec.emit(chat_pipeline.EventToolResult, res)
+		case schema.Assistant:
+			step++
+			for _, tc := range msg.ToolCalls {
+				rec.emit(chat_pipeline.EventToolCall, chat_pipeline.ToolCallEvent{
+					Step: step, CallID: tc.ID, Tool: tc.Function.Name, Arguments: tc.Function.Arguments,
+				})
+			}
+			// 只有最后一条是回答，之前的计划和步骤输出作为 thinking
+			if strings.TrimSpace(msg.Content) != "" {
+				if pending != "" {
+					rec.emit(chat_pipeline.EventThinking, chat_pipeline.ThinkingEvent{Step: step - 1, Content: pending})
+				}
+				pending = msg.Content
+			}
+		}
+	})
+	if err != nil {
+		return "", steps, err
+	}
+	rec.emit(chat_pipeline.EventMessage, chat_pipeline.MessageEvent{Content: answer})
+	return answer, steps, nil
+}
+
+const maxEvidenceResult = 2000
+
+// recorder forwards events to the caller and collects the citations and
+// tool evidence of the response.
+type recorder struct {
+	next      chat_pipeline.Emitter
+	mu        sync.Mutex
+	citations []chat_pipeline.CitationEvent
+	evidence  []ToolEvidence
+	calls     map[string]int // call id -> index in evidence
+}
+
+func newRecorder(next chat_pipeline.Emitter) *recorder {
+	return &recorder{next: next, calls: map[string]int{}}
+}
+
+func (r *recorder) emit(event string, payload any) {
+	r.mu.Lock()
+	switch ev := payload.(type) {
+	case chat_pipeline.CitationEvent:
+		r.citations = append(r.citations, ev)
+	case chat_pipeline.ToolCallEvent:
+		if ev.CallID != "" {
+			r.calls[ev.CallID] = len(r.evidence)
+		}
+		r.evidence = append(r.evidence, ToolEvidence{CallID: ev.CallID, Tool: ev.Tool, Arguments: ev.Arguments})
+	case chat_pipeline.ToolResultEvent:
+		i, ok := r.calls[ev.CallID]
+		if !ok || ev.CallID == "" {
+			i = len(r.evidence)
+			r.evidence = append(r.evidence, ToolEvidence{CallID: ev.CallID, Tool: ev.Tool})
+		}
+		e := &r.evidence[i]
+		if e.Tool == "" {
+			e.Tool = ev.Tool
+		}
+		e.Result, e.Truncated, e.Error, e.DurationMs = ev.Result, ev.Truncated, ev.Error, ev.DurationMs
+	}
+	r.mu.Unlock()
+	if r.next != nil {
+		r.next(event, payload)
+	}
+}
+
+func (r *recorder) response() *Response {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return &Response{
+		Citations: append([]chat_pipeline.CitationEvent{}, r.citations...),
+		Evidence:  append([]ToolEvidence{}, r.evidence...),
+	}
+}
@@ -0,0 +1,86 @@
+package assistant
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/WyRainBow/ops-portal/internal/ai/agent/chat_pipeline"
+	"github.com/WyRainBow/ops-portal/internal/store"
+	"github.com/cloudwego/eino/adk"
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestRunDeep(t *testing.T) {
+	retrieve = func(context.Context, string) ([]*schema.Document, error) {
+		return []*schema.Document{{ID: "d1", Content: "resume-api 502 处理手册", MetaData: map[string]any{"_source": "docs/resume-api.md"}}}, nil
+	}
+	var query string
+	runPlan = func(_ context.Context, msgs []adk.Message, onEvent func(*adk.AgentEvent)) (string, []string, error) {
+		query = msgs[len(msgs)-1].Content
+		for _, m := range []*schema.Message{
+			schema.AssistantMessage(`{"steps":["查日志"]}`, nil),
+			schema.AssistantMessage("", []schema.ToolCall{{ID: "c1", Function: schema.FunctionCall{Name: "query_logs", Arguments: `{"service":"resume-api"}`}}}),
+			schema.ToolMessage("12 errors", "c1", schema.WithToolName("query_logs")),
+			schema.AssistantMessage("上游超时导致 502 [1]", nil),
+		} {
+			onEvent(adk.EventFromMessage(m, nil, m.Role, m.ToolName))
+		}
+		return "上游超时导致 502 [1]", []string{"plan", "step"}, nil
+	}
+	var saved string
+	saveTurn = func(_ context.Context, _ *store.ChatSession, q, a string) error {
+		saved = q + "|" + a
+		return nil
+	}
+
+	var events []string
+	conv := &Conversation{
+		Session:  &store.ChatSession{ID: "s1", Title: "502"},
+		Created:  true,
+		Mode:     ModeDeep,
+		question: "resume-api 为什么 502",
+		history:  []*schema.Message{schema.UserMessage("之前的问题"), schema.AssistantMessage("之前的回答", nil)},
+	}
+	res, err := conv.Run(context.Background(), func(event string, _ any) { events = append(events, event) })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.SessionID != "s1" || res.Mode != ModeDeep || res.Answer != "上游超时导致 502 [1]" || len(res.Steps) != 2 {
+		t.Errorf("unexpected response: %+v", res)
+	}
+	if len(res.Citations) != 1 || res.Citations[0].Source != "docs/resume-api.md" {
+		t.Errorf("citations = %+v", res.Citations)
+	}
+	if len(res.Evidence) != 1 || res.Evidence[0].Tool != "query_logs" || res.Evidence[0].Arguments == "" || res.Evidence[0].Result != "12 errors" {
+		t.Errorf("evidence = %+v", res.Evidence)
+	}
+	want := []string{EventSession, chat_pipeline.EventCitation, chat_pipeline.EventToolCall, chat_pipeline.EventToolResult, chat_pipeline.EventThinking, chat_pipeline.EventMessage}
+	if len(events) != len(want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("events = %v, want %v", events, want)
+		}
+	}
+	if saved != "resume-api 为什么 502|上游超时导致 502 [1]" {
+		t.Errorf("saved turn = %q", saved)
+	}
+	if !strings.Contains(query, "docs/resume-api.md") || !strings.Contains(query, "只读") {
+		t.Errorf("deep query lacks documents or preamble: %q", query)
+	}
+}
+
+func TestNormalizeMode(t *testing.T) {
+	if m, _ := NormalizeMode("", ModeDeep); m != ModeDeep {
+		t.Errorf("default = %q", m)
+	}
+	if m, _ := NormalizeMode(" Quick ", ModeDeep); m != ModeQuick {
+		t.Errorf("quick = %q", m)
+	}
+	if _, err := NormalizeMode("fast", ModeQuick); err == nil {
+		t.Error("invalid mode accepted")
+	}
+}
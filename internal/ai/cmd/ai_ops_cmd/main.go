@@ -2,12 +2,16 @@ package main
 
 import (
 	"github.com/WyRainBow/ops-portal/internal/ai/agent/plan_execute_replan"
+	"github.com/WyRainBow/ops-portal/internal/ai/agentrun"
+	"github.com/WyRainBow/ops-portal/internal/ai/usage"
 	"context"
 	"fmt"
 )
 
 func main() {
-	ctx := context.Background()
+	usage.Init()
+	ctx := usage.WithSubject(context.Background(), usage.Subject{CLI: "ai_ops_cmd"})
+	ctx = agentrun.WithLabel(ctx, "cli", "ai_ops_cmd")
 	query := `
 "1. 你是一个智能的服务告警运维分析助手,首先调用工具query_prometheus_alerts获取所有活跃的告警。"
 "2. 分别根据告警的名称调用工具query_internal_docs，获取告警名对应的处理方案。"
@@ -2,6 +2,7 @@ package main
 
 import (
 	"github.com/WyRainBow/ops-portal/internal/ai/agent/chat_pipeline"
+	"github.com/WyRainBow/ops-portal/internal/ai/usage"
 	"github.com/WyRainBow/ops-portal/utility/mem"
 	"context"
 	"fmt"
@@ -10,7 +11,8 @@ import (
 )
 
 func main() {
-	ctx := context.Background()
+	usage.Init()
+	ctx := usage.WithSubject(context.Background(), usage.Subject{CLI: "chat_cmd"})
 	id := "111"
 	userMessage := &chat_pipeline.UserMessage{
 		ID:      id,
@@ -30,7 +32,7 @@ func main() {
 	fmt.Println("Q: 你好")
 	fmt.Println("A:", answer)
 	mem.GetSimpleMemory(id).SetMessages(schema.UserMessage("你好"))
-	mem.GetSimpleMemory(id).SetMessages(schema.SystemMessage(out.Content))
+	mem.GetSimpleMemory(id).SetMessages(schema.AssistantMessage(out.Content, nil))
 	// 第二次对话
 	userMessage = &chat_pipeline.UserMessage{
 		ID:      id,
@@ -7,11 +7,13 @@ import (
 	"strings"
 
 	"github.com/WyRainBow/ops-portal/internal/ai/models"
+	"github.com/WyRainBow/ops-portal/internal/ai/usage"
 	"github.com/cloudwego/eino/schema"
 )
 
 func main() {
-	ctx := context.Background()
+	usage.Init()
+	ctx := usage.WithSubject(context.Background(), usage.Subject{CLI: "llm_tool_cmd"})
 
 	// Smoke test: call the configured DeepSeek model via Eino OpenAI-compatible client.
 	// Configure via env:
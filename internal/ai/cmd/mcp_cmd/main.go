@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	aierrors "github.com/WyRainBow/ops-portal/internal/ai/errors"
+	"github.com/WyRainBow/ops-portal/internal/ai/mcp"
+	"github.com/WyRainBow/ops-portal/internal/ai/registry"
+	"github.com/WyRainBow/ops-portal/internal/security"
+	"github.com/WyRainBow/ops-portal/utility/middleware"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gctx"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// MCP server over stdio, publishing the enabled tools of the portal.
+// Example (Claude Desktop / IDE config):
+//
+//	{"command": "go", "args": ["run", "./internal/ai/cmd/mcp_cmd"],
+//	 "env": {"OPS_PORTAL_TOKEN": "<portal JWT>"}}
+//
+// The token is the same JWT the portal issues on login and is checked with
+// OPS_PORTAL_JWT_SECRET.
+func main() {
+	ctx := gctx.New()
+
+	// stdout 只能用于 MCP 协议，日志全部写 stderr
+	aierrors.SetOutput(os.Stderr)
+	g.Log().SetStdoutPrint(false)
+	g.Log().SetWriter(os.Stderr)
+
+	claims, err := security.ParseToken(strings.TrimSpace(os.Getenv("OPS_PORTAL_TOKEN")))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "OPS_PORTAL_TOKEN is missing or invalid: %v\n", err)
+		os.Exit(2)
+	}
+	user := &middleware.UserContext{
+		UserID:   claims.Subject,
+		Username: claims.Username,
+		Role:     claims.Role,
+	}
+
+	// 管理员禁用的工具不能通过 stdio 发布；之后的修改定期重新加载
+	if err := registry.LoadOverrides(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "load tool overrides: %v\n", err)
+	}
+	registry.WatchOverrides(ctx)
+
+	if err := registry.RegisterStandardTools(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "register tools: %v\n", err)
+	}
+
+	srv := mcp.NewServer(registry.Global())
+	err = server.ServeStdio(srv.MCPServer(), server.WithStdioContextFunc(func(ctx context.Context) context.Context {
+		return middleware.SetUserContext(ctx, user)
+	}))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mcp stdio server: %v\n", err)
+		os.Exit(1)
+	}
+}
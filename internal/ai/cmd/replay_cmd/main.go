@@ -0,0 +1,84 @@
+// replay_cmd replays a recorded agent run against its recorded tool outputs
+// and prints how the replay differs from the recording. The run comes from
+// the database (-run) or from a file saved from GET /api/admin/agent-runs/{id}
+// (-file), so regression checks of prompt and model changes run offline:
+//
+//	go run ./internal/ai/cmd/replay_cmd -file testdata/run.json -fail-on-diff
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/WyRainBow/ops-portal/internal/ai/agent/plan_execute_replan"
+	"github.com/WyRainBow/ops-portal/internal/ai/agentrun"
+	"github.com/WyRainBow/ops-portal/internal/ai/usage"
+)
+
+func main() {
+	runID := flag.String("run", "", "id of a run recorded in the database")
+	file := flag.String("file", "", "run exported from /api/admin/agent-runs/{id}")
+	live := flag.Bool("live", false, "call the configured models instead of replaying the recorded outputs")
+	question := flag.String("question", "", "replace the last user message of the recorded input")
+	failOnDiff := flag.Bool("fail-on-diff", false, "exit 1 when the output or the tool calls differ")
+	flag.Parse()
+
+	usage.Init()
+	ctx := usage.WithSubject(context.Background(), usage.Subject{CLI: "replay_cmd"})
+	var run *agentrun.Run
+	var err error
+	switch {
+	case *file != "":
+		run, err = readRun(*file)
+	case *runID != "":
+		run, err = agentrun.Load(ctx, *runID)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	replayed, diff, err := plan_execute_replan.Replay(ctx, run, agentrun.ReplayOptions{LiveModel: *live, Question: *question})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	fmt.Println("----- Replayed Output -----")
+	fmt.Println(replayed.Output)
+	if replayed.Error != "" {
+		fmt.Println("----- Replay Error -----")
+		fmt.Println(replayed.Error)
+	}
+	fmt.Println("----- Diff -----")
+	out, _ := json.MarshalIndent(diff, "", "  ")
+	fmt.Println(string(out))
+	if *failOnDiff && (!diff.OutputMatches || !diff.ToolCallsMatch || replayed.Error != "") {
+		os.Exit(1)
+	}
+}
+
+// readRun reads a run file; the {"code","message","data"} envelope of the API
+// response is accepted as well.
+func readRun(path string) (*agentrun.Run, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var envelope struct {
+		Data *agentrun.Run `json:"data"`
+	}
+	if err := json.Unmarshal(b, &envelope); err == nil && envelope.Data != nil && envelope.Data.ID != "" {
+		return envelope.Data, nil
+	}
+	var run agentrun.Run
+	if err := json.Unmarshal(b, &run); err != nil {
+		return nil, fmt.Errorf("read %s: %v", path, err)
+	}
+	return &run, nil
+}
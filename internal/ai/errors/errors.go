@@ -3,6 +3,7 @@ package errors
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"runtime/debug"
@@ -82,6 +83,12 @@ func SetPrefix(p string) {
 	defaultLogger.prefix = p
 }
 
+// SetOutput redirects the default logger, e.g. to stderr when stdout
+// carries a protocol (MCP stdio).
+func SetOutput(w io.Writer) {
+	defaultLogger.logger.SetOutput(w)
+}
+
 // Error logs an error with context.
 func Error(tool, message string, err error) {
 	defaultLogger.Error(tool, message, err)
@@ -153,18 +160,42 @@ func (l *Logger) ToolResult(tool string, success bool, duration time.Duration) {
 	l.logger.Printf("[TOOL] [%s] %s duration=%s", tool, status, duration)
 }
 
+// ToolCallEvent is the structured record of one tool invocation.
+type ToolCallEvent struct {
+	Tool        string  `json:"tool"`
+	RunID       string  `json:"run_id,omitempty"`
+	Arguments   string  `json:"arguments,omitempty"`
+	Success     bool    `json:"success"`
+	DurationMs  float64 `json:"duration_ms"`
+	OutputBytes int     `json:"output_bytes"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// ToolEvent logs a structured tool-call event as one JSON line.
+func ToolEvent(ev ToolCallEvent) {
+	b, _ := json.Marshal(ev)
+	defaultLogger.logger.Printf("[TOOL] %s", string(b))
+}
+
 // RecoverPanic recovers from panic and logs it with stack trace.
 // Returns the panic value as error, or nil if no panic occurred.
+// recover only works when RecoverPanic itself is the deferred call; from a
+// deferred closure use recover() with PanicError.
 func RecoverPanic(tool string) (err error) {
 	if r := recover(); r != nil {
-		stack := debug.Stack()
-		l := defaultLogger
-		l.logger.Printf("[PANIC] [%s] recovered: %v\n%s", tool, r, string(stack))
-		return fmt.Errorf("panic in %s: %v", tool, r)
+		return PanicError(tool, r)
 	}
 	return nil
 }
 
+// PanicError logs a recovered panic value with stack trace and converts it
+// into an error.
+func PanicError(tool string, r any) error {
+	stack := debug.Stack()
+	defaultLogger.logger.Printf("[PANIC] [%s] recovered: %v\n%s", tool, r, string(stack))
+	return fmt.Errorf("panic in %s: %v", tool, r)
+}
+
 // SafeToolWrapper wraps a tool function with panic recovery.
 func SafeToolWrapper(toolName string, fn func() (string, error)) (output string, err error) {
 	defer func() {
@@ -0,0 +1,540 @@
+// Package mcp connects ops-portal to Model Context Protocol servers.
+//
+// client.go mounts the tools of external MCP servers (configured under
+// mcp_servers) into the tool registry so the agents can call them.
+package mcp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/internal/ai/errors"
+	"github.com/WyRainBow/ops-portal/internal/ai/registry"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/eino-contrib/jsonschema"
+	"github.com/gogf/gf/v2/frame/g"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+)
+
+// Transport types.
+const (
+	TransportStdio = "stdio"
+	TransportHTTP  = "http"
+)
+
+// ServerConfig describes an external MCP server.
+type ServerConfig struct {
+	Name       string            `json:"name"`
+	Transport  string            `json:"transport"` // "stdio" or "http" (streamable HTTP)
+	Command    string            `json:"command"`   // stdio
+	Args       []string          `json:"args"`      // stdio
+	Env        []string          `json:"env"`       // stdio, KEY=VALUE
+	URL        string            `json:"url"`       // http
+	Headers    map[string]string `json:"headers"`   // http
+	AgentTypes []string          `json:"agent_types"`
+	Disabled   bool              `json:"disabled"`
+}
+
+// ServerStatus is a snapshot of a server connection.
+type ServerStatus struct {
+	Name        string    `json:"name"`
+	Transport   string    `json:"transport"`
+	Connected   bool      `json:"connected"`
+	Tools       []string  `json:"tools"`
+	LastError   string    `json:"last_error,omitempty"`
+	ConnectedAt time.Time `json:"connected_at,omitempty"`
+}
+
+const (
+	healthInterval = 30 * time.Second
+	maxBackoff     = 5 * time.Minute
+	callTimeout    = 60 * time.Second
+)
+
+// Manager keeps connections to all configured MCP servers.
+type Manager struct {
+	registry *registry.Registry
+
+	mu      sync.Mutex
+	servers map[string]*serverConn
+	cancel  context.CancelFunc
+}
+
+// NewManager creates a manager that registers tools into reg.
+func NewManager(reg *registry.Registry) *Manager {
+	return &Manager{
+		registry: reg,
+		servers:  make(map[string]*serverConn),
+	}
+}
+
+// Start connects to every server and keeps the connections alive in the
+// background. Connection failures are retried with backoff.
+func (m *Manager) Start(ctx context.Context, configs []ServerConfig) {
+	m.mu.Lock()
+	if m.cancel != nil {
+		m.mu.Unlock()
+		return
+	}
+	ctx, m.cancel = context.WithCancel(ctx)
+	for _, cfg := range configs {
+		if cfg.Disabled || strings.TrimSpace(cfg.Name) == "" {
+			continue
+		}
+		if _, dup := m.servers[cfg.Name]; dup {
+			errors.Warn("mcp", "duplicate mcp server name: "+cfg.Name)
+			continue
+		}
+		if cfg.Transport == "" {
+			cfg.Transport = TransportStdio
+		}
+		if len(cfg.AgentTypes) == 0 {
+			cfg.AgentTypes = []string{"all"}
+		}
+		sc := &serverConn{cfg: cfg, registry: m.registry}
+		m.servers[cfg.Name] = sc
+		go sc.run(ctx)
+	}
+	m.mu.Unlock()
+}
+
+// Stop closes all connections and unregisters their tools.
+func (m *Manager) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.cancel != nil {
+		m.cancel()
+		m.cancel = nil
+	}
+	for _, sc := range m.servers {
+		sc.disconnect(nil)
+	}
+}
+
+// Status returns the state of every configured server.
+func (m *Manager) Status() []ServerStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]ServerStatus, 0, len(m.servers))
+	for _, sc := range m.servers {
+		out = append(out, sc.status())
+	}
+	return out
+}
+
+// serverConn is the connection to one MCP server.
+type serverConn struct {
+	cfg      ServerConfig
+	registry *registry.Registry
+
+	mu          sync.RWMutex
+	client      *mcpclient.Client
+	tools       map[string]remoteDef // registry name -> mounted remote tool
+	lastErr     string
+	connectedAt time.Time
+	lost        chan struct{}
+	refresh     chan struct{}
+}
+
+// remoteDef is a mounted remote tool: its name on the server and its
+// definition as listed, used to skip re-registering unchanged tools.
+type remoteDef struct {
+	remote string
+	def    string
+}
+
+func (sc *serverConn) run(ctx context.Context) {
+	backoff := time.Second
+	for {
+		if err := sc.connect(ctx); err != nil {
+			sc.disconnect(err)
+			errors.Warn("mcp", fmt.Sprintf("connect %s failed, retry in %s: %v", sc.cfg.Name, backoff, err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+			continue
+		}
+		backoff = time.Second
+		errors.Info("mcp", fmt.Sprintf("connected to %s (%d tools)", sc.cfg.Name, len(sc.toolNames())))
+
+		err := sc.watch(ctx)
+		sc.disconnect(err)
+		if ctx.Err() != nil {
+			return
+		}
+		errors.Warn("mcp", fmt.Sprintf("connection to %s lost, reconnecting: %v", sc.cfg.Name, err))
+	}
+}
+
+// watch blocks until the connection is lost, refreshing the tool list on
+// tools/list_changed notifications.
+func (sc *serverConn) watch(ctx context.Context) error {
+	ticker := time.NewTicker(healthInterval)
+	defer ticker.Stop()
+	for {
+		sc.mu.RLock()
+		lost, refresh, cli := sc.lost, sc.refresh, sc.client
+		sc.mu.RUnlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-lost:
+			return fmt.Errorf("transport closed")
+		case <-refresh:
+			if err := sc.syncTools(ctx); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			pctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			err := cli.Ping(pctx)
+			cancel()
+			if err != nil {
+				return fmt.Errorf("ping failed: %w", err)
+			}
+			// Servers without list_changed support are polled.
+			if err := sc.syncTools(ctx); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (sc *serverConn) connect(ctx context.Context) error {
+	var (
+		cli *mcpclient.Client
+		err error
+	)
+	switch sc.cfg.Transport {
+	case TransportStdio:
+		if sc.cfg.Command == "" {
+			return fmt.Errorf("command is required for stdio transport")
+		}
+		cli, err = mcpclient.NewStdioMCPClient(sc.cfg.Command, sc.cfg.Env, sc.cfg.Args...)
+	case TransportHTTP:
+		if sc.cfg.URL == "" {
+			return fmt.Errorf("url is required for http transport")
+		}
+		cli, err = mcpclient.NewStreamableHttpClient(sc.cfg.URL, transport.WithHTTPHeaders(sc.cfg.Headers))
+	default:
+		return fmt.Errorf("unknown transport %q", sc.cfg.Transport)
+	}
+	if err != nil {
+		return err
+	}
+	// Start is idempotent for the already running stdio transport and wires
+	// up notification dispatch for both.
+	if err := cli.Start(ctx); err != nil {
+		_ = cli.Close()
+		return err
+	}
+
+	lost := make(chan struct{})
+	refresh := make(chan struct{}, 1)
+	var once sync.Once
+	cli.OnConnectionLost(func(error) { once.Do(func() { close(lost) }) })
+	cli.OnNotification(func(n mcpgo.JSONRPCNotification) {
+		if n.Method == mcpgo.MethodNotificationToolsListChanged {
+			select {
+			case refresh <- struct{}{}:
+			default:
+			}
+		}
+	})
+
+	ictx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	initReq := mcpgo.InitializeRequest{}
+	initReq.Params.ProtocolVersion = mcpgo.LATEST_PROTOCOL_VERSION
+	initReq.Params.ClientInfo = mcpgo.Implementation{Name: "ops-portal", Version: "1.0.0"}
+	if _, err := cli.Initialize(ictx, initReq); err != nil {
+		_ = cli.Close()
+		return fmt.Errorf("initialize: %w", err)
+	}
+
+	sc.mu.Lock()
+	sc.client = cli
+	sc.lost = lost
+	sc.refresh = refresh
+	sc.lastErr = ""
+	sc.connectedAt = time.Now()
+	sc.mu.Unlock()
+
+	return sc.syncTools(ctx)
+}
+
+// syncTools lists the remote tools and updates the registry: new or changed
+// tools are registered, removed ones are unregistered.
+func (sc *serverConn) syncTools(ctx context.Context) error {
+	sc.mu.RLock()
+	cli := sc.client
+	sc.mu.RUnlock()
+	if cli == nil {
+		return fmt.Errorf("not connected")
+	}
+
+	lctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	res, err := cli.ListTools(lctx, mcpgo.ListToolsRequest{})
+	if err != nil {
+		return fmt.Errorf("list tools: %w", err)
+	}
+
+	sc.mu.RLock()
+	prev := sc.tools
+	sc.mu.RUnlock()
+
+	next := make(map[string]remoteDef, len(res.Tools))
+	for _, t := range res.Tools {
+		name := registryName(sc.cfg.Name, t.Name)
+		if other, dup := next[name]; dup {
+			errors.Warn("mcp", fmt.Sprintf("skip tool %s/%s: name %s is already used by %s", sc.cfg.Name, t.Name, name, other.remote))
+			continue
+		}
+		raw, err := json.Marshal(t)
+		if err != nil {
+			errors.Warn("mcp", fmt.Sprintf("skip tool %s/%s: %v", sc.cfg.Name, t.Name, err))
+			continue
+		}
+		def := remoteDef{remote: t.Name, def: string(raw)}
+		if old, ok := prev[name]; ok && old == def {
+			if _, _, registered := sc.registry.Lookup(name); registered {
+				next[name] = def
+				continue
+			}
+		}
+		info, err := toolInfo(name, sc.cfg.Name, t)
+		if err != nil {
+			errors.Warn("mcp", fmt.Sprintf("skip tool %s/%s: %v", sc.cfg.Name, t.Name, err))
+			continue
+		}
+		next[name] = def
+
+		// Register with the defaults; admin overrides are applied by the registry
+		sc.registry.Register(&remoteTool{conn: sc, remoteName: t.Name, info: info}, registry.ToolMetadata{
+			Name:        name,
+			Description: info.Desc,
+			Category:    string(registry.CategoryMCP),
+			Enabled:     true,
+			AgentTypes:  sc.cfg.AgentTypes,
+		})
+	}
+
+	sc.mu.Lock()
+	sc.tools = next
+	sc.mu.Unlock()
+	for name := range prev {
+		if _, ok := next[name]; !ok {
+			sc.registry.Unregister(name)
+		}
+	}
+	return nil
+}
+
+// disconnect closes the client and removes the server's tools.
+func (sc *serverConn) disconnect(cause error) {
+	sc.mu.Lock()
+	cli := sc.client
+	tools := sc.tools
+	sc.client = nil
+	sc.tools = nil
+	if cause != nil {
+		sc.lastErr = cause.Error()
+	}
+	sc.mu.Unlock()
+
+	if cli != nil {
+		_ = cli.Close()
+	}
+	for name := range tools {
+		sc.registry.Unregister(name)
+	}
+}
+
+func (sc *serverConn) toolNames() []string {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	names := make([]string, 0, len(sc.tools))
+	for name := range sc.tools {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (sc *serverConn) status() ServerStatus {
+	names := sc.toolNames()
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	return ServerStatus{
+		Name:        sc.cfg.Name,
+		Transport:   sc.cfg.Transport,
+		Connected:   sc.client != nil,
+		Tools:       names,
+		LastError:   sc.lastErr,
+		ConnectedAt: sc.connectedAt,
+	}
+}
+
+// remoteTool adapts an MCP tool to an Eino InvokableTool. Calls go through
+// the server's current client so they survive reconnects.
+type remoteTool struct {
+	conn       *serverConn
+	remoteName string
+	info       *schema.ToolInfo
+}
+
+func (t *remoteTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return t.info, nil
+}
+
+func (t *remoteTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	t.conn.mu.RLock()
+	cli := t.conn.client
+	t.conn.mu.RUnlock()
+	if cli == nil {
+		return errorResult(fmt.Sprintf("mcp server %s is not connected", t.conn.cfg.Name)), nil
+	}
+
+	args := map[string]any{}
+	if strings.TrimSpace(argumentsInJSON) != "" {
+		if err := json.Unmarshal([]byte(argumentsInJSON), &args); err != nil {
+			return errorResult("invalid arguments: " + err.Error()), nil
+		}
+	}
+
+	cctx, cancel := context.WithTimeout(ctx, callTimeout)
+	defer cancel()
+	req := mcpgo.CallToolRequest{}
+	req.Params.Name = t.remoteName
+	req.Params.Arguments = args
+	res, err := cli.CallTool(cctx, req)
+	if err != nil {
+		return errorResult(err.Error()), nil
+	}
+
+	texts := make([]string, 0, len(res.Content))
+	for _, c := range res.Content {
+		switch v := c.(type) {
+		case mcpgo.TextContent:
+			texts = append(texts, v.Text)
+		case mcpgo.EmbeddedResource:
+			if r, ok := v.Resource.(mcpgo.TextResourceContents); ok {
+				texts = append(texts, r.Text)
+			}
+		}
+	}
+	out := map[string]any{
+		"success": !res.IsError,
+		"content": strings.Join(texts, "\n"),
+	}
+	if res.StructuredContent != nil {
+		out["structured"] = res.StructuredContent
+	}
+	if res.IsError {
+		out["error"] = out["content"]
+	}
+	b, _ := json.MarshalIndent(out, "", "  ")
+	return string(b), nil
+}
+
+var invalidNameRE = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// maxToolName is the name length accepted by model function-calling APIs.
+const maxToolName = 64
+
+// registryName namespaces a remote tool: mcp_<server>_<tool>. Longer names
+// are cut to maxToolName with a hash of the full name, so two long tool
+// names sharing a prefix stay distinct.
+func registryName(server, tool string) string {
+	name := "mcp_" + invalidNameRE.ReplaceAllString(server, "_") + "_" + invalidNameRE.ReplaceAllString(tool, "_")
+	if len(name) > maxToolName {
+		sum := sha256.Sum256([]byte(name))
+		suffix := "_" + hex.EncodeToString(sum[:4])
+		name = name[:maxToolName-len(suffix)] + suffix
+	}
+	return name
+}
+
+// toolInfo converts the MCP tool definition, including its input JSON
+// schema, into an Eino ToolInfo.
+func toolInfo(name, server string, t mcpgo.Tool) (*schema.ToolInfo, error) {
+	raw, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	var def struct {
+		InputSchema json.RawMessage `json:"inputSchema"`
+	}
+	if err := json.Unmarshal(raw, &def); err != nil {
+		return nil, err
+	}
+	s := &jsonschema.Schema{}
+	if len(def.InputSchema) > 0 && string(def.InputSchema) != "null" {
+		if err := json.Unmarshal(def.InputSchema, s); err != nil {
+			return nil, fmt.Errorf("input schema: %w", err)
+		}
+	}
+	if s.Type == "" {
+		s.Type = "object"
+	}
+
+	desc := t.Description
+	if desc == "" {
+		desc = t.Name
+	}
+	return &schema.ToolInfo{
+		Name:        name,
+		Desc:        fmt.Sprintf("[%s] %s", server, desc),
+		ParamsOneOf: schema.NewParamsOneOfByJSONSchema(s),
+	}, nil
+}
+
+// errorResult is the tool output of a failed call.
+func errorResult(msg string) string {
+	b, _ := json.Marshal(map[string]any{"success": false, "error": msg})
+	return string(b)
+}
+
+// Global manager instance.
+var globalManager *Manager
+
+// InitClients reads mcp_servers from the config and starts the manager.
+func InitClients(ctx context.Context) error {
+	var configs []ServerConfig
+	v, err := g.Cfg().Get(ctx, "mcp_servers")
+	if err != nil {
+		return err
+	}
+	if !v.IsNil() {
+		if err := v.Scan(&configs); err != nil {
+			return fmt.Errorf("invalid mcp_servers config: %w", err)
+		}
+	}
+	globalManager = NewManager(registry.Global())
+	globalManager.Start(ctx, configs)
+	if len(configs) > 0 {
+		errors.Info("mcp", fmt.Sprintf("starting %d mcp server connection(s)", len(configs)))
+	}
+	return nil
+}
+
+// GlobalManager returns the global manager.
+func GlobalManager() *Manager {
+	return globalManager
+}
@@ -0,0 +1,78 @@
+package mcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/internal/ai/registry"
+	"github.com/cloudwego/eino/components/tool"
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+func TestManagerMountsRemoteTools(t *testing.T) {
+	srv := server.NewMCPServer("test", "1.0.0", server.WithToolCapabilities(true))
+	srv.AddTool(
+		mcpgo.NewTool("echo", mcpgo.WithDescription("Echo the input"), mcpgo.WithString("text", mcpgo.Required())),
+		func(ctx context.Context, req mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+			return mcpgo.NewToolResultText("echo: " + req.GetString("text", "")), nil
+		},
+	)
+	ts := server.NewTestStreamableHTTPServer(srv)
+	defer ts.Close()
+
+	reg := registry.New()
+	m := NewManager(reg)
+	m.Start(context.Background(), []ServerConfig{{
+		Name:       "test",
+		Transport:  TransportHTTP,
+		URL:        ts.URL + "/mcp",
+		AgentTypes: []string{"chat"},
+	}})
+	defer m.Stop()
+
+	var bt tool.BaseTool
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(50 * time.Millisecond) {
+		if bt = reg.Get("mcp_test_echo"); bt != nil {
+			break
+		}
+	}
+	if bt == nil {
+		t.Fatalf("remote tool was not registered: %+v", m.Status())
+	}
+	meta, _ := reg.GetMetadata("mcp_test_echo")
+	if meta.Category != string(registry.CategoryMCP) || len(reg.GetAll("plan_execute")) != 0 {
+		t.Errorf("unexpected metadata: %+v", meta)
+	}
+
+	// 工具未变化时轮询不重新注册
+	sc := m.servers["test"]
+	if err := sc.syncTools(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if reg.Get("mcp_test_echo") != bt {
+		t.Error("unchanged tool was registered again")
+	}
+
+	out, err := bt.(tool.InvokableTool).InvokableRun(context.Background(), `{"text":"hi"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "echo: hi") || !strings.Contains(out, `"success": true`) {
+		t.Errorf("unexpected output: %s", out)
+	}
+}
+
+func TestRegistryNameKeepsLongNamesDistinct(t *testing.T) {
+	prefix := strings.Repeat("query_the_metrics_", 4)
+	a := registryName("prom", prefix+"by_service")
+	b := registryName("prom", prefix+"by_instance")
+	if a == b || len(a) != maxToolName || len(b) != maxToolName {
+		t.Errorf("registryName = %q, %q", a, b)
+	}
+	if got := registryName("my.server", "echo"); got != "mcp_my_server_echo" {
+		t.Errorf("registryName = %q", got)
+	}
+}
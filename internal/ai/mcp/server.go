@@ -0,0 +1,217 @@
+package mcp
+
+// server.go exposes the portal's own tools (registry.Global()) as an MCP
+// server, so IDE assistants and other agents can call them. Two transports
+// share the same server: streamable HTTP under /api/mcp (JWT protected by
+// the router) and stdio via internal/ai/cmd/mcp_cmd.
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/WyRainBow/ops-portal/internal/ai/errors"
+	"github.com/WyRainBow/ops-portal/internal/ai/registry"
+	"github.com/WyRainBow/ops-portal/utility/middleware"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/gogf/gf/v2/net/ghttp"
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	serverName    = "ops-portal"
+	serverVersion = "1.0.0"
+
+	// EndpointPath is where the streamable HTTP transport is mounted.
+	EndpointPath = "/api/mcp"
+)
+
+// Server publishes the enabled tools of a registry over MCP.
+//
+// The tool list is reconciled with the registry before every tools/list and
+// tools/call, so Enable/Disable and newly registered tools take effect
+// without a restart. Tools mounted from other MCP servers (category mcp)
+// are not re-exported.
+type Server struct {
+	registry *registry.Registry
+	mcp      *server.MCPServer
+
+	mu      sync.Mutex
+	schemas map[string]string // tool name -> published input schema
+}
+
+// NewServer creates an MCP server backed by reg.
+func NewServer(reg *registry.Registry) *Server {
+	s := &Server{
+		registry: reg,
+		schemas:  make(map[string]string),
+	}
+	hooks := &server.Hooks{}
+	hooks.AddBeforeListTools(func(ctx context.Context, _ any, _ *mcpgo.ListToolsRequest) {
+		s.Sync(ctx)
+	})
+	hooks.AddBeforeCallTool(func(ctx context.Context, _ any, _ *mcpgo.CallToolRequest) {
+		s.Sync(ctx)
+	})
+	s.mcp = server.NewMCPServer(serverName, serverVersion,
+		server.WithToolCapabilities(true),
+		server.WithRecovery(),
+		server.WithHooks(hooks),
+		// 禁用的工具不出现在 tools/list 中
+		server.WithToolFilter(func(ctx context.Context, tools []mcpgo.Tool) []mcpgo.Tool {
+			out := make([]mcpgo.Tool, 0, len(tools))
+			for _, t := range tools {
+				if s.registry.Get(t.Name) != nil {
+					out = append(out, t)
+				}
+			}
+			return out
+		}),
+	)
+	s.Sync(context.Background())
+	return s
+}
+
+// MCPServer returns the underlying mcp-go server.
+func (s *Server) MCPServer() *server.MCPServer {
+	return s.mcp
+}
+
+// Sync reconciles the published tools with the registry.
+func (s *Server) Sync(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	seen := make(map[string]bool)
+	var added []server.ServerTool
+	for _, meta := range s.registry.ListMetadata() {
+		if meta.Category == string(registry.CategoryMCP) {
+			continue
+		}
+		seen[meta.Name] = true
+		t := s.registry.Get(meta.Name)
+		if t == nil {
+			// disabled: keep whatever was published, the filter hides it
+			continue
+		}
+		def, err := s.toolDef(ctx, meta, t)
+		if err != nil {
+			errors.Warn("mcp-server", fmt.Sprintf("skip tool %s: %v", meta.Name, err))
+			continue
+		}
+		raw := string(def.RawInputSchema)
+		if s.schemas[meta.Name] == raw {
+			continue
+		}
+		s.schemas[meta.Name] = raw
+		added = append(added, server.ServerTool{Tool: def, Handler: s.callTool})
+	}
+
+	var removed []string
+	for name := range s.schemas {
+		if !seen[name] {
+			removed = append(removed, name)
+			delete(s.schemas, name)
+		}
+	}
+	if len(added) > 0 {
+		s.mcp.AddTools(added...)
+	}
+	if len(removed) > 0 {
+		s.mcp.DeleteTools(removed...)
+	}
+}
+
+func (s *Server) toolDef(ctx context.Context, meta registry.ToolMetadata, t tool.BaseTool) (mcpgo.Tool, error) {
+	info, err := t.Info(ctx)
+	if err != nil {
+		return mcpgo.Tool{}, err
+	}
+	raw := json.RawMessage(`{"type":"object","properties":{}}`)
+	if info.ParamsOneOf != nil {
+		js, err := info.ParamsOneOf.ToJSONSchema()
+		if err != nil {
+			return mcpgo.Tool{}, fmt.Errorf("input schema: %w", err)
+		}
+		if js != nil {
+			if js.Type == "" {
+				js.Type = "object"
+			}
+			if raw, err = json.Marshal(js); err != nil {
+				return mcpgo.Tool{}, err
+			}
+		}
+	}
+	desc := info.Desc
+	if desc == "" {
+		desc = meta.Description
+	}
+	return mcpgo.NewToolWithRawSchema(meta.Name, desc, raw), nil
+}
+
+func (s *Server) callTool(ctx context.Context, req mcpgo.CallToolRequest) (*mcpgo.CallToolResult, error) {
+	name := req.Params.Name
+	t := s.registry.Get(name)
+	if t == nil {
+		return mcpgo.NewToolResultError(fmt.Sprintf("tool %s is not available or disabled", name)), nil
+	}
+	inv, ok := t.(tool.InvokableTool)
+	if !ok {
+		return mcpgo.NewToolResultError(fmt.Sprintf("tool %s is not invokable", name)), nil
+	}
+
+	args := "{}"
+	if req.Params.Arguments != nil {
+		b, err := json.Marshal(req.Params.Arguments)
+		if err != nil {
+			return mcpgo.NewToolResultError("invalid arguments: " + err.Error()), nil
+		}
+		args = string(b)
+	}
+
+	caller := "anonymous"
+	if u := middleware.GetUserContext(ctx); u != nil {
+		caller = u.Username
+	}
+	errors.Info("mcp-server", fmt.Sprintf("call %s by %s", name, caller))
+
+	out, err := inv.InvokableRun(ctx, args)
+	if err != nil {
+		return mcpgo.NewToolResultErrorFromErr("tool execution failed", err), nil
+	}
+	res := mcpgo.NewToolResultText(out)
+	// 工具约定返回 {"success": false, ...} 表示失败
+	var status struct {
+		Success *bool `json:"success"`
+	}
+	if json.Unmarshal([]byte(out), &status) == nil && status.Success != nil && !*status.Success {
+		res.IsError = true
+	}
+	return res, nil
+}
+
+// HTTPHandler returns a GoFrame handler serving the streamable HTTP
+// transport. Authentication is left to the router middleware (JWTAuth),
+// whose user context is propagated into the tool calls.
+func (s *Server) HTTPHandler() ghttp.HandlerFunc {
+	h := server.NewStreamableHTTPServer(s.mcp, server.WithEndpointPath(EndpointPath))
+	return func(r *ghttp.Request) {
+		h.ServeHTTP(r.Response.BufferWriter, r.Request)
+	}
+}
+
+// Global server instance, built lazily from registry.Global().
+var (
+	globalServer     *Server
+	globalServerOnce sync.Once
+)
+
+// GlobalServer returns the MCP server publishing registry.Global().
+func GlobalServer() *Server {
+	globalServerOnce.Do(func() {
+		globalServer = NewServer(registry.Global())
+	})
+	return globalServer
+}
@@ -0,0 +1,72 @@
+package mcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/WyRainBow/ops-portal/internal/ai/registry"
+	"github.com/cloudwego/eino/components/tool/utils"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	mcpgo "github.com/mark3labs/mcp-go/mcp"
+)
+
+type greetInput struct {
+	Name string `json:"name" jsonschema:"description=who to greet"`
+}
+
+func TestServerPublishesEnabledTools(t *testing.T) {
+	reg := registry.New()
+	greet, err := utils.InferTool("greet", "Say hello", func(ctx context.Context, in *greetInput) (string, error) {
+		return `{"success": true, "message": "hello ` + in.Name + `"}`, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.Register(greet, registry.ToolMetadata{Name: "greet", Category: "utility", Enabled: true, AgentTypes: []string{"all"}})
+
+	ctx := context.Background()
+	cli, err := mcpclient.NewInProcessClient(NewServer(reg).MCPServer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	if err := cli.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	initReq := mcpgo.InitializeRequest{}
+	initReq.Params.ProtocolVersion = mcpgo.LATEST_PROTOCOL_VERSION
+	if _, err := cli.Initialize(ctx, initReq); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := cli.ListTools(ctx, mcpgo.ListToolsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Tools) != 1 || list.Tools[0].Name != "greet" || list.Tools[0].InputSchema.Properties["name"] == nil {
+		t.Fatalf("unexpected tools: %+v", list.Tools)
+	}
+
+	call := mcpgo.CallToolRequest{}
+	call.Params.Name = "greet"
+	call.Params.Arguments = map[string]any{"name": "ops"}
+	res, err := cli.CallTool(ctx, call)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.IsError || !strings.Contains(res.Content[0].(mcpgo.TextContent).Text, "hello ops") {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+
+	// 禁用后既不出现在列表中，也不能被调用
+	if err := reg.Disable("greet"); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ = cli.ListTools(ctx, mcpgo.ListToolsRequest{}); len(list.Tools) != 0 {
+		t.Errorf("disabled tool still listed: %+v", list.Tools)
+	}
+	if res, _ = cli.CallTool(ctx, call); res == nil || !res.IsError {
+		t.Errorf("disabled tool was callable: %+v", res)
+	}
+}
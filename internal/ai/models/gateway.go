@@ -0,0 +1,524 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	aierrors "github.com/WyRainBow/ops-portal/internal/ai/errors"
+	"github.com/WyRainBow/ops-portal/internal/metrics"
+	"github.com/WyRainBow/ops-portal/internal/resilience"
+	"github.com/cloudwego/eino-ext/components/model/openai"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// 模型网关：按角色路由到配置的 provider 列表，出错/超时时按顺序切换到下一个，
+// 每个 provider 有独立的熔断器，并记录调用次数、失败和延迟供 /api/admin/llm/providers 展示。
+//
+// 配置见 manifest/config/config.yaml 的 llm_gateway；未配置时沿用
+// dashscope_chat_model / ds_think_chat_model / ds_quick_chat_model 以及 LLM_PROVIDER 等环境变量。
+
+// Roles routed by the gateway.
+const (
+	RolePlanner    = "planner"
+	RoleExecutor   = "executor"
+	RoleReplanner  = "replanner"
+	RoleChat       = "chat"
+	RoleSummarizer = "summarizer"
+
+	routeDefault = "default"
+)
+
+const defaultProviderTimeout = 2 * time.Minute
+
+// OpenAI-compatible endpoints of the supported provider types.
+var providerBaseURLs = map[string]string{
+	"openai":    "https://api.openai.com/v1",
+	"deepseek":  "https://api.deepseek.com/v1",
+	"doubao":    "https://ark.cn-beijing.volces.com/api/v3",
+	"dashscope": "https://dashscope.aliyuncs.com/compatible-mode/v1",
+	"claude":    "https://api.anthropic.com/v1/",
+}
+
+// ProviderConfig is one model endpoint of the gateway.
+type ProviderConfig struct {
+	Name      string `json:"name"`
+	Type      string `json:"type"`     // openai, deepseek, doubao, dashscope, claude (all OpenAI-compatible)
+	BaseURL   string `json:"base_url"` // defaults by type
+	APIKey    string `json:"api_key"`
+	APIKeyEnv string `json:"api_key_env"` // read the key from this env variable instead
+	Model     string `json:"model"`
+	Timeout   string `json:"timeout"` // per call, or until the first chunk of a stream; default 2m
+}
+
+// BreakerConfig configures the per-provider circuit breaker.
+type BreakerConfig struct {
+	MaxFailures  int    `json:"max_failures"`  // default 3
+	ResetTimeout string `json:"reset_timeout"` // default 1m
+}
+
+// GatewayConfig is the llm_gateway config section.
+type GatewayConfig struct {
+	Providers []ProviderConfig    `json:"providers"`
+	Routes    map[string][]string `json:"routes"` // role -> provider names in order; "default" for other roles
+	Breaker   BreakerConfig       `json:"breaker"`
+}
+
+// ProviderHealth reports the state of one provider.
+type ProviderHealth struct {
+	Name                string     `json:"name"`
+	Type                string     `json:"type"`
+	Model               string     `json:"model"`
+	State               string     `json:"state"` // circuit breaker: closed, half-open, open
+	Requests            int64      `json:"requests"`
+	Failures            int64      `json:"failures"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	AvgLatencyMs        float64    `json:"avg_latency_ms"`
+	LastLatencyMs       float64    `json:"last_latency_ms"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastErrorAt         *time.Time `json:"last_error_at,omitempty"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+}
+
+type provider struct {
+	cfg     ProviderConfig
+	model   model.ToolCallingChatModel
+	timeout time.Duration
+	breaker *resilience.CircuitBreaker
+
+	mu      sync.Mutex
+	health  ProviderHealth
+	totalMs float64
+}
+
+func (p *provider) record(latency time.Duration, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	ms := float64(latency.Microseconds()) / 1000
+	p.health.Requests++
+	p.health.LastLatencyMs = ms
+	p.totalMs += ms
+	p.health.AvgLatencyMs = p.totalMs / float64(p.health.Requests)
+	if err != nil {
+		p.health.Failures++
+		p.health.ConsecutiveFailures++
+		p.health.LastError = err.Error()
+		p.health.LastErrorAt = &now
+	} else {
+		p.health.ConsecutiveFailures = 0
+		p.health.LastSuccessAt = &now
+	}
+}
+
+func (p *provider) snapshot() ProviderHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	h := p.health
+	h.State = p.breaker.State().String()
+	return h
+}
+
+// Gateway routes chat model calls of each role over its providers.
+type Gateway struct {
+	providers map[string]*provider
+	order     []string
+	routes    map[string][]string
+}
+
+type modelBuilder func(ctx context.Context, cfg ProviderConfig) (model.ToolCallingChatModel, error)
+
+func openAIModel(ctx context.Context, cfg ProviderConfig) (model.ToolCallingChatModel, error) {
+	return openai.NewChatModel(ctx, &openai.ChatModelConfig{
+		Model:   cfg.Model,
+		APIKey:  cfg.APIKey,
+		BaseURL: cfg.BaseURL,
+	})
+}
+
+// NewGateway builds a gateway from cfg. Providers without a model or API
+// key are skipped with a warning.
+func NewGateway(ctx context.Context, cfg GatewayConfig) (*Gateway, error) {
+	return newGateway(ctx, cfg, openAIModel)
+}
+
+func newGateway(ctx context.Context, cfg GatewayConfig, build modelBuilder) (*Gateway, error) {
+	cb := resilience.DefaultCircuitBreakerConfig()
+	cb.MaxFailures = 3
+	cb.HalfOpenAttempts = 1
+	if cfg.Breaker.MaxFailures > 0 {
+		cb.MaxFailures = cfg.Breaker.MaxFailures
+	}
+	if cfg.Breaker.ResetTimeout != "" {
+		d, err := time.ParseDuration(cfg.Breaker.ResetTimeout)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid llm_gateway.breaker.reset_timeout %q", cfg.Breaker.ResetTimeout)
+		}
+		cb.ResetTimeout = d
+	}
+
+	gw := &Gateway{providers: map[string]*provider{}, routes: map[string][]string{}}
+	for _, pc := range cfg.Providers {
+		pc.Name = strings.TrimSpace(pc.Name)
+		pc.Type = strings.ToLower(strings.TrimSpace(pc.Type))
+		if pc.Type == "" {
+			pc.Type = "openai"
+		}
+		if pc.APIKeyEnv != "" {
+			pc.APIKey = strings.TrimSpace(os.Getenv(pc.APIKeyEnv))
+		}
+		if pc.BaseURL == "" {
+			pc.BaseURL = providerBaseURLs[pc.Type]
+		}
+		if pc.Name == "" {
+			return nil, fmt.Errorf("llm_gateway provider without name")
+		}
+		if _, dup := gw.providers[pc.Name]; dup {
+			return nil, fmt.Errorf("duplicate llm_gateway provider %q", pc.Name)
+		}
+		if pc.Model == "" || pc.APIKey == "" || pc.BaseURL == "" {
+			aierrors.Warn("llm_gateway", fmt.Sprintf("provider %s skipped: model, api key and base_url are required", pc.Name))
+			continue
+		}
+		timeout := defaultProviderTimeout
+		if pc.Timeout != "" {
+			d, err := time.ParseDuration(pc.Timeout)
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid timeout %q for provider %s", pc.Timeout, pc.Name)
+			}
+			timeout = d
+		}
+		m, err := build(ctx, pc)
+		if err != nil {
+			return nil, fmt.Errorf("provider %s: %v", pc.Name, err)
+		}
+		breakerCfg := *cb
+		gw.providers[pc.Name] = &provider{
+			cfg:     pc,
+			model:   m,
+			timeout: timeout,
+			breaker: resilience.NewCircuitBreaker("llm:"+pc.Name, &breakerCfg),
+			health:  ProviderHealth{Name: pc.Name, Type: pc.Type, Model: pc.Model},
+		}
+		gw.order = append(gw.order, pc.Name)
+	}
+	if len(gw.order) == 0 {
+		return nil, fmt.Errorf("no usable LLM provider configured")
+	}
+
+	for role, names := range cfg.Routes {
+		for _, n := range names {
+			if _, ok := gw.providers[n]; ok {
+				gw.routes[role] = append(gw.routes[role], n)
+			}
+		}
+	}
+	if len(gw.routes[routeDefault]) == 0 {
+		gw.routes[routeDefault] = gw.order
+	}
+	return gw, nil
+}
+
+// Route returns the provider names tried for role, in order.
+func (gw *Gateway) Route(role string) []string {
+	if r := gw.routes[role]; len(r) > 0 {
+		return r
+	}
+	return gw.routes[routeDefault]
+}
+
+// Routes returns the effective route of every known role.
+func (gw *Gateway) Routes() map[string][]string {
+	out := map[string][]string{}
+	for _, role := range []string{RolePlanner, RoleExecutor, RoleReplanner, RoleChat, RoleSummarizer, routeDefault} {
+		out[role] = gw.Route(role)
+	}
+	return out
+}
+
+// Health reports every provider in config order.
+func (gw *Gateway) Health() []ProviderHealth {
+	out := make([]ProviderHealth, 0, len(gw.order))
+	for _, n := range gw.order {
+		out = append(out, gw.providers[n].snapshot())
+	}
+	return out
+}
+
+// Model returns the chat model of role.
+func (gw *Gateway) Model(role string) model.ToolCallingChatModel {
+	return &routedModel{gw: gw, role: role}
+}
+
+// routedModel is the model.ToolCallingChatModel handed to agents.
+type routedModel struct {
+	gw    *Gateway
+	role  string
+	tools []*schema.ToolInfo
+}
+
+func (m *routedModel) WithTools(tools []*schema.ToolInfo) (model.ToolCallingChatModel, error) {
+	return &routedModel{gw: m.gw, role: m.role, tools: tools}, nil
+}
+
+func (m *routedModel) GetType() string { return "Gateway" }
+
+// IsCallbacksEnabled is true because the provider models report callbacks
+// themselves; eino must not report them twice.
+func (m *routedModel) IsCallbacksEnabled() bool { return true }
+
+func (m *routedModel) providerModel(p *provider) (model.ToolCallingChatModel, error) {
+	if m.tools == nil {
+		return p.model, nil
+	}
+	return p.model.WithTools(m.tools)
+}
+
+// try runs call on each provider of the route until one succeeds. Providers
+// with an open circuit are skipped; caller cancellation stops immediately
+// and is not counted as a provider failure.
+func (m *routedModel) try(ctx context.Context, call func(ctx context.Context, p *provider) error) error {
+	var errs []string
+	for _, name := range m.gw.Route(m.role) {
+		p := m.gw.providers[name]
+		var callErr error
+		start := time.Now()
+		err := p.breaker.Execute(func() error {
+			callErr = call(ctx, p)
+			if callErr != nil && ctx.Err() != nil {
+				return nil
+			}
+			return callErr
+		})
+		if errors.Is(err, resilience.ErrCircuitBreakerOpen) {
+			errs = append(errs, name+": circuit open")
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		elapsed := time.Since(start)
+		p.record(elapsed, callErr)
+		status := "success"
+		if callErr != nil {
+			status = "error"
+		}
+		labels := map[string]string{"provider": name, "model": p.cfg.Model, "role": m.role, "status": status}
+		metrics.Global().Increment(metrics.LLMRequestsTotal, labels)
+		metrics.Global().Timing(metrics.LLMDurationSeconds, elapsed, labels)
+		if callErr == nil {
+			return nil
+		}
+		aierrors.Warn("llm_gateway", fmt.Sprintf("%s via %s failed after %s: %v", m.role, name, elapsed.Round(time.Millisecond), callErr))
+		errs = append(errs, fmt.Sprintf("%s: %v", name, callErr))
+	}
+	return fmt.Errorf("all LLM providers failed for %s: %s", m.role, strings.Join(errs, "; "))
+}
+
+func (m *routedModel) Generate(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.Message, error) {
+	var out *schema.Message
+	err := m.try(ctx, func(ctx context.Context, p *provider) error {
+		cm, err := m.providerModel(p)
+		if err != nil {
+			return err
+		}
+		cctx, cancel := context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+		msg, err := cm.Generate(cctx, input, opts...)
+		if err != nil {
+			return err
+		}
+		out = msg
+		return nil
+	})
+	return out, err
+}
+
+// Stream fails over until a provider delivers its first chunk; the
+// provider timeout only applies to that first chunk.
+func (m *routedModel) Stream(ctx context.Context, input []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	var out *schema.StreamReader[*schema.Message]
+	err := m.try(ctx, func(ctx context.Context, p *provider) error {
+		cm, err := m.providerModel(p)
+		if err != nil {
+			return err
+		}
+		sctx, cancel := context.WithCancel(ctx)
+		timer := time.AfterFunc(p.timeout, cancel)
+		sr, err := cm.Stream(sctx, input, opts...)
+		if err != nil {
+			timer.Stop()
+			cancel()
+			return err
+		}
+		first, err := sr.Recv()
+		if !timer.Stop() && err != nil {
+			err = fmt.Errorf("no response within %s: %w", p.timeout, err)
+		}
+		if err != nil && err != io.EOF {
+			sr.Close()
+			cancel()
+			return err
+		}
+
+		reader, writer := schema.Pipe[*schema.Message](8)
+		go func() {
+			defer cancel()
+			defer sr.Close()
+			defer writer.Close()
+			if err == io.EOF {
+				return
+			}
+			if writer.Send(first, nil) {
+				return
+			}
+			for {
+				msg, rerr := sr.Recv()
+				if rerr == io.EOF {
+					return
+				}
+				if writer.Send(msg, rerr) || rerr != nil {
+					return
+				}
+			}
+		}()
+		out = reader
+		return nil
+	})
+	return out, err
+}
+
+var (
+	gatewayMu     sync.Mutex
+	globalGateway *Gateway
+)
+
+// DefaultGateway returns the gateway built from config on first use.
+func DefaultGateway(ctx context.Context) (*Gateway, error) {
+	gatewayMu.Lock()
+	defer gatewayMu.Unlock()
+	if globalGateway != nil {
+		return globalGateway, nil
+	}
+	cfg, err := loadGatewayConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	gw, err := NewGateway(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	aierrors.Info("llm_gateway", fmt.Sprintf("providers %v, routes %v", gw.order, gw.Routes()))
+	globalGateway = gw
+	return gw, nil
+}
+
+// ForRole returns the gateway model of role, e.g. models.ForRole(ctx, models.RolePlanner).
+func ForRole(ctx context.Context, role string) (model.ToolCallingChatModel, error) {
+	gw, err := DefaultGateway(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return gw.Model(role), nil
+}
+
+func loadGatewayConfig(ctx context.Context) (GatewayConfig, error) {
+	var cfg GatewayConfig
+	if v, err := g.Cfg().Get(ctx, "llm_gateway"); err == nil && !v.IsNil() {
+		if err := v.Scan(&cfg); err != nil {
+			return cfg, fmt.Errorf("invalid llm_gateway config: %v", err)
+		}
+	}
+	if len(cfg.Providers) == 0 {
+		cfg.Providers = legacyProviders(ctx)
+		if len(cfg.Routes) == 0 {
+			cfg.Routes = legacyRoutes()
+		}
+	}
+	return cfg, nil
+}
+
+// legacyProviders maps the single-model config sections used before the
+// gateway existed.
+func legacyProviders(ctx context.Context) []ProviderConfig {
+	get := func(cfgKey, envKey string) string {
+		v, _ := getCfgOrEnv(ctx, cfgKey, envKey)
+		return v
+	}
+	out := []ProviderConfig{
+		{
+			Name:    "dashscope",
+			Type:    "dashscope",
+			Model:   get("dashscope_chat_model.model", "DASHSCOPE_MODEL"),
+			APIKey:  get("dashscope_chat_model.api_key", "DASHSCOPE_API_KEY"),
+			BaseURL: get("dashscope_chat_model.base_url", "DASHSCOPE_BASE_URL"),
+		},
+		{
+			Name:    "ds_think",
+			Type:    "doubao",
+			Model:   get("ds_think_chat_model.model", "OPS_PORTAL_DS_THINK_MODEL"),
+			APIKey:  get("ds_think_chat_model.api_key", "OPS_PORTAL_DS_THINK_API_KEY"),
+			BaseURL: get("ds_think_chat_model.base_url", "OPS_PORTAL_DS_THINK_BASE_URL"),
+		},
+		{
+			Name:    "ds_quick",
+			Type:    "doubao",
+			Model:   get("ds_quick_chat_model.model", "OPS_PORTAL_DS_QUICK_MODEL"),
+			APIKey:  get("ds_quick_chat_model.api_key", "OPS_PORTAL_DS_QUICK_API_KEY"),
+			BaseURL: get("ds_quick_chat_model.base_url", "OPS_PORTAL_DS_QUICK_BASE_URL"),
+		},
+	}
+	if out[0].Model == "" {
+		out[0].Model = "qwen-max"
+	}
+
+	// LLM_PROVIDER / LLM_API_KEY / LLM_BASE_URL / LLM_MODEL (see internal/config)
+	llm := ProviderConfig{
+		Name:    "llm",
+		Type:    strings.ToLower(strings.TrimSpace(os.Getenv("LLM_PROVIDER"))),
+		Model:   strings.TrimSpace(os.Getenv("LLM_MODEL")),
+		APIKey:  strings.TrimSpace(os.Getenv("LLM_API_KEY")),
+		BaseURL: strings.TrimSpace(os.Getenv("LLM_BASE_URL")),
+	}
+	if llm.Type == "" {
+		llm.Type = "doubao"
+	}
+	if llm.Type == "doubao" {
+		if llm.APIKey == "" {
+			llm.APIKey = strings.TrimSpace(os.Getenv("DOUBAO_API_KEY"))
+		}
+		if llm.BaseURL == "" {
+			llm.BaseURL = strings.TrimSpace(os.Getenv("DOUBAO_BASE_URL"))
+		}
+	}
+	if llm.APIKey != "" && llm.Model != "" {
+		out = append(out, llm)
+	}
+
+	usable := out[:0]
+	for _, p := range out {
+		if p.APIKey != "" && p.Model != "" {
+			usable = append(usable, p)
+		}
+	}
+	return usable
+}
+
+// legacyRoutes keeps the model order the agents used before the gateway,
+// with the other providers as fallbacks.
+func legacyRoutes() map[string][]string {
+	return map[string][]string{
+		RolePlanner:    {"dashscope", "ds_think", "llm"},
+		RoleReplanner:  {"dashscope", "ds_think", "llm"},
+		RoleExecutor:   {"dashscope", "ds_quick", "llm"},
+		RoleChat:       {"ds_quick", "dashscope", "llm"},
+		RoleSummarizer: {"ds_quick", "dashscope", "llm"},
+	}
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+type fakeModel struct {
+	name  string
+	err   error
+	delay time.Duration
+	calls int
+}
+
+func (f *fakeModel) Generate(ctx context.Context, _ []*schema.Message, _ ...model.Option) (*schema.Message, error) {
+	f.calls++
+	if f.delay > 0 {
+		select {
+		case <-time.After(f.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if f.err != nil {
+		return nil, f.err
+	}
+	return schema.AssistantMessage(f.name, nil), nil
+}
+
+func (f *fakeModel) Stream(ctx context.Context, in []*schema.Message, opts ...model.Option) (*schema.StreamReader[*schema.Message], error) {
+	msg, err := f.Generate(ctx, in, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return schema.StreamReaderFromArray([]*schema.Message{msg, schema.AssistantMessage("!", nil)}), nil
+}
+
+func (f *fakeModel) WithTools([]*schema.ToolInfo) (model.ToolCallingChatModel, error) { return f, nil }
+
+func newTestGateway(t *testing.T, fakes map[string]*fakeModel, routes map[string][]string) *Gateway {
+	t.Helper()
+	cfg := GatewayConfig{Routes: routes, Breaker: BreakerConfig{MaxFailures: 2, ResetTimeout: "1h"}}
+	for _, name := range []string{"primary", "backup"} {
+		cfg.Providers = append(cfg.Providers, ProviderConfig{Name: name, Model: name, APIKey: "k", Timeout: "50ms"})
+	}
+	gw, err := newGateway(context.Background(), cfg, func(_ context.Context, pc ProviderConfig) (model.ToolCallingChatModel, error) {
+		return fakes[pc.Name], nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gw
+}
+
+func TestGatewayFailover(t *testing.T) {
+	primary := &fakeModel{name: "primary", err: errors.New("502 bad gateway")}
+	backup := &fakeModel{name: "backup"}
+	gw := newTestGateway(t, map[string]*fakeModel{"primary": primary, "backup": backup},
+		map[string][]string{RolePlanner: {"primary", "backup"}})
+	m := gw.Model(RolePlanner)
+
+	for i := 0; i < 3; i++ {
+		msg, err := m.Generate(context.Background(), nil)
+		if err != nil || msg.Content != "backup" {
+			t.Fatalf("call %d: got %v, %v", i, msg, err)
+		}
+	}
+	// the breaker opens after two failures, so the third call skips primary
+	if primary.calls != 2 {
+		t.Errorf("primary called %d times, want 2", primary.calls)
+	}
+	h := gw.Health()
+	if h[0].State != "open" || h[0].Failures != 2 || h[1].Requests != 3 || h[1].LastSuccessAt == nil {
+		t.Errorf("unexpected health: %+v", h)
+	}
+}
+
+func TestGatewayTimeoutAndStream(t *testing.T) {
+	primary := &fakeModel{name: "primary", delay: time.Second}
+	backup := &fakeModel{name: "backup"}
+	gw := newTestGateway(t, map[string]*fakeModel{"primary": primary, "backup": backup}, nil)
+
+	// roles without a route use all providers in config order
+	sr, err := gw.Model(RoleChat).Stream(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got string
+	for {
+		msg, err := sr.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got += msg.Content
+	}
+	if got != "backup!" {
+		t.Errorf("stream = %q", got)
+	}
+	if h := gw.Health(); h[0].Failures != 1 {
+		t.Errorf("timeout not recorded: %+v", h[0])
+	}
+
+	backup.err = errors.New("boom")
+	primary.delay = 0
+	primary.err = errors.New("boom")
+	if _, err := gw.Model(RoleChat).Generate(context.Background(), nil); err == nil {
+		t.Error("expected error when all providers fail")
+	}
+}
+
+func TestGatewayCallerCancel(t *testing.T) {
+	primary := &fakeModel{name: "primary", delay: time.Second}
+	gw := newTestGateway(t, map[string]*fakeModel{"primary": primary, "backup": {name: "backup"}}, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := gw.Model(RoleChat).Generate(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if h := gw.Health(); h[0].Failures != 0 {
+		t.Errorf("caller cancellation counted as failure: %+v", h[0])
+	}
+}
@@ -0,0 +1,154 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/internal/ai/errors"
+	"github.com/WyRainBow/ops-portal/utility/middleware"
+	"github.com/gogf/gf/v2/util/guid"
+)
+
+// SSE events sent through the run notifier.
+const (
+	EventApprovalRequired = "approval_required"
+	EventApprovalResolved = "approval_resolved"
+)
+
+// Approval is a pending request for a human to confirm a tool call.
+type Approval struct {
+	ID          string         `json:"approval_id"`
+	RunID       string         `json:"run_id"`
+	Tool        string         `json:"tool"`
+	Arguments   map[string]any `json:"arguments"`
+	RequestedBy string         `json:"requested_by,omitempty"` // user driving the run
+	CreatedAt   time.Time      `json:"created_at"`
+	ExpiresAt   time.Time      `json:"expires_at"`
+
+	ownerID  string
+	decision chan approvalDecision
+}
+
+type approvalDecision struct {
+	approved bool
+	by       string
+	comment  string
+}
+
+// Approvals keeps the pending approvals.
+type Approvals struct {
+	mu      sync.Mutex
+	pending map[string]*Approval
+}
+
+// NewApprovals creates an empty store.
+func NewApprovals() *Approvals {
+	return &Approvals{pending: make(map[string]*Approval)}
+}
+
+// Request asks the user of run to confirm the call and blocks until they
+// decide, the request times out or ctx is done.
+func (a *Approvals) Request(ctx context.Context, run *Run, tool string, args map[string]any, timeout time.Duration) error {
+	if run == nil || run.Notify == nil {
+		return &Denied{Tool: tool, Reason: "requires human approval, which is only available in the interactive chat stream"}
+	}
+	now := time.Now()
+	ap := &Approval{
+		ID:        guid.S(),
+		RunID:     run.ID,
+		Tool:      tool,
+		Arguments: args,
+		CreatedAt: now,
+		ExpiresAt: now.Add(timeout),
+		decision:  make(chan approvalDecision, 1),
+	}
+	if u := middleware.GetUserContext(ctx); u != nil {
+		ap.RequestedBy = u.Username
+		ap.ownerID = u.UserID
+	}
+
+	a.mu.Lock()
+	a.pending[ap.ID] = ap
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		delete(a.pending, ap.ID)
+		a.mu.Unlock()
+	}()
+
+	errors.Info("policy", fmt.Sprintf("approval %s requested for %s (run %s)", ap.ID, tool, run.ID))
+	run.Notify(EventApprovalRequired, ap)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var d approvalDecision
+	select {
+	case d = <-ap.decision:
+	case <-timer.C:
+		d = approvalDecision{comment: "approval timed out"}
+	case <-ctx.Done():
+		return &Denied{Tool: tool, Reason: "run cancelled while waiting for approval"}
+	}
+
+	run.Notify(EventApprovalResolved, map[string]any{
+		"approval_id": ap.ID,
+		"tool":        tool,
+		"approved":    d.approved,
+		"by":          d.by,
+		"comment":     d.comment,
+	})
+	errors.Info("policy", fmt.Sprintf("approval %s for %s: approved=%v by %q", ap.ID, tool, d.approved, d.by))
+	if !d.approved {
+		reason := "rejected by " + d.by
+		if d.by == "" {
+			reason = d.comment
+		} else if d.comment != "" {
+			reason += ": " + d.comment
+		}
+		return &Denied{Tool: tool, Reason: reason}
+	}
+	return nil
+}
+
+// Resolve records a decision. Only the user driving the run or an admin may
+// decide.
+func (a *Approvals) Resolve(id string, user *middleware.UserContext, approved bool, comment string) error {
+	if user == nil {
+		return fmt.Errorf("未提供有效的认证信息")
+	}
+	a.mu.Lock()
+	ap, ok := a.pending[id]
+	if ok && ap.ownerID != "" && ap.ownerID != user.UserID && user.Role != "admin" {
+		a.mu.Unlock()
+		return fmt.Errorf("权限不足")
+	}
+	if ok {
+		delete(a.pending, id)
+	}
+	a.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("审批不存在或已处理: %s", id)
+	}
+	ap.decision <- approvalDecision{approved: approved, by: user.Username, comment: comment}
+	return nil
+}
+
+// Pending lists the pending approvals visible to user.
+func (a *Approvals) Pending(user *middleware.UserContext) []Approval {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	out := make([]Approval, 0, len(a.pending))
+	for _, ap := range a.pending {
+		if user == nil || (ap.ownerID != "" && ap.ownerID != user.UserID && user.Role != "admin") {
+			continue
+		}
+		out = append(out, *ap)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
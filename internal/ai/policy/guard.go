@@ -0,0 +1,52 @@
+package policy
+
+import (
+	"context"
+	stderrors "errors"
+
+	"github.com/WyRainBow/ops-portal/internal/ai/errors"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// guardedTool checks the engine's policy before every call.
+type guardedTool struct {
+	name   string
+	inner  tool.InvokableTool
+	engine *Engine
+}
+
+// Wrap returns t guarded by the global engine. Tools that are not invokable
+// are returned unchanged.
+func Wrap(name string, t tool.BaseTool) tool.BaseTool {
+	inv, ok := t.(tool.InvokableTool)
+	if !ok {
+		return t
+	}
+	if g, ok := t.(*guardedTool); ok {
+		return g
+	}
+	return &guardedTool{name: name, inner: inv, engine: Global()}
+}
+
+func (g *guardedTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return g.inner.Info(ctx)
+}
+
+func (g *guardedTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	if err := g.engine.Check(ctx, g.name, argumentsInJSON); err != nil {
+		var denied *Denied
+		if stderrors.As(err, &denied) {
+			errors.Warn("policy", denied.Error())
+			// 以工具结果的形式返回，让 Agent 可以调整参数或换用其它工具
+			return errors.NewToolError(g.name, "policy denied: "+denied.Reason, nil).ToJSON(), nil
+		}
+		return "", err
+	}
+	return g.inner.InvokableRun(ctx, argumentsInJSON, opts...)
+}
+
+// Unwrap returns the guarded tool.
+func (g *guardedTool) Unwrap() tool.InvokableTool {
+	return g.inner
+}
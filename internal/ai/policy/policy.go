@@ -0,0 +1,306 @@
+// Package policy enforces per-tool access policies for agent tool calls:
+// which portal roles may trigger a tool, constraints on its arguments, a
+// per-run call budget and human approval for risky tools.
+//
+// Policies come from built-in defaults overlaid with tool_policies in the
+// config. Registry.Register wraps every tool with Wrap, so the checks apply
+// to all agents and to the MCP server alike.
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/internal/ai/errors"
+	"github.com/WyRainBow/ops-portal/utility/middleware"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// RoleSystem is the role of runs ops-portal starts itself, e.g. alert
+// diagnosis triggered by Alertmanager. It must be attached with WithSystem.
+const RoleSystem = "system"
+
+// RoleAnonymous is the role of calls with neither a portal user nor the
+// system identity, e.g. CLIs and the stdio MCP server.
+const RoleAnonymous = "anonymous"
+
+type systemKey struct{}
+
+// WithSystem marks ctx as a run started by ops-portal itself, so its tool
+// calls are checked as RoleSystem.
+func WithSystem(ctx context.Context) context.Context {
+	return context.WithValue(ctx, systemKey{}, true)
+}
+
+const defaultApprovalTimeout = 5 * time.Minute
+
+// ArgConstraint restricts one top-level argument of a tool call.
+type ArgConstraint struct {
+	Field     string   `json:"field"`
+	Required  bool     `json:"required"`  // must be present and non-empty
+	Forbidden bool     `json:"forbidden"` // must be absent or empty
+	Pattern   string   `json:"pattern"`   // regexp the value must match
+	Enum      []string `json:"enum"`      // allowed values
+	Message   string   `json:"message"`   // shown to the model on violation
+
+	re *regexp.Regexp
+}
+
+// Policy is the declarative policy of one tool.
+type Policy struct {
+	Roles           []string            `json:"roles"`             // empty: any role
+	Args            []ArgConstraint     `json:"args"`              // argument constraints
+	MaxCallsPerRun  int                 `json:"max_calls_per_run"` // 0: unlimited
+	RequireApproval bool                `json:"require_approval"`  // always ask a human
+	ApprovalWhen    map[string][]string `json:"approval_when"`     // ask when an argument has one of these values
+	ApprovalTimeout string              `json:"approval_timeout"`  // e.g. "5m"
+
+	timeout time.Duration
+	invalid error
+}
+
+func (p *Policy) compile() {
+	p.timeout = defaultApprovalTimeout
+	if p.ApprovalTimeout != "" {
+		d, err := time.ParseDuration(p.ApprovalTimeout)
+		if err != nil || d <= 0 {
+			p.invalid = fmt.Errorf("invalid approval_timeout %q", p.ApprovalTimeout)
+			return
+		}
+		p.timeout = d
+	}
+	for i := range p.Args {
+		c := &p.Args[i]
+		if c.Pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(c.Pattern)
+		if err != nil {
+			p.invalid = fmt.Errorf("invalid pattern for %s: %v", c.Field, err)
+			return
+		}
+		c.re = re
+	}
+}
+
+// defaultPolicies protect the built-in tools when nothing is configured.
+func defaultPolicies() map[string]Policy {
+	lokiJob := []ArgConstraint{{
+		Field:   "query",
+		Pattern: `job\s*=~?\s*"`,
+		Message: `LogQL query must select a job, e.g. {job="resume-backend"}`,
+	}}
+	return map[string]Policy{
+		"mysql_crud": {
+			Roles: []string{"admin"},
+			Args: []ArgConstraint{
+				{Field: "dsn", Forbidden: true, Message: "raw DSN is not allowed, use a named connection"},
+				{Field: "connection", Required: true, Message: "connection (a name from mysql_connections) is required"},
+			},
+			MaxCallsPerRun: 10,
+			ApprovalWhen:   map[string][]string{"operate_type": {"insert", "update", "delete"}},
+		},
+		"query_loki_logs":     {Args: lokiJob, MaxCallsPerRun: 30},
+		"loki_log_patterns":   {Args: lokiJob, MaxCallsPerRun: 30},
+		"db_readonly_query":   {MaxCallsPerRun: 20},
+		"run_host_diagnostic": {Roles: []string{"admin", RoleSystem}, MaxCallsPerRun: 20},
+		"http_probe":          {MaxCallsPerRun: 20},
+		"recent_changes":      {MaxCallsPerRun: 20},
+		"grafana_panel_data":  {MaxCallsPerRun: 20},
+		"grafana_annotate":    {MaxCallsPerRun: 5},
+	}
+}
+
+// Denied is returned when a policy rejects a call.
+type Denied struct {
+	Tool   string
+	Reason string
+}
+
+func (d *Denied) Error() string {
+	return fmt.Sprintf("policy denied %s: %s", d.Tool, d.Reason)
+}
+
+// Engine evaluates tool policies.
+type Engine struct {
+	mu        sync.RWMutex
+	policies  map[string]Policy
+	approvals *Approvals
+}
+
+// NewEngine creates an engine with the given policies.
+func NewEngine(policies map[string]Policy) *Engine {
+	e := &Engine{approvals: NewApprovals()}
+	e.SetPolicies(policies)
+	return e
+}
+
+// SetPolicies replaces the policy set.
+func (e *Engine) SetPolicies(policies map[string]Policy) {
+	compiled := make(map[string]Policy, len(policies))
+	for name, p := range policies {
+		p.compile()
+		if p.invalid != nil {
+			errors.Warn("policy", fmt.Sprintf("%s: %v (all calls will be denied)", name, p.invalid))
+		}
+		compiled[name] = p
+	}
+	e.mu.Lock()
+	e.policies = compiled
+	e.mu.Unlock()
+}
+
+// Policy returns the policy of a tool.
+func (e *Engine) Policy(name string) (Policy, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	p, ok := e.policies[name]
+	return p, ok
+}
+
+// Approvals returns the pending approval store of the engine.
+func (e *Engine) Approvals() *Approvals {
+	return e.approvals
+}
+
+// Check authorizes one call of tool with the JSON arguments. It blocks while
+// waiting for human approval. A *Denied error means the call must not run.
+func (e *Engine) Check(ctx context.Context, tool, argumentsInJSON string) error {
+	p, ok := e.Policy(tool)
+	if !ok {
+		return nil
+	}
+	if p.invalid != nil {
+		return &Denied{Tool: tool, Reason: "policy misconfigured: " + p.invalid.Error()}
+	}
+
+	role := RoleAnonymous
+	if u := middleware.GetUserContext(ctx); u != nil {
+		role = u.Role
+	} else if system, _ := ctx.Value(systemKey{}).(bool); system {
+		role = RoleSystem
+	}
+	if len(p.Roles) > 0 && !contains(p.Roles, role) {
+		return &Denied{Tool: tool, Reason: fmt.Sprintf("role %q may not use this tool", role)}
+	}
+
+	args := map[string]any{}
+	if strings.TrimSpace(argumentsInJSON) != "" {
+		if err := json.Unmarshal([]byte(argumentsInJSON), &args); err != nil {
+			return &Denied{Tool: tool, Reason: "arguments are not a JSON object"}
+		}
+	}
+	for _, c := range p.Args {
+		if err := c.check(args); err != "" {
+			return &Denied{Tool: tool, Reason: err}
+		}
+	}
+
+	run := RunFromContext(ctx)
+	if p.MaxCallsPerRun > 0 && run != nil {
+		if n := run.count(tool); n > p.MaxCallsPerRun {
+			return &Denied{Tool: tool, Reason: fmt.Sprintf("call budget exceeded (%d per run)", p.MaxCallsPerRun)}
+		}
+	}
+
+	if p.needsApproval(args) {
+		return e.approvals.Request(ctx, run, tool, args, p.timeout)
+	}
+	return nil
+}
+
+func (p Policy) needsApproval(args map[string]any) bool {
+	if p.RequireApproval {
+		return true
+	}
+	for field, values := range p.ApprovalWhen {
+		if v := argString(args[field]); v != "" && contains(values, strings.ToLower(v)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c ArgConstraint) check(args map[string]any) string {
+	v := argString(args[c.Field])
+	fail := func(def string) string {
+		if c.Message != "" {
+			return c.Message
+		}
+		return def
+	}
+	if c.Forbidden && v != "" {
+		return fail(fmt.Sprintf("argument %s is not allowed", c.Field))
+	}
+	if v == "" {
+		if c.Required {
+			return fail(fmt.Sprintf("argument %s is required", c.Field))
+		}
+		return ""
+	}
+	if len(c.Enum) > 0 && !contains(c.Enum, v) {
+		return fail(fmt.Sprintf("argument %s must be one of %v", c.Field, c.Enum))
+	}
+	if c.re != nil && !c.re.MatchString(v) {
+		return fail(fmt.Sprintf("argument %s must match %s", c.Field, c.Pattern))
+	}
+	return ""
+}
+
+func argString(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(x)
+	default:
+		b, _ := json.Marshal(x)
+		return string(b)
+	}
+}
+
+func contains(list []string, v string) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	globalEngine *Engine
+	globalOnce   sync.Once
+)
+
+// Global returns the engine loaded from the defaults and tool_policies.
+func Global() *Engine {
+	globalOnce.Do(func() {
+		globalEngine = NewEngine(loadPolicies(context.Background()))
+	})
+	return globalEngine
+}
+
+// loadPolicies overlays tool_policies from the config on the defaults. A
+// configured tool replaces its default policy entirely.
+func loadPolicies(ctx context.Context) map[string]Policy {
+	policies := defaultPolicies()
+	v, err := g.Cfg().Get(ctx, "tool_policies")
+	if err != nil || v == nil || v.IsNil() {
+		return policies
+	}
+	var configured map[string]Policy
+	if err := v.Scan(&configured); err != nil {
+		errors.Warn("policy", fmt.Sprintf("invalid tool_policies config: %v", err))
+		return policies
+	}
+	for name, p := range configured {
+		policies[name] = p
+	}
+	return policies
+}
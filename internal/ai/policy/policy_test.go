@@ -0,0 +1,79 @@
+package policy
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/utility/middleware"
+)
+
+func userCtx(role string) context.Context {
+	return middleware.SetUserContext(context.Background(), &middleware.UserContext{UserID: "1", Username: "alice", Role: role})
+}
+
+func TestCheckRolesArgsAndBudget(t *testing.T) {
+	e := NewEngine(defaultPolicies())
+
+	if err := e.Check(userCtx("member"), "mysql_crud", `{"connection":"ops","sql":"select 1"}`); err == nil || !strings.Contains(err.Error(), "role") {
+		t.Errorf("member should be denied mysql_crud, got %v", err)
+	}
+	if err := e.Check(userCtx("admin"), "mysql_crud", `{"dsn":"root:x@tcp(db)/x","sql":"select 1"}`); err == nil || !strings.Contains(err.Error(), "named connection") {
+		t.Errorf("raw dsn should be denied, got %v", err)
+	}
+	if err := e.Check(userCtx("admin"), "mysql_crud", `{"connection":"ops","sql":"select 1","operate_type":"query"}`); err != nil {
+		t.Errorf("read query should pass: %v", err)
+	}
+	if err := e.Check(userCtx("member"), "run_host_diagnostic", `{"command":"memory"}`); err == nil {
+		t.Error("member should be denied run_host_diagnostic")
+	}
+	if err := e.Check(WithSystem(context.Background()), "run_host_diagnostic", `{"command":"memory"}`); err != nil {
+		t.Errorf("alert diagnosis should be allowed run_host_diagnostic: %v", err)
+	}
+	if err := e.Check(context.Background(), "run_host_diagnostic", `{"command":"memory"}`); err == nil || !strings.Contains(err.Error(), RoleAnonymous) {
+		t.Errorf("calls without a user or system identity should be denied run_host_diagnostic, got %v", err)
+	}
+	if err := e.Check(context.Background(), "query_loki_logs", `{"query":"{stream=\"error\"}"}`); err == nil {
+		t.Error("loki query without job should be denied")
+	}
+
+	ctx := WithRun(userCtx("member"), NewRun(nil))
+	for i := 0; i < 20; i++ {
+		if err := e.Check(ctx, "db_readonly_query", `{"sql":"select 1"}`); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+	if err := e.Check(ctx, "db_readonly_query", `{"sql":"select 1"}`); err == nil {
+		t.Error("21st call should exceed the budget")
+	}
+}
+
+func TestApprovalFlow(t *testing.T) {
+	e := NewEngine(map[string]Policy{"restart": {RequireApproval: true, ApprovalTimeout: "2s"}})
+
+	if err := e.Check(WithRun(userCtx("admin"), NewRun(nil)), "restart", `{}`); err == nil {
+		t.Fatal("approval without an interactive run should be denied")
+	}
+
+	events := make(chan string, 4)
+	run := NewRun(func(event string, payload any) {
+		if ap, ok := payload.(*Approval); ok {
+			go func() {
+				_ = e.Approvals().Resolve(ap.ID, &middleware.UserContext{UserID: "2", Username: "bob", Role: "member"}, true, "")
+				_ = e.Approvals().Resolve(ap.ID, &middleware.UserContext{UserID: "1", Username: "alice", Role: "admin"}, true, "go")
+			}()
+		}
+		events <- event
+	})
+	start := time.Now()
+	if err := e.Check(WithRun(userCtx("admin"), run), "restart", `{}`); err != nil {
+		t.Fatalf("approved call denied: %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("approval should not wait for the timeout")
+	}
+	if <-events != EventApprovalRequired || <-events != EventApprovalResolved {
+		t.Error("unexpected event order")
+	}
+}
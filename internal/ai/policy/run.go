@@ -0,0 +1,70 @@
+package policy
+
+import (
+	"context"
+	"sync"
+
+	"github.com/gogf/gf/v2/util/guid"
+)
+
+// Notifier pushes run events (e.g. approval requests) to the user driving
+// the run, typically over the chat SSE stream.
+type Notifier func(event string, payload any)
+
+// Run is one agent run; call budgets and approvals are scoped to it.
+type Run struct {
+	ID     string
+	Notify Notifier // nil: no interactive channel, approvals are denied
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+// NewRun creates a run. notify may be nil.
+func NewRun(notify Notifier) *Run {
+	return &Run{
+		ID:     guid.S(),
+		Notify: notify,
+		calls:  make(map[string]int),
+	}
+}
+
+// count records a call of tool and returns the number of calls so far.
+func (r *Run) count(tool string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls[tool]++
+	return r.calls[tool]
+}
+
+// Calls returns the number of calls per tool.
+func (r *Run) Calls() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make(map[string]int, len(r.calls))
+	for k, v := range r.calls {
+		out[k] = v
+	}
+	return out
+}
+
+type runKey struct{}
+
+// WithRun attaches run to ctx.
+func WithRun(ctx context.Context, run *Run) context.Context {
+	return context.WithValue(ctx, runKey{}, run)
+}
+
+// RunFromContext returns the run attached to ctx, or nil.
+func RunFromContext(ctx context.Context) *Run {
+	run, _ := ctx.Value(runKey{}).(*Run)
+	return run
+}
+
+// EnsureRun attaches a non-interactive run to ctx unless one is present.
+func EnsureRun(ctx context.Context) context.Context {
+	if RunFromContext(ctx) != nil {
+		return ctx
+	}
+	return WithRun(ctx, NewRun(nil))
+}
@@ -0,0 +1,484 @@
+// Package prompts renders the prompts of incident diagnosis, AI ops and chat
+// from templates instead of Go string literals. Templates come from three
+// layers, later ones overriding earlier ones with the same kind and key:
+// the defaults embedded from templates/, files in prompts.dir
+// (<kind>.<key>.tmpl with YAML front matter), and versioned rows of
+// ops_prompt_templates edited through /api/admin/prompts. The template of a
+// kind is selected by the alertname and labels of the incident.
+package prompts
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	aierrors "github.com/WyRainBow/ops-portal/internal/ai/errors"
+	"github.com/gogf/gf/v2/encoding/gyaml"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// Template kinds.
+const (
+	KindDiagnosis  = "diagnosis"   // alert diagnosis, rendered per incident
+	KindAIOps      = "aiops"       // /api/chat/ai_ops sweep over active alerts
+	KindChatSystem = "chat_system" // system prompt of the chat pipeline
+)
+
+// Kinds lists the known template kinds.
+var Kinds = []string{KindDiagnosis, KindAIOps, KindChatSystem}
+
+// DefaultKey is the key of the fallback template of every kind.
+const DefaultKey = "default"
+
+// Template sources.
+const (
+	SourceEmbedded = "embedded"
+	SourceFile     = "file"
+	SourceDB       = "db"
+)
+
+var keyPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+//go:embed templates/*.tmpl
+var embedded embed.FS
+
+// Match selects the incidents a template applies to. An empty match applies
+// to everything; label values may be globs (path.Match).
+type Match struct {
+	AlertNames []string          `json:"alertnames,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+// Template is one prompt template.
+type Template struct {
+	Kind        string     `json:"kind"`
+	Key         string     `json:"key"`
+	Version     int        `json:"version"`
+	Description string     `json:"description,omitempty"`
+	Match       Match      `json:"match"`
+	Priority    int        `json:"priority"`
+	Body        string     `json:"body"`
+	Disabled    bool       `json:"disabled,omitempty"`
+	Source      string     `json:"source"`
+	CreatedBy   string     `json:"created_by,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+}
+
+// Incident is what templates know about an alert.
+type Incident struct {
+	ID          string
+	AlertName   string
+	Status      string
+	Severity    string
+	Summary     string
+	Description string
+	Labels      map[string]string
+	StartedAt   time.Time
+}
+
+// Window is the time range an investigation should look at.
+type Window struct {
+	From   time.Time
+	To     time.Time
+	Before string // how long before the incident started From is
+}
+
+// Data is the dot of a template.
+type Data struct {
+	Incident   Incident
+	Labels     map[string]string
+	RunbookURL string
+	Window     Window
+	Now        time.Time
+	Vars       map[string]string // prompts.vars
+}
+
+func (m Match) matches(inc *Incident) bool {
+	if len(m.AlertNames) == 0 && len(m.Labels) == 0 {
+		return true
+	}
+	if inc == nil {
+		return false
+	}
+	if len(m.AlertNames) > 0 {
+		found := false
+		for _, name := range m.AlertNames {
+			if strings.EqualFold(name, inc.AlertName) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for k, want := range m.Labels {
+		got, ok := inc.Labels[k]
+		if !ok {
+			return false
+		}
+		if ok, _ := path.Match(want, got); !ok && want != got {
+			return false
+		}
+	}
+	return true
+}
+
+func (m Match) specificity() int {
+	n := len(m.Labels)
+	if len(m.AlertNames) > 0 {
+		n++
+	}
+	return n
+}
+
+var funcs = template.FuncMap{
+	"fmtTime": func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format("2006-01-02 15:04:05 MST")
+	},
+	"default": func(def string, v any) string {
+		if s := fmt.Sprint(v); v != nil && s != "" && s != "<no value>" {
+			return s
+		}
+		return def
+	},
+	"labels": func(m map[string]string) string {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		parts := make([]string, 0, len(keys))
+		for _, k := range keys {
+			parts = append(parts, k+"="+m[k])
+		}
+		return strings.Join(parts, ", ")
+	},
+	"join": strings.Join,
+}
+
+// Render executes t with data.
+func (t *Template) Render(data Data) (string, error) {
+	tpl, err := template.New(t.Kind + "." + t.Key).Funcs(funcs).Option("missingkey=zero").Parse(t.Body)
+	if err != nil {
+		return "", fmt.Errorf("parse template %s/%s: %v", t.Kind, t.Key, err)
+	}
+	var b bytes.Buffer
+	if err := tpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("render template %s/%s: %v", t.Kind, t.Key, err)
+	}
+	return strings.TrimSpace(b.String()) + "\n", nil
+}
+
+// frontMatter is the YAML header of a template file.
+type frontMatter struct {
+	Description string `json:"description"`
+	Priority    int    `json:"priority"`
+	Match       Match  `json:"match"`
+}
+
+// parseFile parses "<kind>.<key>.tmpl": an optional YAML front matter
+// between "---" lines followed by the body.
+func parseFile(name string, content []byte, source string) (*Template, error) {
+	base := strings.TrimSuffix(filepath.Base(name), ".tmpl")
+	kind, key, ok := strings.Cut(base, ".")
+	if !ok || !validKind(kind) || !keyPattern.MatchString(key) {
+		return nil, fmt.Errorf("template file %s: name must be <kind>.<key>.tmpl", name)
+	}
+	t := &Template{Kind: kind, Key: key, Source: source}
+	body := strings.ReplaceAll(string(content), "\r\n", "\n")
+	if rest, ok := strings.CutPrefix(body, "---\n"); ok {
+		header, after, found := strings.Cut(rest, "\n---\n")
+		if !found {
+			return nil, fmt.Errorf("template file %s: unterminated front matter", name)
+		}
+		var fm frontMatter
+		if err := gyaml.DecodeTo([]byte(header), &fm); err != nil {
+			return nil, fmt.Errorf("template file %s: front matter: %v", name, err)
+		}
+		t.Description, t.Priority, t.Match = fm.Description, fm.Priority, fm.Match
+		body = after
+	}
+	t.Body = body
+	return t, nil
+}
+
+func validKind(kind string) bool {
+	for _, k := range Kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func loadEmbedded() []*Template {
+	entries, _ := embedded.ReadDir("templates")
+	var out []*Template
+	for _, e := range entries {
+		b, err := embedded.ReadFile("templates/" + e.Name())
+		if err != nil {
+			continue
+		}
+		t, err := parseFile(e.Name(), b, SourceEmbedded)
+		if err != nil {
+			panic(err) // embedded templates are part of the build
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+func loadDir(dir string) []*Template {
+	files, _ := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	var out []*Template
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			aierrors.Warn("prompts", fmt.Sprintf("read %s: %v", f, err))
+			continue
+		}
+		t, err := parseFile(f, b, SourceFile)
+		if err != nil {
+			aierrors.Warn("prompts", err.Error())
+			continue
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// Config is the prompts config section.
+type Config struct {
+	Dir            string            `json:"dir"`              // template files, default manifest/prompts
+	Vars           map[string]string `json:"vars"`             // .Vars of every template
+	RunbookBaseURL string            `json:"runbook_base_url"` // runbook of an alert without a runbook_url label: <base>/<alertname>
+	WindowBefore   string            `json:"window_before"`    // investigation window starts this long before the incident, default 30m
+}
+
+func loadConfig(ctx context.Context) Config {
+	var cfg Config
+	if v, err := g.Cfg().Get(ctx, "prompts"); err == nil && !v.IsNil() {
+		if err := v.Scan(&cfg); err != nil {
+			aierrors.Warn("prompts", "invalid prompts config: "+err.Error())
+		}
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = "manifest/prompts"
+	}
+	return cfg
+}
+
+const cacheTTL = 30 * time.Second
+
+var (
+	cacheMu   sync.Mutex
+	cached    map[string][]*Template // kind -> effective templates
+	cachedAt  time.Time
+	embedOnce = sync.OnceValue(loadEmbedded)
+)
+
+// Invalidate drops the cached templates, e.g. after an admin edit.
+func Invalidate() {
+	cacheMu.Lock()
+	cached = nil
+	cacheMu.Unlock()
+}
+
+// effective returns the templates in effect per kind: files override the
+// embedded defaults, the latest database version overrides both.
+func effective(ctx context.Context) map[string][]*Template {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if cached != nil && time.Since(cachedAt) < cacheTTL {
+		return cached
+	}
+	byKey := map[string]*Template{}
+	add := func(ts []*Template) {
+		for _, t := range ts {
+			byKey[t.Kind+"/"+t.Key] = t
+		}
+	}
+	add(embedOnce())
+	add(loadDir(loadConfig(ctx).Dir))
+	if rows, err := latestFromDB(ctx); err != nil {
+		aierrors.Warn("prompts", "load templates from database: "+err.Error())
+	} else {
+		add(rows)
+	}
+
+	out := map[string][]*Template{}
+	for _, t := range byKey {
+		if t.Disabled {
+			continue
+		}
+		out[t.Kind] = append(out[t.Kind], t)
+	}
+	for _, ts := range out {
+		sort.Slice(ts, func(i, j int) bool { return ts[i].Key < ts[j].Key })
+	}
+	cached, cachedAt = out, time.Now()
+	return out
+}
+
+// pick returns the matching template with the highest priority, then the
+// most specific match; the default key is the last resort.
+func pick(ts []*Template, inc *Incident) *Template {
+	var best *Template
+	for _, t := range ts {
+		if !t.Match.matches(inc) {
+			continue
+		}
+		if best == nil || better(t, best) {
+			best = t
+		}
+	}
+	return best
+}
+
+func better(a, b *Template) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	if sa, sb := a.Match.specificity(), b.Match.specificity(); sa != sb {
+		return sa > sb
+	}
+	if (a.Key == DefaultKey) != (b.Key == DefaultKey) {
+		return b.Key == DefaultKey
+	}
+	return a.Key < b.Key
+}
+
+// Select returns the template of kind for inc (nil for prompts without an
+// incident).
+func Select(ctx context.Context, kind string, inc *Incident) (*Template, error) {
+	if !validKind(kind) {
+		return nil, fmt.Errorf("unknown template kind %q", kind)
+	}
+	if t := pick(effective(ctx)[kind], inc); t != nil {
+		return t, nil
+	}
+	return embeddedDefault(kind)
+}
+
+func embeddedDefault(kind string) (*Template, error) {
+	for _, t := range embedOnce() {
+		if t.Kind == kind && t.Key == DefaultKey {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("no default template for %q", kind)
+}
+
+// NewData builds the template data for inc at now.
+func NewData(ctx context.Context, inc *Incident, now time.Time) Data {
+	cfg := loadConfig(ctx)
+	before, err := time.ParseDuration(strings.TrimSpace(cfg.WindowBefore))
+	if err != nil || before <= 0 {
+		before = 30 * time.Minute
+	}
+	d := Data{Now: now, Vars: cfg.Vars, Labels: map[string]string{}}
+	if d.Vars == nil {
+		d.Vars = map[string]string{}
+	}
+	start := now.Add(-time.Hour)
+	if inc != nil {
+		d.Incident = *inc
+		if inc.Labels != nil {
+			d.Labels = inc.Labels
+		}
+		if !inc.StartedAt.IsZero() {
+			start = inc.StartedAt
+		}
+		d.RunbookURL = d.Labels["runbook_url"]
+		if d.RunbookURL == "" && cfg.RunbookBaseURL != "" && inc.AlertName != "" {
+			d.RunbookURL = strings.TrimRight(cfg.RunbookBaseURL, "/") + "/" + inc.AlertName
+		}
+	}
+	d.Window = Window{From: start.Add(-before), To: now, Before: before.String()}
+	return d
+}
+
+// Render renders the selected template of kind for inc. When the selected
+// template fails to render the embedded default is used instead, so a bad
+// edit never breaks diagnosis.
+func Render(ctx context.Context, kind string, inc *Incident) (string, *Template, error) {
+	t, err := Select(ctx, kind, inc)
+	if err != nil {
+		return "", nil, err
+	}
+	data := NewData(ctx, inc, time.Now())
+	out, err := t.Render(data)
+	if err == nil {
+		return out, t, nil
+	}
+	aierrors.Warn("prompts", fmt.Sprintf("%v; falling back to the embedded default", err))
+	def, derr := embeddedDefault(kind)
+	if derr != nil {
+		return "", nil, err
+	}
+	out, derr = def.Render(data)
+	if derr != nil {
+		return "", nil, derr
+	}
+	return out, def, nil
+}
+
+// EscapeFString escapes the braces of s for an Eino FString template except
+// the {name} placeholders of vars, so literal braces such as a PromQL
+// selector {job="api"} reach the model unchanged.
+func EscapeFString(s string, vars ...string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			if v := placeholderAt(s[i:], vars); v != "" {
+				b.WriteString(v)
+				i += len(v) - 1
+				continue
+			}
+			b.WriteString("{{")
+		case '}':
+			b.WriteString("}}")
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+func placeholderAt(s string, vars []string) string {
+	for _, v := range vars {
+		if p := "{" + v + "}"; strings.HasPrefix(s, p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// SampleIncident is used to validate and preview templates.
+func SampleIncident() *Incident {
+	return &Incident{
+		ID:          "sample",
+		AlertName:   "HighErrorRate",
+		Status:      "firing",
+		Severity:    "critical",
+		Summary:     "resume-api 5xx error rate above 5%",
+		Description: "resume-api 5 分钟内 5xx 错误率 12%，超过阈值 5%",
+		Labels:      map[string]string{"alertname": "HighErrorRate", "service": "resume-api", "severity": "critical"},
+		StartedAt:   time.Now().Add(-10 * time.Minute),
+	}
+}
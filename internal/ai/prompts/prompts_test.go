@@ -0,0 +1,126 @@
+package prompts
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+func withDB(t *testing.T, ts ...*Template) {
+	t.Helper()
+	orig := latestFromDB
+	latestFromDB = func(context.Context) ([]*Template, error) { return ts, nil }
+	Invalidate()
+	t.Cleanup(func() {
+		latestFromDB = orig
+		Invalidate()
+	})
+}
+
+func TestEmbeddedDefaultsRender(t *testing.T) {
+	withDB(t)
+	ctx := context.Background()
+	inc := SampleIncident()
+	inc.Labels["runbook_url"] = "https://wiki/runbooks/high-error-rate"
+
+	out, tpl, err := Render(ctx, KindDiagnosis, inc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tpl.Key != DefaultKey || tpl.Source != SourceEmbedded {
+		t.Errorf("selected %s/%s from %s", tpl.Kind, tpl.Key, tpl.Source)
+	}
+	for _, want := range []string{"告警名称：HighErrorRate", "service=resume-api", "https://wiki/runbooks/high-error-rate", "告警开始前 30m0s"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("diagnosis prompt missing %q:\n%s", want, out)
+		}
+	}
+
+	out, _, err = Render(ctx, KindChatSystem, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "{date}") || !strings.Contains(out, "{documents}") {
+		t.Errorf("chat system prompt lost its FString placeholders:\n%s", out)
+	}
+	if !strings.Contains(out, "未配置（prompts.vars.cls_topic_id）") {
+		t.Errorf("chat system prompt should mark an unset CLS topic:\n%s", out)
+	}
+	if _, _, err := Render(ctx, KindAIOps, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSelectByAlert(t *testing.T) {
+	withDB(t,
+		&Template{Kind: KindDiagnosis, Key: "errors", Match: Match{AlertNames: []string{"HighErrorRate"}}, Body: "errors", Source: SourceDB, Version: 2},
+		&Template{Kind: KindDiagnosis, Key: "resume-errors", Match: Match{AlertNames: []string{"HighErrorRate"}, Labels: map[string]string{"service": "resume-*"}}, Body: "resume", Source: SourceDB},
+		&Template{Kind: KindDiagnosis, Key: "disk", Match: Match{AlertNames: []string{"DiskFull"}}, Priority: 10, Body: "disk", Source: SourceDB},
+		&Template{Kind: KindDiagnosis, Key: "off", Body: "off", Priority: 100, Disabled: true, Source: SourceDB},
+	)
+	ctx := context.Background()
+	cases := []struct {
+		inc  *Incident
+		want string
+	}{
+		{&Incident{AlertName: "HighErrorRate", Labels: map[string]string{"service": "resume-api"}}, "resume-errors"},
+		{&Incident{AlertName: "HighErrorRate", Labels: map[string]string{"service": "gateway"}}, "errors"},
+		{&Incident{AlertName: "diskfull"}, "disk"},
+		{&Incident{AlertName: "PodCrashLooping"}, DefaultKey},
+		{nil, DefaultKey},
+	}
+	for _, c := range cases {
+		tpl, err := Select(ctx, KindDiagnosis, c.inc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tpl.Key != c.want {
+			t.Errorf("Select(%+v) = %s, want %s", c.inc, tpl.Key, c.want)
+		}
+	}
+}
+
+func TestRenderFallsBackOnBrokenTemplate(t *testing.T) {
+	withDB(t, &Template{Kind: KindAIOps, Key: DefaultKey, Body: "{{.Incident.Nope.Deeper}}", Source: SourceDB, Version: 3})
+	out, tpl, err := Render(context.Background(), KindAIOps, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tpl.Source != SourceEmbedded || !strings.Contains(out, "query_prometheus_alerts") {
+		t.Errorf("expected embedded fallback, got %s v%d", tpl.Source, tpl.Version)
+	}
+}
+
+func TestParseFileFrontMatter(t *testing.T) {
+	src := "---\ndescription: disk\npriority: 5\nmatch:\n  alertnames: [DiskFull]\n  labels:\n    env: prod\n---\n磁盘 {{.Incident.AlertName}}\n---\n"
+	tpl, err := parseFile("diagnosis.disk.tmpl", []byte(src), SourceFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tpl.Priority != 5 || tpl.Match.AlertNames[0] != "DiskFull" || tpl.Match.Labels["env"] != "prod" {
+		t.Errorf("front matter not parsed: %+v", tpl)
+	}
+	out, err := tpl.Render(NewData(context.Background(), &Incident{AlertName: "DiskFull"}, time.Now()))
+	if err != nil || out != "磁盘 DiskFull\n---\n" {
+		t.Errorf("render = %q, %v", out, err)
+	}
+	if _, err := parseFile("unknown.disk.tmpl", []byte("x"), SourceFile); err == nil {
+		t.Error("unknown kind accepted")
+	}
+}
+
+func TestEscapeFString(t *testing.T) {
+	body := "- 当前日期：{date}\n示例：sum(rate(http_requests_total{job=\"api\"}[5m]))\n{documents}"
+	msgs, err := schema.SystemMessage(EscapeFString(body, "date", "documents")).
+		Format(context.Background(), map[string]any{"date": "2026-10-18", "documents": "doc"}, schema.FString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "- 当前日期：2026-10-18\n示例：sum(rate(http_requests_total{job=\"api\"}[5m]))\ndoc"
+	if msgs[0].Content != want {
+		t.Errorf("formatted = %q, want %q", msgs[0].Content, want)
+	}
+}
@@ -0,0 +1,187 @@
+package prompts
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/internal/store"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNotFound is returned for an unknown template version.
+var ErrNotFound = errors.New("prompt template not found")
+
+func fromRow(r *store.PromptTemplate) *Template {
+	t := &Template{
+		Kind: r.Kind, Key: r.Key, Version: r.Version, Description: r.Description,
+		Priority: r.Priority, Body: r.Body, Disabled: r.Disabled, Source: SourceDB,
+		CreatedBy: r.CreatedBy, CreatedAt: r.CreatedAt,
+	}
+	if len(r.Match) > 0 {
+		_ = json.Unmarshal(r.Match, &t.Match)
+	}
+	return t
+}
+
+// latestFromDB loads the latest version of every (kind, key); tests replace it.
+var latestFromDB = func(ctx context.Context) ([]*Template, error) {
+	db, err := store.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var rows []store.PromptTemplate
+	err = db.WithContext(ctx).Raw(`SELECT DISTINCT ON (kind, key) * FROM ops_prompt_templates ORDER BY kind, key, version DESC`).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*Template, 0, len(rows))
+	for i := range rows {
+		out = append(out, fromRow(&rows[i]))
+	}
+	return out, nil
+}
+
+// List returns the templates in effect, by kind and key.
+func List(ctx context.Context) []*Template {
+	var out []*Template
+	all := effective(ctx)
+	for _, kind := range Kinds {
+		out = append(out, all[kind]...)
+	}
+	return out
+}
+
+// Get returns the template in effect for kind and key.
+func Get(ctx context.Context, kind, key string) (*Template, error) {
+	for _, t := range effective(ctx)[kind] {
+		if t.Key == key {
+			return t, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// Versions returns the stored versions of kind/key, newest first.
+func Versions(ctx context.Context, kind, key string) ([]*Template, error) {
+	db, err := store.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var rows []store.PromptTemplate
+	if err := db.WithContext(ctx).Where("kind = ? AND key = ?", kind, key).Order("version DESC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]*Template, 0, len(rows))
+	for i := range rows {
+		out = append(out, fromRow(&rows[i]))
+	}
+	return out, nil
+}
+
+// Validate checks kind and key and that the body renders for the sample
+// incident.
+func Validate(ctx context.Context, t *Template) error {
+	if !validKind(t.Kind) {
+		return fmt.Errorf("unknown template kind %q (%s)", t.Kind, strings.Join(Kinds, ", "))
+	}
+	if !keyPattern.MatchString(t.Key) {
+		return fmt.Errorf("invalid template key %q: lowercase letters, digits, '-' and '_'", t.Key)
+	}
+	if strings.TrimSpace(t.Body) == "" {
+		return errors.New("template body is empty")
+	}
+	_, err := t.Render(NewData(ctx, SampleIncident(), time.Now()))
+	return err
+}
+
+// Save stores t as the next version of its kind/key and puts it in effect.
+func Save(ctx context.Context, t Template, user string) (*Template, error) {
+	if err := Validate(ctx, &t); err != nil {
+		return nil, err
+	}
+	db, err := store.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	match, err := json.Marshal(t.Match)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	row := &store.PromptTemplate{
+		Kind: t.Kind, Key: t.Key, Description: t.Description, Match: match,
+		Priority: t.Priority, Body: t.Body, Disabled: t.Disabled, CreatedBy: user, CreatedAt: &now,
+	}
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var last store.PromptTemplate
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("kind = ? AND key = ?", t.Kind, t.Key).Order("version DESC").First(&last).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		row.Version = last.Version + 1
+		return tx.Create(row).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	Invalidate()
+	return fromRow(row), nil
+}
+
+// Rollback puts version of kind/key back in effect by saving a copy of it as
+// a new version.
+func Rollback(ctx context.Context, kind, key string, version int, user string) (*Template, error) {
+	db, err := store.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var row store.PromptTemplate
+	err = db.WithContext(ctx).Where("kind = ? AND key = ? AND version = ?", kind, key, version).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	t := fromRow(&row)
+	if t.Description == "" {
+		t.Description = fmt.Sprintf("rollback to v%d", version)
+	}
+	return Save(ctx, *t, user)
+}
+
+// Preview renders a template for inc (the sample incident when nil): body
+// when given, else the template in effect for key, else the one selected for
+// inc.
+func Preview(ctx context.Context, kind, key, body string, inc *Incident) (string, *Template, error) {
+	if inc == nil {
+		inc = SampleIncident()
+	}
+	var t *Template
+	var err error
+	switch {
+	case strings.TrimSpace(body) != "":
+		t = &Template{Kind: kind, Key: key, Body: body, Source: "draft"}
+		if !validKind(kind) {
+			return "", nil, fmt.Errorf("unknown template kind %q", kind)
+		}
+	case key != "":
+		t, err = Get(ctx, kind, key)
+	default:
+		t, err = Select(ctx, kind, inc)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	out, err := t.Render(NewData(ctx, inc, time.Now()))
+	if err != nil {
+		return "", t, err
+	}
+	return out, t, nil
+}
@@ -0,0 +1,99 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/internal/ai/errors"
+	"github.com/WyRainBow/ops-portal/internal/cache"
+	"github.com/WyRainBow/ops-portal/internal/metrics"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// cachedTool serves repeated calls with the same arguments from ToolCache
+// for the tool's CacheTTL. Only successful results are cached.
+type cachedTool struct {
+	name  string
+	inner tool.InvokableTool
+	ttl   time.Duration
+}
+
+// cachedResult is what is stored in the cache.
+type cachedResult struct {
+	Output   string    `json:"output"`
+	CachedAt time.Time `json:"cached_at"`
+}
+
+// withCache wraps t with the result cache when ttl > 0.
+func withCache(name string, t tool.BaseTool, ttl time.Duration) tool.BaseTool {
+	inv, ok := t.(tool.InvokableTool)
+	if !ok || ttl <= 0 {
+		return t
+	}
+	return &cachedTool{name: name, inner: inv, ttl: ttl}
+}
+
+func (c *cachedTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return c.inner.Info(ctx)
+}
+
+func (c *cachedTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	tc := cache.NewToolCache(cache.Global(), c.ttl)
+	key := canonicalArgs(argumentsInJSON)
+
+	var hit cachedResult
+	if err := tc.Get(ctx, c.name, key, &hit); err == nil && time.Since(hit.CachedAt) < c.ttl {
+		metrics.Global().Increment(metrics.ToolCacheHitsTotal, map[string]string{"tool": c.name})
+		return markCacheHit(hit.Output, hit.CachedAt), nil
+	}
+	metrics.Global().Increment(metrics.ToolCacheMissesTotal, map[string]string{"tool": c.name})
+
+	out, err := c.inner.InvokableRun(ctx, argumentsInJSON, opts...)
+	if err != nil || !succeeded(out) {
+		return out, err
+	}
+	if err := tc.Set(ctx, c.name, key, cachedResult{Output: out, CachedAt: time.Now()}, c.ttl); err != nil {
+		errors.Warn("registry", "cache tool result for "+c.name+": "+err.Error())
+	}
+	return out, nil
+}
+
+// canonicalArgs normalizes the JSON arguments so that key order and
+// whitespace do not produce different cache keys.
+func canonicalArgs(argumentsInJSON string) any {
+	var v any
+	if err := json.Unmarshal([]byte(argumentsInJSON), &v); err != nil {
+		return argumentsInJSON
+	}
+	return v
+}
+
+// succeeded reports whether a tool output is not a {"success": false} result.
+func succeeded(out string) bool {
+	var status struct {
+		Success *bool `json:"success"`
+	}
+	if json.Unmarshal([]byte(out), &status) != nil || status.Success == nil {
+		return true
+	}
+	return *status.Success
+}
+
+// markCacheHit flags a cached output so the model knows the data may be
+// slightly stale: JSON objects get cache_hit/cached_at fields, other outputs
+// a prefix.
+func markCacheHit(out string, cachedAt time.Time) string {
+	flag := `"cache_hit": true, "cached_at": "` + cachedAt.UTC().Format(time.RFC3339) + `"`
+	trimmed := strings.TrimLeft(out, " \t\r\n")
+	if strings.HasPrefix(trimmed, "{") {
+		rest := strings.TrimLeft(trimmed[1:], " \t\r\n")
+		if strings.HasPrefix(rest, "}") {
+			return "{" + flag + rest
+		}
+		return "{" + flag + ", " + rest
+	}
+	return "[cache_hit cached_at=" + cachedAt.UTC().Format(time.RFC3339) + "] " + out
+}
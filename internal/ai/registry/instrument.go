@@ -0,0 +1,129 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/internal/ai/errors"
+	"github.com/WyRainBow/ops-portal/internal/ai/policy"
+	"github.com/WyRainBow/ops-portal/internal/metrics"
+	"github.com/WyRainBow/ops-portal/utility/textutil"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// DefaultToolTimeout applies when ToolMetadata.Timeout is not set.
+const DefaultToolTimeout = 60 * time.Second
+
+// maxLoggedArgs caps the arguments written into the tool-call event.
+const maxLoggedArgs = 2048
+
+// instrumentedTool records metrics and a structured event for every call,
+// recovers panics and enforces the tool timeout.
+type instrumentedTool struct {
+	name    string
+	inner   tool.InvokableTool
+	timeout time.Duration
+	metrics *metrics.InstrumentedTool
+}
+
+// instrument wraps t with metrics, logging, panic recovery and a timeout.
+func instrument(name string, t tool.BaseTool, timeout time.Duration) tool.BaseTool {
+	inv, ok := t.(tool.InvokableTool)
+	if !ok {
+		return t
+	}
+	if timeout <= 0 {
+		timeout = DefaultToolTimeout
+	}
+	return &instrumentedTool{
+		name:    name,
+		inner:   inv,
+		timeout: timeout,
+		metrics: metrics.NewInstrumentedTool(name),
+	}
+}
+
+func (t *instrumentedTool) Info(ctx context.Context) (*schema.ToolInfo, error) {
+	return t.inner.Info(ctx)
+}
+
+type callResult struct {
+	out     string
+	err     error
+	failure string // set when the call failed without an error (panic, timeout)
+}
+
+func (t *instrumentedTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	start := time.Now()
+	t.metrics.RecordCall(nil)
+
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	// 在独立 goroutine 中执行，不响应 ctx 的工具也不会拖住 Agent
+	done := make(chan callResult, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err := errors.PanicError(t.name, r)
+				done <- callResult{out: errors.NewToolError(t.name, "panic during execution", err).ToJSON(), failure: err.Error()}
+			}
+		}()
+		out, err := t.inner.InvokableRun(ctx, argumentsInJSON, opts...)
+		done <- callResult{out: out, err: err}
+	}()
+
+	// panic 与超时以失败结果返回给 Agent，不中断整个运行
+	var res callResult
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		res.failure = fmt.Sprintf("timed out after %s", t.timeout)
+		if ctx.Err() == context.Canceled {
+			res.failure = "cancelled"
+		}
+		res.out = errors.NewToolError(t.name, res.failure, ctx.Err()).ToJSON()
+	}
+	duration := time.Since(start)
+
+	out, err, failure := res.out, res.err, res.failure
+	if err != nil {
+		failure = err.Error()
+	}
+	success := failure == "" && succeeded(out)
+	if success {
+		t.metrics.RecordSuccess(duration)
+	} else {
+		if failure == "" {
+			failure = "tool reported failure"
+		}
+		t.metrics.RecordError(duration, failure)
+	}
+	t.metrics.RecordOutputSize(len(out))
+
+	ev := errors.ToolCallEvent{
+		Tool:        t.name,
+		Arguments:   truncateArgs(argumentsInJSON),
+		Success:     success,
+		DurationMs:  float64(duration.Microseconds()) / 1000,
+		OutputBytes: len(out),
+		Error:       failure,
+	}
+	if run := policy.RunFromContext(ctx); run != nil {
+		ev.RunID = run.ID
+	}
+	errors.ToolEvent(ev)
+	return out, err
+}
+
+// truncateArgs clips s to maxLoggedArgs bytes on a rune boundary so logs and
+// metric labels stay valid UTF-8.
+func truncateArgs(s string) string {
+	s, cut := textutil.TruncateBytes(s, maxLoggedArgs)
+	if cut {
+		s += "...(truncated)"
+	}
+	return s
+}
@@ -11,6 +11,8 @@ const (
 	CategoryObservability ToolCategory = "observability"
 	CategoryDatabase      ToolCategory = "database"
 	CategoryKnowledge     ToolCategory = "knowledge"
+	CategoryKubernetes    ToolCategory = "kubernetes"
+	CategoryHost          ToolCategory = "host"
 	CategoryUtility       ToolCategory = "utility"
 	CategoryMCP           ToolCategory = "mcp"
 	CategoryCustom        ToolCategory = "custom"
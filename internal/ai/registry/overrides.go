@@ -0,0 +1,239 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/internal/ai/errors"
+	"github.com/WyRainBow/ops-portal/internal/store"
+	"gorm.io/gorm/clause"
+)
+
+// Override is an admin change to a tool's registration defaults. Nil fields
+// keep the value the tool was registered with.
+type Override struct {
+	Enabled    *bool    `json:"enabled,omitempty"`
+	AgentTypes []string `json:"agent_types,omitempty"`
+}
+
+func (o Override) apply(m *ToolMetadata) {
+	if o.Enabled != nil {
+		m.Enabled = *o.Enabled
+	}
+	if o.AgentTypes != nil {
+		m.AgentTypes = append([]string(nil), o.AgentTypes...)
+	}
+}
+
+// merge returns o with the non-nil fields of ov applied on top.
+func (o Override) merge(ov Override) Override {
+	if ov.Enabled != nil {
+		o.Enabled = ov.Enabled
+	}
+	if ov.AgentTypes != nil {
+		o.AgentTypes = ov.AgentTypes
+	}
+	return o
+}
+
+// ValidAgentType reports whether t is a known agent type.
+func ValidAgentType(t string) bool {
+	switch AgentType(t) {
+	case AgentTypeChat, AgentTypePlanExecute, AgentTypeKnowledge, AgentTypeAll:
+		return true
+	}
+	return false
+}
+
+// restore resets the metadata of a tool to its registered defaults.
+func (w *ToolWrapper) restore() {
+	w.Metadata = w.defaults
+	w.Metadata.AgentTypes = append([]string(nil), w.defaults.AgentTypes...)
+}
+
+// SetOverrides replaces the override set and applies it to the tools that
+// are already registered. Tools whose override was dropped get their
+// registered defaults back. Tools registered later (e.g. MCP tools) pick up
+// their override in Register.
+func (r *Registry) SetOverrides(overrides map[string]Override) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name := range r.overrides {
+		if _, kept := overrides[name]; kept {
+			continue
+		}
+		if wrapper, ok := r.tools[name]; ok {
+			wrapper.restore()
+		}
+	}
+	r.overrides = make(map[string]Override, len(overrides))
+	for name, ov := range overrides {
+		r.overrides[name] = ov
+		if wrapper, ok := r.tools[name]; ok {
+			ov.apply(&wrapper.Metadata)
+		}
+	}
+}
+
+// ApplyOverride merges ov into the stored override of a registered tool and
+// applies it. Agents see the change the next time they are built.
+func (r *Registry) ApplyOverride(name string, ov Override) (ToolMetadata, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wrapper, ok := r.tools[name]
+	if !ok {
+		return ToolMetadata{}, ErrToolNotFound
+	}
+	merged := r.overrides[name].merge(ov)
+	r.overrides[name] = merged
+	merged.apply(&wrapper.Metadata)
+
+	errors.Info("registry", fmt.Sprintf("override tool: %s (enabled=%v, agent_types=%v)", name, wrapper.Metadata.Enabled, wrapper.Metadata.AgentTypes))
+	return wrapper.Metadata, nil
+}
+
+// ResetOverride drops the override of a registered tool and restores the
+// metadata it was registered with.
+func (r *Registry) ResetOverride(name string) (ToolMetadata, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wrapper, ok := r.tools[name]
+	if !ok {
+		return ToolMetadata{}, ErrToolNotFound
+	}
+	delete(r.overrides, name)
+	wrapper.restore()
+
+	errors.Info("registry", fmt.Sprintf("reset tool override: %s (enabled=%v, agent_types=%v)", name, wrapper.Metadata.Enabled, wrapper.Metadata.AgentTypes))
+	return wrapper.Metadata, nil
+}
+
+// GetOverride returns the stored override of a tool.
+func (r *Registry) GetOverride(name string) (Override, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ov, ok := r.overrides[name]
+	return ov, ok
+}
+
+// overrideReloadInterval is how often WatchOverrides re-reads the persisted
+// overrides; tests shorten it.
+var overrideReloadInterval = 30 * time.Second
+
+// LoadOverrides reads the persisted overrides (ops_tool_overrides) into the
+// global registry.
+func LoadOverrides(ctx context.Context) error {
+	n, err := loadOverrides(ctx)
+	if err != nil {
+		return err
+	}
+	errors.Info("registry", fmt.Sprintf("loaded %d tool overrides", n))
+	return nil
+}
+
+// WatchOverrides reloads the persisted overrides periodically until ctx is
+// done, so admin changes made on another replica or in the portal reach
+// long-running processes such as the stdio MCP server.
+func WatchOverrides(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(overrideReloadInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := loadOverrides(ctx); err != nil {
+					errors.Warn("registry", fmt.Sprintf("reload tool overrides: %v", err))
+				}
+			}
+		}
+	}()
+}
+
+func loadOverrides(ctx context.Context) (int, error) {
+	rows, err := readOverrides(ctx)
+	if err != nil {
+		return 0, err
+	}
+	overrides := make(map[string]Override, len(rows))
+	for _, row := range rows {
+		ov := Override{Enabled: row.Enabled}
+		if len(row.AgentTypes) > 0 {
+			if err := json.Unmarshal(row.AgentTypes, &ov.AgentTypes); err != nil {
+				errors.Warn("registry", fmt.Sprintf("bad agent_types override for %s: %v", row.Name, err))
+			}
+		}
+		overrides[row.Name] = ov
+	}
+	Global().SetOverrides(overrides)
+	return len(overrides), nil
+}
+
+// readOverrides returns the persisted overrides; tests replace it.
+var readOverrides = func(ctx context.Context) ([]store.ToolOverride, error) {
+	db, err := store.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var rows []store.ToolOverride
+	if err := db.WithContext(ctx).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// SaveOverride persists the merged override of a tool and applies it to the
+// global registry.
+func SaveOverride(ctx context.Context, name string, ov Override, updatedBy string) (ToolMetadata, error) {
+	if _, _, ok := Global().Lookup(name); !ok {
+		return ToolMetadata{}, ErrToolNotFound
+	}
+	current, _ := Global().GetOverride(name)
+	merged := current.merge(ov)
+
+	db, err := store.DB(ctx)
+	if err != nil {
+		return ToolMetadata{}, err
+	}
+	row := store.ToolOverride{
+		Name:      name,
+		Enabled:   merged.Enabled,
+		UpdatedBy: updatedBy,
+	}
+	if merged.AgentTypes != nil {
+		row.AgentTypes, _ = json.Marshal(merged.AgentTypes)
+	}
+	now := time.Now().UTC()
+	row.UpdatedAt = &now
+	err = db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "agent_types", "updated_by", "updated_at"}),
+	}).Create(&row).Error
+	if err != nil {
+		return ToolMetadata{}, err
+	}
+	return Global().ApplyOverride(name, ov)
+}
+
+// DeleteOverride removes the persisted override of a tool and restores its
+// registered defaults in the global registry.
+func DeleteOverride(ctx context.Context, name string) (ToolMetadata, error) {
+	if _, _, ok := Global().Lookup(name); !ok {
+		return ToolMetadata{}, ErrToolNotFound
+	}
+	db, err := store.DB(ctx)
+	if err != nil {
+		return ToolMetadata{}, err
+	}
+	if err := db.WithContext(ctx).Where("name = ?", name).Delete(&store.ToolOverride{}).Error; err != nil {
+		return ToolMetadata{}, err
+	}
+	return Global().ResetOverride(name)
+}
@@ -5,8 +5,10 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/WyRainBow/ops-portal/internal/ai/errors"
+	"github.com/WyRainBow/ops-portal/internal/ai/policy"
 	"github.com/WyRainBow/ops-portal/internal/ai/tools"
 	"github.com/cloudwego/eino/components/tool"
 )
@@ -15,28 +17,39 @@ import (
 type ToolMetadata struct {
 	Name        string
 	Description string
-	Category    string   // e.g., "observability", "database", "utility"
-	Enabled     bool     // Whether the tool is currently enabled
-	AgentTypes  []string // Which agent types can use this tool: "chat", "plan_execute", "all"
+	Category    string        // e.g., "observability", "database", "utility"
+	Enabled     bool          // Whether the tool is currently enabled
+	AgentTypes  []string      // Which agent types can use this tool: "chat", "plan_execute", "all"
+	CacheTTL    time.Duration // How long results are served from ToolCache; 0 = never cache
+	Timeout     time.Duration // Per-call timeout; 0 = DefaultToolTimeout
 }
 
 // ToolWrapper wraps a tool with its metadata.
 type ToolWrapper struct {
 	Tool     tool.BaseTool
 	Metadata ToolMetadata
+
+	defaults ToolMetadata // metadata as registered, before overrides
 }
 
 // Registry manages tool registration and lookup.
 type Registry struct {
-	mu    sync.RWMutex
-	tools map[string]*ToolWrapper
+	mu        sync.RWMutex
+	tools     map[string]*ToolWrapper
+	overrides map[string]Override // persisted admin overrides, see overrides.go
 }
 
-// Global registry instance.
-var globalRegistry = &Registry{
-	tools: make(map[string]*ToolWrapper),
+// New creates an empty registry.
+func New() *Registry {
+	return &Registry{
+		tools:     make(map[string]*ToolWrapper),
+		overrides: make(map[string]Override),
+	}
 }
 
+// Global registry instance.
+var globalRegistry = New()
+
 // Global returns the global tool registry.
 func Global() *Registry {
 	return globalRegistry
@@ -44,7 +57,17 @@ func Global() *Registry {
 
 // Register registers a tool with the given metadata.
 // If a tool with the same name already exists, it will be replaced.
+// The stored tool is wrapped, outermost first, by its policy (see
+// internal/ai/policy), instrumentation (metrics, tool-call event, panic
+// recovery, timeout) and, when CacheTTL is set, the ToolCache.
 func (r *Registry) Register(t tool.BaseTool, metadata ToolMetadata) {
+	// Fill the description from the tool itself so the admin API can show it
+	if metadata.Description == "" {
+		if info, err := t.Info(context.Background()); err == nil && info != nil {
+			metadata.Description = info.Desc
+		}
+	}
+
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -58,14 +81,28 @@ func (r *Registry) Register(t tool.BaseTool, metadata ToolMetadata) {
 		}
 	}
 
+	defaults := metadata
+	defaults.AgentTypes = append([]string(nil), metadata.AgentTypes...)
+	if ov, ok := r.overrides[metadata.Name]; ok {
+		ov.apply(&metadata)
+	}
+
 	r.tools[metadata.Name] = &ToolWrapper{
-		Tool:     t,
+		Tool:     wrap(t, metadata),
 		Metadata: metadata,
+		defaults: defaults,
 	}
 
 	errors.Info("registry", fmt.Sprintf("registered tool: %s (category=%s, enabled=%v)", metadata.Name, metadata.Category, metadata.Enabled))
 }
 
+// wrap applies the registry middlewares to t.
+func wrap(t tool.BaseTool, m ToolMetadata) tool.BaseTool {
+	t = withCache(m.Name, t, m.CacheTTL)
+	t = instrument(m.Name, t, m.Timeout)
+	return policy.Wrap(m.Name, t)
+}
+
 // Unregister removes a tool from the registry.
 func (r *Registry) Unregister(name string) {
 	r.mu.Lock()
@@ -117,6 +154,17 @@ func (r *Registry) GetAll(agentType string) []tool.BaseTool {
 	return result
 }
 
+// Lookup returns a tool and its metadata regardless of its enabled state.
+func (r *Registry) Lookup(name string) (tool.BaseTool, ToolMetadata, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if wrapper, ok := r.tools[name]; ok {
+		return wrapper.Tool, wrapper.Metadata, true
+	}
+	return nil, ToolMetadata{}, false
+}
+
 // GetMetadata returns the metadata for a tool.
 func (r *Registry) GetMetadata(name string) (ToolMetadata, bool) {
 	r.mu.RLock()
@@ -241,8 +289,28 @@ func RegisterStandardTools(ctx context.Context) error {
 		Category:   "observability",
 		Enabled:    true,
 		AgentTypes: []string{"chat", "plan_execute", "all"},
+		CacheTTL:   30 * time.Second,
 	})
 
+	// Loki aggregation tools
+	for _, t := range []tool.InvokableTool{
+		tools.NewLokiMetricQueryTool(),
+		tools.NewLokiLabelDiscoveryTool(),
+		tools.NewLokiLogPatternsTool(),
+	} {
+		info, err := t.Info(ctx)
+		if err != nil {
+			return err
+		}
+		registry.Register(t, ToolMetadata{
+			Name:       info.Name,
+			Category:   "observability",
+			Enabled:    true,
+			AgentTypes: []string{"chat", "plan_execute", "all"},
+			CacheTTL:   standardCacheTTL(info.Name),
+		})
+	}
+
 	// Prometheus alerts tool
 	promTool := tools.NewPrometheusAlertsQueryTool()
 	registry.Register(promTool, ToolMetadata{
@@ -250,6 +318,121 @@ func RegisterStandardTools(ctx context.Context) error {
 		Category:   "observability",
 		Enabled:    true,
 		AgentTypes: []string{"chat", "plan_execute", "all"},
+		CacheTTL:   30 * time.Second,
+	})
+
+	// Prometheus metric tools
+	for _, t := range []tool.InvokableTool{
+		tools.NewPrometheusRangeQueryTool(),
+		tools.NewPrometheusInstantQueryTool(),
+		tools.NewPrometheusSeriesDiscoveryTool(),
+	} {
+		info, err := t.Info(ctx)
+		if err != nil {
+			return err
+		}
+		registry.Register(t, ToolMetadata{
+			Name:       info.Name,
+			Category:   "observability",
+			Enabled:    true,
+			AgentTypes: []string{"chat", "plan_execute", "all"},
+			CacheTTL:   standardCacheTTL(info.Name),
+		})
+	}
+
+	// Request log and trace tools over api_request_logs / api_trace_spans
+	for _, t := range []tool.InvokableTool{
+		tools.NewGetTraceTool(),
+		tools.NewSearchRequestLogsTool(),
+		tools.NewTopErrorTypesTool(),
+	} {
+		info, err := t.Info(ctx)
+		if err != nil {
+			return err
+		}
+		registry.Register(t, ToolMetadata{
+			Name:       info.Name,
+			Category:   "observability",
+			Enabled:    true,
+			AgentTypes: []string{"chat", "plan_execute", "all"},
+			CacheTTL:   standardCacheTTL(info.Name),
+		})
+	}
+
+	// Change-event timeline (deploys, config changes, commits, restarts)
+	changesTool := tools.NewRecentChangesTool()
+	registry.Register(changesTool, ToolMetadata{
+		Name:       "recent_changes",
+		Category:   "observability",
+		Enabled:    true,
+		AgentTypes: []string{"chat", "plan_execute", "all"},
+		CacheTTL:   30 * time.Second,
+	})
+
+	// Grafana dashboards, panel data (via the datasource proxy) and annotations
+	for _, t := range []tool.InvokableTool{
+		tools.NewGrafanaSearchDashboardsTool(),
+		tools.NewGrafanaPanelDataTool(),
+	} {
+		info, err := t.Info(ctx)
+		if err != nil {
+			return err
+		}
+		registry.Register(t, ToolMetadata{
+			Name:       info.Name,
+			Category:   "observability",
+			Enabled:    true,
+			AgentTypes: []string{"chat", "plan_execute", "all"},
+			CacheTTL:   standardCacheTTL(info.Name),
+		})
+	}
+	annotateTool := tools.NewGrafanaAnnotateTool()
+	registry.Register(annotateTool, ToolMetadata{
+		Name:       "grafana_annotate",
+		Category:   "observability",
+		Enabled:    true,
+		AgentTypes: []string{"chat", "plan_execute", "all"},
+	})
+
+	// HTTP probe against allowlisted targets (live, never cached)
+	probeTool := tools.NewHTTPProbeTool()
+	registry.Register(probeTool, ToolMetadata{
+		Name:       "http_probe",
+		Category:   "observability",
+		Enabled:    true,
+		AgentTypes: []string{"chat", "plan_execute", "all"},
+		Timeout:    tools.HTTPProbeMaxDuration + 15*time.Second, // all samples timing out
+	})
+
+	// Kubernetes read-only tools, enabled when a kubeconfig or in-cluster
+	// config is available
+	for _, t := range []tool.InvokableTool{
+		tools.NewK8sListPodsTool(),
+		tools.NewK8sDescribePodTool(),
+		tools.NewK8sRolloutStatusTool(),
+		tools.NewK8sPodLogsTool(),
+	} {
+		info, err := t.Info(ctx)
+		if err != nil {
+			return err
+		}
+		registry.Register(t, ToolMetadata{
+			Name:       info.Name,
+			Category:   "kubernetes",
+			Enabled:    tools.KubernetesConfigured(),
+			AgentTypes: []string{"chat", "plan_execute", "all"},
+			CacheTTL:   10 * time.Second,
+		})
+	}
+
+	// Host diagnostics (allowlisted commands, never cached)
+	hostTool := tools.NewHostDiagnosticTool()
+	registry.Register(hostTool, ToolMetadata{
+		Name:       "run_host_diagnostic",
+		Category:   "host",
+		Enabled:    true,
+		AgentTypes: []string{"chat", "plan_execute", "all"},
+		Timeout:    30 * time.Second,
 	})
 
 	// Database tools
@@ -258,7 +441,16 @@ func RegisterStandardTools(ctx context.Context) error {
 		Name:       "db_readonly_query",
 		Category:   "database",
 		Enabled:    true,
-		AgentTypes: []string{"chat", "all"},
+		AgentTypes: []string{"chat", "plan_execute", "all"},
+	})
+
+	describeTool := tools.NewDescribeTablesTool()
+	registry.Register(describeTool, ToolMetadata{
+		Name:       "describe_tables",
+		Category:   "database",
+		Enabled:    true,
+		AgentTypes: []string{"chat", "plan_execute", "all"},
+		CacheTTL:   10 * time.Minute,
 	})
 
 	// MySQL CRUD tool (use with caution)
@@ -276,10 +468,11 @@ func RegisterStandardTools(ctx context.Context) error {
 		Name:       "query_internal_docs",
 		Category:   "knowledge",
 		Enabled:    true,
-		AgentTypes: []string{"chat", "all"},
+		AgentTypes: []string{"chat", "plan_execute", "all"},
+		CacheTTL:   10 * time.Minute,
 	})
 
-	// Time tool
+	// Time tool (never cached)
 	timeTool := tools.NewGetCurrentTimeTool()
 	registry.Register(timeTool, ToolMetadata{
 		Name:       "get_current_time",
@@ -293,3 +486,14 @@ func RegisterStandardTools(ctx context.Context) error {
 
 	return nil
 }
+
+// standardCacheTTL returns the result cache TTL of the looped observability
+// tools: discovery results change slowly, query results quickly.
+func standardCacheTTL(name string) time.Duration {
+	switch name {
+	case "loki_label_discovery", "prometheus_series_discovery", "get_trace", "grafana_search_dashboards":
+		return 5 * time.Minute
+	default:
+		return 30 * time.Second
+	}
+}
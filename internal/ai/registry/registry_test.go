@@ -1,7 +1,19 @@
 package registry
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/WyRainBow/ops-portal/internal/ai/tools"
+	"github.com/WyRainBow/ops-portal/internal/metrics"
+	"github.com/WyRainBow/ops-portal/internal/store"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
 )
 
 func TestGlobalRegistry(t *testing.T) {
@@ -121,3 +133,180 @@ func TestUnregisterNonExistent(t *testing.T) {
 	reg.Unregister("non_existent_tool_xyz")
 	// If we get here, test passes
 }
+
+func TestOverridesApplyOnRegister(t *testing.T) {
+	reg := New()
+	disabled := false
+	reg.SetOverrides(map[string]Override{
+		"get_current_time": {Enabled: &disabled},
+	})
+	reg.Register(tools.NewGetCurrentTimeTool(), ToolMetadata{
+		Name:       "get_current_time",
+		Enabled:    true,
+		AgentTypes: []string{"chat"},
+	})
+
+	meta, _ := reg.GetMetadata("get_current_time")
+	if meta.Enabled || reg.Get("get_current_time") != nil {
+		t.Fatalf("override not applied on register: %+v", meta)
+	}
+	if meta.Description == "" {
+		t.Error("description should be filled from the tool info")
+	}
+
+	enabled := true
+	meta, err := reg.ApplyOverride("get_current_time", Override{Enabled: &enabled, AgentTypes: []string{"plan_execute"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !meta.Enabled || len(reg.GetAll("plan_execute")) != 1 || len(reg.GetAll("chat")) != 0 {
+		t.Errorf("override not applied: %+v", meta)
+	}
+	if _, err := reg.ApplyOverride("missing", Override{Enabled: &enabled}); !IsNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+}
+
+func TestResetOverrideRestoresDefaults(t *testing.T) {
+	reg := New()
+	reg.Register(tools.NewGetCurrentTimeTool(), ToolMetadata{
+		Name:       "get_current_time",
+		Enabled:    true,
+		AgentTypes: []string{"chat"},
+	})
+	disabled := false
+	if _, err := reg.ApplyOverride("get_current_time", Override{Enabled: &disabled, AgentTypes: []string{"plan_execute"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	meta, err := reg.ResetOverride("get_current_time")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !meta.Enabled || len(meta.AgentTypes) != 1 || meta.AgentTypes[0] != "chat" {
+		t.Errorf("defaults not restored: %+v", meta)
+	}
+	if _, ok := reg.GetOverride("get_current_time"); ok {
+		t.Error("override still stored")
+	}
+	if _, err := reg.ResetOverride("missing"); !IsNotFound(err) {
+		t.Errorf("expected not found, got %v", err)
+	}
+
+	// 其他副本删除覆盖后，重新加载时恢复默认值
+	reg.SetOverrides(map[string]Override{"get_current_time": {Enabled: &disabled}})
+	reg.SetOverrides(map[string]Override{})
+	if meta, _ := reg.GetMetadata("get_current_time"); !meta.Enabled {
+		t.Errorf("dropped override not reverted on reload: %+v", meta)
+	}
+}
+
+func TestCachedToolResults(t *testing.T) {
+	calls := 0
+	counter, err := utils.InferTool("counter", "count calls", func(ctx context.Context, in *struct {
+		Key string `json:"key"`
+	}) (string, error) {
+		calls++
+		if in.Key == "bad" {
+			return `{"success": false, "error": "boom"}`, nil
+		}
+		return fmt.Sprintf(`{"success": true, "calls": %d}`, calls), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := New()
+	reg.Register(counter, ToolMetadata{Name: "counter_cached", Enabled: true, CacheTTL: time.Minute})
+	reg.Register(counter, ToolMetadata{Name: "counter_uncached", Enabled: true})
+
+	ctx := context.Background()
+	cached := reg.Get("counter_cached").(tool.InvokableTool)
+	first, _ := cached.InvokableRun(ctx, `{"key": "a"}`)
+	second, _ := cached.InvokableRun(ctx, `{"key":"a"}`)
+	if strings.Contains(first, "cache_hit") || !strings.Contains(second, `"cache_hit": true`) || !strings.Contains(second, `"calls": 1`) {
+		t.Fatalf("expected second call from cache: %s / %s", first, second)
+	}
+	if _, err := json.Marshal(json.RawMessage(second)); err != nil {
+		t.Errorf("flagged output is not valid JSON: %s", second)
+	}
+
+	_, _ = cached.InvokableRun(ctx, `{"key":"bad"}`)
+	_, _ = cached.InvokableRun(ctx, `{"key":"bad"}`)
+	uncached := reg.Get("counter_uncached").(tool.InvokableTool)
+	_, _ = uncached.InvokableRun(ctx, `{"key":"a"}`)
+	_, _ = uncached.InvokableRun(ctx, `{"key":"a"}`)
+	if calls != 5 {
+		t.Errorf("failures and TTL 0 tools must not be cached, calls=%d", calls)
+	}
+}
+
+func TestInstrumentedToolRecoversAndTimesOut(t *testing.T) {
+	metrics.InitializeStandardMetrics()
+	reg := New()
+	boom, _ := utils.InferTool("boom", "panics", func(ctx context.Context, in *struct{}) (string, error) {
+		panic("kaboom")
+	})
+	slow, _ := utils.InferTool("slow", "sleeps", func(ctx context.Context, in *struct{}) (string, error) {
+		time.Sleep(2 * time.Second)
+		return `{"success": true}`, nil
+	})
+	reg.Register(boom, ToolMetadata{Name: "boom", Enabled: true})
+	reg.Register(slow, ToolMetadata{Name: "slow", Enabled: true, Timeout: 50 * time.Millisecond})
+
+	ctx := context.Background()
+	calls, _ := metrics.Global().Get(metrics.ToolCallsTotal)
+
+	out, err := reg.Get("boom").(tool.InvokableTool).InvokableRun(ctx, `{}`)
+	if err != nil || !strings.Contains(out, "panic") {
+		t.Errorf("panic not converted to a tool error: %q, %v", out, err)
+	}
+	start := time.Now()
+	out, err = reg.Get("slow").(tool.InvokableTool).InvokableRun(ctx, `{}`)
+	if err != nil || !strings.Contains(out, "timed out") || time.Since(start) > time.Second {
+		t.Errorf("timeout not enforced: %q, %v", out, err)
+	}
+
+	if after, _ := metrics.Global().Get(metrics.ToolCallsTotal); after != calls+2 {
+		t.Errorf("calls not counted: %v -> %v", calls, after)
+	}
+	if m := metrics.Global().GetAll()[metrics.ToolDurationSeconds]; m == nil || m.Count < 2 {
+		t.Errorf("latency not observed: %+v", m)
+	}
+}
+
+func TestTruncateArgsOnRuneBoundary(t *testing.T) {
+	args := `{"query":"` + strings.Repeat("连接池", maxLoggedArgs) + `"}`
+	got := truncateArgs(args)
+	if !utf8.ValidString(got) || !strings.HasSuffix(got, "...(truncated)") || len(got) > maxLoggedArgs+len("...(truncated)") {
+		t.Errorf("truncateArgs returned %d bytes, valid=%v", len(got), utf8.ValidString(got))
+	}
+}
+
+func TestWatchOverridesReloads(t *testing.T) {
+	origRead, origInterval := readOverrides, overrideReloadInterval
+	t.Cleanup(func() {
+		readOverrides, overrideReloadInterval = origRead, origInterval
+		Global().SetOverrides(nil)
+	})
+	name := "watch_overrides_test_tool"
+	Global().Register(tools.NewGetCurrentTimeTool(), ToolMetadata{Name: name, Enabled: true, AgentTypes: []string{"chat"}})
+	t.Cleanup(func() { Global().Unregister(name) })
+
+	// 另一个副本在数据库里禁用了该工具
+	disabled := false
+	readOverrides = func(context.Context) ([]store.ToolOverride, error) {
+		return []store.ToolOverride{{Name: name, Enabled: &disabled}}, nil
+	}
+	overrideReloadInterval = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	WatchOverrides(ctx)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for Global().Get(name) != nil {
+		if time.Now().After(deadline) {
+			t.Fatal("override was not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,454 @@
+// Package session persists chat conversations in PostgreSQL
+// (ops_chat_sessions / ops_chat_messages), owned by the JWT user. The
+// history sent to the model is token-budgeted: older turns are condensed
+// into a running summary by the summarizer model, recent turns are kept
+// verbatim and cached in utility/mem until the session goes idle.
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	aierrors "github.com/WyRainBow/ops-portal/internal/ai/errors"
+	"github.com/WyRainBow/ops-portal/internal/ai/models"
+	"github.com/WyRainBow/ops-portal/internal/store"
+	"github.com/WyRainBow/ops-portal/utility/mem"
+	"github.com/WyRainBow/ops-portal/utility/middleware"
+	"github.com/WyRainBow/ops-portal/utility/textutil"
+	"github.com/cloudwego/eino/schema"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrNotFound is returned for sessions that do not exist or belong to
+// another user.
+var ErrNotFound = errors.New("会话不存在")
+
+const (
+	defaultTokenBudget = 3000
+	defaultIdleTTL     = 30 * time.Minute
+	maxTitleRunes      = 30
+)
+
+// Config is the chat_memory config section.
+type Config struct {
+	TokenBudget int    `json:"token_budget"` // tokens of history sent to the model
+	IdleTTL     string `json:"idle_ttl"`     // in-memory sessions idle longer than this are evicted
+}
+
+func loadConfig(ctx context.Context) (budget int, idle time.Duration) {
+	var cfg Config
+	if v, err := g.Cfg().Get(ctx, "chat_memory"); err == nil && !v.IsNil() {
+		if err := v.Scan(&cfg); err != nil {
+			aierrors.Warn("session", "invalid chat_memory config: "+err.Error())
+		}
+	}
+	budget, idle = cfg.TokenBudget, defaultIdleTTL
+	if budget <= 0 {
+		budget = defaultTokenBudget
+	}
+	if d, err := time.ParseDuration(strings.TrimSpace(cfg.IdleTTL)); err == nil && d > 0 {
+		idle = d
+	}
+	return budget, idle
+}
+
+// StartEvictor drops cached sessions idle longer than chat_memory.idle_ttl.
+func StartEvictor(ctx context.Context) {
+	_, idle := loadConfig(ctx)
+	interval := idle / 4
+	if interval < time.Minute {
+		interval = time.Minute
+	}
+	mem.StartEvictor(ctx, idle, interval)
+}
+
+// UserID returns the id of the JWT user of ctx.
+func UserID(ctx context.Context) (int64, error) {
+	u := middleware.GetUserContext(ctx)
+	if u == nil {
+		return 0, errors.New("未提供有效的认证信息")
+	}
+	id, err := strconv.ParseInt(u.UserID, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid user id %q", u.UserID)
+	}
+	return id, nil
+}
+
+// EstimateTokens roughly counts tokens: one per CJK character, one per four
+// other characters.
+func EstimateTokens(s string) int {
+	cjk, other := 0, 0
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+func titleOf(question string) string {
+	title := strings.Join(strings.Fields(question), " ")
+	if t, cut := textutil.Truncate(title, maxTitleRunes); cut {
+		title = t + "…"
+	}
+	if title == "" {
+		title = "新对话"
+	}
+	return title
+}
+
+func cacheKey(id string) string { return "session:" + id }
+
+// Create starts a session for userID; an empty id gets a new UUID.
+func Create(ctx context.Context, userID int64, id, title string) (*store.ChatSession, error) {
+	db, err := store.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if id = strings.TrimSpace(id); id == "" {
+		id = uuid.NewString()
+	}
+	now := time.Now()
+	s := &store.ChatSession{ID: id, UserID: userID, Title: titleOf(title), CreatedAt: &now, UpdatedAt: &now}
+	if err := db.WithContext(ctx).Create(s).Error; err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Get returns the session id of userID.
+func Get(ctx context.Context, userID int64, id string) (*store.ChatSession, error) {
+	db, err := store.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var s store.ChatSession
+	err = db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&s).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Ensure returns session id of userID, creating it (titled after the first
+// question) when it does not exist yet. An id used by another user is
+// rejected.
+func Ensure(ctx context.Context, userID int64, id, question string) (*store.ChatSession, bool, error) {
+	if strings.TrimSpace(id) != "" {
+		s, err := Get(ctx, userID, id)
+		if err == nil {
+			return s, false, nil
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return nil, false, err
+		}
+	}
+	s, err := Create(ctx, userID, id, question)
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(err.Error(), "duplicate key") {
+			return nil, false, ErrNotFound
+		}
+		return nil, false, err
+	}
+	return s, true, nil
+}
+
+// List returns the sessions of userID, most recently used first.
+func List(ctx context.Context, userID int64, page, pageSize int) ([]store.ChatSession, int64, error) {
+	db, err := store.DB(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	base := db.WithContext(ctx).Model(&store.ChatSession{}).Where("user_id = ?", userID)
+	var total int64
+	if err := base.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var list []store.ChatSession
+	err = base.Order("updated_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&list).Error
+	return list, total, err
+}
+
+// Rename sets the title of session id of userID.
+func Rename(ctx context.Context, userID int64, id, title string) error {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return errors.New("title is required")
+	}
+	db, err := store.DB(ctx)
+	if err != nil {
+		return err
+	}
+	res := db.WithContext(ctx).Model(&store.ChatSession{}).Where("id = ? AND user_id = ?", id, userID).
+		Updates(map[string]any{"title": title, "updated_at": time.Now()})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Delete removes session id of userID with its messages.
+func Delete(ctx context.Context, userID int64, id string) error {
+	db, err := store.DB(ctx)
+	if err != nil {
+		return err
+	}
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ? AND user_id = ?", id, userID).Delete(&store.ChatSession{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return tx.Where("session_id = ?", id).Delete(&store.ChatMessage{}).Error
+	})
+	if err == nil {
+		mem.DeleteSimpleMemory(cacheKey(id))
+	}
+	return err
+}
+
+// Messages returns the messages of session id of userID in order, at most
+// limit of the latest ones.
+func Messages(ctx context.Context, userID int64, id string, limit int) ([]store.ChatMessage, error) {
+	if _, err := Get(ctx, userID, id); err != nil {
+		return nil, err
+	}
+	db, err := store.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var list []store.ChatMessage
+	if err := db.WithContext(ctx).Where("session_id = ?", id).Order("id DESC").Limit(limit).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+		list[i], list[j] = list[j], list[i]
+	}
+	return list, nil
+}
+
+func toSchema(m store.ChatMessage) *schema.Message {
+	if m.Role == string(schema.Assistant) {
+		return schema.AssistantMessage(m.Content, nil)
+	}
+	return schema.UserMessage(m.Content)
+}
+
+// recent returns the unsummarised messages of s, from the cache or the
+// database.
+func recent(ctx context.Context, s *store.ChatSession) ([]*schema.Message, error) {
+	if c, ok := mem.LookupSimpleMemory(cacheKey(s.ID)); ok {
+		return c.GetMessages(), nil
+	}
+	db, err := store.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var rows []store.ChatMessage
+	if err := db.WithContext(ctx).Where("session_id = ? AND id > ?", s.ID, s.SummarizedUntil).Order("id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	msgs := make([]*schema.Message, 0, len(rows))
+	for _, r := range rows {
+		msgs = append(msgs, toSchema(r))
+	}
+	c := mem.GetSimpleMemory(cacheKey(s.ID))
+	c.MaxWindowSize = 0 // the token budget bounds the history, not the window
+	c.ReplaceMessages(msgs)
+	return msgs, nil
+}
+
+// History returns the messages to send to the model before the next
+// question: the summary of older turns followed by as many recent messages
+// as fit in chat_memory.token_budget.
+func History(ctx context.Context, s *store.ChatSession) ([]*schema.Message, error) {
+	msgs, err := recent(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	budget, _ := loadConfig(ctx)
+	return buildHistory(s.Summary, msgs, budget), nil
+}
+
+func summaryMessage(summary string) *schema.Message {
+	return schema.SystemMessage("以下是本会话较早对话的摘要，回答时可参考：\n" + summary)
+}
+
+// buildHistory keeps the newest messages fitting budget, dropping whole
+// user/assistant pairs from the front.
+func buildHistory(summary string, msgs []*schema.Message, budget int) []*schema.Message {
+	used := 0
+	if summary != "" {
+		used = EstimateTokens(summary)
+	}
+	start := len(msgs)
+	for i := len(msgs) - 1; i >= 0; i-- {
+		used += EstimateTokens(msgs[i].Content)
+		if used > budget {
+			break
+		}
+		start = i
+	}
+	// 不从 assistant 回复开始，保持问答配对
+	for start < len(msgs) && msgs[start].Role == schema.Assistant {
+		start++
+	}
+	out := make([]*schema.Message, 0, len(msgs)-start+1)
+	if summary != "" {
+		out = append(out, summaryMessage(summary))
+	}
+	return append(out, msgs[start:]...)
+}
+
+// AppendTurn stores a question and its answer, and summarises older turns in
+// the background once the unsummarised history exceeds the token budget.
+func AppendTurn(ctx context.Context, s *store.ChatSession, question, answer string) error {
+	db, err := store.DB(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	rows := []store.ChatMessage{
+		{SessionID: s.ID, Role: string(schema.User), Content: question, Tokens: EstimateTokens(question), CreatedAt: &now},
+		{SessionID: s.ID, Role: string(schema.Assistant), Content: answer, Tokens: EstimateTokens(answer), CreatedAt: &now},
+	}
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&rows).Error; err != nil {
+			return err
+		}
+		return tx.Model(&store.ChatSession{}).Where("id = ?", s.ID).Updates(map[string]any{
+			"message_count": gorm.Expr("message_count + ?", len(rows)),
+			"updated_at":    now,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+	if c, ok := mem.LookupSimpleMemory(cacheKey(s.ID)); ok {
+		c.SetMessages(toSchema(rows[0]))
+		c.SetMessages(toSchema(rows[1]))
+	}
+
+	// 摘要不阻塞本次回复
+	go maybeSummarize(context.WithoutCancel(ctx), s.ID)
+	return nil
+}
+
+var summarizing sync.Map // session id -> struct{}
+
+// summarize condenses msgs into the previous summary; tests replace it.
+var summarize = func(ctx context.Context, previous string, msgs []*schema.Message) (string, error) {
+	cm, err := models.ForRole(ctx, models.RoleSummarizer)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if previous != "" {
+		b.WriteString("已有摘要：\n" + previous + "\n\n")
+	}
+	b.WriteString("新增对话：\n")
+	for _, m := range msgs {
+		role := "用户"
+		if m.Role == schema.Assistant {
+			role = "助手"
+		}
+		b.WriteString(role + "：" + m.Content + "\n")
+	}
+	out, err := cm.Generate(ctx, []*schema.Message{
+		schema.SystemMessage("你负责压缩运维对话的上下文。把已有摘要和新增对话合并成一份简洁的中文摘要，保留用户的目标、涉及的服务/主机/告警、已确认的结论和数据、未解决的问题，不要编造内容，不超过 300 字。"),
+		schema.UserMessage(b.String()),
+	})
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out.Content), nil
+}
+
+// splitForSummary returns how many of the oldest rows to summarise so that
+// the rest fit in half of budget; 0 when the history fits in budget. At
+// least the latest pair is kept and the cut never splits a pair.
+func splitForSummary(rows []store.ChatMessage, budget int) int {
+	total := 0
+	for _, r := range rows {
+		total += r.Tokens
+	}
+	if total <= budget || len(rows) <= 2 {
+		return 0
+	}
+	keep, kept := 0, 0
+	for i := len(rows) - 1; i >= 0; i-- {
+		if kept+rows[i].Tokens > budget/2 && keep >= 2 {
+			break
+		}
+		kept += rows[i].Tokens
+		keep++
+	}
+	cut := len(rows) - keep
+	for cut > 0 && rows[cut].Role == string(schema.Assistant) {
+		cut--
+	}
+	return cut
+}
+
+func maybeSummarize(ctx context.Context, id string) {
+	if _, busy := summarizing.LoadOrStore(id, struct{}{}); busy {
+		return
+	}
+	defer summarizing.Delete(id)
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	db, err := store.DB(ctx)
+	if err != nil {
+		return
+	}
+	var s store.ChatSession
+	if err := db.WithContext(ctx).First(&s, "id = ?", id).Error; err != nil {
+		return
+	}
+	var rows []store.ChatMessage
+	if err := db.WithContext(ctx).Where("session_id = ? AND id > ?", id, s.SummarizedUntil).Order("id").Find(&rows).Error; err != nil {
+		return
+	}
+	budget, _ := loadConfig(ctx)
+	cut := splitForSummary(rows, budget)
+	if cut == 0 {
+		return
+	}
+	old := make([]*schema.Message, 0, cut)
+	for _, r := range rows[:cut] {
+		old = append(old, toSchema(r))
+	}
+	summary, err := summarize(ctx, s.Summary, old)
+	if err != nil || summary == "" {
+		aierrors.Warn("session", fmt.Sprintf("summarise session %s: %v", id, err))
+		return
+	}
+	res := db.WithContext(ctx).Model(&store.ChatSession{}).
+		Where("id = ? AND summarized_until = ?", id, s.SummarizedUntil).
+		Updates(map[string]any{"summary": summary, "summarized_until": rows[cut-1].ID})
+	if res.Error != nil || res.RowsAffected == 0 {
+		return
+	}
+	// 重新从数据库加载未摘要的消息
+	mem.DeleteSimpleMemory(cacheKey(id))
+}
@@ -0,0 +1,75 @@
+package session
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/internal/store"
+	"github.com/WyRainBow/ops-portal/utility/mem"
+	"github.com/cloudwego/eino/schema"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	if got := EstimateTokens("服务告警"); got != 4 {
+		t.Errorf("cjk = %d, want 4", got)
+	}
+	if got := EstimateTokens("abcdefgh"); got != 2 {
+		t.Errorf("ascii = %d, want 2", got)
+	}
+}
+
+func TestBuildHistory(t *testing.T) {
+	msgs := []*schema.Message{
+		schema.UserMessage(strings.Repeat("a", 400)),
+		schema.AssistantMessage(strings.Repeat("b", 400), nil),
+		schema.UserMessage("q2"),
+		schema.AssistantMessage("a2", nil),
+	}
+	// 100 + 100 + 1 + 1 tokens; a budget of 150 drops the first answer,
+	// and with it the dangling first question
+	got := buildHistory("", msgs, 150)
+	if len(got) != 2 || got[0].Content != "q2" || got[1].Role != schema.Assistant {
+		t.Fatalf("unexpected history: %v", got)
+	}
+
+	got = buildHistory("用户在排查 resume-api 的 5xx", msgs, 1000)
+	if len(got) != 5 || got[0].Role != schema.System || !strings.Contains(got[0].Content, "resume-api") {
+		t.Fatalf("summary not prepended: %v", got)
+	}
+}
+
+func TestSplitForSummary(t *testing.T) {
+	rows := []store.ChatMessage{
+		{ID: 1, Role: "user", Tokens: 400},
+		{ID: 2, Role: "assistant", Tokens: 800},
+		{ID: 3, Role: "user", Tokens: 100},
+		{ID: 4, Role: "assistant", Tokens: 600},
+		{ID: 5, Role: "user", Tokens: 50},
+		{ID: 6, Role: "assistant", Tokens: 300},
+	}
+	if cut := splitForSummary(rows, 5000); cut != 0 {
+		t.Errorf("history within budget: cut = %d", cut)
+	}
+	// keep <= 500 tokens: the last pair (350); the cut lands on a user turn
+	if cut := splitForSummary(rows, 1000); cut != 4 {
+		t.Errorf("cut = %d, want 4", cut)
+	}
+	// the latest pair is always kept even if it alone exceeds the budget
+	if cut := splitForSummary(rows, 100); cut != 4 {
+		t.Errorf("small budget cut = %d, want 4", cut)
+	}
+}
+
+func TestEvictIdle(t *testing.T) {
+	m := mem.GetSimpleMemory(cacheKey("idle-test"))
+	m.SetMessages(schema.UserMessage("hi"))
+	if n := mem.EvictIdle(time.Hour); n != 0 {
+		t.Fatalf("evicted %d fresh sessions", n)
+	}
+	time.Sleep(5 * time.Millisecond)
+	mem.EvictIdle(time.Millisecond)
+	if _, ok := mem.LookupSimpleMemory(cacheKey("idle-test")); ok {
+		t.Error("idle session not evicted")
+	}
+}
@@ -0,0 +1,352 @@
+package tools
+
+import (
+	"context"
+	stderrors "errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/internal/logic/apilog"
+	"github.com/WyRainBow/ops-portal/internal/store"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+)
+
+const (
+	traceMaxTreeSpans   = 200
+	traceMaxErrorSpans  = 20
+	requestLogsDefault  = 50
+	requestLogsMax      = 200
+	errorTypesDefault   = 10
+	errorTypesMax       = 50
+	errorTypesMaxWindow = 7 * 24 * time.Hour
+)
+
+// GetTraceInput is the input of get_trace.
+type GetTraceInput struct {
+	TraceID string `json:"trace_id" jsonschema:"description=Trace ID, e.g. from search_request_logs or an error log"`
+}
+
+// SearchRequestLogsInput is the input of search_request_logs.
+type SearchRequestLogsInput struct {
+	Path       string `json:"path,omitempty" jsonschema:"description=Substring of the request path, e.g. /api/resume"`
+	Method     string `json:"method,omitempty" jsonschema:"description=HTTP method, e.g. POST"`
+	StatusCode *int64 `json:"status_code,omitempty" jsonschema:"description=Exact status code"`
+	MinStatus  *int64 `json:"min_status,omitempty" jsonschema:"description=Minimum status code, e.g. 500 for server errors"`
+	MaxStatus  *int64 `json:"max_status,omitempty" jsonschema:"description=Maximum status code, e.g. 599"`
+	TraceID    string `json:"trace_id,omitempty" jsonschema:"description=Only requests of this trace"`
+	Start      int64  `json:"start,omitempty" jsonschema:"description=Start time (unix seconds/ms/ns). Optional, defaults to 1 hour ago."`
+	End        int64  `json:"end,omitempty" jsonschema:"description=End time (unix seconds/ms/ns). Optional, defaults to now."`
+	Limit      int    `json:"limit,omitempty" jsonschema:"description=Max rows returned, newest first. Default 50, max 200."`
+}
+
+// TopErrorTypesInput is the input of top_error_types.
+type TopErrorTypesInput struct {
+	Window string `json:"window,omitempty" jsonschema:"description=Look-back window such as 15m, 1h or 24h. Default 1h, max 168h."`
+	Limit  int    `json:"limit,omitempty" jsonschema:"description=Max groups returned. Default 10, max 50."`
+}
+
+// traceNode is a span in the get_trace tree.
+type traceNode struct {
+	SpanID        string       `json:"span_id"`
+	Name          string       `json:"name"`
+	StartOffsetMs float64      `json:"start_offset_ms"`
+	DurationMs    float64      `json:"duration_ms"`
+	SelfMs        float64      `json:"self_ms"`
+	Status        string       `json:"status,omitempty"`
+	Error         bool         `json:"error,omitempty"`
+	Children      []*traceNode `json:"children,omitempty"`
+}
+
+// NewGetTraceTool 创建 trace 详情工具
+func NewGetTraceTool() tool.InvokableTool {
+	t, err := utils.InferOptionableTool(
+		"get_trace",
+		"Get a trace from api_trace_spans as a span tree with the critical path (the chain of spans that determined the latency, with self time), the failed spans and the error logs of the trace. Use it after finding a slow or failed request.",
+		func(ctx context.Context, input *GetTraceInput, opts ...tool.Option) (output string, err error) {
+			traceID := strings.TrimSpace(input.TraceID)
+			if traceID == "" {
+				return `{"success":false,"error":"trace_id is empty"}`, nil
+			}
+			db, err := store.DB(ctx)
+			if err != nil {
+				return promError(fmt.Errorf("db init failed: %v", err)), nil
+			}
+			qctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+			defer cancel()
+
+			spans, err := apilog.TraceSpans(qctx, db, traceID)
+			if stderrors.Is(err, apilog.ErrTraceNotFound) {
+				return promError(fmt.Errorf("trace %s not found", traceID)), nil
+			}
+			if err != nil {
+				return promError(fmt.Errorf("db query failed: %v", err)), nil
+			}
+			errorLogs, _, err := apilog.SearchErrorLogs(qctx, db, apilog.ErrorLogFilter{TraceID: traceID}, 0, 10)
+			if err != nil {
+				errorLogs = nil
+			}
+			return promJSON(summarizeTrace(traceID, spans, errorLogs)), nil
+		},
+	)
+	if err != nil {
+		return createErrorAPILogTool("get_trace", err)
+	}
+	return t
+}
+
+// summarizeTrace builds the get_trace result from the spans of a trace.
+func summarizeTrace(traceID string, spans []apilog.Span, errorLogs []store.APIErrorLog) map[string]any {
+	roots := apilog.BuildTree(spans)
+	var start, end time.Time
+	for _, s := range spans {
+		if start.IsZero() || s.Start.Before(start) {
+			start = s.Start
+		}
+		if s.End.After(end) {
+			end = s.End
+		}
+	}
+
+	budget := traceMaxTreeSpans
+	var convert func(n *apilog.SpanNode) *traceNode
+	convert = func(n *apilog.SpanNode) *traceNode {
+		budget--
+		out := &traceNode{
+			SpanID:        n.SpanID,
+			Name:          n.Name,
+			StartOffsetMs: float64(n.Start.Sub(start).Microseconds()) / 1000,
+			DurationMs:    n.DurationMs,
+			SelfMs:        n.SelfMs,
+			Status:        n.Status,
+			Error:         n.IsError(),
+		}
+		for _, c := range n.Children {
+			if budget <= 0 {
+				break
+			}
+			out.Children = append(out.Children, convert(c))
+		}
+		return out
+	}
+	tree := make([]*traceNode, 0, len(roots))
+	for _, r := range roots {
+		if budget <= 0 {
+			break
+		}
+		tree = append(tree, convert(r))
+	}
+
+	path := apilog.CriticalPath(roots)
+	critical := make([]map[string]any, 0, len(path))
+	for _, n := range path {
+		critical = append(critical, map[string]any{
+			"span_id":     n.SpanID,
+			"name":        n.Name,
+			"duration_ms": n.DurationMs,
+			"self_ms":     n.SelfMs,
+			"status":      n.Status,
+		})
+	}
+
+	errSpans := make([]map[string]any, 0)
+	errCount := 0
+	for _, s := range spans {
+		if !s.IsError() {
+			continue
+		}
+		errCount++
+		if len(errSpans) < traceMaxErrorSpans {
+			errSpans = append(errSpans, map[string]any{
+				"span_id":     s.SpanID,
+				"name":        s.Name,
+				"status":      s.Status,
+				"duration_ms": s.DurationMs,
+				"tags":        s.Tags,
+			})
+		}
+	}
+
+	logs := make([]map[string]any, 0, len(errorLogs))
+	for _, l := range errorLogs {
+		item := map[string]any{"error_message": l.ErrorMessage}
+		if l.ErrorType != nil {
+			item["error_type"] = *l.ErrorType
+		}
+		if l.Service != nil {
+			item["service"] = *l.Service
+		}
+		if l.CreatedAt != nil {
+			item["created_at"] = l.CreatedAt.UTC().Format(time.RFC3339)
+		}
+		logs = append(logs, item)
+	}
+
+	return map[string]any{
+		"success":       true,
+		"trace_id":      traceID,
+		"span_count":    len(spans),
+		"start_time":    start.UTC().Format(time.RFC3339Nano),
+		"duration_ms":   float64(end.Sub(start).Microseconds()) / 1000,
+		"error_count":   errCount,
+		"critical_path": critical,
+		"error_spans":   errSpans,
+		"error_logs":    logs,
+		"tree":          tree,
+		"truncated":     len(spans) > traceMaxTreeSpans,
+	}
+}
+
+// NewSearchRequestLogsTool 创建请求日志检索工具
+func NewSearchRequestLogsTool() tool.InvokableTool {
+	t, err := utils.InferOptionableTool(
+		"search_request_logs",
+		"Search API request logs (api_request_logs) by path, method, status code range and time range. Returns totals, 4xx/5xx counts, avg/p95/max latency and the newest matching requests with their trace_id for get_trace.",
+		func(ctx context.Context, input *SearchRequestLogsInput, opts ...tool.Option) (output string, err error) {
+			now := time.Now().UTC()
+			end := now
+			if v := promUnixSeconds(input.End); v > 0 {
+				end = time.Unix(v, 0).UTC()
+			}
+			start := end.Add(-time.Hour)
+			if v := promUnixSeconds(input.Start); v > 0 {
+				start = time.Unix(v, 0).UTC()
+			}
+			if !start.Before(end) {
+				return `{"success":false,"error":"start must be before end"}`, nil
+			}
+			limit := input.Limit
+			if limit <= 0 {
+				limit = requestLogsDefault
+			}
+			if limit > requestLogsMax {
+				limit = requestLogsMax
+			}
+
+			db, err := store.DB(ctx)
+			if err != nil {
+				return promError(fmt.Errorf("db init failed: %v", err)), nil
+			}
+			qctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+			defer cancel()
+
+			f := apilog.RequestLogFilter{
+				TraceID:       input.TraceID,
+				Path:          input.Path,
+				Method:        input.Method,
+				StatusCode:    input.StatusCode,
+				MinStatusCode: input.MinStatus,
+				MaxStatusCode: input.MaxStatus,
+				Since:         start,
+				Until:         end,
+			}
+			stats, err := apilog.StatsRequestLogs(qctx, db, f)
+			if err != nil {
+				return promError(fmt.Errorf("db query failed: %v", err)), nil
+			}
+			rows, _, err := apilog.SearchRequestLogs(qctx, db, f, 0, limit)
+			if err != nil {
+				return promError(fmt.Errorf("db query failed: %v", err)), nil
+			}
+
+			items := make([]map[string]any, 0, len(rows))
+			for _, r := range rows {
+				item := map[string]any{
+					"trace_id":    r.TraceID,
+					"method":      r.Method,
+					"path":        r.Path,
+					"status_code": r.StatusCode,
+					"latency_ms":  r.LatencyMs,
+				}
+				if r.UserID != nil {
+					item["user_id"] = *r.UserID
+				}
+				if r.CreatedAt != nil {
+					item["created_at"] = r.CreatedAt.UTC().Format(time.RFC3339)
+				}
+				items = append(items, item)
+			}
+			return promJSON(map[string]any{
+				"success":  true,
+				"start":    start.Format(time.RFC3339),
+				"end":      end.Format(time.RFC3339),
+				"stats":    stats,
+				"returned": len(items),
+				"requests": items,
+			}), nil
+		},
+	)
+	if err != nil {
+		return createErrorAPILogTool("search_request_logs", err)
+	}
+	return t
+}
+
+// NewTopErrorTypesTool 创建错误类型排行工具
+func NewTopErrorTypesTool() tool.InvokableTool {
+	t, err := utils.InferOptionableTool(
+		"top_error_types",
+		"Rank the error types recorded in api_error_logs within a recent window, grouped by error type and service, with counts, first/last seen, a sample message and a sample trace_id for get_trace.",
+		func(ctx context.Context, input *TopErrorTypesInput, opts ...tool.Option) (output string, err error) {
+			window := time.Hour
+			if w := strings.TrimSpace(input.Window); w != "" {
+				d, perr := time.ParseDuration(w)
+				if perr != nil || d <= 0 {
+					return promError(fmt.Errorf("invalid window %q, use e.g. 30m or 24h", w)), nil
+				}
+				window = d
+			}
+			if window > errorTypesMaxWindow {
+				window = errorTypesMaxWindow
+			}
+			limit := input.Limit
+			if limit <= 0 {
+				limit = errorTypesDefault
+			}
+			if limit > errorTypesMax {
+				limit = errorTypesMax
+			}
+
+			db, err := store.DB(ctx)
+			if err != nil {
+				return promError(fmt.Errorf("db init failed: %v", err)), nil
+			}
+			qctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+			defer cancel()
+
+			since := time.Now().UTC().Add(-window)
+			groups, err := apilog.TopErrorTypes(qctx, db, since, limit)
+			if err != nil {
+				return promError(fmt.Errorf("db query failed: %v", err)), nil
+			}
+			var total int64
+			for _, g := range groups {
+				total += g.Count
+			}
+			return promJSON(map[string]any{
+				"success":          true,
+				"window":           window.String(),
+				"since":            since.Format(time.RFC3339),
+				"groups":           groups,
+				"errors_in_groups": total,
+			}), nil
+		},
+	)
+	if err != nil {
+		return createErrorAPILogTool("top_error_types", err)
+	}
+	return t
+}
+
+// createErrorAPILogTool returns a tool that always returns an error
+func createErrorAPILogTool(name string, createErr error) tool.InvokableTool {
+	t, _ := utils.InferOptionableTool(
+		name,
+		"Error tool - Request log tool failed to initialize",
+		func(ctx context.Context, input any, opts ...tool.Option) (output string, err error) {
+			return fmt.Sprintf(`{"success":false,"error":"Tool initialization failed: %s"}`, escapeJSON(createErr.Error())), nil
+		},
+	)
+	return t
+}
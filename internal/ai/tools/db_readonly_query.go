@@ -5,48 +5,82 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/WyRainBow/ops-portal/internal/store"
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/components/tool/utils"
 	"github.com/gogf/gf/v2/frame/g"
+	pgast "github.com/pganalyze/pg_query_go/v6"
+	pgquery "github.com/wasilibs/go-pgquery"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"gorm.io/gorm"
+)
+
+const (
+	dbQueryTimeout      = 5 * time.Second
+	dbQueryDefaultLimit = 200
+	dbQueryMaxRows      = 500
+	redactedValue       = "[REDACTED]"
+)
+
+var (
+	allowTbl = []string{"users", "members", "api_request_logs", "api_error_logs", "api_trace_spans", "permission_audit_logs"}
+
+	// sensitiveColRE matches columns whose values must never reach the model.
+	sensitiveColRE = regexp.MustCompile(`(?i)(password|passwd|secret|token|api_key|private_key)`)
+
+	// deniedFuncPrefixes blocks functions that read files, run nested SQL
+	// strings (bypassing the relation allowlist) or touch server state.
+	deniedFuncPrefixes = []string{"pg_", "dblink", "lo_", "set_config", "current_setting", "query_to_xml", "table_to_xml", "cursor_to_xml", "schema_to_xml", "database_to_xml", "ts_stat", "ts_rewrite", "txid_", "nextval", "setval"}
 )
 
 type DBReadonlyQueryInput struct {
-	SQL string `json:"sql" jsonschema:"description=Readonly SQL query (SELECT/WITH only). Avoid selecting large tables without LIMIT."`
+	SQL string `json:"sql" jsonschema:"description=Readonly SQL query (single SELECT/WITH statement) against allowlisted tables. Call describe_tables first if unsure of columns. Without LIMIT at most 200 rows are returned."`
+}
+
+type DescribeTablesInput struct {
+	Tables []string `json:"tables,omitempty" jsonschema:"description=Tables to describe. Optional, defaults to all allowlisted tables."`
 }
 
 func NewDBReadonlyQueryTool() tool.InvokableTool {
 	t, err := utils.InferOptionableTool(
 		"db_readonly_query",
-		"Run a readonly SQL query (SELECT/WITH) against the PostgreSQL database. Use this tool to inspect users, members, logs, traces. Writes are forbidden.",
+		"Run a readonly SQL query (SELECT/WITH) against the PostgreSQL database. Use this tool to inspect users, members, logs, traces. Only allowlisted tables are accessible, writes are forbidden and sensitive columns (e.g. password_hash) are redacted.",
 		func(ctx context.Context, input *DBReadonlyQueryInput, opts ...tool.Option) (output string, err error) {
 			sql := strings.TrimSpace(input.SQL)
 			if sql == "" {
 				return `{"success":false,"error":"sql is empty"}`, nil
 			}
-			if err := validateReadonlySQL(sql); err != nil {
+			checked, err := validateReadonlySQL(sql)
+			if err != nil {
 				return `{"success":false,"error":"` + escapeJSON(err.Error()) + `"}`, nil
 			}
+			if !checked.hasLimit {
+				sql = checked.limited(dbQueryDefaultLimit)
+			}
 
 			db, err := store.DB(ctx)
 			if err != nil {
 				return `{"success":false,"error":"db init failed"}`, nil
 			}
 
-			rows := make([]map[string]any, 0)
-			// hard cap: if user didn't provide LIMIT, add a conservative one.
-			if !hasLimit(sql) {
-				sql = sql + " LIMIT 200"
-			}
-			if err := db.WithContext(ctx).Raw(sql).Scan(&rows).Error; err != nil {
+			rows, truncated, err := runReadonly(db.WithContext(ctx), sql)
+			if err != nil {
 				return `{"success":false,"error":"` + escapeJSON(err.Error()) + `"}`, nil
 			}
+			redacted := redactRows(rows)
 			out := map[string]any{
-				"success": true,
-				"count":   len(rows),
-				"rows":    rows,
+				"success":   true,
+				"count":     len(rows),
+				"truncated": truncated,
+				"tables":    checked.relations,
+				"rows":      rows,
+			}
+			if len(redacted) > 0 {
+				out["redacted_columns"] = redacted
 			}
 			b, _ := json.MarshalIndent(out, "", "  ")
 			return string(b), nil
@@ -55,55 +89,452 @@ func NewDBReadonlyQueryTool() tool.InvokableTool {
 	if err != nil {
 		// Log error instead of panic
 		g.Log().Errorf(context.Background(), "db_readonly_query tool creation failed: %v", err)
-		return createErrorDBTool(err)
+		return createErrorDBTool("db_readonly_query", err)
+	}
+	return t
+}
+
+// NewDescribeTablesTool 创建表结构查询工具
+func NewDescribeTablesTool() tool.InvokableTool {
+	t, err := utils.InferOptionableTool(
+		"describe_tables",
+		"Describe the tables that db_readonly_query may access: columns, data types, nullability, approximate row counts and which columns are redacted. Call this before writing SQL.",
+		func(ctx context.Context, input *DescribeTablesInput, opts ...tool.Option) (output string, err error) {
+			tables := allowTbl
+			if len(input.Tables) > 0 {
+				tables = make([]string, 0, len(input.Tables))
+				for _, name := range input.Tables {
+					name = strings.ToLower(strings.TrimSpace(name))
+					if !isAllowedTable(name) {
+						return `{"success":false,"error":"` + escapeJSON(fmt.Sprintf("table %q is not allowlisted; allowed: %s", name, strings.Join(allowTbl, ", "))) + `"}`, nil
+					}
+					tables = append(tables, name)
+				}
+			}
+
+			db, err := store.DB(ctx)
+			if err != nil {
+				return `{"success":false,"error":"db init failed"}`, nil
+			}
+
+			var cols []struct {
+				TableName  string `gorm:"column:table_name"`
+				ColumnName string `gorm:"column:column_name"`
+				DataType   string `gorm:"column:data_type"`
+				IsNullable string `gorm:"column:is_nullable"`
+			}
+			if err := db.WithContext(ctx).Raw(`SELECT table_name, column_name, data_type, is_nullable
+FROM information_schema.columns
+WHERE table_schema = current_schema() AND table_name IN ?
+ORDER BY table_name, ordinal_position`, tables).Scan(&cols).Error; err != nil {
+				return `{"success":false,"error":"` + escapeJSON(err.Error()) + `"}`, nil
+			}
+
+			var counts []struct {
+				Relname   string  `gorm:"column:relname"`
+				Reltuples float64 `gorm:"column:reltuples"`
+			}
+			_ = db.WithContext(ctx).Raw(`SELECT c.relname, c.reltuples FROM pg_class c
+JOIN pg_namespace n ON n.oid = c.relnamespace
+WHERE n.nspname = current_schema() AND c.relname IN ?`, tables).Scan(&counts).Error
+			estimates := make(map[string]int64, len(counts))
+			for _, c := range counts {
+				estimates[c.Relname] = int64(c.Reltuples)
+			}
+
+			type column struct {
+				Name     string `json:"name"`
+				Type     string `json:"type"`
+				Nullable bool   `json:"nullable"`
+				Redacted bool   `json:"redacted,omitempty"`
+			}
+			type table struct {
+				Name         string   `json:"name"`
+				EstimatedRow int64    `json:"estimated_rows"`
+				Columns      []column `json:"columns"`
+			}
+			byName := make(map[string]*table)
+			result := make([]*table, 0, len(tables))
+			for _, name := range tables {
+				tb := &table{Name: name, EstimatedRow: estimates[name], Columns: []column{}}
+				byName[name] = tb
+				result = append(result, tb)
+			}
+			for _, c := range cols {
+				if tb, ok := byName[c.TableName]; ok {
+					tb.Columns = append(tb.Columns, column{
+						Name:     c.ColumnName,
+						Type:     c.DataType,
+						Nullable: c.IsNullable == "YES",
+						Redacted: sensitiveColRE.MatchString(c.ColumnName),
+					})
+				}
+			}
+
+			b, _ := json.MarshalIndent(map[string]any{
+				"success": true,
+				"tables":  result,
+			}, "", "  ")
+			return string(b), nil
+		},
+	)
+	if err != nil {
+		g.Log().Errorf(context.Background(), "describe_tables tool creation failed: %v", err)
+		return createErrorDBTool("describe_tables", err)
 	}
 	return t
 }
 
 // createErrorDBTool returns a tool that always returns an error
-func createErrorDBTool(createErr error) tool.InvokableTool {
+func createErrorDBTool(name string, createErr error) tool.InvokableTool {
 	t, _ := utils.InferOptionableTool(
-		"db_readonly_query",
+		name,
 		"Error tool - DB query tool failed to initialize",
 		func(ctx context.Context, input any, opts ...tool.Option) (output string, err error) {
-			return fmt.Sprintf(`{"success":false,"error":"Tool initialization failed: %v"}`, createErr.Error()), nil
+			return fmt.Sprintf(`{"success":false,"error":"Tool initialization failed: %s"}`, escapeJSON(createErr.Error())), nil
 		},
 	)
 	return t
 }
 
-var (
-	denyRE   = regexp.MustCompile(`(?i)\\b(insert|update|delete|drop|alter|truncate|create|grant|revoke)\\b`)
-	semiRE   = regexp.MustCompile(`;`)
-	allowTbl = []string{"users", "members", "api_request_logs", "api_error_logs", "api_trace_spans", "permission_audit_logs"}
-)
+// runReadonly executes the query in a read-only transaction with a statement
+// timeout and returns at most dbQueryMaxRows rows.
+func runReadonly(db *gorm.DB, sql string) ([]map[string]any, bool, error) {
+	tx := db.Begin()
+	if tx.Error != nil {
+		return nil, false, tx.Error
+	}
+	// Nothing is ever written; always roll back.
+	defer tx.Rollback()
 
-func validateReadonlySQL(sql string) error {
-	if semiRE.MatchString(sql) {
-		return errf("semicolon is not allowed")
+	if err := tx.Exec("SET TRANSACTION READ ONLY").Error; err != nil {
+		return nil, false, err
 	}
-	low := strings.ToLower(strings.TrimSpace(sql))
-	if !(strings.HasPrefix(low, "select ") || strings.HasPrefix(low, "with ")) {
-		return errf("only SELECT/WITH is allowed")
+	if err := tx.Exec(fmt.Sprintf("SET LOCAL statement_timeout = %d", dbQueryTimeout.Milliseconds())).Error; err != nil {
+		return nil, false, err
 	}
-	if denyRE.MatchString(sql) {
-		return errf("write operations are forbidden")
+
+	rows, err := tx.Raw(sql).Rows()
+	if err != nil {
+		return nil, false, err
 	}
-	ok := false
-	for _, t := range allowTbl {
-		if strings.Contains(low, t) {
-			ok = true
+	defer rows.Close()
+
+	out := make([]map[string]any, 0)
+	truncated := false
+	for rows.Next() {
+		if len(out) >= dbQueryMaxRows {
+			truncated = true
 			break
 		}
+		row := map[string]any{}
+		if err := tx.ScanRows(rows, &row); err != nil {
+			return nil, false, err
+		}
+		out = append(out, row)
 	}
-	if !ok {
-		return errf("query must target an allowlisted table")
+	if err := rows.Err(); err != nil {
+		return nil, false, err
 	}
-	return nil
+	return out, truncated, nil
+}
+
+func isAllowedTable(name string) bool {
+	for _, t := range allowTbl {
+		if t == name {
+			return true
+		}
+	}
+	return false
+}
+
+// readonlyCheck is the outcome of validating a query.
+type readonlyCheck struct {
+	relations []string
+	hasLimit  bool
+	stmt      string // deparsed statement, without comments or a trailing ;
+}
+
+// limited wraps the statement in a subquery capped at limit rows.
+func (c *readonlyCheck) limited(limit int) string {
+	return fmt.Sprintf("SELECT * FROM (%s) AS _q LIMIT %d", c.stmt, limit)
 }
 
-func hasLimit(sql string) bool {
-	return regexp.MustCompile(`(?i)\\blimit\\b`).MatchString(sql)
+// validateReadonlySQL parses the query with the PostgreSQL parser and checks
+// that it is a single plain SELECT touching only allowlisted relations.
+func validateReadonlySQL(sql string) (*readonlyCheck, error) {
+	tree, err := pgquery.Parse(sql)
+	if err != nil {
+		return nil, errf("syntax error: " + err.Error())
+	}
+	if len(tree.GetStmts()) != 1 {
+		return nil, errf("exactly one statement is allowed")
+	}
+	sel := tree.GetStmts()[0].GetStmt().GetSelectStmt()
+	if sel == nil {
+		return nil, errf("only SELECT/WITH is allowed")
+	}
+
+	w := &sqlWalker{relations: map[string]bool{}, aliases: map[string]bool{}}
+	w.walk(sel.ProtoReflect(), map[string]bool{}, false, false)
+	if w.err != nil {
+		return nil, w.err
+	}
+	for _, name := range w.wholeRowRefs {
+		if w.aliases[name] {
+			return nil, errf(fmt.Sprintf("whole-row reference %q is not allowed; list columns explicitly", name))
+		}
+	}
+	if len(w.relations) == 0 {
+		return nil, errf("query must target an allowlisted table: " + strings.Join(allowTbl, ", "))
+	}
+
+	stmt, err := pgquery.Deparse(tree)
+	if err != nil {
+		return nil, errf("deparse failed: " + err.Error())
+	}
+	check := &readonlyCheck{hasLimit: sel.GetLimitCount() != nil, stmt: stmt}
+	for name := range w.relations {
+		check.relations = append(check.relations, name)
+	}
+	sort.Strings(check.relations)
+	return check, nil
+}
+
+// sqlWalker walks the parse tree and records the first violation.
+type sqlWalker struct {
+	err          error
+	relations    map[string]bool // allowlisted tables referenced
+	aliases      map[string]bool // relation names, aliases and CTE names in scope anywhere
+	wholeRowRefs []string        // single-field column refs that may name a relation
+	// topStars holds the select list items that are a plain * or rel.*; a
+	// star anywhere else (casts, ROW(), ARRAY[], …) would return whole rows
+	// under a column name redactRows does not know.
+	topStars map[any]bool
+}
+
+func (w *sqlWalker) fail(msg string) {
+	if w.err == nil {
+		w.err = errf(msg)
+	}
+}
+
+// walk visits a node. ctes holds the CTE names visible at this point so that
+// references to them are not mistaken for tables.
+func (w *sqlWalker) walk(m protoreflect.Message, ctes map[string]bool, inSetOp, inFunc bool) {
+	if w.err != nil || !m.IsValid() {
+		return
+	}
+
+	switch n := m.Interface().(type) {
+	case *pgast.SelectStmt:
+		if n.GetIntoClause() != nil {
+			w.fail("SELECT INTO is not allowed")
+			return
+		}
+		if len(n.GetLockingClause()) > 0 {
+			w.fail("FOR UPDATE/SHARE is not allowed")
+			return
+		}
+		scope := ctes
+		if wc := n.GetWithClause(); wc != nil {
+			scope = copyScope(ctes)
+			if wc.GetRecursive() {
+				for _, c := range wc.GetCtes() {
+					scope[strings.ToLower(c.GetCommonTableExpr().GetCtename())] = true
+				}
+			}
+			for _, c := range wc.GetCtes() {
+				cte := c.GetCommonTableExpr()
+				if len(cte.GetAliascolnames()) > 0 {
+					w.fail("column alias lists are not allowed")
+					return
+				}
+				name := strings.ToLower(cte.GetCtename())
+				w.aliases[name] = true
+				// A non-recursive CTE only sees the CTEs declared before it.
+				w.walk(cte.GetCtequery().ProtoReflect(), copyScope(scope), false, false)
+				scope[name] = true
+			}
+		}
+		setOp := inSetOp || n.GetOp() != pgast.SetOperation_SETOP_NONE
+		m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+			if fd.Name() == "with_clause" {
+				return true
+			}
+			w.walkValue(fd, v, scope, setOp, inFunc)
+			return w.err == nil
+		})
+		return
+
+	case *pgast.RangeVar:
+		name := strings.ToLower(n.GetRelname())
+		if alias := n.GetAlias(); alias != nil {
+			if len(alias.GetColnames()) > 0 {
+				w.fail("column alias lists are not allowed")
+				return
+			}
+			w.aliases[strings.ToLower(alias.GetAliasname())] = true
+		}
+		w.aliases[name] = true
+		if n.GetSchemaname() == "" && n.GetCatalogname() == "" && ctes[name] {
+			return
+		}
+		if n.GetCatalogname() != "" || (n.GetSchemaname() != "" && !strings.EqualFold(n.GetSchemaname(), "public")) {
+			w.fail(fmt.Sprintf("relation %q is not allowlisted", qualifiedName(n)))
+			return
+		}
+		if !isAllowedTable(name) {
+			w.fail(fmt.Sprintf("relation %q is not allowlisted; allowed: %s", name, strings.Join(allowTbl, ", ")))
+			return
+		}
+		w.relations[name] = true
+		return
+
+	case *pgast.ResTarget:
+		if ref := n.GetVal().GetColumnRef(); ref != nil {
+			w.topStar(ref)
+		} else if ind := n.GetVal().GetAIndirection(); ind != nil {
+			w.topStar(ind)
+		}
+
+	case *pgast.Alias:
+		if len(n.GetColnames()) > 0 {
+			w.fail("column alias lists are not allowed")
+			return
+		}
+		w.aliases[strings.ToLower(n.GetAliasname())] = true
+		return
+
+	case *pgast.FuncCall:
+		names := make([]string, 0, len(n.GetFuncname()))
+		for _, part := range n.GetFuncname() {
+			names = append(names, strings.ToLower(part.GetString_().GetSval()))
+		}
+		fn := names[len(names)-1]
+		for _, prefix := range deniedFuncPrefixes {
+			if strings.HasPrefix(fn, prefix) {
+				w.fail(fmt.Sprintf("function %q is not allowed", strings.Join(names, ".")))
+				return
+			}
+		}
+		if len(names) > 1 && names[0] != "pg_catalog" {
+			w.fail(fmt.Sprintf("function %q is not allowed", strings.Join(names, ".")))
+			return
+		}
+		inFunc = true
+
+	case *pgast.ColumnRef:
+		fields := n.GetFields()
+		for _, f := range fields {
+			if f.GetAStar() != nil {
+				if !w.starAllowed(n, inSetOp, inFunc) {
+					return
+				}
+				continue
+			}
+			if col := f.GetString_().GetSval(); sensitiveColRE.MatchString(col) {
+				w.fail(fmt.Sprintf("column %q is sensitive and cannot be queried", col))
+				return
+			}
+		}
+		if len(fields) == 1 && fields[0].GetString_() != nil {
+			w.wholeRowRefs = append(w.wholeRowRefs, strings.ToLower(fields[0].GetString_().GetSval()))
+		}
+		return
+
+	case *pgast.A_Indirection:
+		for _, f := range n.GetIndirection() {
+			if f.GetAStar() != nil && !w.starAllowed(n, inSetOp, inFunc) {
+				return
+			}
+			if col := f.GetString_().GetSval(); sensitiveColRE.MatchString(col) {
+				w.fail(fmt.Sprintf("column %q is sensitive and cannot be queried", col))
+				return
+			}
+		}
+	}
+
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		w.walkValue(fd, v, ctes, inSetOp, inFunc)
+		return w.err == nil
+	})
+}
+
+func (w *sqlWalker) topStar(n any) {
+	if w.topStars == nil {
+		w.topStars = map[any]bool{}
+	}
+	w.topStars[n] = true
+}
+
+// starAllowed reports whether the * of n may stay, failing the walk if not.
+func (w *sqlWalker) starAllowed(n any, inSetOp, inFunc bool) bool {
+	switch {
+	case inFunc:
+		w.fail("* is not allowed inside function calls")
+	case inSetOp:
+		w.fail("* is not allowed in UNION/INTERSECT/EXCEPT; list columns explicitly")
+	case !w.topStars[n]:
+		w.fail("* is only allowed as a select list item; list columns explicitly")
+	default:
+		return true
+	}
+	return false
+}
+
+func (w *sqlWalker) walkValue(fd protoreflect.FieldDescriptor, v protoreflect.Value, ctes map[string]bool, inSetOp, inFunc bool) {
+	if fd.Kind() != protoreflect.MessageKind {
+		return
+	}
+	if fd.IsList() {
+		list := v.List()
+		for i := 0; i < list.Len() && w.err == nil; i++ {
+			w.walk(list.Get(i).Message(), ctes, inSetOp, inFunc)
+		}
+		return
+	}
+	if fd.IsMap() {
+		return
+	}
+	w.walk(v.Message(), ctes, inSetOp, inFunc)
+}
+
+func copyScope(in map[string]bool) map[string]bool {
+	out := make(map[string]bool, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
+
+func qualifiedName(rv *pgast.RangeVar) string {
+	parts := make([]string, 0, 3)
+	for _, p := range []string{rv.GetCatalogname(), rv.GetSchemaname(), rv.GetRelname()} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// redactRows masks sensitive columns in place and returns their names.
+func redactRows(rows []map[string]any) []string {
+	seen := map[string]bool{}
+	for _, row := range rows {
+		for col := range row {
+			if sensitiveColRE.MatchString(col) {
+				row[col] = redactedValue
+				seen[col] = true
+			}
+		}
+	}
+	cols := make([]string, 0, len(seen))
+	for col := range seen {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+	return cols
 }
 
 type simpleErr string
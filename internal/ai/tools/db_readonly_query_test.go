@@ -0,0 +1,96 @@
+package tools
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateReadonlySQLAccepts(t *testing.T) {
+	cases := []struct {
+		sql       string
+		relations []string
+		hasLimit  bool
+	}{
+		{"SELECT id, username FROM users WHERE role = 'admin'", []string{"users"}, false},
+		{"select count(*) from api_error_logs limit 10", []string{"api_error_logs"}, true},
+		{"WITH recent AS (SELECT trace_id FROM api_request_logs WHERE status_code >= 500) SELECT s.span_name FROM api_trace_spans s JOIN recent r ON r.trace_id = s.trace_id", []string{"api_request_logs", "api_trace_spans"}, false},
+		{"SELECT * FROM public.members", []string{"members"}, false},
+		{"SELECT u.*, m.role FROM users u JOIN members m ON m.user_id = u.id", []string{"members", "users"}, false},
+		{"SELECT id FROM users UNION SELECT user_id FROM members", []string{"members", "users"}, false},
+	}
+	for _, c := range cases {
+		got, err := validateReadonlySQL(c.sql)
+		if err != nil {
+			t.Errorf("validateReadonlySQL(%q) returned error: %v", c.sql, err)
+			continue
+		}
+		if !reflect.DeepEqual(got.relations, c.relations) || got.hasLimit != c.hasLimit {
+			t.Errorf("validateReadonlySQL(%q) = %+v, want relations=%v hasLimit=%v", c.sql, got, c.relations, c.hasLimit)
+		}
+	}
+}
+
+func TestLimitedSQLDropsTrailingSemicolon(t *testing.T) {
+	for _, sql := range []string{
+		"SELECT id FROM users;",
+		"SELECT id FROM users ;  \n",
+		"SELECT id FROM users; -- recent first",
+	} {
+		checked, err := validateReadonlySQL(sql)
+		if err != nil {
+			t.Errorf("validateReadonlySQL(%q) returned error: %v", sql, err)
+			continue
+		}
+		limited := checked.limited(dbQueryDefaultLimit)
+		if _, err := validateReadonlySQL(limited); err != nil {
+			t.Errorf("limited(%q) = %q is not valid: %v", sql, limited, err)
+		}
+		if strings.Contains(limited, ";") {
+			t.Errorf("limited(%q) = %q keeps the semicolon", sql, limited)
+		}
+	}
+}
+
+func TestValidateReadonlySQLRejects(t *testing.T) {
+	cases := []string{
+		"DELETE FROM users",
+		"SELECT 1; DROP TABLE users",
+		"SELECT * FROM secrets",
+		"SELECT * FROM pg_catalog.pg_authid",
+		"SELECT id FROM users WHERE username IN (SELECT usename FROM pg_user)",
+		"SELECT * INTO tmp_users FROM users",
+		"SELECT * FROM users FOR UPDATE",
+		"SELECT password_hash FROM users",
+		"SELECT id FROM users WHERE password_hash LIKE 'a%'",
+		"SELECT u FROM users u",
+		"SELECT row_to_json(u.*) FROM users u",
+		"SELECT (u.*)::text FROM users u",
+		"SELECT ROW(u.*) FROM users u",
+		"SELECT CAST(u.* AS text) FROM users u",
+		"SELECT ARRAY[u.*] FROM users u",
+		"SELECT * FROM users AS u(a, b, c, d)",
+		"SELECT 1, 'x', 'y', 'z', 'r', 'i', 1, now(), now() UNION ALL SELECT * FROM users",
+		"SELECT query_to_xml('select * from secrets', true, true, '')",
+		"SELECT pg_sleep(10) FROM users",
+		"WITH t AS (SELECT * FROM secrets) SELECT * FROM t, users",
+		"SELECT 1",
+		"SELECT * FROM users WHERE",
+	}
+	for _, sql := range cases {
+		if _, err := validateReadonlySQL(sql); err == nil {
+			t.Errorf("validateReadonlySQL(%q) should fail", sql)
+		}
+	}
+}
+
+func TestRedactRows(t *testing.T) {
+	rows := []map[string]any{{"id": 1, "password_hash": "$2a$10$abc"}}
+	cols := redactRows(rows)
+	if rows[0]["password_hash"] != redactedValue || rows[0]["id"] != 1 {
+		t.Errorf("unexpected row after redaction: %v", rows[0])
+	}
+	if !reflect.DeepEqual(cols, []string{"password_hash"}) {
+		t.Errorf("redacted columns = %v", cols)
+	}
+}
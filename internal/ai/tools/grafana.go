@@ -0,0 +1,480 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	obsgrafana "github.com/WyRainBow/ops-portal/internal/observability"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+)
+
+// Grafana 工具通过 OBS_GRAFANA_URL / GRAFANA_API_KEY 访问 Grafana。面板数据经
+// Grafana 数据源代理（/api/datasources/proxy）查询，支持 Prometheus 与 Loki 面板；
+// 写注释需要 API key 具备 annotations:write 权限。
+
+const (
+	grafanaMaxDashboards = 20
+	grafanaMaxTargets    = 10
+	grafanaMaxLogLines   = 5
+	grafanaDefaultRange  = time.Hour
+)
+
+// GrafanaSearchInput is the input of grafana_search_dashboards.
+type GrafanaSearchInput struct {
+	Query     string   `json:"query,omitempty" jsonschema:"description=Free text matched against dashboard titles"`
+	Tags      []string `json:"tags,omitempty" jsonschema:"description=Only dashboards having all these tags"`
+	Service   string   `json:"service,omitempty" jsonschema:"description=Service name (e.g. the job or service label of an alert); dashboards titled or tagged with it are returned first"`
+	AlertName string   `json:"alertname,omitempty" jsonschema:"description=Alert name; also returns a link to the best dashboard or an Explore view for the alert"`
+	Severity  string   `json:"severity,omitempty" jsonschema:"description=Alert severity, used with alertname"`
+	StartsAt  int64    `json:"starts_at,omitempty" jsonschema:"description=Alert start (unix seconds/ms/ns), used for the time range of links. Optional, defaults to 1 hour ago"`
+}
+
+// GrafanaPanelInput is the input of grafana_panel_data.
+type GrafanaPanelInput struct {
+	DashboardUID string            `json:"dashboard_uid" jsonschema:"description=Dashboard uid from grafana_search_dashboards"`
+	PanelID      int               `json:"panel_id,omitempty" jsonschema:"description=Panel id. Omit panel_id and panel_title to list the panels and their queries"`
+	PanelTitle   string            `json:"panel_title,omitempty" jsonschema:"description=Panel title (substring match) when the id is unknown"`
+	Start        int64             `json:"start,omitempty" jsonschema:"description=Start time (unix seconds/ms/ns). Optional, defaults to 1 hour ago"`
+	End          int64             `json:"end,omitempty" jsonschema:"description=End time (unix seconds/ms/ns). Optional, defaults to now"`
+	Variables    map[string]string `json:"variables,omitempty" jsonschema:"description=Dashboard variable overrides, e.g. {\"job\":\"resume-backend\"}"`
+	MaxPoints    int               `json:"max_points,omitempty" jsonschema:"description=Max points per series after downsampling. Default 30, max 200"`
+}
+
+// GrafanaAnnotateInput is the input of grafana_annotate.
+type GrafanaAnnotateInput struct {
+	Kind         string   `json:"kind" jsonschema:"description=incident_start, diagnosis or resolved"`
+	Text         string   `json:"text" jsonschema:"description=Annotation text, e.g. the alert summary or the diagnosis conclusion"`
+	Service      string   `json:"service,omitempty" jsonschema:"description=Service name; used as a tag and to find the service dashboard when dashboard_uid is empty"`
+	DashboardUID string   `json:"dashboard_uid,omitempty" jsonschema:"description=Dashboard uid. Optional"`
+	PanelID      int      `json:"panel_id,omitempty" jsonschema:"description=Panel id to attach to. Optional"`
+	Time         int64    `json:"time,omitempty" jsonschema:"description=Time of the event (unix seconds/ms/ns). Optional, defaults to now"`
+	TimeEnd      int64    `json:"time_end,omitempty" jsonschema:"description=End time for a region annotation, e.g. incident start to diagnosis. Optional"`
+	Tags         []string `json:"tags,omitempty" jsonschema:"description=Extra tags"`
+}
+
+// GrafanaDashboardItem is a search result.
+type GrafanaDashboardItem struct {
+	UID   string   `json:"uid"`
+	Title string   `json:"title"`
+	Tags  []string `json:"tags,omitempty"`
+	URL   string   `json:"url"`
+}
+
+// grafanaRange resolves start/end with a one hour default.
+func grafanaRange(start, end int64) (time.Time, time.Time, error) {
+	to := time.Now()
+	if v := promUnixSeconds(end); v > 0 {
+		to = time.Unix(v, 0)
+	}
+	from := to.Add(-grafanaDefaultRange)
+	if v := promUnixSeconds(start); v > 0 {
+		from = time.Unix(v, 0)
+	}
+	if !from.Before(to) {
+		return from, to, fmt.Errorf("start must be before end")
+	}
+	if to.Sub(from) > promMaxRange {
+		return from, to, fmt.Errorf("time range exceeds %s", promMaxRange)
+	}
+	return from, to, nil
+}
+
+// NewGrafanaSearchDashboardsTool 创建 Grafana 仪表盘搜索工具
+func NewGrafanaSearchDashboardsTool() tool.InvokableTool {
+	return newGrafanaSearchDashboardsTool(obsgrafana.DefaultClient())
+}
+
+func newGrafanaSearchDashboardsTool(c *obsgrafana.Client) tool.InvokableTool {
+	t, err := utils.InferOptionableTool(
+		"grafana_search_dashboards",
+		"Find Grafana dashboards relevant to a service or alert. Returns dashboard uids and links; pass a uid to grafana_panel_data to read panel queries and data.",
+		func(ctx context.Context, input *GrafanaSearchInput, opts ...tool.Option) (output string, err error) {
+			from, to, _ := grafanaRange(input.StartsAt, 0)
+			if input.StartsAt > 0 {
+				to = from.Add(grafanaDefaultRange)
+				from = from.Add(-15 * time.Minute)
+			}
+			tr := obsgrafana.NewTimeRange(from, to)
+
+			// 依次按 query、service、alertname 搜索，命中多次的排前面
+			terms := []string{}
+			for _, s := range []string{input.Query, input.Service, input.AlertName} {
+				if s = strings.TrimSpace(s); s != "" {
+					terms = append(terms, s)
+				}
+			}
+			if len(terms) == 0 {
+				terms = []string{""}
+			}
+			hits := map[string]int{}
+			found := map[string]obsgrafana.Dashboard{}
+			for _, term := range terms {
+				list, err := c.SearchDashboards(ctx, term, input.Tags...)
+				if err != nil {
+					return promError(err), nil
+				}
+				for _, d := range list {
+					hits[d.UID]++
+					found[d.UID] = d
+				}
+				if svc := strings.TrimSpace(input.Service); svc != "" && term == svc {
+					// 以服务名为 tag 的仪表盘也算命中
+					if tagged, err := c.SearchDashboards(ctx, "", append(append([]string{}, input.Tags...), svc)...); err == nil {
+						for _, d := range tagged {
+							hits[d.UID]++
+							found[d.UID] = d
+						}
+					}
+				}
+			}
+
+			uids := make([]string, 0, len(found))
+			for uid := range found {
+				uids = append(uids, uid)
+			}
+			sort.Slice(uids, func(i, j int) bool {
+				if hits[uids[i]] != hits[uids[j]] {
+					return hits[uids[i]] > hits[uids[j]]
+				}
+				return found[uids[i]].Title < found[uids[j]].Title
+			})
+			if len(uids) > grafanaMaxDashboards {
+				uids = uids[:grafanaMaxDashboards]
+			}
+			items := make([]GrafanaDashboardItem, 0, len(uids))
+			for _, uid := range uids {
+				d := found[uid]
+				items = append(items, GrafanaDashboardItem{UID: uid, Title: d.Title, Tags: d.Tags, URL: c.DashboardURL(uid, tr)})
+			}
+
+			out := map[string]any{"success": true, "count": len(items), "dashboards": items}
+			if input.AlertName != "" {
+				link, err := c.BuildAlertDashboard(ctx, &obsgrafana.AlertContext{
+					AlertName: input.AlertName,
+					Severity:  input.Severity,
+					StartsAt:  from,
+					EndsAt:    to,
+				})
+				if err == nil {
+					out["alert_link"] = link
+				}
+				out["explore_links"] = obsgrafana.GenerateLinks(&obsgrafana.AlertingLink{AlertName: input.AlertName, TimeRange: tr})
+			}
+			return promJSON(out), nil
+		},
+	)
+	if err != nil {
+		return createErrorGrafanaTool("grafana_search_dashboards", err)
+	}
+	return t
+}
+
+// NewGrafanaPanelDataTool 创建 Grafana 面板数据工具
+func NewGrafanaPanelDataTool() tool.InvokableTool {
+	return newGrafanaPanelDataTool(obsgrafana.DefaultClient())
+}
+
+func newGrafanaPanelDataTool(c *obsgrafana.Client) tool.InvokableTool {
+	t, err := utils.InferOptionableTool(
+		"grafana_panel_data",
+		"Read a Grafana dashboard panel: its queries (with dashboard variables substituted) and the data they return over a time range, queried through the Grafana datasource proxy. Supports Prometheus and Loki panels. Without panel_id/panel_title lists the dashboard's panels.",
+		func(ctx context.Context, input *GrafanaPanelInput, opts ...tool.Option) (output string, err error) {
+			if strings.TrimSpace(input.DashboardUID) == "" {
+				return promError(fmt.Errorf("dashboard_uid is required")), nil
+			}
+			from, to, err := grafanaRange(input.Start, input.End)
+			if err != nil {
+				return promError(err), nil
+			}
+			maxPoints := input.MaxPoints
+			if maxPoints <= 0 {
+				maxPoints = 30
+			}
+			if maxPoints > 200 {
+				maxPoints = 200
+			}
+
+			dash, err := c.GetDashboard(ctx, input.DashboardUID)
+			if err != nil {
+				return promError(err), nil
+			}
+			vars := map[string]string{}
+			for k, v := range dash.Variables {
+				vars[k] = v
+			}
+			for k, v := range input.Variables {
+				vars[k] = v
+			}
+			step := autoStep(to.Sub(from))
+			for k, v := range obsgrafana.BuiltinVariables(to.Sub(from), step) {
+				vars[k] = v
+			}
+
+			if input.PanelID <= 0 && strings.TrimSpace(input.PanelTitle) == "" {
+				panels := make([]map[string]any, 0, len(dash.Panels))
+				for _, p := range dash.Panels {
+					queries := []string{}
+					for _, tg := range p.Targets {
+						if tg.Expr != "" && !tg.Hide {
+							queries = append(queries, tg.Expr)
+						}
+					}
+					panels = append(panels, map[string]any{"id": p.ID, "title": p.Title, "type": p.Type, "queries": queries})
+				}
+				return promJSON(map[string]any{
+					"success":   true,
+					"dashboard": dash.Title,
+					"url":       dash.URL,
+					"variables": dash.Variables,
+					"panels":    panels,
+				}), nil
+			}
+
+			panel, ok := dash.FindPanel(input.PanelID, input.PanelTitle)
+			if !ok {
+				return promError(fmt.Errorf("panel not found in dashboard %s; call without panel_id to list panels", dash.Title)), nil
+			}
+			datasources, err := c.Datasources(ctx)
+			if err != nil {
+				return promError(err), nil
+			}
+
+			results := []map[string]any{}
+			for _, tg := range panel.Targets {
+				if tg.Hide || strings.TrimSpace(tg.Expr) == "" {
+					continue
+				}
+				if len(results) >= grafanaMaxTargets {
+					break
+				}
+				ref := obsgrafana.DatasourceRef{}
+				if panel.Datasource != nil {
+					ref = *panel.Datasource
+				}
+				if tg.Datasource != nil && tg.Datasource.UID != "-- Mixed --" {
+					ref = *tg.Datasource
+				}
+				expr := obsgrafana.ExpandVariables(tg.Expr, vars)
+				res := map[string]any{"ref_id": tg.RefID, "query": expr}
+				ds, err := obsgrafana.ResolveDatasource(ref, vars, datasources)
+				if err != nil {
+					res["error"] = err.Error()
+					results = append(results, res)
+					continue
+				}
+				res["datasource"] = ds.Name
+				data, err := grafanaQueryTarget(ctx, c, ds, expr, from, to, step, maxPoints)
+				if err != nil {
+					res["error"] = err.Error()
+				} else {
+					for k, v := range data {
+						res[k] = v
+					}
+				}
+				results = append(results, res)
+			}
+
+			return promJSON(map[string]any{
+				"success":   true,
+				"dashboard": dash.Title,
+				"panel":     map[string]any{"id": panel.ID, "title": panel.Title, "type": panel.Type},
+				"url":       c.PanelURL(dash.UID, panel.ID, obsgrafana.NewTimeRange(from, to)),
+				"start":     from.Unix(),
+				"end":       to.Unix(),
+				"targets":   results,
+			}), nil
+		},
+	)
+	if err != nil {
+		return createErrorGrafanaTool("grafana_panel_data", err)
+	}
+	return t
+}
+
+// grafanaQueryTarget runs one panel query through the datasource proxy and
+// summarises the result.
+func grafanaQueryTarget(ctx context.Context, c *obsgrafana.Client, ds obsgrafana.Datasource, expr string, from, to time.Time, step time.Duration, maxPoints int) (map[string]any, error) {
+	var path string
+	params := url.Values{}
+	params.Set("query", expr)
+	switch ds.Type {
+	case "prometheus":
+		path = "/api/v1/query_range"
+		params.Set("start", strconv.FormatInt(from.Unix(), 10))
+		params.Set("end", strconv.FormatInt(to.Unix(), 10))
+		params.Set("step", strconv.FormatInt(int64(step.Seconds()), 10))
+	case "loki":
+		path = "/loki/api/v1/query_range"
+		params.Set("start", strconv.FormatInt(from.UnixNano(), 10))
+		params.Set("end", strconv.FormatInt(to.UnixNano(), 10))
+		params.Set("step", strconv.FormatInt(int64(step.Seconds()), 10))
+		params.Set("limit", "100")
+	default:
+		return nil, fmt.Errorf("datasource type %q is not supported, only prometheus and loki", ds.Type)
+	}
+	body, err := c.ProxyGet(ctx, ds.UID, path, params)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Status string `json:"status"`
+		Error  string `json:"error"`
+		Data   struct {
+			ResultType string `json:"resultType"`
+			Result     []struct {
+				Metric map[string]string `json:"metric"`
+				Stream map[string]string `json:"stream"`
+				Values [][]any           `json:"values"`
+			} `json:"result"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+	if resp.Status != "success" {
+		return nil, fmt.Errorf("query failed: %s", resp.Error)
+	}
+
+	switch resp.Data.ResultType {
+	case "matrix":
+		series := make([]PromSeriesSummary, 0, len(resp.Data.Result))
+		for i, r := range resp.Data.Result {
+			if i >= promMaxSeries {
+				break
+			}
+			points := make([]PromPoint, 0, len(r.Values))
+			for _, pair := range r.Values {
+				if p, ok := parseSample(pair); ok {
+					points = append(points, p)
+				}
+			}
+			series = append(series, summarizeSeries(r.Metric, points, maxPoints))
+		}
+		return map[string]any{
+			"result_type":  "matrix",
+			"series_total": len(resp.Data.Result),
+			"truncated":    len(resp.Data.Result) > promMaxSeries,
+			"series":       series,
+		}, nil
+	case "streams":
+		streams := make([]map[string]any, 0, len(resp.Data.Result))
+		total := 0
+		for _, r := range resp.Data.Result {
+			total += len(r.Values)
+			if len(streams) >= promMaxSeries {
+				continue
+			}
+			lines := []string{}
+			for _, v := range r.Values {
+				if len(lines) >= grafanaMaxLogLines {
+					break
+				}
+				if len(v) >= 2 {
+					if s, ok := v[1].(string); ok {
+						lines = append(lines, s)
+					}
+				}
+			}
+			streams = append(streams, map[string]any{"labels": r.Stream, "lines": len(r.Values), "sample": lines})
+		}
+		return map[string]any{"result_type": "streams", "lines_total": total, "streams": streams}, nil
+	default:
+		return map[string]any{"result_type": resp.Data.ResultType, "results": len(resp.Data.Result)}, nil
+	}
+}
+
+var grafanaAnnotationKinds = map[string]string{
+	"incident_start": "Incident started",
+	"diagnosis":      "Diagnosis",
+	"resolved":       "Resolved",
+}
+
+// NewGrafanaAnnotateTool 创建 Grafana 注释工具
+func NewGrafanaAnnotateTool() tool.InvokableTool {
+	return newGrafanaAnnotateTool(obsgrafana.DefaultClient())
+}
+
+func newGrafanaAnnotateTool(c *obsgrafana.Client) tool.InvokableTool {
+	t, err := utils.InferOptionableTool(
+		"grafana_annotate",
+		"Write a Grafana annotation on the service dashboard marking an incident start, the diagnosis conclusion or the resolution, so the timeline is visible next to the metrics. Use once per event at the end of a diagnosis.",
+		func(ctx context.Context, input *GrafanaAnnotateInput, opts ...tool.Option) (output string, err error) {
+			kind := strings.ToLower(strings.TrimSpace(input.Kind))
+			prefix, ok := grafanaAnnotationKinds[kind]
+			if !ok {
+				return promError(fmt.Errorf("invalid kind %q, use incident_start, diagnosis or resolved", input.Kind)), nil
+			}
+			text := strings.TrimSpace(input.Text)
+			if text == "" {
+				return promError(fmt.Errorf("text is required")), nil
+			}
+			text = truncateRunes(text, 2000)
+			at := time.Now()
+			if v := promUnixSeconds(input.Time); v > 0 {
+				at = time.Unix(v, 0)
+			}
+
+			service := strings.TrimSpace(input.Service)
+			dashUID := strings.TrimSpace(input.DashboardUID)
+			if dashUID == "" && service != "" {
+				if list, err := c.SearchDashboards(ctx, service); err == nil && len(list) > 0 {
+					dashUID = list[0].UID
+				}
+			}
+
+			tags := []string{"ops-portal", kind}
+			if service != "" {
+				tags = append(tags, "service:"+service)
+			}
+			tags = append(tags, input.Tags...)
+			ann := &obsgrafana.Annotation{
+				Text:         prefix + ": " + text,
+				Time:         at.UnixMilli(),
+				Tags:         tags,
+				DashboardUID: dashUID,
+				PanelID:      input.PanelID,
+			}
+			if v := promUnixSeconds(input.TimeEnd); v > 0 {
+				if end := time.Unix(v, 0); end.After(at) {
+					ann.TimeEnd = end.UnixMilli()
+				}
+			}
+			if err := c.CreateAnnotation(ctx, ann); err != nil {
+				return promError(err), nil
+			}
+
+			out := map[string]any{"success": true, "time": at.Unix(), "tags": tags}
+			if dashUID != "" {
+				out["dashboard_uid"] = dashUID
+				out["url"] = c.DashboardURL(dashUID, obsgrafana.NewTimeRange(at.Add(-30*time.Minute), at.Add(30*time.Minute)))
+			} else {
+				out["note"] = "no dashboard found, wrote an organization-wide annotation"
+			}
+			return promJSON(out), nil
+		},
+	)
+	if err != nil {
+		return createErrorGrafanaTool("grafana_annotate", err)
+	}
+	return t
+}
+
+// createErrorGrafanaTool returns a tool that always returns an error
+func createErrorGrafanaTool(name string, createErr error) tool.InvokableTool {
+	t, _ := utils.InferOptionableTool(
+		name,
+		"Error tool - Grafana tool failed to initialize",
+		func(ctx context.Context, input any, opts ...tool.Option) (output string, err error) {
+			return fmt.Sprintf(`{"success":false,"error":"Tool initialization failed: %s"}`, escapeJSON(createErr.Error())), nil
+		},
+	)
+	return t
+}
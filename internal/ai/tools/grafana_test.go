@@ -0,0 +1,138 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	obsgrafana "github.com/WyRainBow/ops-portal/internal/observability"
+	"github.com/cloudwego/eino/components/tool"
+)
+
+const grafanaTestDashboard = `{
+  "dashboard": {
+    "uid": "svc", "title": "Resume Backend", "tags": ["resume-backend"],
+    "templating": {"list": [{"name": "job", "current": {"value": "resume-backend"}}, {"name": "ds", "current": {"value": "prom-main"}}]},
+    "panels": [
+      {"id": 1, "type": "timeseries", "title": "Request rate", "datasource": {"type": "prometheus", "uid": "${ds}"},
+       "targets": [{"refId": "A", "expr": "sum(rate(http_requests_total{job=\"$job\"}[$__rate_interval]))"}]},
+      {"id": 10, "type": "row", "title": "Logs", "collapsed": true, "panels": [
+        {"id": 11, "type": "logs", "title": "Errors", "datasource": "Loki",
+         "targets": [{"refId": "A", "expr": "{job=\"[[job]]\"} |= \"error\""}]}
+      ]}
+    ]
+  },
+  "meta": {"url": "/d/svc/resume-backend"}
+}`
+
+func newGrafanaTestServer(t *testing.T, annotations chan<- map[string]any) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Path == "/api/search":
+			if r.URL.Query().Get("query") == "resume-backend" || r.URL.Query().Get("tag") == "resume-backend" {
+				fmt.Fprint(w, `[{"uid":"svc","title":"Resume Backend","tags":["resume-backend"]}]`)
+				return
+			}
+			fmt.Fprint(w, `[]`)
+		case r.URL.Path == "/api/dashboards/uid/svc":
+			fmt.Fprint(w, grafanaTestDashboard)
+		case r.URL.Path == "/api/datasources":
+			fmt.Fprint(w, `[{"uid":"p1","name":"prom-main","type":"prometheus","isDefault":true},{"uid":"l1","name":"Loki","type":"loki"}]`)
+		case r.URL.Path == "/api/datasources/proxy/uid/p1/api/v1/query_range":
+			q := r.URL.Query().Get("query")
+			if !strings.Contains(q, `job="resume-backend"`) || strings.Contains(q, "$") {
+				t.Errorf("variables not expanded: %s", q)
+			}
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"job":"resume-backend"},"values":[[1700000000,"1"],[1700000060,"3"]]}]}}`)
+		case r.URL.Path == "/api/datasources/proxy/uid/l1/loki/api/v1/query_range":
+			fmt.Fprint(w, `{"status":"success","data":{"resultType":"streams","result":[{"stream":{"job":"resume-backend"},"values":[["1700000000000000000","error: boom"]]}]}}`)
+		case r.URL.Path == "/api/annotations" && r.Method == http.MethodPost:
+			var body map[string]any
+			b, _ := io.ReadAll(r.Body)
+			_ = json.Unmarshal(b, &body)
+			annotations <- body
+			fmt.Fprint(w, `{"id":1,"message":"Annotation added"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func invokeGrafana(t *testing.T, tl tool.InvokableTool, args string) map[string]any {
+	t.Helper()
+	out, err := tl.InvokableRun(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res map[string]any
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatalf("invalid json %q: %v", out, err)
+	}
+	if res["success"] != true {
+		t.Fatalf("call failed: %s", out)
+	}
+	return res
+}
+
+func TestGrafanaTools(t *testing.T) {
+	annotations := make(chan map[string]any, 1)
+	srv := newGrafanaTestServer(t, annotations)
+	defer srv.Close()
+	c := obsgrafana.NewClient(srv.URL, "test-key")
+
+	res := invokeGrafana(t, newGrafanaSearchDashboardsTool(c), `{"service":"resume-backend","alertname":"HighErrorRate"}`)
+	dashboards := res["dashboards"].([]any)
+	if len(dashboards) != 1 || dashboards[0].(map[string]any)["uid"] != "svc" {
+		t.Fatalf("unexpected dashboards: %v", res["dashboards"])
+	}
+	if res["alert_link"] == nil {
+		t.Error("missing alert_link")
+	}
+
+	// panel list includes panels of collapsed rows
+	res = invokeGrafana(t, newGrafanaPanelDataTool(c), `{"dashboard_uid":"svc"}`)
+	if panels := res["panels"].([]any); len(panels) != 2 {
+		t.Fatalf("unexpected panels: %v", panels)
+	}
+
+	res = invokeGrafana(t, newGrafanaPanelDataTool(c), `{"dashboard_uid":"svc","panel_id":1}`)
+	target := res["targets"].([]any)[0].(map[string]any)
+	series := target["series"].([]any)
+	if target["datasource"] != "prom-main" || len(series) != 1 || series[0].(map[string]any)["max"].(float64) != 3 {
+		t.Fatalf("unexpected prometheus target: %v", target)
+	}
+
+	res = invokeGrafana(t, newGrafanaPanelDataTool(c), `{"dashboard_uid":"svc","panel_title":"errors"}`)
+	target = res["targets"].([]any)[0].(map[string]any)
+	if target["result_type"] != "streams" || target["lines_total"].(float64) != 1 {
+		t.Fatalf("unexpected loki target: %v", target)
+	}
+
+	res = invokeGrafana(t, newGrafanaAnnotateTool(c), `{"kind":"diagnosis","text":"connection pool exhausted","service":"resume-backend","time":1700000000,"time_end":1700000600}`)
+	if res["dashboard_uid"] != "svc" {
+		t.Errorf("annotation not attached to the service dashboard: %v", res)
+	}
+	ann := <-annotations
+	if ann["dashboardUID"] != "svc" || ann["timeEnd"].(float64) != 1700000600000 || !strings.HasPrefix(ann["text"].(string), "Diagnosis: ") {
+		t.Errorf("unexpected annotation: %v", ann)
+	}
+}
+
+func TestExpandVariables(t *testing.T) {
+	vars := map[string]string{"job": "api", "job_name": "x"}
+	got := obsgrafana.ExpandVariables(`up{job="$job",a="${job}",b="[[job]]",c="$job_name"}[$__rate_interval]`, vars)
+	want := `up{job="api",a="api",b="api",c="x"}[$__rate_interval]`
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,595 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/internal/ai/errors"
+	"github.com/WyRainBow/ops-portal/internal/ai/policy"
+	"github.com/WyRainBow/ops-portal/internal/store"
+	"github.com/WyRainBow/ops-portal/utility/middleware"
+	"github.com/WyRainBow/ops-portal/utility/textutil"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// 主机诊断工具：只允许运行白名单内的只读命令，参数按模板逐个校验后直接作为 argv
+// 传递（本机不经过 shell，SSH 时逐个单引号转义）。
+//
+// 主机：
+//   - local：ops-portal 所在机器
+//   - OPS_PORTAL_SSH_HOST 配置时的 SSH 主机，名称为 OPS_PORTAL_SSH_NAME（默认 server），
+//     使用 OPS_PORTAL_SSH_USER / OPS_PORTAL_SSH_PORT / OPS_PORTAL_SSH_KEY
+//   - config.yaml 中 diagnostic_hosts 定义的其它主机
+
+const (
+	hostCmdTimeout      = 20 * time.Second
+	hostDefaultMaxLines = 200
+	hostMaxLines        = 1000
+	hostMaxOutputBytes  = 32 * 1024
+)
+
+var (
+	hostAbsPathRE = regexp.MustCompile(`^/[A-Za-z0-9._/@+-]*$`)
+	hostUnitRE    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9@:._-]*$`)
+	hostLinesRE   = regexp.MustCompile(`^[0-9]{1,4}$`)
+	hostSinceRE   = regexp.MustCompile(`^([0-9]{1,3} ?(min|h|d) ago|today|yesterday|[0-9]{4}-[0-9]{2}-[0-9]{2}( [0-9]{2}:[0-9]{2}(:[0-9]{2})?)?)$`)
+	hostSortRE    = regexp.MustCompile(`^(%cpu|%mem|rss)$`)
+)
+
+// hostArg is a templated argument of an allowlisted command.
+type hostArg struct {
+	Pattern  *regexp.Regexp
+	Default  string
+	Required bool
+	Help     string
+}
+
+// hostCommand is an allowlisted diagnostic command. Argv elements may contain
+// {name} placeholders; an element whose placeholder is empty is dropped.
+// SummaryOnly commands return only their summary, never the raw output.
+type hostCommand struct {
+	Description string
+	Argv        []string
+	Args        map[string]hostArg
+	Summarize   func(stdout string) any
+	SummaryOnly bool
+}
+
+var hostCommands = map[string]hostCommand{
+	"disk_usage": {
+		Description: "df -hP [path]: filesystem usage",
+		Argv:        []string{"df", "-hP", "{path}"},
+		Args:        map[string]hostArg{"path": {Pattern: hostAbsPathRE, Help: "absolute path"}},
+		Summarize:   summarizeDF,
+	},
+	"inode_usage": {
+		Description: "df -iP [path]: inode usage",
+		Argv:        []string{"df", "-iP", "{path}"},
+		Args:        map[string]hostArg{"path": {Pattern: hostAbsPathRE, Help: "absolute path"}},
+		Summarize:   summarizeDF,
+	},
+	"directory_size": {
+		Description: "du -sh <path>: size of a directory",
+		Argv:        []string{"du", "-sh", "{path}"},
+		Args:        map[string]hostArg{"path": {Pattern: hostAbsPathRE, Required: true, Help: "absolute path"}},
+	},
+	"memory": {
+		Description: "free -m: memory and swap",
+		Argv:        []string{"free", "-m"},
+		Summarize:   summarizeFree,
+	},
+	"uptime": {
+		Description: "uptime: uptime and load average",
+		Argv:        []string{"uptime"},
+		Summarize:   summarizeUptime,
+	},
+	"listening_ports": {
+		Description: "ss -tnlp: listening TCP sockets and owning processes",
+		Argv:        []string{"ss", "-tnlp"},
+	},
+	"socket_summary": {
+		Description: "ss -s: socket statistics",
+		Argv:        []string{"ss", "-s"},
+	},
+	"top_processes": {
+		Description: "ps sorted by cpu/memory",
+		Argv:        []string{"ps", "-eo", "pid,user,%cpu,%mem,rss,etime,comm", "--sort=-{sort}"},
+		Args:        map[string]hostArg{"sort": {Pattern: hostSortRE, Default: "%cpu", Help: "%cpu, %mem or rss"}},
+	},
+	"service_status": {
+		Description: "systemctl status <unit>",
+		Argv:        []string{"systemctl", "status", "{unit}", "--no-pager", "-l"},
+		Args:        map[string]hostArg{"unit": {Pattern: hostUnitRE, Required: true, Help: "systemd unit, e.g. nginx"}},
+	},
+	"service_logs": {
+		Description: "journalctl -u <unit> -n <lines> [--since]",
+		Argv:        []string{"journalctl", "-u", "{unit}", "-n", "{lines}", "--no-pager", "--since={since}"},
+		Args: map[string]hostArg{
+			"unit":  {Pattern: hostUnitRE, Required: true, Help: "systemd unit, e.g. nginx"},
+			"lines": {Pattern: hostLinesRE, Default: "200", Help: "number of lines, max 1000"},
+			"since": {Pattern: hostSinceRE, Help: `e.g. "30 min ago", "today", "2026-03-01 10:00"`},
+		},
+	},
+	"kernel_log": {
+		Description: "journalctl -k -p warning: kernel warnings (OOM killer, disk errors)",
+		Argv:        []string{"journalctl", "-k", "-p", "warning", "-n", "{lines}", "--no-pager"},
+		Args:        map[string]hostArg{"lines": {Pattern: hostLinesRE, Default: "200", Help: "number of lines, max 1000"}},
+	},
+	"pm2_list": {
+		Description: "pm2 jlist: PM2 processes with status, restarts, cpu and memory",
+		Argv:        []string{"pm2", "jlist"},
+		Summarize:   summarizePM2,
+		// pm2_env 里有进程的全部环境变量（数据库密码、API key），只返回摘要
+		SummaryOnly: true,
+	},
+}
+
+// diagHost is a host the diagnostic tool may run commands on. Addr is empty
+// for the local machine.
+type diagHost struct {
+	Name string `json:"name"`
+	Addr string `json:"host"`
+	User string `json:"user"`
+	Port string `json:"port"`
+	Key  string `json:"key"`
+}
+
+// diagHosts returns the configured hosts by name.
+func diagHosts(ctx context.Context) map[string]diagHost {
+	hosts := map[string]diagHost{"local": {Name: "local"}}
+	if addr := os.Getenv("OPS_PORTAL_SSH_HOST"); addr != "" {
+		name := os.Getenv("OPS_PORTAL_SSH_NAME")
+		if name == "" {
+			name = "server"
+		}
+		hosts[name] = diagHost{
+			Name: name,
+			Addr: addr,
+			User: os.Getenv("OPS_PORTAL_SSH_USER"),
+			Port: os.Getenv("OPS_PORTAL_SSH_PORT"),
+			Key:  os.Getenv("OPS_PORTAL_SSH_KEY"),
+		}
+	}
+	var extra []diagHost
+	if v, err := g.Cfg().Get(ctx, "diagnostic_hosts"); err == nil && !v.IsNil() {
+		if err := v.Scan(&extra); err != nil {
+			errors.Warn("run_host_diagnostic", "invalid diagnostic_hosts config: "+err.Error())
+		}
+	}
+	for _, h := range extra {
+		if h.Name != "" && h.Addr != "" {
+			hosts[h.Name] = h
+		}
+	}
+	return hosts
+}
+
+// buildHostArgv validates args against the command template and returns the argv.
+func buildHostArgv(cmd hostCommand, args map[string]string) ([]string, error) {
+	for k := range args {
+		if _, ok := cmd.Args[k]; !ok {
+			return nil, fmt.Errorf("unknown argument %q", k)
+		}
+	}
+	values := make(map[string]string, len(cmd.Args))
+	for name, spec := range cmd.Args {
+		v := strings.TrimSpace(args[name])
+		if v == "" {
+			v = spec.Default
+		}
+		if v == "" {
+			if spec.Required {
+				return nil, fmt.Errorf("argument %q is required (%s)", name, spec.Help)
+			}
+			continue
+		}
+		if !spec.Pattern.MatchString(v) || strings.Contains(v, "..") {
+			return nil, fmt.Errorf("invalid value for %q: expected %s", name, spec.Help)
+		}
+		if spec.Pattern == hostLinesRE {
+			if n, _ := strconv.Atoi(v); n <= 0 || n > hostMaxLines {
+				return nil, fmt.Errorf("%q must be between 1 and %d", name, hostMaxLines)
+			}
+		}
+		values[name] = v
+	}
+
+	argv := make([]string, 0, len(cmd.Argv))
+	for _, el := range cmd.Argv {
+		out, keep := el, true
+		for name := range cmd.Args {
+			ph := "{" + name + "}"
+			if !strings.Contains(out, ph) {
+				continue
+			}
+			if values[name] == "" {
+				keep = false
+				break
+			}
+			out = strings.ReplaceAll(out, ph, values[name])
+		}
+		if keep {
+			argv = append(argv, out)
+		}
+	}
+	return argv, nil
+}
+
+// shellQuote quotes s for the remote POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// hostExecResult is the raw outcome of a command.
+type hostExecResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+// hostRunner runs argv on host; tests replace it.
+var hostRunner = func(ctx context.Context, h diagHost, argv []string) (hostExecResult, error) {
+	name, args := argv[0], argv[1:]
+	if h.Addr != "" {
+		quoted := make([]string, len(argv))
+		for i, a := range argv {
+			quoted[i] = shellQuote(a)
+		}
+		name = "ssh"
+		args = []string{"-o", "BatchMode=yes", "-o", "ConnectTimeout=8"}
+		if h.Port != "" {
+			args = append(args, "-p", h.Port)
+		}
+		if h.Key != "" {
+			key := h.Key
+			if strings.HasPrefix(key, "~/") {
+				home, _ := os.UserHomeDir()
+				key = filepath.Join(home, key[2:])
+			}
+			args = append(args, "-i", key)
+		}
+		target := h.Addr
+		if h.User != "" {
+			target = h.User + "@" + h.Addr
+		}
+		args = append(args, target, "--", strings.Join(quoted, " "))
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	res := hostExecResult{Stdout: stdout.String(), Stderr: stderr.String()}
+	if err != nil {
+		ee, ok := err.(*exec.ExitError)
+		if !ok {
+			return res, err
+		}
+		res.ExitCode = ee.ExitCode()
+	}
+	return res, nil
+}
+
+// truncateHostOutput keeps the first and last lines of long output.
+func truncateHostOutput(s string, maxLines int) (string, int, bool) {
+	s = strings.TrimRight(s, "\n")
+	if s == "" {
+		return "", 0, false
+	}
+	lines := strings.Split(s, "\n")
+	total := len(lines)
+	truncated := false
+	if total > maxLines {
+		head := maxLines / 2
+		tail := maxLines - head
+		omitted := fmt.Sprintf("... %d lines omitted ...", total-maxLines)
+		lines = append(append(append([]string{}, lines[:head]...), omitted), lines[total-tail:]...)
+		truncated = true
+	}
+	out := strings.Join(lines, "\n")
+	// cut on a rune boundary so journal/pm2 text stays valid UTF-8
+	if clipped, cut := textutil.TruncateBytes(out, hostMaxOutputBytes); cut {
+		out = clipped + "\n... output truncated ..."
+		truncated = true
+	}
+	return out, total, truncated
+}
+
+// HostDiagnosticInput is the input of run_host_diagnostic.
+type HostDiagnosticInput struct {
+	Host     string            `json:"host,omitempty" jsonschema:"description=Host name. Optional, defaults to local. Call with command=list_hosts to see the configured hosts"`
+	Command  string            `json:"command" jsonschema:"description=Allowlisted command: disk_usage, inode_usage, directory_size, memory, uptime, listening_ports, socket_summary, top_processes, service_status, service_logs, kernel_log, pm2_list, or list_hosts"`
+	Args     map[string]string `json:"args,omitempty" jsonschema:"description=Command arguments, e.g. {\"unit\":\"nginx\",\"lines\":\"100\",\"since\":\"30 min ago\"} for service_logs or {\"path\":\"/var/log\"} for directory_size"`
+	MaxLines int               `json:"max_lines,omitempty" jsonschema:"description=Maximum output lines returned (first and last lines are kept). Default 200, max 1000"`
+}
+
+// NewHostDiagnosticTool 创建主机诊断命令工具
+func NewHostDiagnosticTool() tool.InvokableTool {
+	t, err := utils.InferOptionableTool(
+		"run_host_diagnostic",
+		"Run an allowlisted read-only diagnostic command (df, free, uptime, ss, ps, systemctl status, journalctl, pm2 jlist) on the local server or a configured SSH host. Arbitrary shell commands are not possible; use command=list_hosts to see hosts and commands. Output is truncated and summarised.",
+		func(ctx context.Context, input *HostDiagnosticInput, opts ...tool.Option) (output string, err error) {
+			hosts := diagHosts(ctx)
+			if input.Command == "list_hosts" {
+				return promJSON(map[string]any{"success": true, "hosts": hostNames(hosts), "commands": hostCommandHelp()}), nil
+			}
+
+			hostName := strings.TrimSpace(input.Host)
+			if hostName == "" {
+				hostName = "local"
+			}
+			h, ok := hosts[hostName]
+			if !ok {
+				return promError(fmt.Errorf("unknown host %q, available: %s", hostName, strings.Join(hostNames(hosts), ", "))), nil
+			}
+			cmd, ok := hostCommands[input.Command]
+			if !ok {
+				return promError(fmt.Errorf("command %q is not allowlisted", input.Command)), nil
+			}
+			argv, err := buildHostArgv(cmd, input.Args)
+			if err != nil {
+				return promError(err), nil
+			}
+			maxLines := input.MaxLines
+			if maxLines <= 0 {
+				maxLines = hostDefaultMaxLines
+			}
+			if maxLines > hostMaxLines {
+				maxLines = hostMaxLines
+			}
+
+			cctx, cancel := context.WithTimeout(ctx, hostCmdTimeout)
+			defer cancel()
+			start := time.Now()
+			res, runErr := hostRunner(cctx, h, argv)
+			duration := time.Since(start)
+			auditHostCommand(ctx, hostName, input.Command, argv, res, runErr, duration)
+			if runErr != nil {
+				return promError(fmt.Errorf("run %s on %s: %v", input.Command, hostName, runErr)), nil
+			}
+
+			stdout, lines, truncated := truncateHostOutput(res.Stdout, maxLines)
+			stderr, _, _ := truncateHostOutput(res.Stderr, 20)
+			out := map[string]any{
+				"success":     res.ExitCode == 0,
+				"host":        hostName,
+				"command":     input.Command,
+				"argv":        argv,
+				"exit_code":   res.ExitCode,
+				"duration_ms": duration.Milliseconds(),
+				"lines":       lines,
+				"truncated":   truncated,
+				"output":      stdout,
+			}
+			if stderr != "" {
+				out["stderr"] = stderr
+			}
+			if res.ExitCode != 0 {
+				out["error"] = fmt.Sprintf("exit code %d", res.ExitCode)
+			}
+			if cmd.Summarize != nil && res.ExitCode == 0 {
+				if s := cmd.Summarize(res.Stdout); s != nil {
+					out["summary"] = s
+				}
+			}
+			if cmd.SummaryOnly {
+				delete(out, "output")
+				delete(out, "lines")
+				delete(out, "truncated")
+				if _, ok := out["summary"]; !ok && res.ExitCode == 0 {
+					out["success"] = false
+					out["error"] = "unexpected output, raw output is withheld"
+				}
+			}
+			return promJSON(out), nil
+		},
+	)
+	if err != nil {
+		return createErrorHostTool("run_host_diagnostic", err)
+	}
+	return t
+}
+
+func hostNames(hosts map[string]diagHost) []string {
+	names := make([]string, 0, len(hosts))
+	for n := range hosts {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func hostCommandHelp() map[string]any {
+	out := make(map[string]any, len(hostCommands))
+	for name, c := range hostCommands {
+		args := map[string]string{}
+		for a, spec := range c.Args {
+			help := spec.Help
+			if spec.Required {
+				help += " (required)"
+			} else if spec.Default != "" {
+				help += " (default " + spec.Default + ")"
+			}
+			args[a] = help
+		}
+		out[name] = map[string]any{"description": c.Description, "args": args}
+	}
+	return out
+}
+
+// auditHostCommand logs every invocation and stores it in
+// ops_host_command_audits when the database is available.
+func auditHostCommand(ctx context.Context, host, command string, argv []string, res hostExecResult, runErr error, duration time.Duration) {
+	rec := store.HostCommandAudit{
+		Host:        host,
+		Command:     command,
+		Argv:        strings.Join(argv, " "),
+		ExitCode:    res.ExitCode,
+		DurationMs:  float64(duration.Microseconds()) / 1000,
+		OutputBytes: len(res.Stdout) + len(res.Stderr),
+	}
+	if runErr != nil {
+		rec.Error = runErr.Error()
+	}
+	if u := middleware.GetUserContext(ctx); u != nil {
+		rec.Username = u.Username
+	}
+	if run := policy.RunFromContext(ctx); run != nil {
+		rec.RunID = run.ID
+	}
+	line, _ := json.Marshal(rec)
+	errors.Info("run_host_diagnostic", "audit "+string(line))
+
+	db, err := store.DB(ctx)
+	if err != nil || db == nil {
+		return
+	}
+	now := time.Now().UTC()
+	rec.CreatedAt = &now
+	if err := db.WithContext(context.WithoutCancel(ctx)).Create(&rec).Error; err != nil {
+		errors.Warn("run_host_diagnostic", "store audit record: "+err.Error())
+	}
+}
+
+// summarizeDF lists filesystems and flags those at or above 85% usage.
+func summarizeDF(stdout string) any {
+	type fs struct {
+		Filesystem string `json:"filesystem"`
+		Size       string `json:"size"`
+		Used       string `json:"used"`
+		Avail      string `json:"avail"`
+		UsePercent int    `json:"use_percent"`
+		MountedOn  string `json:"mounted_on"`
+	}
+	var all, critical []fs
+	for i, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		f := strings.Fields(line)
+		if i == 0 || len(f) < 6 {
+			continue
+		}
+		pct, err := strconv.Atoi(strings.TrimSuffix(f[4], "%"))
+		if err != nil {
+			continue
+		}
+		item := fs{Filesystem: f[0], Size: f[1], Used: f[2], Avail: f[3], UsePercent: pct, MountedOn: strings.Join(f[5:], " ")}
+		all = append(all, item)
+		if pct >= 85 {
+			critical = append(critical, item)
+		}
+	}
+	if len(all) == 0 {
+		return nil
+	}
+	return map[string]any{"filesystems": all, "above_85_percent": critical}
+}
+
+// summarizeFree extracts memory and swap figures (MiB) from free -m.
+func summarizeFree(stdout string) any {
+	out := map[string]any{}
+	for _, line := range strings.Split(stdout, "\n") {
+		f := strings.Fields(line)
+		if len(f) < 3 {
+			continue
+		}
+		nums := make([]int, 0, len(f)-1)
+		for _, s := range f[1:] {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				break
+			}
+			nums = append(nums, n)
+		}
+		switch f[0] {
+		case "Mem:":
+			if len(nums) >= 2 {
+				out["mem_total_mb"], out["mem_used_mb"] = nums[0], nums[1]
+			}
+			if len(nums) >= 6 {
+				out["mem_available_mb"] = nums[5]
+				if nums[0] > 0 {
+					out["mem_available_percent"] = nums[5] * 100 / nums[0]
+				}
+			}
+		case "Swap:":
+			if len(nums) >= 2 {
+				out["swap_total_mb"], out["swap_used_mb"] = nums[0], nums[1]
+			}
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+var loadAvgRE = regexp.MustCompile(`load averages?: ([0-9.]+),? ([0-9.]+),? ([0-9.]+)`)
+
+// summarizeUptime extracts the load averages.
+func summarizeUptime(stdout string) any {
+	m := loadAvgRE.FindStringSubmatch(stdout)
+	if m == nil {
+		return nil
+	}
+	return map[string]string{"load_1m": m[1], "load_5m": m[2], "load_15m": m[3]}
+}
+
+// summarizePM2 reduces pm2 jlist to the fields useful for diagnosis.
+func summarizePM2(stdout string) any {
+	var procs []struct {
+		Name   string `json:"name"`
+		PID    int    `json:"pid"`
+		PM2Env struct {
+			Status      string `json:"status"`
+			RestartTime int    `json:"restart_time"`
+			PMUptime    int64  `json:"pm_uptime"`
+		} `json:"pm2_env"`
+		Monit struct {
+			Memory int64   `json:"memory"`
+			CPU    float64 `json:"cpu"`
+		} `json:"monit"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &procs); err != nil {
+		return nil
+	}
+	out := make([]map[string]any, 0, len(procs))
+	for _, p := range procs {
+		item := map[string]any{
+			"name":        p.Name,
+			"pid":         p.PID,
+			"status":      p.PM2Env.Status,
+			"restarts":    p.PM2Env.RestartTime,
+			"memory_mb":   p.Monit.Memory / (1024 * 1024),
+			"cpu_percent": p.Monit.CPU,
+		}
+		if p.PM2Env.PMUptime > 0 {
+			item["started_at"] = time.UnixMilli(p.PM2Env.PMUptime).UTC().Format(time.RFC3339)
+		}
+		out = append(out, item)
+	}
+	return map[string]any{"processes": out}
+}
+
+// createErrorHostTool returns a tool that always returns an error
+func createErrorHostTool(name string, createErr error) tool.InvokableTool {
+	t, _ := utils.InferOptionableTool(
+		name,
+		"Error tool - Host diagnostic tool failed to initialize",
+		func(ctx context.Context, input any, opts ...tool.Option) (output string, err error) {
+			return fmt.Sprintf(`{"success":false,"error":"Tool initialization failed: %s"}`, escapeJSON(createErr.Error())), nil
+		},
+	)
+	return t
+}
@@ -0,0 +1,112 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestBuildHostArgv(t *testing.T) {
+	argv, err := buildHostArgv(hostCommands["service_logs"], map[string]string{"unit": "nginx", "since": "30 min ago"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"journalctl", "-u", "nginx", "-n", "200", "--no-pager", "--since=30 min ago"}
+	if !reflect.DeepEqual(argv, want) {
+		t.Fatalf("argv = %q, want %q", argv, want)
+	}
+
+	// optional argument left out drops the element
+	argv, err = buildHostArgv(hostCommands["disk_usage"], nil)
+	if err != nil || !reflect.DeepEqual(argv, []string{"df", "-hP"}) {
+		t.Fatalf("argv = %q, err = %v", argv, err)
+	}
+
+	for _, tc := range []struct {
+		cmd  string
+		args map[string]string
+	}{
+		{"service_logs", nil}, // unit required
+		{"service_logs", map[string]string{"unit": "nginx; rm -rf /"}},           // injection
+		{"service_status", map[string]string{"unit": "-Hroot@attacker.example"}}, // option injection
+		{"service_logs", map[string]string{"unit": "nginx", "lines": "5000"}},
+		{"directory_size", map[string]string{"path": "/var/../etc"}},
+		{"memory", map[string]string{"extra": "1"}},
+	} {
+		if _, err := buildHostArgv(hostCommands[tc.cmd], tc.args); err == nil {
+			t.Errorf("%s %v: expected validation error", tc.cmd, tc.args)
+		}
+	}
+}
+
+func TestHostDiagnosticTool(t *testing.T) {
+	t.Setenv("OPS_PORTAL_SSH_HOST", "203.0.113.7")
+	t.Setenv("OPS_PORTAL_SSH_NAME", "web")
+
+	var gotHost diagHost
+	var gotArgv []string
+	orig := hostRunner
+	hostRunner = func(ctx context.Context, h diagHost, argv []string) (hostExecResult, error) {
+		gotHost, gotArgv = h, argv
+		lines := []string{"Filesystem Size Used Avail Use% Mounted on"}
+		lines = append(lines, "/dev/vda1 50G 46G 4G 92% /")
+		for i := 0; i < 50; i++ {
+			lines = append(lines, "tmpfs 1G 0 1G 0% /run/x")
+		}
+		return hostExecResult{Stdout: strings.Join(lines, "\n") + "\n"}, nil
+	}
+	defer func() { hostRunner = orig }()
+
+	out, err := NewHostDiagnosticTool().InvokableRun(context.Background(), `{"host":"web","command":"disk_usage","max_lines":10}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res struct {
+		Success   bool   `json:"success"`
+		Lines     int    `json:"lines"`
+		Truncated bool   `json:"truncated"`
+		Output    string `json:"output"`
+		Summary   struct {
+			Above85 []struct {
+				MountedOn string `json:"mounted_on"`
+			} `json:"above_85_percent"`
+		} `json:"summary"`
+	}
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatalf("invalid json %q: %v", out, err)
+	}
+	if gotHost.Addr != "203.0.113.7" || !reflect.DeepEqual(gotArgv, []string{"df", "-hP"}) {
+		t.Fatalf("ran %q on %+v", gotArgv, gotHost)
+	}
+	if !res.Success || res.Lines != 52 || !res.Truncated || len(strings.Split(res.Output, "\n")) != 11 {
+		t.Fatalf("unexpected result: %s", out)
+	}
+	if len(res.Summary.Above85) != 1 || res.Summary.Above85[0].MountedOn != "/" {
+		t.Fatalf("unexpected summary: %s", out)
+	}
+
+	// pm2 jlist 的原始输出带着进程环境变量，只能返回摘要
+	hostRunner = func(ctx context.Context, h diagHost, argv []string) (hostExecResult, error) {
+		return hostExecResult{Stdout: `[{"name":"api","pid":42,"pm2_env":{"status":"online","restart_time":3,"DB_PASSWORD":"s3cret"},"monit":{"memory":1048576,"cpu":1.5}}]`}, nil
+	}
+	out, _ = NewHostDiagnosticTool().InvokableRun(context.Background(), `{"command":"pm2_list"}`)
+	if strings.Contains(out, "s3cret") || !strings.Contains(out, `"summary"`) || !strings.Contains(out, `"api"`) {
+		t.Fatalf("pm2_list should return only the summary, got %s", out)
+	}
+
+	out, _ = NewHostDiagnosticTool().InvokableRun(context.Background(), `{"host":"db","command":"memory"}`)
+	if !strings.Contains(out, `"success":false`) || !strings.Contains(out, "unknown host") {
+		t.Fatalf("expected unknown host, got %s", out)
+	}
+}
+
+func TestTruncateHostOutputOnRuneBoundary(t *testing.T) {
+	// 一行很长的中文日志，按字节截断时不能切断多字节字符
+	out, _, truncated := truncateHostOutput(strings.Repeat("服务启动失败", hostMaxOutputBytes/6), 200)
+	if !truncated || !utf8.ValidString(out) || len(out) > hostMaxOutputBytes+len("\n... output truncated ...") {
+		t.Errorf("truncated=%v valid=%v len=%d", truncated, utf8.ValidString(out), len(out))
+	}
+}
@@ -0,0 +1,370 @@
+package tools
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// http_probe 只能访问白名单内的 base URL：config.yaml 的 http_probe.targets
+// 与环境变量 OPS_PORTAL_PROBE_TARGETS（逗号分隔）。重定向目标同样需要在白名单内。
+
+const (
+	probeDefaultTimeout  = 10 * time.Second
+	probeMaxTimeout      = 30 * time.Second
+	probeMaxSamples      = 10
+	probeDefaultInterval = 500 * time.Millisecond
+	probeMaxInterval     = 5 * time.Second
+	probeMaxBody         = 2048
+	probeTLSWarnDays     = 14
+
+	// HTTPProbeMaxDuration is the longest a probe can take within its caps:
+	// every sample timing out plus the pauses between them.
+	HTTPProbeMaxDuration = probeMaxSamples*probeMaxTimeout + (probeMaxSamples-1)*probeMaxInterval
+)
+
+// probeHeaders are the response headers returned to the model.
+var probeHeaders = []string{"Content-Type", "Content-Length", "Server", "Location", "Cache-Control", "Retry-After", "X-Request-Id", "X-Trace-Id"}
+
+// probeRootCAs overrides the system roots; tests use it for httptest TLS servers.
+var probeRootCAs *x509.CertPool
+
+// HTTPProbeInput is the input of http_probe.
+type HTTPProbeInput struct {
+	URL            string `json:"url" jsonschema:"description=Absolute URL under one of the allowlisted targets, e.g. https://api.example.com/health"`
+	Method         string `json:"method,omitempty" jsonschema:"description=GET or HEAD. Default GET"`
+	Samples        int    `json:"samples,omitempty" jsonschema:"description=Number of requests for success ratio and p95 latency. Default 1, max 10"`
+	IntervalMs     int    `json:"interval_ms,omitempty" jsonschema:"description=Pause between samples in milliseconds. Default 500, max 5000"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty" jsonschema:"description=Per-request timeout. Default 10, max 30"`
+}
+
+// probeTargets returns the allowlisted base URLs.
+func probeTargets(ctx context.Context) []*url.URL {
+	var raw []string
+	if v, err := g.Cfg().Get(ctx, "http_probe.targets"); err == nil && !v.IsNil() {
+		raw = append(raw, v.Strings()...)
+	}
+	if env := os.Getenv("OPS_PORTAL_PROBE_TARGETS"); env != "" {
+		raw = append(raw, strings.Split(env, ",")...)
+	}
+	out := make([]*url.URL, 0, len(raw))
+	for _, r := range raw {
+		u, err := url.Parse(strings.TrimSpace(r))
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			continue
+		}
+		out = append(out, u)
+	}
+	return out
+}
+
+// probeAllowed reports whether u lies under one of the targets: same scheme
+// and host:port, and the path starts with the target path on a segment boundary.
+func probeAllowed(u *url.URL, targets []*url.URL) bool {
+	if u.User != nil || strings.Contains(u.Path, "..") {
+		return false
+	}
+	for _, t := range targets {
+		if !strings.EqualFold(u.Scheme, t.Scheme) || !strings.EqualFold(u.Host, t.Host) {
+			continue
+		}
+		base := strings.TrimSuffix(t.Path, "/")
+		if base == "" || u.Path == base || strings.HasPrefix(u.Path, base+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// probeSample is the outcome of one request.
+type probeSample struct {
+	Status    int
+	LatencyMs float64
+	Err       error
+	Resp      *http.Response
+	Body      []byte
+	BodyBytes int64
+	Timings   map[string]float64
+}
+
+func probeOnce(ctx context.Context, client *http.Client, method, target string, keep bool) probeSample {
+	var s probeSample
+	var t0, dnsStart, connStart, tlsStart time.Time
+	var mu sync.Mutex // dial attempts may report concurrently
+	timings := map[string]float64{}
+	ms := func(from time.Time) float64 { return float64(time.Since(from).Microseconds()) / 1000 }
+	mark := func(key string, from *time.Time) {
+		mu.Lock()
+		defer mu.Unlock()
+		timings[key] = ms(*from)
+	}
+	start := func(at *time.Time) {
+		mu.Lock()
+		defer mu.Unlock()
+		*at = time.Now()
+	}
+	trace := &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { start(&dnsStart) },
+		DNSDone:              func(httptrace.DNSDoneInfo) { mark("dns_ms", &dnsStart) },
+		ConnectStart:         func(string, string) { start(&connStart) },
+		ConnectDone:          func(string, string, error) { mark("connect_ms", &connStart) },
+		TLSHandshakeStart:    func() { start(&tlsStart) },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { mark("tls_ms", &tlsStart) },
+		GotFirstResponseByte: func() { mark("ttfb_ms", &t0) },
+	}
+
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), method, target, nil)
+	if err != nil {
+		s.Err = err
+		return s
+	}
+	req.Header.Set("User-Agent", "ops-portal-http-probe/1.0")
+	t0 = time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		s.LatencyMs = ms(t0)
+		s.Err = err
+		return s
+	}
+	defer resp.Body.Close()
+	if keep {
+		s.Body, _ = io.ReadAll(io.LimitReader(resp.Body, probeMaxBody))
+	}
+	n, _ := io.Copy(io.Discard, resp.Body)
+	s.BodyBytes = int64(len(s.Body)) + n
+	s.LatencyMs = ms(t0)
+	s.Status = resp.StatusCode
+	s.Resp = resp
+	mu.Lock()
+	s.Timings = timings
+	mu.Unlock()
+	return s
+}
+
+func probeOK(status int) bool { return status >= 200 && status < 400 }
+
+// percentile returns the nearest-rank percentile of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	return sorted[idx]
+}
+
+// NewHTTPProbeTool 创建 HTTP 探测工具
+func NewHTTPProbeTool() tool.InvokableTool {
+	t, err := utils.InferOptionableTool(
+		"http_probe",
+		"Probe an HTTP(S) endpoint with GET/HEAD to check whether a service is actually up (e.g. when investigating '服务下线'). Returns status, latency with DNS/connect/TLS/TTFB timings, TLS certificate expiry, selected headers and a truncated body. Set samples>1 for success ratio and p95 latency. Only allowlisted targets can be probed.",
+		func(ctx context.Context, input *HTTPProbeInput, opts ...tool.Option) (output string, err error) {
+			targets := probeTargets(ctx)
+			if len(targets) == 0 {
+				return `{"success":false,"error":"no probe targets configured (http_probe.targets / OPS_PORTAL_PROBE_TARGETS)"}`, nil
+			}
+			allowed := make([]string, 0, len(targets))
+			for _, t := range targets {
+				allowed = append(allowed, t.String())
+			}
+			u, err := url.Parse(strings.TrimSpace(input.URL))
+			if err != nil || u.Host == "" {
+				return promError(fmt.Errorf("invalid url %q, an absolute URL is required", input.URL)), nil
+			}
+			if !probeAllowed(u, targets) {
+				return promError(fmt.Errorf("url %s is not under an allowlisted target: %s", u.Redacted(), strings.Join(allowed, ", "))), nil
+			}
+			method := strings.ToUpper(strings.TrimSpace(input.Method))
+			if method == "" {
+				method = http.MethodGet
+			}
+			if method != http.MethodGet && method != http.MethodHead {
+				return promError(fmt.Errorf("method %s is not allowed, use GET or HEAD", method)), nil
+			}
+			samples := input.Samples
+			if samples <= 0 {
+				samples = 1
+			}
+			if samples > probeMaxSamples {
+				samples = probeMaxSamples
+			}
+			interval := probeDefaultInterval
+			if input.IntervalMs > 0 {
+				interval = time.Duration(input.IntervalMs) * time.Millisecond
+			}
+			if interval > probeMaxInterval {
+				interval = probeMaxInterval
+			}
+			timeout := probeDefaultTimeout
+			if input.TimeoutSeconds > 0 {
+				timeout = time.Duration(input.TimeoutSeconds) * time.Second
+			}
+			if timeout > probeMaxTimeout {
+				timeout = probeMaxTimeout
+			}
+
+			client := &http.Client{
+				Timeout: timeout,
+				// 每次采样都建立新连接，延迟包含 DNS/TCP/TLS
+				Transport: &http.Transport{
+					Proxy:             http.ProxyFromEnvironment,
+					DisableKeepAlives: true,
+					TLSClientConfig:   &tls.Config{RootCAs: probeRootCAs},
+				},
+				CheckRedirect: func(req *http.Request, via []*http.Request) error {
+					if len(via) >= 5 {
+						return fmt.Errorf("stopped after 5 redirects")
+					}
+					if !probeAllowed(req.URL, targets) {
+						return fmt.Errorf("redirect to %s is not allowlisted", req.URL.Redacted())
+					}
+					return nil
+				},
+			}
+
+			var first probeSample
+			latencies := make([]float64, 0, samples)
+			statusCounts := map[string]int{}
+			var sampleErrors []string
+			okCount := 0
+			taken := 0
+			// 调用被取消时停止采样，返回已采集的样本
+			for i := 0; i < samples && ctx.Err() == nil; i++ {
+				if i > 0 {
+					select {
+					case <-ctx.Done():
+						continue
+					case <-time.After(interval):
+					}
+				}
+				s := probeOnce(ctx, client, method, u.String(), i == 0)
+				if ctx.Err() != nil && i > 0 {
+					break
+				}
+				taken++
+				if i == 0 {
+					first = s
+				}
+				latencies = append(latencies, s.LatencyMs)
+				if s.Err != nil {
+					statusCounts["error"]++
+					if len(sampleErrors) < 3 {
+						sampleErrors = append(sampleErrors, s.Err.Error())
+					}
+					continue
+				}
+				statusCounts[fmt.Sprint(s.Status)]++
+				if probeOK(s.Status) {
+					okCount++
+				}
+			}
+
+			if taken == 0 {
+				// 调用在第一次采样前就已取消或超时
+				return promError(fmt.Errorf("probe of %s not started: %w", u.Redacted(), ctx.Err())), nil
+			}
+
+			out := map[string]any{
+				"success":    true,
+				"url":        u.Redacted(),
+				"method":     method,
+				"up":         okCount > 0,
+				"latency_ms": first.LatencyMs,
+			}
+			if first.Err != nil {
+				out["error"] = first.Err.Error()
+			} else {
+				out["status_code"] = first.Status
+				out["timings"] = first.Timings
+				if first.Resp.Request != nil && first.Resp.Request.URL.String() != u.String() {
+					out["final_url"] = first.Resp.Request.URL.Redacted()
+				}
+				headers := map[string]string{}
+				for _, h := range probeHeaders {
+					if v := first.Resp.Header.Get(h); v != "" {
+						headers[h] = v
+					}
+				}
+				out["headers"] = headers
+				out["body_bytes"] = first.BodyBytes
+				if method == http.MethodGet {
+					out["body"] = string(first.Body)
+					out["body_truncated"] = first.BodyBytes > int64(len(first.Body))
+				}
+				if cs := first.Resp.TLS; cs != nil && len(cs.PeerCertificates) > 0 {
+					cert := cs.PeerCertificates[0]
+					days := int(time.Until(cert.NotAfter).Hours() / 24)
+					out["tls"] = map[string]any{
+						"subject":        cert.Subject.CommonName,
+						"dns_names":      cert.DNSNames,
+						"issuer":         cert.Issuer.CommonName,
+						"not_after":      cert.NotAfter.UTC().Format(time.RFC3339),
+						"days_remaining": days,
+						"expiring_soon":  days < probeTLSWarnDays,
+						"version":        tls.VersionName(cs.Version),
+					}
+				}
+			}
+			if samples > 1 {
+				sorted := append([]float64(nil), latencies...)
+				sort.Float64s(sorted)
+				sum := 0.0
+				for _, l := range sorted {
+					sum += l
+				}
+				stats := map[string]any{
+					"requested":      samples,
+					"count":          taken,
+					"ok":             okCount,
+					"success_ratio":  float64(okCount) / float64(taken),
+					"status_codes":   statusCounts,
+					"min_latency_ms": sorted[0],
+					"avg_latency_ms": sum / float64(taken),
+					"p95_latency_ms": percentile(sorted, 95),
+					"max_latency_ms": sorted[len(sorted)-1],
+				}
+				if len(sampleErrors) > 0 {
+					stats["errors"] = sampleErrors
+				}
+				if taken < samples {
+					stats["stopped"] = fmt.Sprintf("stopped after %d of %d samples: %v", taken, samples, ctx.Err())
+				}
+				out["samples"] = stats
+			}
+			return promJSON(out), nil
+		},
+	)
+	if err != nil {
+		return createErrorProbeTool("http_probe", err)
+	}
+	return t
+}
+
+// createErrorProbeTool returns a tool that always returns an error
+func createErrorProbeTool(name string, createErr error) tool.InvokableTool {
+	t, _ := utils.InferOptionableTool(
+		name,
+		"Error tool - HTTP probe tool failed to initialize",
+		func(ctx context.Context, input any, opts ...tool.Option) (output string, err error) {
+			return fmt.Sprintf(`{"success":false,"error":"Tool initialization failed: %s"}`, escapeJSON(createErr.Error())), nil
+		},
+	)
+	return t
+}
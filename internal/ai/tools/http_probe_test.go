@@ -0,0 +1,132 @@
+package tools
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHTTPProbeTool(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/health":
+			// every other request fails
+			if calls.Add(1)%2 == 0 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"status":"ok","padding":"`+strings.Repeat("x", 4096)+`"}`)
+		case "/api/redirect":
+			http.Redirect(w, r, "https://example.com/", http.StatusFound)
+		}
+	}))
+	defer srv.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	probeRootCAs = pool
+	defer func() { probeRootCAs = nil }()
+	t.Setenv("OPS_PORTAL_PROBE_TARGETS", srv.URL+"/api")
+
+	run := func(args string) map[string]any {
+		t.Helper()
+		out, err := NewHTTPProbeTool().InvokableRun(context.Background(), args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res map[string]any
+		if err := json.Unmarshal([]byte(out), &res); err != nil {
+			t.Fatalf("invalid json %q: %v", out, err)
+		}
+		return res
+	}
+
+	res := run(`{"url":"` + srv.URL + `/api/health","samples":4,"interval_ms":1}`)
+	if res["success"] != true || res["up"] != true || res["status_code"].(float64) != 200 {
+		t.Fatalf("unexpected result: %v", res)
+	}
+	if res["body_truncated"] != true || len(res["body"].(string)) != probeMaxBody {
+		t.Errorf("body not truncated: %v", res["body_truncated"])
+	}
+	tlsInfo, ok := res["tls"].(map[string]any)
+	if !ok || tlsInfo["not_after"] == "" {
+		t.Errorf("missing tls info: %v", res["tls"])
+	}
+	samples := res["samples"].(map[string]any)
+	if samples["success_ratio"].(float64) != 0.5 || samples["status_codes"].(map[string]any)["503"].(float64) != 2 {
+		t.Errorf("unexpected samples: %v", samples)
+	}
+
+	for _, u := range []string{
+		srv.URL + "/admin",               // outside the target path
+		srv.URL + "/api/../admin",        // traversal
+		"https://example.com/api/health", // other host
+	} {
+		if res := run(`{"url":"` + u + `"}`); res["success"] != false {
+			t.Errorf("%s: expected rejection, got %v", u, res)
+		}
+	}
+
+	// redirects leaving the allowlist are not followed
+	res = run(`{"url":"` + srv.URL + `/api/redirect"}`)
+	if res["up"] != false || !strings.Contains(fmt.Sprint(res["error"]), "not allowlisted") {
+		t.Errorf("redirect: unexpected result %v", res)
+	}
+}
+
+func TestHTTPProbeReturnsPartialSamplesOnCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	t.Setenv("OPS_PORTAL_PROBE_TARGETS", srv.URL)
+
+	// 调用超时时返回已采集的样本，而不是丢掉全部结果
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	out, err := NewHTTPProbeTool().InvokableRun(ctx, `{"url":"`+srv.URL+`/health","samples":10,"interval_ms":200}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res struct {
+		Success bool `json:"success"`
+		Samples struct {
+			Requested int    `json:"requested"`
+			Count     int    `json:"count"`
+			OK        int    `json:"ok"`
+			Stopped   string `json:"stopped"`
+		} `json:"samples"`
+	}
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatalf("invalid json %q: %v", out, err)
+	}
+	s := res.Samples
+	if !res.Success || s.Requested != 10 || s.Count == 0 || s.Count >= 10 || s.OK != s.Count || s.Stopped == "" {
+		t.Errorf("unexpected result: %s", out)
+	}
+}
+
+func TestHTTPProbeWithCancelledContext(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	t.Setenv("OPS_PORTAL_PROBE_TARGETS", srv.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, samples := range []int{1, 5} {
+		out, err := NewHTTPProbeTool().InvokableRun(ctx, fmt.Sprintf(`{"url":"%s/health","samples":%d}`, srv.URL, samples))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out, `"success":false`) || !strings.Contains(out, "context canceled") {
+			t.Errorf("samples=%d: %s", samples, out)
+		}
+	}
+}
@@ -0,0 +1,601 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Kubernetes 只读巡检工具。连接方式：
+//   - OPS_PORTAL_KUBECONFIG（或 KUBECONFIG / ~/.kube/config），OPS_PORTAL_K8S_CONTEXT 选择 context
+//   - 未配置 kubeconfig 且运行在集群内时使用 in-cluster 配置
+//
+// OPS_PORTAL_K8S_NAMESPACE 为默认命名空间，OPS_PORTAL_K8S_NAMESPACES（逗号分隔）限制可访问的命名空间。
+
+const (
+	k8sMaxPods      = 200
+	k8sMaxEvents    = 30
+	k8sDefaultTail  = 200
+	k8sMaxTail      = 2000
+	k8sMaxLogBytes  = 64 * 1024
+	k8sRequestLimit = 15 * time.Second
+)
+
+// k8sClientFunc returns the client used by the tools; tests inject a fake.
+type k8sClientFunc func(ctx context.Context) (kubernetes.Interface, error)
+
+var (
+	k8sClientMu     sync.Mutex
+	k8sCachedClient kubernetes.Interface
+)
+
+// defaultK8sClient builds the client from kubeconfig or in-cluster config
+// once it succeeds.
+func defaultK8sClient(ctx context.Context) (kubernetes.Interface, error) {
+	k8sClientMu.Lock()
+	defer k8sClientMu.Unlock()
+	if k8sCachedClient != nil {
+		return k8sCachedClient, nil
+	}
+
+	var cfg *rest.Config
+	var err error
+	explicit := os.Getenv("OPS_PORTAL_KUBECONFIG")
+	if explicit == "" && os.Getenv("KUBECONFIG") == "" && os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		cfg, err = rest.InClusterConfig()
+	} else {
+		rules := clientcmd.NewDefaultClientConfigLoadingRules()
+		if explicit != "" {
+			rules.ExplicitPath = explicit
+		}
+		overrides := &clientcmd.ConfigOverrides{CurrentContext: os.Getenv("OPS_PORTAL_K8S_CONTEXT")}
+		cfg, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("kubernetes config not available: %v", err)
+	}
+	cfg.Timeout = k8sRequestLimit
+
+	cs, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	k8sCachedClient = cs
+	return cs, nil
+}
+
+// KubernetesConfigured reports whether a kubeconfig or in-cluster config is
+// available, so the tools are only enabled where they can work.
+func KubernetesConfigured() bool {
+	if os.Getenv("OPS_PORTAL_KUBECONFIG") != "" || os.Getenv("KUBECONFIG") != "" || os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return true
+	}
+	_, err := os.Stat(clientcmd.RecommendedHomeFile)
+	return err == nil
+}
+
+// k8sNamespace resolves and checks the namespace of a request.
+func k8sNamespace(ns string) (string, error) {
+	ns = strings.TrimSpace(ns)
+	if ns == "" {
+		ns = os.Getenv("OPS_PORTAL_K8S_NAMESPACE")
+	}
+	if ns == "" {
+		ns = "default"
+	}
+	if allowed := strings.TrimSpace(os.Getenv("OPS_PORTAL_K8S_NAMESPACES")); allowed != "" {
+		for _, a := range strings.Split(allowed, ",") {
+			if strings.TrimSpace(a) == ns {
+				return ns, nil
+			}
+		}
+		return "", fmt.Errorf("namespace %q is not allowed (allowed: %s)", ns, allowed)
+	}
+	return ns, nil
+}
+
+// K8sListPodsInput is the input of k8s_list_pods.
+type K8sListPodsInput struct {
+	Namespace     string `json:"namespace,omitempty" jsonschema:"description=Namespace. Optional, defaults to the configured namespace"`
+	LabelSelector string `json:"label_selector,omitempty" jsonschema:"description=Label selector, e.g. app=resume-backend"`
+	OnlyUnhealthy bool   `json:"only_unhealthy,omitempty" jsonschema:"description=Only return pods that are not Running/Succeeded, not ready or have restarted"`
+}
+
+// K8sPodInput identifies a pod.
+type K8sPodInput struct {
+	Namespace string `json:"namespace,omitempty" jsonschema:"description=Namespace. Optional, defaults to the configured namespace"`
+	Name      string `json:"name" jsonschema:"description=Pod name"`
+}
+
+// K8sRolloutInput identifies a deployment.
+type K8sRolloutInput struct {
+	Namespace string `json:"namespace,omitempty" jsonschema:"description=Namespace. Optional, defaults to the configured namespace"`
+	Name      string `json:"name" jsonschema:"description=Deployment name"`
+}
+
+// K8sLogsInput is the input of k8s_pod_logs.
+type K8sLogsInput struct {
+	Namespace    string `json:"namespace,omitempty" jsonschema:"description=Namespace. Optional, defaults to the configured namespace"`
+	Name         string `json:"name" jsonschema:"description=Pod name"`
+	Container    string `json:"container,omitempty" jsonschema:"description=Container name. Optional when the pod has a single container"`
+	Previous     bool   `json:"previous,omitempty" jsonschema:"description=Return logs of the previous (crashed) container instance"`
+	TailLines    int64  `json:"tail_lines,omitempty" jsonschema:"description=Number of lines from the end. Default 200, max 2000"`
+	SinceSeconds int64  `json:"since_seconds,omitempty" jsonschema:"description=Only logs newer than this many seconds. Optional"`
+}
+
+// K8sPodSummary is one pod in k8s_list_pods.
+type K8sPodSummary struct {
+	Name      string `json:"name"`
+	Phase     string `json:"phase"`
+	Ready     string `json:"ready"`
+	Restarts  int32  `json:"restarts"`
+	Reason    string `json:"reason,omitempty"`
+	Node      string `json:"node,omitempty"`
+	Age       string `json:"age"`
+	StartTime string `json:"start_time,omitempty"`
+}
+
+// K8sContainerStatus describes one container of a pod.
+type K8sContainerStatus struct {
+	Name         string `json:"name"`
+	Image        string `json:"image"`
+	Ready        bool   `json:"ready"`
+	RestartCount int32  `json:"restart_count"`
+	State        string `json:"state"`
+	LastState    string `json:"last_state,omitempty"`
+}
+
+// K8sEvent is a Kubernetes event.
+type K8sEvent struct {
+	Type     string `json:"type"`
+	Reason   string `json:"reason"`
+	Message  string `json:"message"`
+	Count    int32  `json:"count"`
+	LastSeen string `json:"last_seen"`
+}
+
+func k8sAge(t metav1.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	d := time.Since(t.Time).Round(time.Second)
+	switch {
+	case d >= 48*time.Hour:
+		return fmt.Sprintf("%dd", int(d.Hours()/24))
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	default:
+		return d.String()
+	}
+}
+
+// summarizePod computes the kubectl-style status of a pod.
+func summarizePod(p *corev1.Pod) K8sPodSummary {
+	s := K8sPodSummary{
+		Name:  p.Name,
+		Phase: string(p.Status.Phase),
+		Node:  p.Spec.NodeName,
+		Age:   k8sAge(p.CreationTimestamp),
+	}
+	if p.Status.StartTime != nil {
+		s.StartTime = p.Status.StartTime.UTC().Format(time.RFC3339)
+	}
+	ready := 0
+	for _, cs := range p.Status.ContainerStatuses {
+		if cs.Ready {
+			ready++
+		}
+		s.Restarts += cs.RestartCount
+		switch {
+		case cs.State.Waiting != nil && cs.State.Waiting.Reason != "":
+			s.Reason = cs.State.Waiting.Reason
+		case cs.State.Terminated != nil && cs.State.Terminated.Reason != "":
+			s.Reason = cs.State.Terminated.Reason
+		case s.Reason == "" && cs.LastTerminationState.Terminated != nil:
+			s.Reason = "Last: " + cs.LastTerminationState.Terminated.Reason
+		}
+	}
+	s.Ready = fmt.Sprintf("%d/%d", ready, len(p.Spec.Containers))
+	if p.Status.Reason != "" {
+		s.Reason = p.Status.Reason
+	}
+	if p.DeletionTimestamp != nil {
+		s.Reason = "Terminating"
+	}
+	return s
+}
+
+func podHealthy(p *corev1.Pod, s K8sPodSummary) bool {
+	if p.Status.Phase == corev1.PodSucceeded {
+		return true
+	}
+	if p.Status.Phase != corev1.PodRunning || s.Restarts > 0 || p.DeletionTimestamp != nil {
+		return false
+	}
+	for _, cs := range p.Status.ContainerStatuses {
+		if !cs.Ready {
+			return false
+		}
+	}
+	return true
+}
+
+func containerState(st corev1.ContainerState) string {
+	switch {
+	case st.Running != nil:
+		return "Running since " + st.Running.StartedAt.UTC().Format(time.RFC3339)
+	case st.Waiting != nil:
+		return strings.TrimSpace("Waiting: " + st.Waiting.Reason + " " + st.Waiting.Message)
+	case st.Terminated != nil:
+		t := st.Terminated
+		return strings.TrimSpace(fmt.Sprintf("Terminated: %s (exit %d) at %s %s", t.Reason, t.ExitCode, t.FinishedAt.UTC().Format(time.RFC3339), t.Message))
+	}
+	return ""
+}
+
+// NewK8sListPodsTool 创建 Pod 列表工具
+func NewK8sListPodsTool() tool.InvokableTool {
+	return newK8sListPodsTool(defaultK8sClient)
+}
+
+func newK8sListPodsTool(client k8sClientFunc) tool.InvokableTool {
+	t, err := utils.InferOptionableTool(
+		"k8s_list_pods",
+		"List Kubernetes pods in a namespace with phase, ready containers, restart count, status reason (e.g. CrashLoopBackOff, OOMKilled), node and age. Use only_unhealthy=true to find failing pods.",
+		func(ctx context.Context, input *K8sListPodsInput, opts ...tool.Option) (output string, err error) {
+			ns, err := k8sNamespace(input.Namespace)
+			if err != nil {
+				return k8sError(err), nil
+			}
+			cs, err := client(ctx)
+			if err != nil {
+				return k8sError(err), nil
+			}
+			list, err := cs.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{LabelSelector: input.LabelSelector})
+			if err != nil {
+				return k8sError(fmt.Errorf("list pods: %v", err)), nil
+			}
+			pods := make([]K8sPodSummary, 0, len(list.Items))
+			unhealthy := 0
+			for i := range list.Items {
+				p := &list.Items[i]
+				s := summarizePod(p)
+				healthy := podHealthy(p, s)
+				if !healthy {
+					unhealthy++
+				}
+				if input.OnlyUnhealthy && healthy {
+					continue
+				}
+				pods = append(pods, s)
+			}
+			sort.Slice(pods, func(i, j int) bool {
+				if pods[i].Restarts != pods[j].Restarts {
+					return pods[i].Restarts > pods[j].Restarts
+				}
+				return pods[i].Name < pods[j].Name
+			})
+			truncated := len(pods) > k8sMaxPods
+			if truncated {
+				pods = pods[:k8sMaxPods]
+			}
+			return k8sJSON(map[string]any{
+				"success":   true,
+				"namespace": ns,
+				"total":     len(list.Items),
+				"unhealthy": unhealthy,
+				"pods":      pods,
+				"truncated": truncated,
+			}), nil
+		},
+	)
+	if err != nil {
+		return createErrorK8sTool("k8s_list_pods", err)
+	}
+	return t
+}
+
+// NewK8sDescribePodTool 创建 Pod 详情与事件工具
+func NewK8sDescribePodTool() tool.InvokableTool {
+	return newK8sDescribePodTool(defaultK8sClient)
+}
+
+func newK8sDescribePodTool(client k8sClientFunc) tool.InvokableTool {
+	t, err := utils.InferOptionableTool(
+		"k8s_describe_pod",
+		"Describe a Kubernetes pod like kubectl describe: container states and last termination (exit code, OOMKilled), conditions and the most recent events (scheduling failures, image pull errors, probe failures, back-off).",
+		func(ctx context.Context, input *K8sPodInput, opts ...tool.Option) (output string, err error) {
+			if strings.TrimSpace(input.Name) == "" {
+				return `{"success":false,"error":"name is empty"}`, nil
+			}
+			ns, err := k8sNamespace(input.Namespace)
+			if err != nil {
+				return k8sError(err), nil
+			}
+			cs, err := client(ctx)
+			if err != nil {
+				return k8sError(err), nil
+			}
+			p, err := cs.CoreV1().Pods(ns).Get(ctx, input.Name, metav1.GetOptions{})
+			if err != nil {
+				return k8sError(fmt.Errorf("get pod: %v", err)), nil
+			}
+
+			containers := make([]K8sContainerStatus, 0, len(p.Status.ContainerStatuses))
+			for _, c := range p.Status.ContainerStatuses {
+				containers = append(containers, K8sContainerStatus{
+					Name:         c.Name,
+					Image:        c.Image,
+					Ready:        c.Ready,
+					RestartCount: c.RestartCount,
+					State:        containerState(c.State),
+					LastState:    containerState(c.LastTerminationState),
+				})
+			}
+			conditions := make([]map[string]string, 0, len(p.Status.Conditions))
+			for _, c := range p.Status.Conditions {
+				conditions = append(conditions, map[string]string{
+					"type":    string(c.Type),
+					"status":  string(c.Status),
+					"reason":  c.Reason,
+					"message": c.Message,
+				})
+			}
+
+			events, err := k8sEvents(ctx, cs, ns, "Pod", p.Name)
+			if err != nil {
+				events = nil
+			}
+			return k8sJSON(map[string]any{
+				"success":    true,
+				"namespace":  ns,
+				"pod":        summarizePod(p),
+				"labels":     p.Labels,
+				"containers": containers,
+				"conditions": conditions,
+				"events":     events,
+			}), nil
+		},
+	)
+	if err != nil {
+		return createErrorK8sTool("k8s_describe_pod", err)
+	}
+	return t
+}
+
+// k8sEvents returns the most recent events of an object, newest first.
+func k8sEvents(ctx context.Context, cs kubernetes.Interface, ns, kind, name string) ([]K8sEvent, error) {
+	selector := fields.Set{"involvedObject.kind": kind, "involvedObject.name": name}.AsSelector().String()
+	list, err := cs.CoreV1().Events(ns).List(ctx, metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	items := list.Items
+	last := func(e corev1.Event) time.Time {
+		switch {
+		case !e.LastTimestamp.IsZero():
+			return e.LastTimestamp.Time
+		case !e.EventTime.IsZero():
+			return e.EventTime.Time
+		}
+		return e.CreationTimestamp.Time
+	}
+	sort.Slice(items, func(i, j int) bool { return last(items[i]).After(last(items[j])) })
+	if len(items) > k8sMaxEvents {
+		items = items[:k8sMaxEvents]
+	}
+	out := make([]K8sEvent, 0, len(items))
+	for _, e := range items {
+		out = append(out, K8sEvent{
+			Type:     e.Type,
+			Reason:   e.Reason,
+			Message:  e.Message,
+			Count:    e.Count,
+			LastSeen: last(e).UTC().Format(time.RFC3339),
+		})
+	}
+	return out, nil
+}
+
+// NewK8sRolloutStatusTool 创建 Deployment 发布状态工具
+func NewK8sRolloutStatusTool() tool.InvokableTool {
+	return newK8sRolloutStatusTool(defaultK8sClient)
+}
+
+func newK8sRolloutStatusTool(client k8sClientFunc) tool.InvokableTool {
+	t, err := utils.InferOptionableTool(
+		"k8s_rollout_status",
+		"Get the rollout status of a Kubernetes deployment like kubectl rollout status: desired/updated/ready/available replicas, whether the rollout is complete, stuck (ProgressDeadlineExceeded) or still progressing, plus recent events.",
+		func(ctx context.Context, input *K8sRolloutInput, opts ...tool.Option) (output string, err error) {
+			if strings.TrimSpace(input.Name) == "" {
+				return `{"success":false,"error":"name is empty"}`, nil
+			}
+			ns, err := k8sNamespace(input.Namespace)
+			if err != nil {
+				return k8sError(err), nil
+			}
+			cs, err := client(ctx)
+			if err != nil {
+				return k8sError(err), nil
+			}
+			d, err := cs.AppsV1().Deployments(ns).Get(ctx, input.Name, metav1.GetOptions{})
+			if err != nil {
+				return k8sError(fmt.Errorf("get deployment: %v", err)), nil
+			}
+
+			desired := int32(1)
+			if d.Spec.Replicas != nil {
+				desired = *d.Spec.Replicas
+			}
+			st := d.Status
+			status, message := "progressing", ""
+			conditions := make([]map[string]string, 0, len(st.Conditions))
+			for _, c := range st.Conditions {
+				conditions = append(conditions, map[string]string{
+					"type":    string(c.Type),
+					"status":  string(c.Status),
+					"reason":  c.Reason,
+					"message": c.Message,
+				})
+				if c.Type == "Progressing" && c.Reason == "ProgressDeadlineExceeded" {
+					status, message = "stuck", c.Message
+				}
+			}
+			// 与 kubectl rollout status 的判断一致
+			switch {
+			case status == "stuck":
+			case d.Generation > st.ObservedGeneration:
+				message = "waiting for the deployment spec update to be observed"
+			case st.UpdatedReplicas < desired:
+				message = fmt.Sprintf("%d of %d updated replicas are available", st.UpdatedReplicas, desired)
+			case st.Replicas > st.UpdatedReplicas:
+				message = fmt.Sprintf("%d old replicas are pending termination", st.Replicas-st.UpdatedReplicas)
+			case st.AvailableReplicas < st.UpdatedReplicas:
+				message = fmt.Sprintf("%d of %d updated replicas are available", st.AvailableReplicas, st.UpdatedReplicas)
+			default:
+				status, message = "complete", "successfully rolled out"
+			}
+
+			events, _ := k8sEvents(ctx, cs, ns, "Deployment", d.Name)
+			return k8sJSON(map[string]any{
+				"success":              true,
+				"namespace":            ns,
+				"deployment":           d.Name,
+				"status":               status,
+				"message":              message,
+				"desired_replicas":     desired,
+				"updated_replicas":     st.UpdatedReplicas,
+				"ready_replicas":       st.ReadyReplicas,
+				"available_replicas":   st.AvailableReplicas,
+				"unavailable_replicas": st.UnavailableReplicas,
+				"generation":           d.Generation,
+				"observed_generation":  st.ObservedGeneration,
+				"conditions":           conditions,
+				"events":               events,
+			}), nil
+		},
+	)
+	if err != nil {
+		return createErrorK8sTool("k8s_rollout_status", err)
+	}
+	return t
+}
+
+// NewK8sPodLogsTool 创建容器日志工具
+func NewK8sPodLogsTool() tool.InvokableTool {
+	return newK8sPodLogsTool(defaultK8sClient)
+}
+
+func newK8sPodLogsTool(client k8sClientFunc) tool.InvokableTool {
+	t, err := utils.InferOptionableTool(
+		"k8s_pod_logs",
+		"Fetch container logs of a Kubernetes pod. Set previous=true to read the logs of the crashed instance of a restarting container (CrashLoopBackOff, OOMKilled).",
+		func(ctx context.Context, input *K8sLogsInput, opts ...tool.Option) (output string, err error) {
+			if strings.TrimSpace(input.Name) == "" {
+				return `{"success":false,"error":"name is empty"}`, nil
+			}
+			ns, err := k8sNamespace(input.Namespace)
+			if err != nil {
+				return k8sError(err), nil
+			}
+			cs, err := client(ctx)
+			if err != nil {
+				return k8sError(err), nil
+			}
+			tail := input.TailLines
+			if tail <= 0 {
+				tail = k8sDefaultTail
+			}
+			if tail > k8sMaxTail {
+				tail = k8sMaxTail
+			}
+			limit := int64(k8sMaxLogBytes)
+			opt := &corev1.PodLogOptions{
+				Container:  input.Container,
+				Previous:   input.Previous,
+				TailLines:  &tail,
+				LimitBytes: &limit,
+			}
+			if input.SinceSeconds > 0 {
+				opt.SinceSeconds = &input.SinceSeconds
+			}
+			stream, err := cs.CoreV1().Pods(ns).GetLogs(input.Name, opt).Stream(ctx)
+			if err != nil {
+				return k8sError(fmt.Errorf("get logs: %v", err)), nil
+			}
+			defer stream.Close()
+			var buf bytes.Buffer
+			if _, err := io.Copy(&buf, io.LimitReader(stream, k8sMaxLogBytes)); err != nil {
+				return k8sError(fmt.Errorf("read logs: %v", err)), nil
+			}
+			logs, truncated := clipK8sLogs(buf.String())
+			return k8sJSON(map[string]any{
+				"success":   true,
+				"namespace": ns,
+				"pod":       input.Name,
+				"container": input.Container,
+				"previous":  input.Previous,
+				"lines":     strings.Count(logs, "\n"),
+				"logs":      logs,
+				"truncated": truncated,
+			}), nil
+		},
+	)
+	if err != nil {
+		return createErrorK8sTool("k8s_pod_logs", err)
+	}
+	return t
+}
+
+// clipK8sLogs reports whether logs were cut by LimitBytes: the API server
+// stops at the limit, so a response that reaches it was truncated. The
+// partial last line is dropped.
+func clipK8sLogs(logs string) (string, bool) {
+	if len(logs) < k8sMaxLogBytes {
+		return logs, false
+	}
+	if i := strings.LastIndexByte(logs, '\n'); i >= 0 {
+		logs = logs[:i+1]
+	}
+	return logs, true
+}
+
+func k8sJSON(v any) string {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return k8sError(fmt.Errorf("failed to encode result: %v", err))
+	}
+	return string(b)
+}
+
+func k8sError(err error) string {
+	return fmt.Sprintf(`{"success":false,"error":"%s"}`, escapeJSON(err.Error()))
+}
+
+// createErrorK8sTool returns a tool that always returns an error
+func createErrorK8sTool(name string, createErr error) tool.InvokableTool {
+	t, _ := utils.InferOptionableTool(
+		name,
+		"Error tool - Kubernetes tool failed to initialize",
+		func(ctx context.Context, input any, opts ...tool.Option) (output string, err error) {
+			return fmt.Sprintf(`{"success":false,"error":"Tool initialization failed: %s"}`, escapeJSON(createErr.Error())), nil
+		},
+	)
+	return t
+}
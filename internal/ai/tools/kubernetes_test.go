@@ -0,0 +1,138 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/tool"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestKubernetesTools(t *testing.T) {
+	t.Setenv("OPS_PORTAL_K8S_NAMESPACE", "prod")
+	t.Setenv("OPS_PORTAL_K8S_NAMESPACES", "prod")
+
+	replicas := int32(2)
+	cs := fake.NewSimpleClientset(
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "api-ok", Namespace: "prod", Labels: map[string]string{"app": "api"}},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "api"}}},
+			Status: corev1.PodStatus{
+				Phase:             corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{{Name: "api", Ready: true}},
+			},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "api-crash", Namespace: "prod", Labels: map[string]string{"app": "api"}},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "api"}}},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{{
+					Name:                 "api",
+					RestartCount:         5,
+					State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+					LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}},
+				}},
+			},
+		},
+		&corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: "api-crash.1", Namespace: "prod"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Pod", Name: "api-crash", Namespace: "prod"},
+			Type:           "Warning",
+			Reason:         "BackOff",
+			Message:        "Back-off restarting failed container",
+			Count:          7,
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "prod", Generation: 3},
+			Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+			Status: appsv1.DeploymentStatus{
+				ObservedGeneration: 3,
+				Replicas:           2,
+				UpdatedReplicas:    2,
+				ReadyReplicas:      1,
+				AvailableReplicas:  1,
+			},
+		},
+	)
+	client := func(ctx context.Context) (kubernetes.Interface, error) { return cs, nil }
+	ctx := context.Background()
+
+	call := func(t *testing.T, tl tool.InvokableTool, args string) map[string]any {
+		t.Helper()
+		out, err := tl.InvokableRun(ctx, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res map[string]any
+		if err := json.Unmarshal([]byte(out), &res); err != nil {
+			t.Fatalf("invalid json %q: %v", out, err)
+		}
+		return res
+	}
+
+	t.Run("list unhealthy", func(t *testing.T) {
+		res := call(t, newK8sListPodsTool(client), `{"label_selector":"app=api","only_unhealthy":true}`)
+		if res["success"] != true || res["total"].(float64) != 2 || res["unhealthy"].(float64) != 1 {
+			t.Fatalf("unexpected result: %v", res)
+		}
+		pods := res["pods"].([]any)
+		pod := pods[0].(map[string]any)
+		if len(pods) != 1 || pod["name"] != "api-crash" || pod["reason"] != "CrashLoopBackOff" || pod["restarts"].(float64) != 5 {
+			t.Fatalf("unexpected pods: %v", pods)
+		}
+	})
+
+	t.Run("namespace allowlist", func(t *testing.T) {
+		res := call(t, newK8sListPodsTool(client), `{"namespace":"kube-system"}`)
+		if res["success"] != false {
+			t.Fatalf("expected denial, got %v", res)
+		}
+	})
+
+	t.Run("describe", func(t *testing.T) {
+		res := call(t, newK8sDescribePodTool(client), `{"name":"api-crash"}`)
+		containers := res["containers"].([]any)
+		last := containers[0].(map[string]any)["last_state"].(string)
+		if last == "" || res["events"] == nil {
+			t.Fatalf("unexpected result: %v", res)
+		}
+		ev := res["events"].([]any)[0].(map[string]any)
+		if ev["reason"] != "BackOff" {
+			t.Fatalf("unexpected events: %v", res["events"])
+		}
+	})
+
+	t.Run("rollout", func(t *testing.T) {
+		res := call(t, newK8sRolloutStatusTool(client), `{"name":"api"}`)
+		if res["status"] != "progressing" || res["message"] != "1 of 2 updated replicas are available" {
+			t.Fatalf("unexpected result: %v", res)
+		}
+	})
+
+	t.Run("logs", func(t *testing.T) {
+		// the fake clientset returns "fake logs" for any pod
+		res := call(t, newK8sPodLogsTool(client), `{"name":"api-crash","previous":true}`)
+		if res["success"] != true || res["logs"] != "fake logs" || res["previous"] != true || res["truncated"] != false {
+			t.Fatalf("unexpected result: %v", res)
+		}
+	})
+}
+
+func TestClipK8sLogs(t *testing.T) {
+	line := strings.Repeat("x", 99) + "\n"
+	full := strings.Repeat(line, k8sMaxLogBytes/len(line)+1)[:k8sMaxLogBytes]
+	logs, truncated := clipK8sLogs(full)
+	if !truncated || !strings.HasSuffix(logs, "\n") || len(logs) != k8sMaxLogBytes/len(line)*len(line) {
+		t.Errorf("truncated=%v len=%d", truncated, len(logs))
+	}
+	if logs, truncated := clipK8sLogs(line); truncated || logs != line {
+		t.Errorf("short logs clipped: %v", truncated)
+	}
+}
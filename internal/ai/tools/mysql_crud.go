@@ -10,6 +10,7 @@ import (
 	"github.com/WyRainBow/ops-portal/internal/ai/errors"
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/components/tool/utils"
+	"github.com/gogf/gf/v2/frame/g"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
@@ -17,7 +18,7 @@ import (
 // MySQLAutomatedInput is the input for automated MySQL operations.
 // This tool is designed for AI automation - no interactive prompts.
 type MySQLAutomatedInput struct {
-	DSN         string `json:"dsn" jsonschema:"description=The Data Source Name for connecting to the MySQL database (user:pass@tcp(host:port)/dbname)"`
+	Connection  string `json:"connection" jsonschema:"description=Name of a configured MySQL connection (mysql_connections in the portal config)"`
 	SQL         string `json:"sql" jsonschema:"description=The SQL query to execute. For writes, must match whitelist tables."`
 	OperateType string `json:"operate_type" jsonschema:"description=Operation type: query (SELECT), insert, update, or delete"`
 	DryRun      bool   `json:"dry_run,omitempty" jsonschema:"description=If true, preview the operation without executing (for write operations). Default false."`
@@ -59,22 +60,23 @@ func NewMysqlCrudTool() tool.InvokableTool {
 		"mysql_crud",
 		"Execute SQL queries against MySQL database. Supports SELECT queries and whitelisted INSERT/UPDATE/DELETE operations. Write operations require a 'reason' field for audit. Use dry_run=true to preview writes before executing.",
 		func(ctx context.Context, input *MySQLAutomatedInput, opts ...tool.Option) (output string, err error) {
-			start := time.Now()
+			// call logging and metrics are done by the registry instrumentation
 			toolName := "mysql_crud"
 
-			defer func() {
-				duration := time.Since(start)
-				success := err == nil && !strings.Contains(output, `"success":false`)
-				errors.ToolResult(toolName, success, duration)
-			}()
-
-			errors.ToolCall(toolName, input)
-
 			// Validate input
-			if input.DSN == "" {
+			// Only named connections resolve; the model never supplies a DSN
+			if strings.TrimSpace(input.Connection) == "" {
+				result := MySQLResult{
+					Success: false,
+					Error:   "connection is required",
+				}
+				return toJSON(result), nil
+			}
+			dsn := mysqlConnectionDSN(ctx, input.Connection)
+			if dsn == "" {
 				result := MySQLResult{
 					Success: false,
-					Error:   "DSN is required",
+					Error:   fmt.Sprintf("Unknown connection: %s", input.Connection),
 				}
 				return toJSON(result), nil
 			}
@@ -121,7 +123,7 @@ func NewMysqlCrudTool() tool.InvokableTool {
 			}
 
 			// Connect to database
-			db, err := gorm.Open(mysql.Open(input.DSN), &gorm.Config{
+			db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
 				// Disable foreign key constraints for automated operations
 				DisableForeignKeyConstraintWhenMigrating: true,
 			})
@@ -175,6 +177,16 @@ func NewMysqlCrudTool() tool.InvokableTool {
 	return t
 }
 
+// mysqlConnectionDSN resolves a named connection from mysql_connections
+// (name -> DSN) in the config.
+func mysqlConnectionDSN(ctx context.Context, name string) string {
+	v, err := g.Cfg().Get(ctx, "mysql_connections")
+	if err != nil || v == nil {
+		return ""
+	}
+	return strings.TrimSpace(v.MapStrStr()[strings.TrimSpace(name)])
+}
+
 // createErrorTool returns a tool that always returns an error
 func createErrorTool(name string, createErr error) tool.InvokableTool {
 	t, _ := utils.InferOptionableTool(
@@ -0,0 +1,25 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestMysqlCrudRequiresNamedConnection(t *testing.T) {
+	tl := NewMysqlCrudTool()
+	cases := map[string]string{
+		`{"dsn":"root:x@tcp(db:3306)/x","sql":"select 1"}`: "connection is required",
+		`{"connection":" ","sql":"select 1"}`:              "connection is required",
+		`{"connection":"nope","sql":"select 1"}`:           "Unknown connection: nope",
+	}
+	for args, want := range cases {
+		out, err := tl.InvokableRun(context.Background(), args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out, want) {
+			t.Errorf("InvokableRun(%s) = %s, want %q", args, out, want)
+		}
+	}
+}
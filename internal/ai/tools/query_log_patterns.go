@@ -0,0 +1,463 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/utility/textutil"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+)
+
+// LokiMetricInput is the input of loki_metric_query.
+type LokiMetricInput struct {
+	Query     string `json:"query" jsonschema:"description=LogQL metric query, e.g. sum by (level) (count_over_time({job=\"resume-backend\"} |= \"ERROR\" [5m])) or topk(5, sum by (path) (rate({job=\"nginx\"} | json [5m])))"`
+	Start     int64  `json:"start,omitempty" jsonschema:"description=Start time (unix seconds/ms/ns). Optional, defaults to 1 hour ago."`
+	End       int64  `json:"end,omitempty" jsonschema:"description=End time (unix seconds/ms/ns). Optional, defaults to now."`
+	Step      string `json:"step,omitempty" jsonschema:"description=Resolution step such as 1m. Optional, chosen automatically."`
+	MaxPoints int    `json:"max_points,omitempty" jsonschema:"description=Max points returned per series after downsampling. Default 60, max 500."`
+}
+
+// LokiDiscoveryInput is the input of loki_label_discovery.
+type LokiDiscoveryInput struct {
+	Action string   `json:"action" jsonschema:"description=One of labels, label_values, series"`
+	Label  string   `json:"label,omitempty" jsonschema:"description=Label name, required for label_values, e.g. job"`
+	Match  []string `json:"match,omitempty" jsonschema:"description=Stream selectors, e.g. {job=\"resume-backend\"}. Required for series, optional for label_values."`
+	Start  int64    `json:"start,omitempty" jsonschema:"description=Start time (unix seconds/ms/ns). Optional, defaults to 6 hours ago."`
+	End    int64    `json:"end,omitempty" jsonschema:"description=End time (unix seconds/ms/ns). Optional, defaults to now."`
+	Limit  int      `json:"limit,omitempty" jsonschema:"description=Max items returned. Default 200, max 1000."`
+}
+
+// LokiPatternInput is the input of loki_log_patterns.
+type LokiPatternInput struct {
+	Query        string `json:"query" jsonschema:"description=LogQL log query, e.g. {job=\"resume-backend\", stream=\"error\"}"`
+	Start        int64  `json:"start,omitempty" jsonschema:"description=Start time (unix seconds/ms/ns). Optional, defaults to 1 hour ago."`
+	End          int64  `json:"end,omitempty" jsonschema:"description=End time (unix seconds/ms/ns). Optional, defaults to now."`
+	Limit        int    `json:"limit,omitempty" jsonschema:"description=Max lines scanned. Default 2000, max 5000."`
+	MaxTemplates int    `json:"max_templates,omitempty" jsonschema:"description=Max templates returned. Default 20, max 100."`
+}
+
+// LogPattern is a group of log lines sharing the same template.
+type LogPattern struct {
+	Template  string   `json:"template"`
+	Count     int      `json:"count"`
+	FirstSeen string   `json:"first_seen"`
+	LastSeen  string   `json:"last_seen"`
+	Samples   []string `json:"samples"`
+}
+
+func lokiBaseURL() string {
+	base := os.Getenv("OBS_LOKI_URL")
+	if base == "" {
+		base = "http://127.0.0.1:3100"
+	}
+	return strings.TrimRight(base, "/")
+}
+
+// lokiGet calls a Loki HTTP API endpoint and returns the "data" field.
+func lokiGet(ctx context.Context, path string, params url.Values) (json.RawMessage, error) {
+	u := lokiBaseURL() + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	cctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(cctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Loki: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 32*1024*1024))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("loki returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var lr struct {
+		Status string          `json:"status"`
+		Data   json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(body, &lr); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+	if lr.Status != "success" {
+		return nil, fmt.Errorf("loki query failed: %s", strings.TrimSpace(string(body)))
+	}
+	return lr.Data, nil
+}
+
+// lokiRange resolves start/end (unix ns) with a default lookback and a 24h cap.
+func lokiRange(start, end int64, lookback time.Duration) (int64, int64) {
+	now := time.Now().UTC()
+	end = normalizeUnixToNs(end)
+	start = normalizeUnixToNs(start)
+	if end <= 0 {
+		end = now.UnixNano()
+	}
+	if start <= 0 {
+		start = time.Unix(0, end).Add(-lookback).UnixNano()
+	}
+	if time.Duration(end-start) > 24*time.Hour {
+		start = time.Unix(0, end).Add(-24 * time.Hour).UnixNano()
+	}
+	return start, end
+}
+
+var (
+	reLogUUID      = regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`)
+	reLogTimestamp = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?`)
+	reLogIP        = regexp.MustCompile(`\b\d{1,3}(?:\.\d{1,3}){3}(?::\d+)?\b`)
+	reLogHex       = regexp.MustCompile(`\b(?:0x)?[0-9a-fA-F]{12,}\b`)
+	reLogQuoted    = regexp.MustCompile(`"[^"]*"|'[^']*'`)
+	reLogNumber    = regexp.MustCompile(`\b\d+(?:\.\d+)?(?:ms|s|µs|us|ns|m|h|b|kb|mb|gb|%)?\b`)
+	reLogSpaces    = regexp.MustCompile(`\s+`)
+)
+
+// logTemplate masks variable tokens (ids, timestamps, ips, numbers, quoted
+// values) so that lines produced by the same log statement collapse together.
+func logTemplate(line string) string {
+	t := reLogTimestamp.ReplaceAllString(line, "<ts>")
+	t = reLogUUID.ReplaceAllString(t, "<uuid>")
+	t = reLogIP.ReplaceAllString(t, "<ip>")
+	t = reLogHex.ReplaceAllString(t, "<hex>")
+	t = reLogQuoted.ReplaceAllString(t, "<str>")
+	t = reLogNumber.ReplaceAllString(t, "<num>")
+	t = reLogSpaces.ReplaceAllString(strings.TrimSpace(t), " ")
+	return truncateRunes(t, 300)
+}
+
+// truncateRunes cuts s to at most n runes so multi-byte log text stays valid.
+func truncateRunes(s string, n int) string {
+	if t, cut := textutil.Truncate(s, n); cut {
+		return t + "…"
+	}
+	return s
+}
+
+// clusterLogLines groups lines by template, most frequent first.
+func clusterLogLines(lines []LokiLine, maxTemplates int) []LogPattern {
+	type seen struct{ first, last time.Time }
+	byTemplate := make(map[string]*LogPattern)
+	spans := make(map[string]*seen)
+	order := make([]string, 0)
+	for _, l := range lines {
+		tpl := logTemplate(l.Line)
+		ts := lokiLineTime(l.Ts)
+		p, ok := byTemplate[tpl]
+		if !ok {
+			p = &LogPattern{Template: tpl, FirstSeen: l.Ts, LastSeen: l.Ts, Samples: []string{}}
+			byTemplate[tpl] = p
+			spans[tpl] = &seen{first: ts, last: ts}
+			order = append(order, tpl)
+		}
+		p.Count++
+		// compare parsed times: RFC3339 strings with different fractional
+		// precision or offsets do not sort lexically
+		if sp := spans[tpl]; !ts.IsZero() {
+			if sp.first.IsZero() || ts.Before(sp.first) {
+				sp.first, p.FirstSeen = ts, l.Ts
+			}
+			if sp.last.IsZero() || ts.After(sp.last) {
+				sp.last, p.LastSeen = ts, l.Ts
+			}
+		}
+		if len(p.Samples) < 3 {
+			p.Samples = append(p.Samples, truncateRunes(l.Line, 500))
+		}
+	}
+
+	out := make([]LogPattern, 0, len(order))
+	for _, tpl := range order {
+		out = append(out, *byTemplate[tpl])
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Count > out[j].Count })
+	if maxTemplates > 0 && len(out) > maxTemplates {
+		out = out[:maxTemplates]
+	}
+	for i := range out {
+		out[i].FirstSeen = lokiTsToRFC3339(out[i].FirstSeen)
+		out[i].LastSeen = lokiTsToRFC3339(out[i].LastSeen)
+	}
+	return out
+}
+
+// lokiLineTime parses a line timestamp: unix nanoseconds as returned by Loki,
+// or RFC3339 as in pod logs. Unparsable timestamps yield the zero time.
+func lokiLineTime(ts string) time.Time {
+	if ns, err := strconv.ParseInt(ts, 10, 64); err == nil {
+		return time.Unix(0, ns)
+	}
+	t, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func lokiTsToRFC3339(ts string) string {
+	t := lokiLineTime(ts)
+	if t.IsZero() {
+		return ts
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// NewLokiMetricQueryTool 创建 LogQL 指标查询工具
+func NewLokiMetricQueryTool() tool.InvokableTool {
+	t, err := utils.InferOptionableTool(
+		"loki_metric_query",
+		"Run a LogQL metric query (count_over_time, rate, sum by, topk by ...) over a time range and return downsampled series with min/max/avg/last summaries. Use this to measure log volume or error rate instead of pulling raw lines.",
+		func(ctx context.Context, input *LokiMetricInput, opts ...tool.Option) (output string, err error) {
+			if strings.TrimSpace(input.Query) == "" {
+				return `{"success":false,"error":"query is empty"}`, nil
+			}
+			start, end := lokiRange(input.Start, input.End, time.Hour)
+			step := autoStep(time.Duration(end - start))
+			if input.Step != "" {
+				d, perr := time.ParseDuration(input.Step)
+				if perr != nil {
+					return fmt.Sprintf(`{"success":false,"error":"invalid step %s"}`, escapeJSON(input.Step)), nil
+				}
+				if d > 0 {
+					step = d
+				}
+			}
+			maxPoints := input.MaxPoints
+			if maxPoints <= 0 {
+				maxPoints = promDefaultMaxPoints
+			}
+			if maxPoints > 500 {
+				maxPoints = 500
+			}
+
+			params := url.Values{}
+			params.Set("query", input.Query)
+			params.Set("start", strconv.FormatInt(start, 10))
+			params.Set("end", strconv.FormatInt(end, 10))
+			params.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+			data, err := lokiGet(ctx, "/loki/api/v1/query_range", params)
+			if err != nil {
+				return promError(err), nil
+			}
+
+			var res struct {
+				ResultType string `json:"resultType"`
+				Result     []struct {
+					Metric map[string]string `json:"metric"`
+					Values [][]any           `json:"values"`
+				} `json:"result"`
+			}
+			if err := json.Unmarshal(data, &res); err != nil {
+				return promError(fmt.Errorf("failed to parse result: %v", err)), nil
+			}
+			if res.ResultType != "matrix" {
+				return promError(fmt.Errorf("query returned %s, not a metric query; use loki_log_patterns or query_loki_logs for log lines", res.ResultType)), nil
+			}
+
+			series := make([]PromSeriesSummary, 0, len(res.Result))
+			for _, r := range res.Result {
+				points := make([]PromPoint, 0, len(r.Values))
+				for _, v := range r.Values {
+					if p, ok := parseSample(v); ok {
+						points = append(points, p)
+					}
+				}
+				series = append(series, summarizeSeries(r.Metric, points, maxPoints))
+			}
+			sort.SliceStable(series, func(i, j int) bool { return series[i].Last > series[j].Last })
+			total := len(series)
+			if len(series) > promMaxSeries {
+				series = series[:promMaxSeries]
+			}
+
+			return promJSON(map[string]any{
+				"success":      true,
+				"query":        input.Query,
+				"start":        time.Unix(0, start).UTC().Format(time.RFC3339),
+				"end":          time.Unix(0, end).UTC().Format(time.RFC3339),
+				"step":         step.String(),
+				"series_total": total,
+				"truncated":    total > len(series),
+				"series":       series,
+			}), nil
+		},
+	)
+	if err != nil {
+		return createErrorLokiNamedTool("loki_metric_query", err)
+	}
+	return t
+}
+
+// NewLokiLabelDiscoveryTool 创建 Loki 标签/流发现工具
+func NewLokiLabelDiscoveryTool() tool.InvokableTool {
+	t, err := utils.InferOptionableTool(
+		"loki_label_discovery",
+		"Discover Loki labels and streams. action=labels lists label names, action=label_values lists values of a label (e.g. job), action=series lists streams matching selectors. Use this before writing LogQL when unsure of job or label names.",
+		func(ctx context.Context, input *LokiDiscoveryInput, opts ...tool.Option) (output string, err error) {
+			limit := input.Limit
+			if limit <= 0 {
+				limit = 200
+			}
+			if limit > 1000 {
+				limit = 1000
+			}
+			start, end := lokiRange(input.Start, input.End, 6*time.Hour)
+			params := url.Values{}
+			params.Set("start", strconv.FormatInt(start, 10))
+			params.Set("end", strconv.FormatInt(end, 10))
+
+			var path string
+			switch input.Action {
+			case "labels":
+				path = "/loki/api/v1/labels"
+			case "label_values":
+				if strings.TrimSpace(input.Label) == "" {
+					return `{"success":false,"error":"label is required for label_values"}`, nil
+				}
+				path = "/loki/api/v1/label/" + url.PathEscape(input.Label) + "/values"
+				if len(input.Match) > 0 {
+					params.Set("query", input.Match[0])
+				}
+			case "series":
+				for _, m := range input.Match {
+					if strings.TrimSpace(m) != "" {
+						params.Add("match[]", m)
+					}
+				}
+				if len(params["match[]"]) == 0 {
+					return `{"success":false,"error":"match is required for series"}`, nil
+				}
+				path = "/loki/api/v1/series"
+			default:
+				return `{"success":false,"error":"action must be one of labels, label_values, series"}`, nil
+			}
+
+			data, err := lokiGet(ctx, path, params)
+			if err != nil {
+				return promError(err), nil
+			}
+
+			out := map[string]any{
+				"success": true,
+				"action":  input.Action,
+			}
+			if input.Action == "series" {
+				var series []map[string]string
+				if err := json.Unmarshal(data, &series); err != nil {
+					return promError(err), nil
+				}
+				out["total"] = len(series)
+				out["truncated"] = len(series) > limit
+				if len(series) > limit {
+					series = series[:limit]
+				}
+				out["series"] = series
+			} else {
+				var values []string
+				if err := json.Unmarshal(data, &values); err != nil {
+					return promError(err), nil
+				}
+				sort.Strings(values)
+				out["total"] = len(values)
+				out["truncated"] = len(values) > limit
+				if len(values) > limit {
+					values = values[:limit]
+				}
+				out["values"] = values
+			}
+			return promJSON(out), nil
+		},
+	)
+	if err != nil {
+		return createErrorLokiNamedTool("loki_label_discovery", err)
+	}
+	return t
+}
+
+// NewLokiLogPatternsTool 创建日志模式聚类工具
+func NewLokiLogPatternsTool() tool.InvokableTool {
+	t, err := utils.InferOptionableTool(
+		"loki_log_patterns",
+		"Fetch log lines for a LogQL query and collapse them into templates (numbers, ids, ips, timestamps masked) with counts, first/last seen and sample lines. Prefer this over query_loki_logs to get an overview of what errors occur and how often.",
+		func(ctx context.Context, input *LokiPatternInput, opts ...tool.Option) (output string, err error) {
+			if strings.TrimSpace(input.Query) == "" {
+				return `{"success":false,"error":"query is empty"}`, nil
+			}
+			limit := input.Limit
+			if limit <= 0 {
+				limit = 2000
+			}
+			if limit > 5000 {
+				limit = 5000
+			}
+			maxTemplates := input.MaxTemplates
+			if maxTemplates <= 0 {
+				maxTemplates = 20
+			}
+			if maxTemplates > 100 {
+				maxTemplates = 100
+			}
+			start, end := lokiRange(input.Start, input.End, time.Hour)
+
+			params := url.Values{}
+			params.Set("query", input.Query)
+			params.Set("start", strconv.FormatInt(start, 10))
+			params.Set("end", strconv.FormatInt(end, 10))
+			params.Set("limit", strconv.Itoa(limit))
+			data, err := lokiGet(ctx, "/loki/api/v1/query_range", params)
+			if err != nil {
+				return promError(err), nil
+			}
+
+			var raw map[string]any
+			if err := json.Unmarshal([]byte(`{"data":`+string(data)+`}`), &raw); err != nil {
+				return promError(fmt.Errorf("failed to parse result: %v", err)), nil
+			}
+			lines := extractLines(raw)
+			patterns := clusterLogLines(lines, maxTemplates)
+
+			return promJSON(map[string]any{
+				"success":   true,
+				"query":     input.Query,
+				"start":     time.Unix(0, start).UTC().Format(time.RFC3339),
+				"end":       time.Unix(0, end).UTC().Format(time.RFC3339),
+				"lines":     len(lines),
+				"limit_hit": len(lines) >= limit,
+				"templates": len(patterns),
+				"patterns":  patterns,
+			}), nil
+		},
+	)
+	if err != nil {
+		return createErrorLokiNamedTool("loki_log_patterns", err)
+	}
+	return t
+}
+
+// createErrorLokiNamedTool returns a tool that always returns an error
+func createErrorLokiNamedTool(name string, createErr error) tool.InvokableTool {
+	t, _ := utils.InferOptionableTool(
+		name,
+		"Error tool - Loki tool failed to initialize",
+		func(ctx context.Context, input any, opts ...tool.Option) (output string, err error) {
+			return fmt.Sprintf(`{"success":false,"error":"Tool initialization failed: %s"}`, escapeJSON(createErr.Error())), nil
+		},
+	)
+	return t
+}
@@ -0,0 +1,47 @@
+package tools
+
+import "testing"
+
+func TestClusterLogLines(t *testing.T) {
+	lines := []LokiLine{
+		{Ts: "3", Line: `2026-03-01T10:00:03Z ERROR request 7f3c2a10-1b2c-4d5e-8f90-112233445566 failed after 120ms from 10.0.0.1:443`},
+		{Ts: "1", Line: `2026-03-01T10:00:01Z ERROR request 0a1b2c3d-1b2c-4d5e-8f90-aabbccddeeff failed after 35ms from 10.0.0.2:443`},
+		{Ts: "2", Line: `2026-03-01T10:00:02Z WARN cache miss for key "user:42"`},
+	}
+
+	patterns := clusterLogLines(lines, 10)
+	if len(patterns) != 2 {
+		t.Fatalf("got %d patterns, want 2: %+v", len(patterns), patterns)
+	}
+	top := patterns[0]
+	if top.Count != 2 {
+		t.Errorf("top count = %d, want 2", top.Count)
+	}
+	want := "<ts> ERROR request <uuid> failed after <num> from <ip>"
+	if top.Template != want {
+		t.Errorf("template = %q, want %q", top.Template, want)
+	}
+	if top.FirstSeen > top.LastSeen {
+		t.Errorf("first_seen %s after last_seen %s", top.FirstSeen, top.LastSeen)
+	}
+	if len(top.Samples) != 2 {
+		t.Errorf("samples = %d, want 2", len(top.Samples))
+	}
+}
+
+func TestClusterLogLinesOrdersByParsedTime(t *testing.T) {
+	// 字符串比较时 "10:00:01Z" > "10:00:01.5Z"，偏移量不同的时间也会排错
+	lines := []LokiLine{
+		{Ts: "2026-03-01T10:00:01.5Z", Line: "ERROR upstream timeout"},
+		{Ts: "2026-03-01T10:00:01Z", Line: "ERROR upstream timeout"},
+		{Ts: "2026-03-01T18:00:02+08:00", Line: "ERROR upstream timeout"},
+		{Ts: "2026-03-01T10:00:03Z", Line: "ERROR upstream timeout"},
+	}
+	patterns := clusterLogLines(lines, 10)
+	if len(patterns) != 1 {
+		t.Fatalf("got %d patterns, want 1", len(patterns))
+	}
+	if p := patterns[0]; p.FirstSeen != "2026-03-01T10:00:01Z" || p.LastSeen != "2026-03-01T10:00:03Z" {
+		t.Errorf("first_seen=%s last_seen=%s", p.FirstSeen, p.LastSeen)
+	}
+}
@@ -0,0 +1,518 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+)
+
+const (
+	promDefaultMaxPoints = 60
+	promMaxSeries        = 20
+	promMaxRange         = 7 * 24 * time.Hour
+)
+
+// PromRangeInput is the input of query_prometheus_range.
+type PromRangeInput struct {
+	Query     string `json:"query" jsonschema:"description=PromQL expression, e.g. sum(rate(http_requests_total{status=~\"5..\"}[5m])) by (service)"`
+	Start     int64  `json:"start,omitempty" jsonschema:"description=Start time (unix seconds/ms/ns). Optional, defaults to 1 hour ago."`
+	End       int64  `json:"end,omitempty" jsonschema:"description=End time (unix seconds/ms/ns). Optional, defaults to now."`
+	Step      string `json:"step,omitempty" jsonschema:"description=Resolution step such as 30s or 5m. Optional, chosen automatically."`
+	MaxPoints int    `json:"max_points,omitempty" jsonschema:"description=Max points returned per series after downsampling. Default 60, max 500."`
+}
+
+// PromInstantInput is the input of prometheus_instant_query.
+type PromInstantInput struct {
+	Query string `json:"query" jsonschema:"description=PromQL expression evaluated at a single point in time"`
+	Time  int64  `json:"time,omitempty" jsonschema:"description=Evaluation time (unix seconds/ms/ns). Optional, defaults to now."`
+	Limit int    `json:"limit,omitempty" jsonschema:"description=Max series returned. Default 50, max 500."`
+}
+
+// PromDiscoveryInput is the input of prometheus_series_discovery.
+type PromDiscoveryInput struct {
+	Action string   `json:"action" jsonschema:"description=One of labels, label_values, series, metadata"`
+	Label  string   `json:"label,omitempty" jsonschema:"description=Label name, required for label_values, e.g. job"`
+	Match  []string `json:"match,omitempty" jsonschema:"description=Series selectors to restrict results, e.g. up{job=\"api\"}. Required for series."`
+	Metric string   `json:"metric,omitempty" jsonschema:"description=Metric name for metadata. Optional."`
+	Limit  int      `json:"limit,omitempty" jsonschema:"description=Max items returned. Default 200, max 1000."`
+}
+
+// PromPoint is a single [timestamp, value] sample.
+type PromPoint struct {
+	Ts    int64   `json:"ts"`
+	Value float64 `json:"value"`
+}
+
+// PromSeriesSummary summarises one range-query series.
+type PromSeriesSummary struct {
+	Labels  map[string]string `json:"labels"`
+	Samples int               `json:"samples"`
+	Min     float64           `json:"min"`
+	Max     float64           `json:"max"`
+	Avg     float64           `json:"avg"`
+	Last    float64           `json:"last"`
+	Points  []PromPoint       `json:"points"`
+}
+
+type promResponse struct {
+	Status    string          `json:"status"`
+	Data      json.RawMessage `json:"data"`
+	Error     string          `json:"error,omitempty"`
+	ErrorType string          `json:"errorType,omitempty"`
+}
+
+func promBaseURL() string {
+	base := os.Getenv("OBS_PROM_URL")
+	if base == "" {
+		base = "http://127.0.0.1:9090"
+	}
+	return strings.TrimRight(base, "/")
+}
+
+// promGet calls a Prometheus HTTP API endpoint and returns the "data" field.
+func promGet(ctx context.Context, path string, params url.Values) (json.RawMessage, error) {
+	u := promBaseURL() + path
+	if len(params) > 0 {
+		u += "?" + params.Encode()
+	}
+	cctx, cancel := context.WithTimeout(ctx, 15*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(cctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Prometheus: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 16*1024*1024))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	var pr promResponse
+	if err := json.Unmarshal(body, &pr); err != nil {
+		if resp.StatusCode/100 != 2 {
+			return nil, fmt.Errorf("prometheus returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		}
+		return nil, fmt.Errorf("failed to parse response: %v", err)
+	}
+	if pr.Status != "success" {
+		return nil, fmt.Errorf("prometheus %s: %s", pr.ErrorType, pr.Error)
+	}
+	return pr.Data, nil
+}
+
+func promJSON(v any) string {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return promError(fmt.Errorf("failed to encode result: %v", err))
+	}
+	return string(b)
+}
+
+func promError(err error) string {
+	return fmt.Sprintf(`{"success":false,"error":"%s"}`, escapeJSON(err.Error()))
+}
+
+// promUnixSeconds normalises seconds/ms/us/ns timestamps to unix seconds.
+func promUnixSeconds(v int64) int64 {
+	if v <= 0 {
+		return v
+	}
+	return normalizeUnixToNs(v) / int64(time.Second)
+}
+
+// autoStep picks a step that yields roughly 250 raw samples, at least 15s.
+func autoStep(rng time.Duration) time.Duration {
+	step := (rng / 250).Round(time.Second)
+	if step < 15*time.Second {
+		step = 15 * time.Second
+	}
+	return step
+}
+
+// parseSample parses a [ts, "value"] pair. Non-finite values (NaN from 0/0
+// ratios, ±Inf) are dropped: they carry no magnitude and JSON cannot encode
+// them.
+func parseSample(pair []any) (PromPoint, bool) {
+	if len(pair) < 2 {
+		return PromPoint{}, false
+	}
+	ts, _ := pair[0].(float64)
+	s, _ := pair[1].(string)
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return PromPoint{}, false
+	}
+	return PromPoint{Ts: int64(ts), Value: v}, true
+}
+
+// summarizeSeries computes min/max/avg/last over all samples and downsamples
+// the points into at most maxPoints buckets (bucket average).
+func summarizeSeries(labels map[string]string, points []PromPoint, maxPoints int) PromSeriesSummary {
+	s := PromSeriesSummary{Labels: labels, Samples: len(points)}
+	if len(points) == 0 {
+		s.Points = []PromPoint{}
+		return s
+	}
+
+	s.Min, s.Max = math.Inf(1), math.Inf(-1)
+	sum, n := 0.0, 0
+	for _, p := range points {
+		if math.IsNaN(p.Value) || math.IsInf(p.Value, 0) {
+			continue
+		}
+		s.Min = math.Min(s.Min, p.Value)
+		s.Max = math.Max(s.Max, p.Value)
+		sum += p.Value
+		n++
+	}
+	if n > 0 {
+		s.Avg = sum / float64(n)
+	} else {
+		s.Min, s.Max = 0, 0
+	}
+	s.Last = points[len(points)-1].Value
+
+	if len(points) <= maxPoints {
+		s.Points = points
+		return s
+	}
+	s.Points = make([]PromPoint, 0, maxPoints)
+	bucket := float64(len(points)) / float64(maxPoints)
+	for i := 0; i < maxPoints; i++ {
+		from := int(float64(i) * bucket)
+		to := int(float64(i+1) * bucket)
+		if to > len(points) {
+			to = len(points)
+		}
+		if from >= to {
+			continue
+		}
+		total := 0.0
+		for _, p := range points[from:to] {
+			total += p.Value
+		}
+		s.Points = append(s.Points, PromPoint{Ts: points[to-1].Ts, Value: total / float64(to-from)})
+	}
+	return s
+}
+
+// NewPrometheusRangeQueryTool 创建 PromQL 区间查询工具
+func NewPrometheusRangeQueryTool() tool.InvokableTool {
+	t, err := utils.InferOptionableTool(
+		"query_prometheus_range",
+		"Run a PromQL range query and return downsampled series with min/max/avg/last summaries. Use this to inspect metric curves such as error rate, latency or saturation over a time window, e.g. when investigating '接口失败率过高'.",
+		func(ctx context.Context, input *PromRangeInput, opts ...tool.Option) (output string, err error) {
+			if strings.TrimSpace(input.Query) == "" {
+				return `{"success":false,"error":"query is empty"}`, nil
+			}
+			now := time.Now().UTC()
+			end := promUnixSeconds(input.End)
+			if end <= 0 {
+				end = now.Unix()
+			}
+			start := promUnixSeconds(input.Start)
+			if start <= 0 {
+				start = end - int64(time.Hour/time.Second)
+			}
+			if start >= end {
+				return `{"success":false,"error":"start must be before end"}`, nil
+			}
+			if time.Duration(end-start)*time.Second > promMaxRange {
+				start = end - int64(promMaxRange/time.Second)
+			}
+
+			step := autoStep(time.Duration(end-start) * time.Second)
+			if input.Step != "" {
+				d, perr := time.ParseDuration(input.Step)
+				if perr != nil {
+					secs, aerr := strconv.Atoi(input.Step)
+					if aerr != nil {
+						return promError(fmt.Errorf("invalid step %q", input.Step)), nil
+					}
+					d = time.Duration(secs) * time.Second
+				}
+				if d > 0 {
+					step = d
+				}
+			}
+			// Prometheus rejects queries above 11000 points per series.
+			if minStep := time.Duration(end-start) * time.Second / 11000; step < minStep {
+				step = minStep.Round(time.Second) + time.Second
+			}
+			maxPoints := input.MaxPoints
+			if maxPoints <= 0 {
+				maxPoints = promDefaultMaxPoints
+			}
+			if maxPoints > 500 {
+				maxPoints = 500
+			}
+
+			params := url.Values{}
+			params.Set("query", input.Query)
+			params.Set("start", strconv.FormatInt(start, 10))
+			params.Set("end", strconv.FormatInt(end, 10))
+			params.Set("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+			data, err := promGet(ctx, "/api/v1/query_range", params)
+			if err != nil {
+				return promError(err), nil
+			}
+
+			var matrix struct {
+				ResultType string `json:"resultType"`
+				Result     []struct {
+					Metric map[string]string `json:"metric"`
+					Values [][]any           `json:"values"`
+				} `json:"result"`
+			}
+			if err := json.Unmarshal(data, &matrix); err != nil {
+				return promError(fmt.Errorf("failed to parse matrix: %v", err)), nil
+			}
+
+			series := make([]PromSeriesSummary, 0, len(matrix.Result))
+			for _, r := range matrix.Result {
+				points := make([]PromPoint, 0, len(r.Values))
+				for _, v := range r.Values {
+					if p, ok := parseSample(v); ok {
+						points = append(points, p)
+					}
+				}
+				series = append(series, summarizeSeries(r.Metric, points, maxPoints))
+			}
+			// Most relevant series first: highest last value.
+			sort.SliceStable(series, func(i, j int) bool { return series[i].Last > series[j].Last })
+			total := len(series)
+			if len(series) > promMaxSeries {
+				series = series[:promMaxSeries]
+			}
+
+			return promJSON(map[string]any{
+				"success":      true,
+				"query":        input.Query,
+				"start":        time.Unix(start, 0).UTC().Format(time.RFC3339),
+				"end":          time.Unix(end, 0).UTC().Format(time.RFC3339),
+				"step":         step.String(),
+				"series_total": total,
+				"truncated":    total > len(series),
+				"series":       series,
+			}), nil
+		},
+	)
+	if err != nil {
+		return createErrorPromTool("query_prometheus_range", err)
+	}
+	return t
+}
+
+// NewPrometheusInstantQueryTool 创建 PromQL 即时查询工具
+func NewPrometheusInstantQueryTool() tool.InvokableTool {
+	t, err := utils.InferOptionableTool(
+		"prometheus_instant_query",
+		"Evaluate a PromQL expression at a single point in time and return the current value of each series. Use this for quick checks like current error ratio, up status or top-k consumers.",
+		func(ctx context.Context, input *PromInstantInput, opts ...tool.Option) (output string, err error) {
+			if strings.TrimSpace(input.Query) == "" {
+				return `{"success":false,"error":"query is empty"}`, nil
+			}
+			limit := input.Limit
+			if limit <= 0 {
+				limit = 50
+			}
+			if limit > 500 {
+				limit = 500
+			}
+			params := url.Values{}
+			params.Set("query", input.Query)
+			if ts := promUnixSeconds(input.Time); ts > 0 {
+				params.Set("time", strconv.FormatInt(ts, 10))
+			}
+			data, err := promGet(ctx, "/api/v1/query", params)
+			if err != nil {
+				return promError(err), nil
+			}
+
+			var res struct {
+				ResultType string          `json:"resultType"`
+				Result     json.RawMessage `json:"result"`
+			}
+			if err := json.Unmarshal(data, &res); err != nil {
+				return promError(fmt.Errorf("failed to parse result: %v", err)), nil
+			}
+
+			type sample struct {
+				Labels map[string]string `json:"labels,omitempty"`
+				Ts     int64             `json:"ts"`
+				Value  float64           `json:"value"`
+			}
+			samples := make([]sample, 0)
+			switch res.ResultType {
+			case "vector":
+				var vec []struct {
+					Metric map[string]string `json:"metric"`
+					Value  []any             `json:"value"`
+				}
+				if err := json.Unmarshal(res.Result, &vec); err != nil {
+					return promError(err), nil
+				}
+				for _, v := range vec {
+					if p, ok := parseSample(v.Value); ok {
+						samples = append(samples, sample{Labels: v.Metric, Ts: p.Ts, Value: p.Value})
+					}
+				}
+			case "scalar":
+				var pair []any
+				if err := json.Unmarshal(res.Result, &pair); err != nil {
+					return promError(err), nil
+				}
+				if p, ok := parseSample(pair); ok {
+					samples = append(samples, sample{Ts: p.Ts, Value: p.Value})
+				}
+			default:
+				return promError(fmt.Errorf("unsupported result type %q, use query_prometheus_range for range vectors", res.ResultType)), nil
+			}
+
+			sort.SliceStable(samples, func(i, j int) bool { return samples[i].Value > samples[j].Value })
+			total := len(samples)
+			if len(samples) > limit {
+				samples = samples[:limit]
+			}
+			return promJSON(map[string]any{
+				"success":     true,
+				"query":       input.Query,
+				"result_type": res.ResultType,
+				"total":       total,
+				"truncated":   total > len(samples),
+				"samples":     samples,
+			}), nil
+		},
+	)
+	if err != nil {
+		return createErrorPromTool("prometheus_instant_query", err)
+	}
+	return t
+}
+
+// NewPrometheusSeriesDiscoveryTool 创建指标发现工具
+func NewPrometheusSeriesDiscoveryTool() tool.InvokableTool {
+	t, err := utils.InferOptionableTool(
+		"prometheus_series_discovery",
+		"Discover what metrics exist in Prometheus. action=labels lists label names, action=label_values lists values of a label (use label=__name__ to list metric names), action=series lists series matching selectors, action=metadata returns metric type and help text. Use this before writing PromQL when unsure of metric or label names.",
+		func(ctx context.Context, input *PromDiscoveryInput, opts ...tool.Option) (output string, err error) {
+			limit := input.Limit
+			if limit <= 0 {
+				limit = 200
+			}
+			if limit > 1000 {
+				limit = 1000
+			}
+			params := url.Values{}
+			for _, m := range input.Match {
+				if strings.TrimSpace(m) != "" {
+					params.Add("match[]", m)
+				}
+			}
+
+			var path string
+			switch input.Action {
+			case "labels":
+				path = "/api/v1/labels"
+			case "label_values":
+				if strings.TrimSpace(input.Label) == "" {
+					return `{"success":false,"error":"label is required for label_values"}`, nil
+				}
+				path = "/api/v1/label/" + url.PathEscape(input.Label) + "/values"
+			case "series":
+				if len(params["match[]"]) == 0 {
+					return `{"success":false,"error":"match is required for series"}`, nil
+				}
+				path = "/api/v1/series"
+			case "metadata":
+				path = "/api/v1/metadata"
+				params = url.Values{}
+				if input.Metric != "" {
+					params.Set("metric", input.Metric)
+				}
+				params.Set("limit", strconv.Itoa(limit))
+			default:
+				return `{"success":false,"error":"action must be one of labels, label_values, series, metadata"}`, nil
+			}
+
+			data, err := promGet(ctx, path, params)
+			if err != nil {
+				return promError(err), nil
+			}
+
+			out := map[string]any{
+				"success": true,
+				"action":  input.Action,
+			}
+			switch input.Action {
+			case "labels", "label_values":
+				var values []string
+				if err := json.Unmarshal(data, &values); err != nil {
+					return promError(err), nil
+				}
+				sort.Strings(values)
+				out["total"] = len(values)
+				out["truncated"] = len(values) > limit
+				if len(values) > limit {
+					values = values[:limit]
+				}
+				out["values"] = values
+			case "series":
+				var series []map[string]string
+				if err := json.Unmarshal(data, &series); err != nil {
+					return promError(err), nil
+				}
+				out["total"] = len(series)
+				out["truncated"] = len(series) > limit
+				if len(series) > limit {
+					series = series[:limit]
+				}
+				out["series"] = series
+			case "metadata":
+				var meta map[string][]struct {
+					Type string `json:"type"`
+					Help string `json:"help"`
+					Unit string `json:"unit"`
+				}
+				if err := json.Unmarshal(data, &meta); err != nil {
+					return promError(err), nil
+				}
+				out["total"] = len(meta)
+				out["metadata"] = meta
+			}
+			return promJSON(out), nil
+		},
+	)
+	if err != nil {
+		return createErrorPromTool("prometheus_series_discovery", err)
+	}
+	return t
+}
+
+// createErrorPromTool returns a tool that always returns an error
+func createErrorPromTool(name string, createErr error) tool.InvokableTool {
+	t, _ := utils.InferOptionableTool(
+		name,
+		"Error tool - Prometheus tool failed to initialize",
+		func(ctx context.Context, input any, opts ...tool.Option) (output string, err error) {
+			return fmt.Sprintf(`{"success":false,"error":"Tool initialization failed: %s"}`, escapeJSON(createErr.Error())), nil
+		},
+	)
+	return t
+}
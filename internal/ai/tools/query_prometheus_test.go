@@ -0,0 +1,137 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newPromTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var data string
+		switch r.URL.Path {
+		case "/api/v1/query_range":
+			if r.URL.Query().Get("query") == "" || r.URL.Query().Get("step") == "" {
+				http.Error(w, "missing query or step", http.StatusBadRequest)
+				return
+			}
+			var values []string
+			for i := 0; i < 120; i++ {
+				values = append(values, fmt.Sprintf(`[%d,"%d"]`, 1700000000+15*i, i))
+			}
+			// 0/0 的错误率是 NaN
+			data = `{"resultType":"matrix","result":[` +
+				`{"metric":{"service":"api"},"values":[` + strings.Join(values, ",") + `]},` +
+				`{"metric":{"service":"idle"},"values":[[1700000000,"NaN"],[1700000015,"NaN"]]}]}`
+		case "/api/v1/query":
+			data = `{"resultType":"vector","result":[` +
+				`{"metric":{"service":"api"},"value":[1700000000,"0.12"]},` +
+				`{"metric":{"service":"idle"},"value":[1700000000,"NaN"]},` +
+				`{"metric":{"service":"web"},"value":[1700000000,"+Inf"]}]}`
+		case "/api/v1/label/job/values":
+			data = `["resume-backend","node","api"]`
+		case "/api/v1/series":
+			if r.URL.Query().Get("match[]") != `up{job="api"}` {
+				http.Error(w, "bad match", http.StatusBadRequest)
+				return
+			}
+			data = `[{"__name__":"up","job":"api","instance":"a:9100"}]`
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"status":"success","data":%s}`, data)
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("OBS_PROM_URL", srv.URL)
+	return srv
+}
+
+func TestPrometheusRangeQueryTool(t *testing.T) {
+	newPromTestServer(t)
+	out, err := NewPrometheusRangeQueryTool().InvokableRun(context.Background(),
+		`{"query":"sum(rate(http_requests_total[5m])) by (service)","start":1700000000,"end":1700001800,"max_points":10}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res struct {
+		Success bool                `json:"success"`
+		Total   int                 `json:"series_total"`
+		Series  []PromSeriesSummary `json:"series"`
+	}
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatalf("invalid json %q: %v", out, err)
+	}
+	if !res.Success || res.Total != 2 {
+		t.Fatalf("unexpected result: %s", out)
+	}
+	api := res.Series[0]
+	if api.Labels["service"] != "api" || api.Samples != 120 || len(api.Points) != 10 || api.Max != 119 || api.Last != 119 {
+		t.Errorf("unexpected api series: %+v", api)
+	}
+	if idle := res.Series[1]; idle.Samples != 0 || len(idle.Points) != 0 {
+		t.Errorf("NaN samples should be dropped: %+v", idle)
+	}
+}
+
+func TestPrometheusInstantQueryTool(t *testing.T) {
+	newPromTestServer(t)
+	out, err := NewPrometheusInstantQueryTool().InvokableRun(context.Background(), `{"query":"error_ratio"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res struct {
+		Success bool `json:"success"`
+		Total   int  `json:"total"`
+		Samples []struct {
+			Labels map[string]string `json:"labels"`
+			Value  float64           `json:"value"`
+		} `json:"samples"`
+	}
+	if err := json.Unmarshal([]byte(out), &res); err != nil {
+		t.Fatalf("invalid json %q: %v", out, err)
+	}
+	if !res.Success || res.Total != 1 || res.Samples[0].Labels["service"] != "api" || res.Samples[0].Value != 0.12 {
+		t.Errorf("unexpected result: %s", out)
+	}
+}
+
+func TestPrometheusSeriesDiscoveryTool(t *testing.T) {
+	newPromTestServer(t)
+	out, _ := NewPrometheusSeriesDiscoveryTool().InvokableRun(context.Background(), `{"action":"label_values","label":"job","limit":2}`)
+	var values struct {
+		Total     int      `json:"total"`
+		Truncated bool     `json:"truncated"`
+		Values    []string `json:"values"`
+	}
+	if err := json.Unmarshal([]byte(out), &values); err != nil {
+		t.Fatalf("invalid json %q: %v", out, err)
+	}
+	if values.Total != 3 || !values.Truncated || strings.Join(values.Values, ",") != "api,node" {
+		t.Errorf("unexpected label values: %s", out)
+	}
+
+	out, _ = NewPrometheusSeriesDiscoveryTool().InvokableRun(context.Background(), `{"action":"series","match":["up{job=\"api\"}"]}`)
+	if !strings.Contains(out, `"instance": "a:9100"`) {
+		t.Errorf("unexpected series: %s", out)
+	}
+
+	out, _ = NewPrometheusSeriesDiscoveryTool().InvokableRun(context.Background(), `{"action":"series"}`)
+	if !strings.Contains(out, "match is required") {
+		t.Errorf("series without match should fail: %s", out)
+	}
+}
+
+func TestPromJSONReportsEncodeErrors(t *testing.T) {
+	out := promJSON(map[string]any{"value": math.NaN()})
+	if !strings.Contains(out, `"success":false`) {
+		t.Errorf("promJSON = %q", out)
+	}
+}
@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/internal/ops/changes"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/components/tool/utils"
+)
+
+const (
+	changesDefaultWindow = 6 * time.Hour
+	changesMaxWindow     = 7 * 24 * time.Hour
+	changesMaxEvents     = 100
+)
+
+// RecentChangesInput is the input of recent_changes.
+type RecentChangesInput struct {
+	Service       string   `json:"service,omitempty" jsonschema:"description=Service name, e.g. resume-backend. Optional, defaults to all services"`
+	Window        string   `json:"window,omitempty" jsonschema:"description=Look-back window such as 30m, 6h or 24h. Default 6h, max 168h"`
+	Kinds         []string `json:"kinds,omitempty" jsonschema:"description=Filter by kind: deploy, config, commit, restart, rollback"`
+	ReferenceTime int64    `json:"reference_time,omitempty" jsonschema:"description=Incident start (unix seconds/ms/ns). Events are related to it with minutes_before_reference. Optional, defaults to now"`
+}
+
+// NewRecentChangesTool 创建变更事件时间线工具
+func NewRecentChangesTool() tool.InvokableTool {
+	t, err := utils.InferOptionableTool(
+		"recent_changes",
+		"List recent deploys, config changes, git commits and process restarts of a service, newest first, with the time between each change and the incident (reference_time). Use it early in a diagnosis: most incidents start shortly after a change.",
+		func(ctx context.Context, input *RecentChangesInput, opts ...tool.Option) (output string, err error) {
+			window := changesDefaultWindow
+			if w := strings.TrimSpace(input.Window); w != "" {
+				d, perr := time.ParseDuration(w)
+				if perr != nil || d <= 0 {
+					return promError(fmt.Errorf("invalid window %q, use e.g. 30m or 24h", w)), nil
+				}
+				window = d
+			}
+			if window > changesMaxWindow {
+				window = changesMaxWindow
+			}
+			ref := time.Now().UTC()
+			if v := promUnixSeconds(input.ReferenceTime); v > 0 {
+				ref = time.Unix(v, 0).UTC()
+			}
+			for _, k := range input.Kinds {
+				if !changes.ValidKind(k) {
+					return promError(fmt.Errorf("invalid kind %q (deploy, config, commit, restart, rollback)", k)), nil
+				}
+			}
+
+			qctx, cancel := context.WithTimeout(ctx, dbQueryTimeout)
+			defer cancel()
+			rows, err := changes.Recent(qctx, changes.Query{
+				Service: input.Service,
+				Kinds:   input.Kinds,
+				Since:   ref.Add(-window),
+				Until:   ref.Add(window / 4), // 也包含事故开始后不久的变更（如回滚）
+				Limit:   changesMaxEvents,
+			})
+			if err != nil {
+				return promError(fmt.Errorf("query change events: %v", err)), nil
+			}
+
+			events := make([]map[string]any, 0, len(rows))
+			var lastDeploy map[string]any
+			for _, r := range rows {
+				before := ref.Sub(r.OccurredAt).Minutes()
+				ev := map[string]any{
+					"service":                  r.Service,
+					"kind":                     r.Kind,
+					"source":                   r.Source,
+					"occurred_at":              r.OccurredAt.UTC().Format(time.RFC3339),
+					"minutes_before_reference": math.Round(before*10) / 10,
+				}
+				for k, v := range map[string]string{"version": r.Version, "summary": r.Summary, "author": r.Author, "url": r.URL} {
+					if v != "" {
+						ev[k] = v
+					}
+				}
+				if len(r.Metadata) > 0 {
+					var meta map[string]any
+					if json.Unmarshal(r.Metadata, &meta) == nil {
+						ev["metadata"] = meta
+					}
+				}
+				if lastDeploy == nil && before >= 0 && (r.Kind == changes.KindDeploy || r.Kind == changes.KindCommit || r.Kind == changes.KindRollback) {
+					lastDeploy = ev
+				}
+				events = append(events, ev)
+			}
+			out := map[string]any{
+				"success":        true,
+				"reference_time": ref.Format(time.RFC3339),
+				"window":         window.String(),
+				"count":          len(events),
+				"events":         events,
+			}
+			if lastDeploy != nil {
+				out["last_deploy_before_reference"] = lastDeploy
+			}
+			return promJSON(out), nil
+		},
+	)
+	if err != nil {
+		return createErrorChangesTool("recent_changes", err)
+	}
+	return t
+}
+
+// createErrorChangesTool returns a tool that always returns an error
+func createErrorChangesTool(name string, createErr error) tool.InvokableTool {
+	t, _ := utils.InferOptionableTool(
+		name,
+		"Error tool - Change timeline tool failed to initialize",
+		func(ctx context.Context, input any, opts ...tool.Option) (output string, err error) {
+			return fmt.Sprintf(`{"success":false,"error":"Tool initialization failed: %s"}`, escapeJSON(createErr.Error())), nil
+		},
+	)
+	return t
+}
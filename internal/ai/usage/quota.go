@@ -0,0 +1,67 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/internal/ai/errors"
+	"github.com/WyRainBow/ops-portal/internal/store"
+)
+
+// QuotaExceededError is returned by CheckQuota once a user has used up
+// users.api_quota tokens in the current period.
+type QuotaExceededError struct {
+	Used   int64
+	Quota  int64
+	Period string
+}
+
+func (e *QuotaExceededError) Error() string {
+	unit := "今日"
+	if e.Period == "month" {
+		unit = "本月"
+	}
+	return fmt.Sprintf("%s LLM 额度已用完（已用 %d / 额度 %d tokens），请联系管理员调整", unit, e.Used, e.Quota)
+}
+
+// quotaLookup returns the quota of a user (nil: unlimited) and the tokens
+// used since the start of the period; tests replace it.
+var quotaLookup = func(ctx context.Context, userID int64, since time.Time) (*int64, int64, error) {
+	db, err := store.DB(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	var u store.User
+	if err := db.WithContext(ctx).Select("id", "api_quota").First(&u, "id = ?", userID).Error; err != nil {
+		return nil, 0, err
+	}
+	if u.APIQuota == nil {
+		return nil, 0, nil
+	}
+	var used int64
+	err = db.WithContext(ctx).Model(&store.LLMUsageDaily{}).
+		Where("user_id = ? AND day >= ?", userID, since).
+		Select("COALESCE(SUM(total_tokens), 0)").Scan(&used).Error
+	return u.APIQuota, used, err
+}
+
+// CheckQuota rejects the request when the user of ctx has exhausted
+// users.api_quota (tokens per quota period; NULL means unlimited). Requests
+// without a user and lookup failures are allowed.
+func CheckQuota(ctx context.Context) error {
+	s := SubjectFromContext(ctx)
+	if s.UserID <= 0 {
+		return nil
+	}
+	since, period := PeriodStart(ctx, time.Now())
+	quota, used, err := quotaLookup(ctx, s.UserID, since)
+	if err != nil {
+		errors.Warn("usage", fmt.Sprintf("quota lookup for user %d failed, allowing request: %v", s.UserID, err))
+		return nil
+	}
+	if quota != nil && used >= *quota {
+		return &QuotaExceededError{Used: used, Quota: *quota, Period: period}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package usage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/internal/store"
+)
+
+// Report groupings.
+const (
+	GroupByDay     = "day"
+	GroupByUser    = "user"
+	GroupBySubject = "subject"
+	GroupByModel   = "model"
+)
+
+// ReportQuery selects daily aggregates in [From, To] (UTC days).
+type ReportQuery struct {
+	From    time.Time
+	To      time.Time
+	GroupBy string // day (default), user, subject, model
+	UserID  int64  // 0: all
+	Subject string // exact subject, e.g. incident:abc
+	Model   string
+}
+
+// ReportRow is one group of a usage report.
+type ReportRow struct {
+	Key              string  `json:"key"`
+	UserID           *int64  `json:"user_id,omitempty"`
+	Requests         int64   `json:"requests"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+// Report aggregates usage by q.GroupBy.
+func Report(ctx context.Context, q ReportQuery) ([]ReportRow, error) {
+	var key, order string
+	switch q.GroupBy {
+	case "", GroupByDay:
+		key, order = "to_char(day, 'YYYY-MM-DD')", "key"
+	case GroupByUser, GroupBySubject:
+		key, order = "subject", "total_tokens DESC"
+	case GroupByModel:
+		key, order = "model", "total_tokens DESC"
+	default:
+		return nil, fmt.Errorf("invalid group_by %q (day, user, subject, model)", q.GroupBy)
+	}
+
+	db, err := store.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx := db.WithContext(ctx).Model(&store.LLMUsageDaily{}).
+		Where("day >= ? AND day <= ?", q.From.UTC().Format("2006-01-02"), q.To.UTC().Format("2006-01-02"))
+	if q.GroupBy == GroupByUser {
+		tx = tx.Where("user_id IS NOT NULL")
+	}
+	if q.UserID > 0 {
+		tx = tx.Where("user_id = ?", q.UserID)
+	}
+	if q.Subject != "" {
+		tx = tx.Where("subject = ?", q.Subject)
+	}
+	if q.Model != "" {
+		tx = tx.Where("model = ?", q.Model)
+	}
+	selectUser := "NULL::bigint AS user_id"
+	if q.GroupBy == GroupByUser {
+		selectUser = "MAX(user_id) AS user_id"
+	}
+
+	var rows []ReportRow
+	err = tx.Select(key + " AS key, " + selectUser + `,
+		SUM(requests) AS requests, SUM(prompt_tokens) AS prompt_tokens,
+		SUM(completion_tokens) AS completion_tokens, SUM(total_tokens) AS total_tokens,
+		SUM(cost_usd) AS cost_usd`).
+		Group(key).Order(order).Limit(1000).Scan(&rows).Error
+	return rows, err
+}
@@ -0,0 +1,244 @@
+// Package usage accounts LLM token usage. A global Eino callback captures
+// the prompt/completion tokens of every chat model call, attributes them to
+// the JWT user, incident or CLI run in the context, and adds them to daily
+// aggregates (ops_llm_usage_daily) used for quotas and usage reports.
+package usage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/internal/ai/errors"
+	"github.com/WyRainBow/ops-portal/internal/metrics"
+	"github.com/WyRainBow/ops-portal/internal/store"
+	"github.com/WyRainBow/ops-portal/utility/middleware"
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	template "github.com/cloudwego/eino/utils/callbacks"
+	"github.com/gogf/gf/v2/frame/g"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SubjectSystem is charged for calls without a user, incident or CLI run.
+const SubjectSystem = "system"
+
+// Subject is who a model call is charged to.
+type Subject struct {
+	UserID   int64
+	Username string
+	Incident string
+	CLI      string
+}
+
+// Key is the subject column of ops_llm_usage_daily.
+func (s Subject) Key() string {
+	switch {
+	case s.UserID > 0:
+		return "user:" + strconv.FormatInt(s.UserID, 10)
+	case s.Incident != "":
+		return "incident:" + s.Incident
+	case s.CLI != "":
+		return "cli:" + s.CLI
+	default:
+		return SubjectSystem
+	}
+}
+
+type subjectKey struct{}
+
+// WithSubject charges model calls made with ctx to s.
+func WithSubject(ctx context.Context, s Subject) context.Context {
+	return context.WithValue(ctx, subjectKey{}, s)
+}
+
+// SubjectFromContext returns the subject set by WithSubject, else the JWT
+// user of the request, else the system subject.
+func SubjectFromContext(ctx context.Context) Subject {
+	if s, ok := ctx.Value(subjectKey{}).(Subject); ok {
+		return s
+	}
+	if u := middleware.GetUserContext(ctx); u != nil {
+		if id, err := strconv.ParseInt(u.UserID, 10, 64); err == nil && id > 0 {
+			return Subject{UserID: id, Username: u.Username}
+		}
+	}
+	return Subject{}
+}
+
+// Usage is the token usage of one model call.
+type Usage struct {
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+}
+
+// Price is the cost of a model in USD per 1K tokens.
+type Price struct {
+	Prompt     float64 `json:"prompt"`
+	Completion float64 `json:"completion"`
+}
+
+var (
+	pricesOnce sync.Once
+	prices     map[string]Price
+)
+
+// Cost returns the USD cost of u from llm_usage.prices; unknown models cost 0.
+func Cost(ctx context.Context, u Usage) float64 {
+	pricesOnce.Do(func() {
+		prices = map[string]Price{}
+		if v, err := g.Cfg().Get(ctx, "llm_usage.prices"); err == nil && !v.IsNil() {
+			if err := v.Scan(&prices); err != nil {
+				errors.Warn("usage", "invalid llm_usage.prices config: "+err.Error())
+			}
+		}
+	})
+	p, ok := prices[u.Model]
+	if !ok {
+		return 0
+	}
+	return (float64(u.PromptTokens)*p.Prompt + float64(u.CompletionTokens)*p.Completion) / 1000
+}
+
+// persist adds one call to the daily aggregate; tests replace it.
+var persist = func(ctx context.Context, row store.LLMUsageDaily) error {
+	db, err := store.DB(ctx)
+	if err != nil {
+		return err
+	}
+	t := row.TableName()
+	return db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "day"}, {Name: "subject"}, {Name: "model"}},
+		DoUpdates: clause.Assignments(map[string]any{
+			"requests":          gorm.Expr(t + ".requests + EXCLUDED.requests"),
+			"prompt_tokens":     gorm.Expr(t + ".prompt_tokens + EXCLUDED.prompt_tokens"),
+			"completion_tokens": gorm.Expr(t + ".completion_tokens + EXCLUDED.completion_tokens"),
+			"total_tokens":      gorm.Expr(t + ".total_tokens + EXCLUDED.total_tokens"),
+			"cost_usd":          gorm.Expr(t + ".cost_usd + EXCLUDED.cost_usd"),
+			"updated_at":        gorm.Expr("EXCLUDED.updated_at"),
+		}),
+	}).Create(&row).Error
+}
+
+// Record charges u to the subject of ctx.
+func Record(ctx context.Context, u Usage) {
+	if u.TotalTokens == 0 {
+		u.TotalTokens = u.PromptTokens + u.CompletionTokens
+	}
+	if u.Model == "" {
+		u.Model = "unknown"
+	}
+	s := SubjectFromContext(ctx)
+	labels := map[string]string{"model": u.Model, "subject": s.Key()}
+	metrics.Global().Add(metrics.LLMTokensTotal, float64(u.TotalTokens), labels)
+
+	now := time.Now().UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	row := store.LLMUsageDaily{
+		Day:              day,
+		Subject:          s.Key(),
+		Model:            u.Model,
+		Requests:         1,
+		PromptTokens:     int64(u.PromptTokens),
+		CompletionTokens: int64(u.CompletionTokens),
+		TotalTokens:      int64(u.TotalTokens),
+		CostUSD:          Cost(ctx, u),
+		UpdatedAt:        &now,
+	}
+	if s.UserID > 0 {
+		id := s.UserID
+		row.UserID = &id
+	}
+	// 调用方的 ctx 可能已结束（流式输出读完即取消），入库使用独立的超时
+	pctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer cancel()
+	if err := persist(pctx, row); err != nil {
+		errors.Warn("usage", fmt.Sprintf("persist usage of %s (%s): %v", s.Key(), u.Model, err))
+	}
+}
+
+func usageOf(out *model.CallbackOutput) (Usage, bool) {
+	if out == nil {
+		return Usage{}, false
+	}
+	var u Usage
+	if out.Config != nil {
+		u.Model = out.Config.Model
+	}
+	if out.TokenUsage == nil {
+		return u, false
+	}
+	u.PromptTokens = out.TokenUsage.PromptTokens
+	u.CompletionTokens = out.TokenUsage.CompletionTokens
+	u.TotalTokens = out.TokenUsage.TotalTokens
+	return u, true
+}
+
+// Handler is the chat model callback that records usage.
+func Handler() callbacks.Handler {
+	return template.NewHandlerHelper().ChatModel(&template.ModelCallbackHandler{
+		OnEnd: func(ctx context.Context, _ *callbacks.RunInfo, out *model.CallbackOutput) context.Context {
+			u, _ := usageOf(out)
+			Record(ctx, u)
+			return ctx
+		},
+		OnEndWithStreamOutput: func(ctx context.Context, _ *callbacks.RunInfo, sr *schema.StreamReader[*model.CallbackOutput]) context.Context {
+			go func() {
+				defer sr.Close()
+				var total Usage
+				for {
+					out, err := sr.Recv()
+					if err == io.EOF {
+						break
+					}
+					if err != nil {
+						errors.Warn("usage", "stream ended with error: "+err.Error())
+						break
+					}
+					// the usage arrives with the last chunk; keep the largest seen
+					u, ok := usageOf(out)
+					if u.Model != "" {
+						total.Model = u.Model
+					}
+					if ok && u.PromptTokens+u.CompletionTokens >= total.PromptTokens+total.CompletionTokens {
+						total.PromptTokens, total.CompletionTokens, total.TotalTokens = u.PromptTokens, u.CompletionTokens, u.TotalTokens
+					}
+				}
+				Record(ctx, total)
+			}()
+			return ctx
+		},
+	}).Handler()
+}
+
+var initOnce sync.Once
+
+// Init registers Handler as a global Eino callback. Call it once at startup,
+// before any agent is built.
+func Init() {
+	initOnce.Do(func() {
+		callbacks.AppendGlobalHandlers(Handler())
+	})
+}
+
+// PeriodStart returns the start of the quota period containing t:
+// llm_usage.quota_period is "day" (default) or "month", in UTC.
+func PeriodStart(ctx context.Context, t time.Time) (time.Time, string) {
+	t = t.UTC()
+	period := "day"
+	if v, err := g.Cfg().Get(ctx, "llm_usage.quota_period"); err == nil && strings.EqualFold(strings.TrimSpace(v.String()), "month") {
+		period = "month"
+	}
+	if period == "month" {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC), period
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), period
+}
@@ -0,0 +1,85 @@
+package usage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/internal/store"
+	"github.com/WyRainBow/ops-portal/utility/middleware"
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+func capturePersist(t *testing.T) chan store.LLMUsageDaily {
+	t.Helper()
+	rows := make(chan store.LLMUsageDaily, 4)
+	orig := persist
+	persist = func(_ context.Context, row store.LLMUsageDaily) error {
+		rows <- row
+		return nil
+	}
+	t.Cleanup(func() { persist = orig })
+	return rows
+}
+
+func receive(t *testing.T, rows chan store.LLMUsageDaily) store.LLMUsageDaily {
+	t.Helper()
+	select {
+	case row := <-rows:
+		return row
+	case <-time.After(2 * time.Second):
+		t.Fatal("usage not recorded")
+		return store.LLMUsageDaily{}
+	}
+}
+
+func TestHandlerRecordsUsage(t *testing.T) {
+	rows := capturePersist(t)
+	ctx := middleware.SetUserContext(context.Background(), &middleware.UserContext{UserID: "42", Username: "alice"})
+	ctx = callbacks.InitCallbacks(ctx, &callbacks.RunInfo{Component: components.ComponentOfChatModel}, Handler())
+
+	callbacks.OnEnd(ctx, &model.CallbackOutput{
+		Config:     &model.Config{Model: "qwen-max"},
+		TokenUsage: &model.TokenUsage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120},
+	})
+	row := receive(t, rows)
+	if row.Subject != "user:42" || row.UserID == nil || *row.UserID != 42 || row.Model != "qwen-max" || row.TotalTokens != 120 || row.Requests != 1 {
+		t.Fatalf("unexpected row: %+v", row)
+	}
+
+	// streamed calls report usage with the last chunk
+	ctx = WithSubject(ctx, Subject{Incident: "inc-1"})
+	sr := schema.StreamReaderFromArray([]*model.CallbackOutput{
+		{Config: &model.Config{Model: "deepseek"}, Message: schema.AssistantMessage("a", nil)},
+		{Message: schema.AssistantMessage("b", nil), TokenUsage: &model.TokenUsage{PromptTokens: 7, CompletionTokens: 3}},
+	})
+	callbacks.OnEndWithStreamOutput(ctx, sr)
+	row = receive(t, rows)
+	if row.Subject != "incident:inc-1" || row.UserID != nil || row.Model != "deepseek" || row.TotalTokens != 10 {
+		t.Fatalf("unexpected stream row: %+v", row)
+	}
+}
+
+func TestCheckQuota(t *testing.T) {
+	orig := quotaLookup
+	t.Cleanup(func() { quotaLookup = orig })
+	quota := int64(1000)
+	used := int64(999)
+	quotaLookup = func(context.Context, int64, time.Time) (*int64, int64, error) { return &quota, used, nil }
+
+	if err := CheckQuota(context.Background()); err != nil {
+		t.Fatalf("requests without a user must pass: %v", err)
+	}
+	ctx := WithSubject(context.Background(), Subject{UserID: 7})
+	if err := CheckQuota(ctx); err != nil {
+		t.Fatalf("under quota: %v", err)
+	}
+	used = 1000
+	err := CheckQuota(ctx)
+	if qe, ok := err.(*QuotaExceededError); !ok || qe.Used != 1000 || qe.Quota != 1000 {
+		t.Fatalf("got %v, want QuotaExceededError", err)
+	}
+}
@@ -0,0 +1,160 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	v1 "github.com/WyRainBow/ops-portal/api/admin/v1"
+	"github.com/WyRainBow/ops-portal/internal/ai/agent/plan_execute_replan"
+	"github.com/WyRainBow/ops-portal/internal/ai/agentrun"
+
+	"github.com/gogf/gf/v2/errors/gerror"
+)
+
+// replayTimeout bounds a replay; live-model replays call the real models.
+const replayTimeout = 5 * time.Minute
+
+func agentRunItem(r *agentrun.Run) v1.AgentRunItem {
+	return v1.AgentRunItem{
+		ID:         r.ID,
+		Kind:       r.Kind,
+		Subject:    r.Subject,
+		ReplayOf:   r.ReplayOf,
+		Status:     r.Status,
+		Output:     r.Output,
+		Error:      r.Error,
+		StepCount:  r.StepCount,
+		DurationMs: r.DurationMs,
+		StartedAt:  r.StartedAt.UTC().Format(time.RFC3339Nano),
+	}
+}
+
+// AgentRuns and AgentRun are admin-only: chat runs carry users' full chat
+// history and tool outputs.
+func (c *ControllerV1) AgentRuns(ctx context.Context, req *v1.AgentRunsReq) (res *v1.AgentRunsRes, err error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	if pageSize > 200 {
+		pageSize = 200
+	}
+
+	runs, total, err := agentrun.List(ctx, agentrun.Query{
+		Kind:     strings.TrimSpace(req.Kind),
+		Subject:  strings.TrimSpace(req.Subject),
+		Status:   strings.TrimSpace(req.Status),
+		ReplayOf: strings.TrimSpace(req.ReplayOf),
+		Offset:   (page - 1) * pageSize,
+		Limit:    pageSize,
+	})
+	if err != nil {
+		return nil, gerror.Newf("db query failed: %v", err)
+	}
+	items := make([]v1.AgentRunItem, 0, len(runs))
+	for _, r := range runs {
+		items = append(items, agentRunItem(r))
+	}
+	return &v1.AgentRunsRes{Items: items, Total: total, Page: page, PageSize: pageSize}, nil
+}
+
+func loadAgentRun(ctx context.Context, id string) (*agentrun.Run, error) {
+	run, err := agentrun.Load(ctx, strings.TrimSpace(id))
+	if errors.Is(err, agentrun.ErrNotFound) {
+		return nil, gerror.New("运行记录不存在")
+	}
+	if err != nil {
+		return nil, gerror.Newf("db query failed: %v", err)
+	}
+	return run, nil
+}
+
+func (c *ControllerV1) AgentRun(ctx context.Context, req *v1.AgentRunReq) (res *v1.AgentRunRes, err error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	run, err := loadAgentRun(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+	steps := make([]v1.AgentRunStepItem, 0, len(run.Steps))
+	for _, s := range run.Steps {
+		steps = append(steps, v1.AgentRunStepItem{
+			Seq:        s.Seq,
+			Type:       s.Type,
+			Agent:      s.Agent,
+			Name:       s.Name,
+			CallID:     s.CallID,
+			Input:      s.Input,
+			Output:     s.Output,
+			Error:      s.Error,
+			DurationMs: s.DurationMs,
+			StartedAt:  s.StartedAt.UTC().Format(time.RFC3339Nano),
+			Truncated:  s.Truncated,
+		})
+	}
+	return &v1.AgentRunRes{
+		ID:         run.ID,
+		Kind:       run.Kind,
+		Subject:    run.Subject,
+		ReplayOf:   run.ReplayOf,
+		Status:     run.Status,
+		Input:      run.Input,
+		Tools:      run.Tools,
+		Output:     run.Output,
+		Error:      run.Error,
+		DurationMs: run.DurationMs,
+		StartedAt:  run.StartedAt.UTC().Format(time.RFC3339Nano),
+		FinishedAt: run.FinishedAt.UTC().Format(time.RFC3339Nano),
+		StepCount:  run.StepCount,
+		Steps:      steps,
+	}, nil
+}
+
+func (c *ControllerV1) ReplayAgentRun(ctx context.Context, req *v1.ReplayAgentRunReq) (res *v1.ReplayAgentRunRes, err error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	opts := agentrun.ReplayOptions{Question: strings.TrimSpace(req.Question)}
+	switch strings.TrimSpace(req.Model) {
+	case "", "recorded":
+	case "live":
+		opts.LiveModel = true
+	default:
+		return nil, gerror.Newf("invalid model %q (recorded, live)", req.Model)
+	}
+	run, err := loadAgentRun(ctx, req.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, replayTimeout)
+	defer cancel()
+	replayed, diff, err := plan_execute_replan.Replay(ctx, run, opts)
+	if err != nil {
+		return nil, gerror.Newf("replay failed: %v", err)
+	}
+	return &v1.ReplayAgentRunRes{
+		Run: agentRunItem(replayed),
+		Diff: v1.ReplayDiff{
+			OutputMatches:      diff.OutputMatches,
+			ToolCallsMatch:     diff.ToolCallsMatch,
+			RecordedToolCalls:  diff.RecordedToolCalls,
+			ReplayedToolCalls:  diff.ReplayedToolCalls,
+			UnmatchedToolCalls: diff.UnmatchedToolCalls,
+			RecordedModelCalls: diff.RecordedModelCalls,
+			ReplayedModelCalls: diff.ReplayedModelCalls,
+			ChangedModelInputs: diff.ChangedModelInputs,
+			TruncatedSteps:     diff.TruncatedSteps,
+		},
+	}, nil
+}
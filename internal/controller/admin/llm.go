@@ -0,0 +1,46 @@
+package admin
+
+import (
+	"context"
+	"time"
+
+	v1 "github.com/WyRainBow/ops-portal/api/admin/v1"
+	"github.com/WyRainBow/ops-portal/internal/ai/models"
+
+	"github.com/gogf/gf/v2/errors/gerror"
+)
+
+func (c *ControllerV1) LLMProviders(ctx context.Context, req *v1.LLMProvidersReq) (res *v1.LLMProvidersRes, err error) {
+	if _, err := requireAdminOrMember(ctx); err != nil {
+		return nil, err
+	}
+	gw, err := models.DefaultGateway(ctx)
+	if err != nil {
+		return nil, gerror.Newf("llm gateway unavailable: %v", err)
+	}
+
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	items := make([]v1.LLMProviderItem, 0)
+	for _, h := range gw.Health() {
+		items = append(items, v1.LLMProviderItem{
+			Name:                h.Name,
+			Type:                h.Type,
+			Model:               h.Model,
+			State:               h.State,
+			Requests:            h.Requests,
+			Failures:            h.Failures,
+			ConsecutiveFailures: h.ConsecutiveFailures,
+			AvgLatencyMs:        h.AvgLatencyMs,
+			LastLatencyMs:       h.LastLatencyMs,
+			LastError:           h.LastError,
+			LastErrorAt:         formatTime(h.LastErrorAt),
+			LastSuccessAt:       formatTime(h.LastSuccessAt),
+		})
+	}
+	return &v1.LLMProvidersRes{Items: items, Routes: gw.Routes()}, nil
+}
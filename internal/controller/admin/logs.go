@@ -2,10 +2,10 @@ package admin
 
 import (
 	"context"
-	"strings"
 	"time"
 
 	v1 "github.com/WyRainBow/ops-portal/api/admin/v1"
+	"github.com/WyRainBow/ops-portal/internal/logic/apilog"
 	"github.com/WyRainBow/ops-portal/internal/store"
 
 	"github.com/gogf/gf/v2/errors/gerror"
@@ -32,30 +32,14 @@ func (c *ControllerV1) RequestLogs(ctx context.Context, req *v1.RequestLogsReq)
 		pageSize = 200
 	}
 
-	q := db.WithContext(ctx).Model(&store.APIRequestLog{})
-	if strings.TrimSpace(req.TraceID) != "" {
-		q = q.Where("trace_id = ?", strings.TrimSpace(req.TraceID))
-	}
-	if strings.TrimSpace(req.Path) != "" {
-		q = q.Where("path ILIKE ?", "%"+strings.TrimSpace(req.Path)+"%")
-	}
-	if req.StatusCode != nil {
-		q = q.Where("status_code = ?", *req.StatusCode)
-	}
-	if req.MinStatusCode != nil {
-		q = q.Where("status_code >= ?", *req.MinStatusCode)
-	}
-	if req.MaxStatusCode != nil {
-		q = q.Where("status_code <= ?", *req.MaxStatusCode)
-	}
-
-	var total int64
-	if err := q.Count(&total).Error; err != nil {
-		return nil, gerror.Newf("db count failed: %v", err)
-	}
-
-	var rows []store.APIRequestLog
-	if err := q.Order("created_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&rows).Error; err != nil {
+	rows, total, err := apilog.SearchRequestLogs(ctx, db, apilog.RequestLogFilter{
+		TraceID:       req.TraceID,
+		Path:          req.Path,
+		StatusCode:    req.StatusCode,
+		MinStatusCode: req.MinStatusCode,
+		MaxStatusCode: req.MaxStatusCode,
+	}, (page-1)*pageSize, pageSize)
+	if err != nil {
 		return nil, gerror.Newf("db query failed: %v", err)
 	}
 
@@ -112,21 +96,11 @@ func (c *ControllerV1) ErrorLogs(ctx context.Context, req *v1.ErrorLogsReq) (res
 		pageSize = 200
 	}
 
-	q := db.WithContext(ctx).Model(&store.APIErrorLog{})
-	if strings.TrimSpace(req.TraceID) != "" {
-		q = q.Where("trace_id = ?", strings.TrimSpace(req.TraceID))
-	}
-	if strings.TrimSpace(req.Keyword) != "" {
-		q = q.Where("error_message ILIKE ?", "%"+strings.TrimSpace(req.Keyword)+"%")
-	}
-
-	var total int64
-	if err := q.Count(&total).Error; err != nil {
-		return nil, gerror.Newf("db count failed: %v", err)
-	}
-
-	var rows []store.APIErrorLog
-	if err := q.Order("created_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&rows).Error; err != nil {
+	rows, total, err := apilog.SearchErrorLogs(ctx, db, apilog.ErrorLogFilter{
+		TraceID: req.TraceID,
+		Keyword: req.Keyword,
+	}, (page-1)*pageSize, pageSize)
+	if err != nil {
 		return nil, gerror.Newf("db query failed: %v", err)
 	}
 
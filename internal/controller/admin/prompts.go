@@ -0,0 +1,136 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	v1 "github.com/WyRainBow/ops-portal/api/admin/v1"
+	"github.com/WyRainBow/ops-portal/internal/ai/prompts"
+
+	"github.com/gogf/gf/v2/errors/gerror"
+)
+
+func promptItem(t *prompts.Template) v1.PromptTemplateItem {
+	item := v1.PromptTemplateItem{
+		Kind:        t.Kind,
+		Key:         t.Key,
+		Version:     t.Version,
+		Description: t.Description,
+		Match:       v1.PromptMatch{AlertNames: t.Match.AlertNames, Labels: t.Match.Labels},
+		Priority:    t.Priority,
+		Body:        t.Body,
+		Disabled:    t.Disabled,
+		Source:      t.Source,
+		CreatedBy:   t.CreatedBy,
+	}
+	if t.CreatedAt != nil {
+		item.CreatedAt = t.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+	return item
+}
+
+func (c *ControllerV1) Prompts(ctx context.Context, req *v1.PromptsReq) (res *v1.PromptsRes, err error) {
+	if _, err := requireAdminOrMember(ctx); err != nil {
+		return nil, err
+	}
+	kind := strings.TrimSpace(req.Kind)
+	items := make([]v1.PromptTemplateItem, 0)
+	for _, t := range prompts.List(ctx) {
+		if kind == "" || t.Kind == kind {
+			items = append(items, promptItem(t))
+		}
+	}
+	return &v1.PromptsRes{Items: items, Total: len(items)}, nil
+}
+
+func (c *ControllerV1) PromptVersions(ctx context.Context, req *v1.PromptVersionsReq) (res *v1.PromptVersionsRes, err error) {
+	if _, err := requireAdminOrMember(ctx); err != nil {
+		return nil, err
+	}
+	versions, err := prompts.Versions(ctx, req.Kind, req.Key)
+	if err != nil {
+		return nil, gerror.Newf("list prompt versions failed: %v", err)
+	}
+	items := make([]v1.PromptTemplateItem, 0, len(versions))
+	for _, t := range versions {
+		items = append(items, promptItem(t))
+	}
+	return &v1.PromptVersionsRes{Items: items}, nil
+}
+
+func (c *ControllerV1) SavePrompt(ctx context.Context, req *v1.SavePromptReq) (res *v1.SavePromptRes, err error) {
+	operator, err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t, err := prompts.Save(ctx, prompts.Template{
+		Kind:        strings.TrimSpace(req.Kind),
+		Key:         strings.TrimSpace(req.Key),
+		Description: strings.TrimSpace(req.Description),
+		Match:       prompts.Match{AlertNames: req.Match.AlertNames, Labels: req.Match.Labels},
+		Priority:    req.Priority,
+		Body:        req.Body,
+		Disabled:    req.Disabled,
+	}, operator.Username)
+	if err != nil {
+		return nil, gerror.Newf("save prompt template failed: %v", err)
+	}
+	return &v1.SavePromptRes{Item: promptItem(t)}, nil
+}
+
+func (c *ControllerV1) RollbackPrompt(ctx context.Context, req *v1.RollbackPromptReq) (res *v1.RollbackPromptRes, err error) {
+	operator, err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t, err := prompts.Rollback(ctx, req.Kind, req.Key, req.Version, operator.Username)
+	if errors.Is(err, prompts.ErrNotFound) {
+		return nil, gerror.Newf("模板版本不存在: %s/%s v%d", req.Kind, req.Key, req.Version)
+	}
+	if err != nil {
+		return nil, gerror.Newf("rollback prompt template failed: %v", err)
+	}
+	return &v1.RollbackPromptRes{Item: promptItem(t)}, nil
+}
+
+func (c *ControllerV1) PreviewPrompt(ctx context.Context, req *v1.PreviewPromptReq) (res *v1.PreviewPromptRes, err error) {
+	if _, err := requireAdminOrMember(ctx); err != nil {
+		return nil, err
+	}
+	inc := prompts.SampleIncident()
+	if s := req.Incident; s != nil {
+		inc = &prompts.Incident{
+			ID:          "preview",
+			AlertName:   strings.TrimSpace(s.AlertName),
+			Status:      "firing",
+			Severity:    s.Severity,
+			Summary:     s.Summary,
+			Description: s.Description,
+			Labels:      s.Labels,
+			StartedAt:   time.Now().Add(-10 * time.Minute),
+		}
+		if inc.Labels == nil {
+			inc.Labels = map[string]string{}
+		}
+		if inc.AlertName == "" {
+			inc.AlertName = inc.Labels["alertname"]
+		}
+		if s.StartedAt != "" {
+			started, err := time.Parse(time.RFC3339, s.StartedAt)
+			if err != nil {
+				return nil, gerror.Newf("invalid started_at %q (RFC3339)", s.StartedAt)
+			}
+			inc.StartedAt = started
+		}
+	}
+	rendered, t, err := prompts.Preview(ctx, strings.TrimSpace(req.Kind), strings.TrimSpace(req.Key), req.Body, inc)
+	if errors.Is(err, prompts.ErrNotFound) {
+		return nil, gerror.Newf("模板不存在: %s/%s", req.Kind, req.Key)
+	}
+	if err != nil {
+		return nil, gerror.Newf("render prompt template failed: %v", err)
+	}
+	return &v1.PreviewPromptRes{Kind: t.Kind, Key: t.Key, Version: t.Version, Source: t.Source, Rendered: rendered}, nil
+}
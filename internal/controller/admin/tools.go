@@ -0,0 +1,135 @@
+package admin
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	v1 "github.com/WyRainBow/ops-portal/api/admin/v1"
+	"github.com/WyRainBow/ops-portal/internal/ai/registry"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/gogf/gf/v2/errors/gerror"
+)
+
+func (c *ControllerV1) Tools(ctx context.Context, req *v1.ToolsReq) (res *v1.ToolsRes, err error) {
+	if _, err := requireAdminOrMember(ctx); err != nil {
+		return nil, err
+	}
+	category := strings.TrimSpace(req.Category)
+	agentType := strings.TrimSpace(req.AgentType)
+
+	reg := registry.Global()
+	items := make([]v1.ToolItem, 0)
+	for _, meta := range reg.ListMetadata() {
+		if category != "" && meta.Category != category {
+			continue
+		}
+		if agentType != "" && !hasAgentType(meta.AgentTypes, agentType) {
+			continue
+		}
+		if t, _, ok := reg.Lookup(meta.Name); ok {
+			items = append(items, toolItem(ctx, t, meta))
+		}
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Category != items[j].Category {
+			return items[i].Category < items[j].Category
+		}
+		return items[i].Name < items[j].Name
+	})
+	return &v1.ToolsRes{Items: items, Total: len(items)}, nil
+}
+
+func (c *ControllerV1) UpdateTool(ctx context.Context, req *v1.UpdateToolReq) (res *v1.UpdateToolRes, err error) {
+	operator, err := requireAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Name)
+	if req.Enabled == nil && req.AgentTypes == nil {
+		return nil, gerror.New("enabled 或 agent_types 至少提供一个")
+	}
+	var agentTypes []string
+	if req.AgentTypes != nil {
+		agentTypes = make([]string, 0, len(req.AgentTypes))
+		seen := map[string]bool{}
+		for _, at := range req.AgentTypes {
+			at = strings.TrimSpace(at)
+			if !registry.ValidAgentType(at) {
+				return nil, gerror.Newf("不支持的 agent 类型: %s", at)
+			}
+			if !seen[at] {
+				seen[at] = true
+				agentTypes = append(agentTypes, at)
+			}
+		}
+	}
+
+	meta, err := registry.SaveOverride(ctx, name, registry.Override{
+		Enabled:    req.Enabled,
+		AgentTypes: agentTypes,
+	}, operator.Username)
+	if err != nil {
+		if registry.IsNotFound(err) {
+			return nil, gerror.New("工具不存在")
+		}
+		return nil, gerror.Newf("save tool override failed: %v", err)
+	}
+	t, _, _ := registry.Global().Lookup(name)
+	return &v1.UpdateToolRes{Item: toolItem(ctx, t, meta)}, nil
+}
+
+func (c *ControllerV1) ResetTool(ctx context.Context, req *v1.ResetToolReq) (res *v1.ResetToolRes, err error) {
+	if _, err := requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Name)
+	meta, err := registry.DeleteOverride(ctx, name)
+	if err != nil {
+		if registry.IsNotFound(err) {
+			return nil, gerror.New("工具不存在")
+		}
+		return nil, gerror.Newf("delete tool override failed: %v", err)
+	}
+	t, _, _ := registry.Global().Lookup(name)
+	return &v1.ResetToolRes{Item: toolItem(ctx, t, meta)}, nil
+}
+
+func toolItem(ctx context.Context, t tool.BaseTool, meta registry.ToolMetadata) v1.ToolItem {
+	item := v1.ToolItem{
+		Name:        meta.Name,
+		Description: meta.Description,
+		Category:    meta.Category,
+		Enabled:     meta.Enabled,
+		AgentTypes:  meta.AgentTypes,
+		CacheTTL:    int64(meta.CacheTTL.Seconds()),
+	}
+	if item.AgentTypes == nil {
+		item.AgentTypes = []string{}
+	}
+	_, item.Overridden = registry.Global().GetOverride(meta.Name)
+	if t == nil {
+		return item
+	}
+	if info, err := t.Info(ctx); err == nil && info != nil {
+		if item.Description == "" {
+			item.Description = info.Desc
+		}
+		if info.ParamsOneOf != nil {
+			if js, err := info.ParamsOneOf.ToJSONSchema(); err == nil && js != nil {
+				item.Schema = js
+			}
+		}
+	}
+	return item
+}
+
+func hasAgentType(types []string, want string) bool {
+	for _, t := range types {
+		if t == want || t == string(registry.AgentTypeAll) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package admin
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	v1 "github.com/WyRainBow/ops-portal/api/admin/v1"
+	"github.com/WyRainBow/ops-portal/internal/ai/usage"
+	"github.com/WyRainBow/ops-portal/internal/store"
+
+	"github.com/gogf/gf/v2/errors/gerror"
+)
+
+func (c *ControllerV1) Usage(ctx context.Context, req *v1.UsageReq) (res *v1.UsageRes, err error) {
+	if _, err := requireAdminOrMember(ctx); err != nil {
+		return nil, err
+	}
+
+	parseDay := func(s string, def time.Time) (time.Time, error) {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			return def, nil
+		}
+		return time.Parse("2006-01-02", s)
+	}
+	now := time.Now().UTC()
+	to, err := parseDay(req.To, now)
+	if err != nil {
+		return nil, gerror.Newf("invalid to %q (YYYY-MM-DD)", req.To)
+	}
+	from, err := parseDay(req.From, to.AddDate(0, 0, -6))
+	if err != nil {
+		return nil, gerror.Newf("invalid from %q (YYYY-MM-DD)", req.From)
+	}
+	if from.After(to) {
+		return nil, gerror.New("from must not be after to")
+	}
+	groupBy := strings.TrimSpace(req.GroupBy)
+	if groupBy == "" {
+		groupBy = usage.GroupByDay
+	}
+
+	rows, err := usage.Report(ctx, usage.ReportQuery{
+		From:    from,
+		To:      to,
+		GroupBy: groupBy,
+		UserID:  req.UserID,
+		Subject: strings.TrimSpace(req.Subject),
+		Model:   strings.TrimSpace(req.Model),
+	})
+	if err != nil {
+		return nil, gerror.Newf("usage report failed: %v", err)
+	}
+
+	// 按用户分组时补充用户名和额度
+	users := map[int64]store.User{}
+	if groupBy == usage.GroupByUser && len(rows) > 0 {
+		ids := make([]int64, 0, len(rows))
+		for _, r := range rows {
+			if r.UserID != nil {
+				ids = append(ids, *r.UserID)
+			}
+		}
+		db, err := store.DB(ctx)
+		if err != nil {
+			return nil, gerror.Newf("db init failed: %v", err)
+		}
+		var list []store.User
+		if err := db.WithContext(ctx).Select("id", "username", "api_quota").Where("id IN ?", ids).Find(&list).Error; err != nil {
+			return nil, gerror.Newf("db query failed: %v", err)
+		}
+		for _, u := range list {
+			users[u.ID] = u
+		}
+	}
+
+	res = &v1.UsageRes{
+		Items:   make([]v1.UsageItem, 0, len(rows)),
+		Total:   v1.UsageItem{Key: "total"},
+		From:    from.Format("2006-01-02"),
+		To:      to.Format("2006-01-02"),
+		GroupBy: groupBy,
+	}
+	for _, r := range rows {
+		item := v1.UsageItem{
+			Key:              r.Key,
+			UserID:           r.UserID,
+			Requests:         r.Requests,
+			PromptTokens:     r.PromptTokens,
+			CompletionTokens: r.CompletionTokens,
+			TotalTokens:      r.TotalTokens,
+			CostUSD:          r.CostUSD,
+		}
+		if r.UserID != nil {
+			if u, ok := users[*r.UserID]; ok {
+				item.Username = u.Username
+				item.APIQuota = u.APIQuota
+			}
+		}
+		res.Items = append(res.Items, item)
+		res.Total.Requests += r.Requests
+		res.Total.PromptTokens += r.PromptTokens
+		res.Total.CompletionTokens += r.CompletionTokens
+		res.Total.TotalTokens += r.TotalTokens
+		res.Total.CostUSD += r.CostUSD
+	}
+	return res, nil
+}
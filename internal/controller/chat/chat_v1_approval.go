@@ -0,0 +1,42 @@
+package chat
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/api/chat/v1"
+	"github.com/WyRainBow/ops-portal/internal/ai/policy"
+	"github.com/WyRainBow/ops-portal/utility/middleware"
+
+	"github.com/gogf/gf/v2/errors/gerror"
+)
+
+func (c *ControllerV1) Approvals(ctx context.Context, req *v1.ApprovalsReq) (res *v1.ApprovalsRes, err error) {
+	user := middleware.GetUserContext(ctx)
+	if user == nil {
+		return nil, gerror.New("未提供有效的认证信息")
+	}
+	pending := policy.Global().Approvals().Pending(user)
+	items := make([]v1.ApprovalItem, 0, len(pending))
+	for _, ap := range pending {
+		items = append(items, v1.ApprovalItem{
+			ApprovalID:  ap.ID,
+			RunID:       ap.RunID,
+			Tool:        ap.Tool,
+			Arguments:   ap.Arguments,
+			RequestedBy: ap.RequestedBy,
+			CreatedAt:   ap.CreatedAt.UTC().Format(time.RFC3339),
+			ExpiresAt:   ap.ExpiresAt.UTC().Format(time.RFC3339),
+		})
+	}
+	return &v1.ApprovalsRes{Items: items}, nil
+}
+
+func (c *ControllerV1) ResolveApproval(ctx context.Context, req *v1.ResolveApprovalReq) (res *v1.ResolveApprovalRes, err error) {
+	id := strings.TrimSpace(req.ApprovalID)
+	if err := policy.Global().Approvals().Resolve(id, middleware.GetUserContext(ctx), req.Approved, strings.TrimSpace(req.Comment)); err != nil {
+		return nil, gerror.New(err.Error())
+	}
+	return &v1.ResolveApprovalRes{ApprovalID: id, Approved: req.Approved}, nil
+}
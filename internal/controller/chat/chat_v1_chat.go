@@ -2,27 +2,50 @@ package chat
 
 import (
 	"github.com/WyRainBow/ops-portal/api/chat/v1"
-	"github.com/WyRainBow/ops-portal/internal/ai/agent/plan_execute_replan"
+	"github.com/WyRainBow/ops-portal/internal/ai/assistant"
 	"context"
+
+	"github.com/gogf/gf/v2/errors/gerror"
 )
 
 func (c *ControllerV1) Chat(ctx context.Context, req *v1.ChatReq) (res *v1.ChatRes, err error) {
-	msg := req.Question
-	// Use the Plan/Execute/Replan agent so the assistant can call observability tools.
-	// This endpoint is intentionally read-only: tools do not execute commands or write DB.
-	query := `
-"你是一个只读的智能运维助手。"
-"你必须在回答前按需调用工具获取事实（例如 Loki 日志、Prometheus 告警、只读数据库查询），避免凭空猜测。"
-"你不能执行任何修复动作，只能输出诊断结论与建议的下一步命令（供人工执行）。"
-"用户问题如下："
-` + msg
-
-	out, _, err := plan_execute_replan.BuildPlanAgent(ctx, query)
+	// 未指定模式时沿用计划-执行-重规划（deep），与此前行为一致
+	mode, err := assistant.NormalizeMode(req.Mode, assistant.ModeDeep)
+	if err != nil {
+		return nil, gerror.New(err.Error())
+	}
+	conv, err := assistant.Prepare(ctx, assistant.Request{SessionID: req.Id, Question: req.Question, Mode: mode})
 	if err != nil {
 		return nil, err
 	}
-	res = &v1.ChatRes{
-		Answer: out,
+	out, err := conv.Run(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return toChatRes(out), nil
+}
+
+func toChatRes(out *assistant.Response) *v1.ChatRes {
+	res := &v1.ChatRes{
+		Answer:    out.Answer,
+		SessionID: out.SessionID,
+		Title:     out.Title,
+		Mode:      out.Mode,
+		Citations: make([]v1.Citation, 0, len(out.Citations)),
+		Evidence:  make([]v1.ToolEvidence, 0, len(out.Evidence)),
+		Steps:     out.Steps,
+	}
+	for _, ct := range out.Citations {
+		res.Citations = append(res.Citations, v1.Citation{
+			Index:   ct.Index,
+			ID:      ct.ID,
+			Source:  ct.Source,
+			Score:   ct.Score,
+			Snippet: ct.Snippet,
+		})
+	}
+	for _, e := range out.Evidence {
+		res.Evidence = append(res.Evidence, v1.ToolEvidence(e))
 	}
-	return res, nil
+	return res
 }
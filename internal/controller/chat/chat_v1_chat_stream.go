@@ -3,63 +3,76 @@ package chat
 import (
 	"github.com/WyRainBow/ops-portal/api/chat/v1"
 	"github.com/WyRainBow/ops-portal/internal/ai/agent/chat_pipeline"
-	"github.com/WyRainBow/ops-portal/utility/log_call_back"
-	"github.com/WyRainBow/ops-portal/utility/mem"
+	"github.com/WyRainBow/ops-portal/internal/ai/assistant"
+	"github.com/WyRainBow/ops-portal/internal/ai/policy"
+	"github.com/WyRainBow/ops-portal/internal/ai/session"
+	"github.com/WyRainBow/ops-portal/internal/logic/sse"
 	"context"
-	"errors"
-	"io"
-	"strings"
+	"time"
 
-	"github.com/cloudwego/eino/compose"
-	"github.com/cloudwego/eino/schema"
 	"github.com/gogf/gf/v2/frame/g"
 )
 
+// chatRunTimeout bounds a chat run; it keeps running after the client
+// disconnects so that the client can resume with Last-Event-ID.
+const chatRunTimeout = 10 * time.Minute
+
 func (c *ControllerV1) ChatStream(ctx context.Context, req *v1.ChatStreamReq) (res *v1.ChatStreamRes, err error) {
-	id := req.Id
+	r := g.RequestFromCtx(ctx)
 	msg := req.Question
 
-	ctx = context.WithValue(ctx, "client_id", req.Id)
-	client, err := c.service.Create(ctx, g.RequestFromCtx(ctx))
-	if err != nil {
-		return nil, err
+	// 断线重连：带 Last-Event-ID 时续传该会话正在进行（或刚结束）的事件流，不重新提问
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" && req.Id != "" {
+		userID, err := session.UserID(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := session.Get(ctx, userID, req.Id); err != nil {
+			return nil, err
+		}
+		client, err := c.service.Create(context.WithValue(ctx, "client_id", req.Id), r)
+		if err != nil {
+			return nil, err
+		}
+		st, ok := c.service.Stream(req.Id)
+		if !ok {
+			client.SendToClient(chat_pipeline.EventError, `{"message":"事件流已结束或已过期，请重新提问"}`)
+			return &v1.ChatStreamRes{}, nil
+		}
+		_ = client.Follow(ctx, st, sse.ParseLastEventID(lastEventID))
+		return &v1.ChatStreamRes{}, nil
 	}
 
-	userMessage := &chat_pipeline.UserMessage{
-		ID:      id,
-		Query:   msg,
-		History: mem.GetSimpleMemory(id).GetMessages(),
+	conv, err := assistant.Prepare(ctx, assistant.Request{SessionID: req.Id, Question: msg, Mode: req.Mode})
+	if err != nil {
+		return nil, err
 	}
+	id := conv.Session.ID
 
-	runner, err := chat_pipeline.BuildChatAgent(ctx)
-	sr, err := runner.Stream(ctx, userMessage, compose.WithCallbacks(log_call_back.LogCallback(nil)))
+	ctx = context.WithValue(ctx, "client_id", id)
+	client, err := c.service.Create(ctx, r)
 	if err != nil {
-		client.SendToClient("error", err.Error())
 		return nil, err
 	}
-	defer sr.Close()
+	st := c.service.NewStream(id)
+	emit := func(event string, payload any) { st.Publish(event, payload) }
 
-	var fullResponse strings.Builder
+	// 运行与连接解耦：客户端断开后继续执行，结果写入事件流和会话
+	runCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), chatRunTimeout)
+	// 工具策略：调用预算按本次对话计算，需要审批的工具通过 SSE 请求用户确认
+	runCtx = policy.WithRun(runCtx, policy.NewRun(emit))
 
-	defer func() {
-		completeResponse := fullResponse.String()
-		if completeResponse != "" {
-			mem.GetSimpleMemory(id).SetMessages(schema.UserMessage(msg))
-			mem.GetSimpleMemory(id).SetMessages(schema.SystemMessage(completeResponse))
+	go func() {
+		defer cancel()
+		defer c.service.CloseStream(st)
+		out, err := conv.Run(runCtx, emit)
+		if err != nil {
+			emit(chat_pipeline.EventError, chat_pipeline.ErrorEvent{Message: err.Error()})
+			return
 		}
+		emit(chat_pipeline.EventDone, out)
 	}()
 
-	for {
-		chunk, err := sr.Recv()
-		if errors.Is(err, io.EOF) {
-			client.SendToClient("done", "Stream completed")
-			return &v1.ChatStreamRes{}, nil
-		}
-		if err != nil {
-			client.SendToClient("error", err.Error())
-			return &v1.ChatStreamRes{}, nil
-		}
-		fullResponse.WriteString(chunk.Content)
-		client.SendToClient("message", chunk.Content)
-	}
+	_ = client.Follow(ctx, st, 0)
+	return &v1.ChatStreamRes{}, nil
 }
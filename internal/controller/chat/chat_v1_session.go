@@ -0,0 +1,134 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/api/chat/v1"
+	"github.com/WyRainBow/ops-portal/internal/ai/session"
+	"github.com/WyRainBow/ops-portal/internal/store"
+
+	"github.com/gogf/gf/v2/errors/gerror"
+)
+
+func sessionError(err error) error {
+	if errors.Is(err, session.ErrNotFound) {
+		return gerror.New(err.Error())
+	}
+	return gerror.Newf("session: %v", err)
+}
+
+func formatSessionTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+func toSessionItem(s *store.ChatSession) v1.SessionItem {
+	return v1.SessionItem{
+		ID:           s.ID,
+		Title:        s.Title,
+		MessageCount: s.MessageCount,
+		Summary:      s.Summary,
+		CreatedAt:    formatSessionTime(s.CreatedAt),
+		UpdatedAt:    formatSessionTime(s.UpdatedAt),
+	}
+}
+
+func (c *ControllerV1) Sessions(ctx context.Context, req *v1.SessionsReq) (res *v1.SessionsRes, err error) {
+	userID, err := session.UserID(ctx)
+	if err != nil {
+		return nil, gerror.New(err.Error())
+	}
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+	list, total, err := session.List(ctx, userID, page, pageSize)
+	if err != nil {
+		return nil, sessionError(err)
+	}
+	items := make([]v1.SessionItem, 0, len(list))
+	for i := range list {
+		item := toSessionItem(&list[i])
+		item.Summary = ""
+		items = append(items, item)
+	}
+	return &v1.SessionsRes{Items: items, Total: total, Page: page, PageSize: pageSize}, nil
+}
+
+func (c *ControllerV1) CreateSession(ctx context.Context, req *v1.CreateSessionReq) (res *v1.CreateSessionRes, err error) {
+	userID, err := session.UserID(ctx)
+	if err != nil {
+		return nil, gerror.New(err.Error())
+	}
+	s, err := session.Create(ctx, userID, "", req.Title)
+	if err != nil {
+		return nil, sessionError(err)
+	}
+	return &v1.CreateSessionRes{Item: toSessionItem(s)}, nil
+}
+
+func (c *ControllerV1) SessionMessages(ctx context.Context, req *v1.SessionMessagesReq) (res *v1.SessionMessagesRes, err error) {
+	userID, err := session.UserID(ctx)
+	if err != nil {
+		return nil, gerror.New(err.Error())
+	}
+	id := strings.TrimSpace(req.SessionID)
+	s, err := session.Get(ctx, userID, id)
+	if err != nil {
+		return nil, sessionError(err)
+	}
+	limit := req.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 200
+	}
+	list, err := session.Messages(ctx, userID, id, limit)
+	if err != nil {
+		return nil, sessionError(err)
+	}
+	items := make([]v1.SessionMessageItem, 0, len(list))
+	for _, m := range list {
+		items = append(items, v1.SessionMessageItem{
+			ID:        m.ID,
+			Role:      m.Role,
+			Content:   m.Content,
+			CreatedAt: formatSessionTime(m.CreatedAt),
+		})
+	}
+	return &v1.SessionMessagesRes{Session: toSessionItem(s), Items: items}, nil
+}
+
+func (c *ControllerV1) RenameSession(ctx context.Context, req *v1.RenameSessionReq) (res *v1.RenameSessionRes, err error) {
+	userID, err := session.UserID(ctx)
+	if err != nil {
+		return nil, gerror.New(err.Error())
+	}
+	id, title := strings.TrimSpace(req.SessionID), strings.TrimSpace(req.Title)
+	if err := session.Rename(ctx, userID, id, title); err != nil {
+		return nil, sessionError(err)
+	}
+	return &v1.RenameSessionRes{ID: id, Title: title}, nil
+}
+
+func (c *ControllerV1) DeleteSession(ctx context.Context, req *v1.DeleteSessionReq) (res *v1.DeleteSessionRes, err error) {
+	userID, err := session.UserID(ctx)
+	if err != nil {
+		return nil, gerror.New(err.Error())
+	}
+	id := strings.TrimSpace(req.SessionID)
+	if err := session.Delete(ctx, userID, id); err != nil {
+		return nil, sessionError(err)
+	}
+	return &v1.DeleteSessionRes{ID: id}, nil
+}
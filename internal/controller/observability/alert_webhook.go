@@ -174,6 +174,9 @@ func (c *AlertWebhookController) GetDiagnosis(req *ghttp.Request) {
 		"incident_id": id,
 		"status": "completed",
 		"result": result.Result,
+		"report": result.Report,
+		"report_error": result.ReportErr,
+		"tool_calls": result.ToolCalls,
 		"detail": result.Detail,
 		"created_at": result.CreatedAt,
 	})
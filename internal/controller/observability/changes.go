@@ -0,0 +1,139 @@
+package observability
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"os"
+	"strings"
+	"time"
+
+	v1 "github.com/WyRainBow/ops-portal/api/observability/v1"
+	"github.com/WyRainBow/ops-portal/internal/ops/changes"
+
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+)
+
+// maxIngestEvents caps the events accepted by one ingestion request.
+const maxIngestEvents = 100
+
+func (c *ControllerV1) Changes(ctx context.Context, req *v1.ChangesReq) (res *v1.ChangesRes, err error) {
+	if err := requireAdminOrMember(ctx); err != nil {
+		return nil, err
+	}
+	minutes := req.Minutes
+	if minutes <= 0 {
+		minutes = 1440
+	}
+	q := changes.Query{
+		Service: req.Service,
+		Since:   time.Now().Add(-time.Duration(minutes) * time.Minute),
+		Limit:   req.Limit,
+	}
+	if req.Kind != "" {
+		q.Kinds = []string{req.Kind}
+	}
+	rows, err := changes.Recent(ctx, q)
+	if err != nil {
+		return nil, gerror.Newf("db query failed: %v", err)
+	}
+
+	items := make([]v1.ChangeItem, 0, len(rows))
+	for _, r := range rows {
+		var meta map[string]any
+		if len(r.Metadata) > 0 {
+			_ = json.Unmarshal(r.Metadata, &meta)
+		}
+		items = append(items, v1.ChangeItem{
+			ID:         r.ID,
+			Service:    r.Service,
+			Kind:       r.Kind,
+			Source:     r.Source,
+			Version:    r.Version,
+			Summary:    r.Summary,
+			Author:     r.Author,
+			URL:        r.URL,
+			Metadata:   meta,
+			OccurredAt: r.OccurredAt.UTC().Format(time.RFC3339Nano),
+		})
+	}
+	return &v1.ChangesRes{Items: items}, nil
+}
+
+// RegisterChangeEventRoutes registers the ingestion endpoint used by CI/CD.
+// It authenticates with OPS_PORTAL_CHANGE_TOKEN or an admin/member JWT, so it
+// gets its own group that must be created before group attaches JWTAuth;
+// otherwise token-only callers would be rejected.
+func RegisterChangeEventRoutes(group *ghttp.RouterGroup) {
+	group.Group("/changes", func(changesGroup *ghttp.RouterGroup) {
+		changesGroup.POST("/events", IngestChangeEvents)
+	})
+}
+
+// IngestChangeEvents records one event or an array of events.
+// POST /api/observability/changes/events
+func IngestChangeEvents(req *ghttp.Request) {
+	ctx := req.Context()
+	if !changeTokenValid(req) {
+		if err := requireAdminOrMember(ctx); err != nil {
+			req.Response.WriteStatus(401)
+			req.Response.WriteJson(g.Map{"success": false, "error": err.Error()})
+			return
+		}
+	}
+
+	body := strings.TrimSpace(string(req.GetBody()))
+	var events []changes.Event
+	var err error
+	if strings.HasPrefix(body, "[") {
+		err = json.Unmarshal([]byte(body), &events)
+	} else {
+		var ev changes.Event
+		err = json.Unmarshal([]byte(body), &ev)
+		events = []changes.Event{ev}
+	}
+	if err != nil || len(events) == 0 {
+		req.Response.WriteStatus(400)
+		req.Response.WriteJson(g.Map{"success": false, "error": "invalid JSON body, expected an event or an array of events"})
+		return
+	}
+	if len(events) > maxIngestEvents {
+		req.Response.WriteStatus(400)
+		req.Response.WriteJson(g.Map{"success": false, "error": "too many events in one request"})
+		return
+	}
+
+	created := 0
+	for i, ev := range events {
+		if ev.Source == changes.SourceGitWatcher || ev.Source == changes.SourcePM2Watcher {
+			ev.Source = changes.SourceAPI
+		}
+		ok, err := changes.Record(ctx, ev)
+		if err != nil {
+			g.Log().Warningf(ctx, "Failed to record change event %d: %v", i, err)
+			req.Response.WriteStatus(400)
+			req.Response.WriteJson(g.Map{"success": false, "error": err.Error(), "index": i, "created": created})
+			return
+		}
+		if ok {
+			created++
+		}
+	}
+	req.Response.WriteJson(g.Map{"success": true, "received": len(events), "created": created})
+}
+
+func changeTokenValid(req *ghttp.Request) bool {
+	want := os.Getenv("OPS_PORTAL_CHANGE_TOKEN")
+	if want == "" {
+		return false
+	}
+	got := req.Header.Get("X-Change-Token")
+	if got == "" {
+		if authz := req.Header.Get("Authorization"); strings.HasPrefix(strings.ToLower(authz), "bearer ") {
+			got = strings.TrimSpace(authz[7:])
+		}
+	}
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
@@ -0,0 +1,69 @@
+package observability
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/utility/middleware"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+)
+
+func TestChangeEventsAcceptTokenBehindJWTGroup(t *testing.T) {
+	t.Setenv("OPS_PORTAL_CHANGE_TOKEN", "ci-secret")
+
+	// Same wiring as main.go: the route is registered before JWTAuth is attached
+	s := g.Server(t.Name())
+	s.SetPort(0)
+	s.SetDumpRouterMap(false)
+	s.Group("/api", func(group *ghttp.RouterGroup) {
+		group.Group("/observability", func(obsGroup *ghttp.RouterGroup) {
+			RegisterChangeEventRoutes(obsGroup)
+			obsGroup.Middleware(middleware.JWTAuth(nil))
+			obsGroup.GET("/changes", func(r *ghttp.Request) { r.Response.Write("ok") })
+		})
+	})
+	if err := s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Shutdown()
+	time.Sleep(100 * time.Millisecond)
+	base := fmt.Sprintf("http://127.0.0.1:%d/api/observability", s.GetListenedPort())
+
+	post := func(header, value string) (int, string) {
+		t.Helper()
+		// An invalid body fails after authentication, before touching the DB
+		req, _ := http.NewRequest(http.MethodPost, base+"/changes/events", strings.NewReader("not json"))
+		req.Header.Set(header, value)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(b)
+	}
+	for _, h := range [][2]string{{"X-Change-Token", "ci-secret"}, {"Authorization", "Bearer ci-secret"}} {
+		if code, body := post(h[0], h[1]); code != http.StatusBadRequest || !strings.Contains(body, "invalid JSON body") {
+			t.Errorf("%s: status %d, body %s", h[0], code, body)
+		}
+	}
+	if code, _ := post("X-Change-Token", "wrong"); code != http.StatusUnauthorized {
+		t.Errorf("wrong token: status %d, want 401", code)
+	}
+
+	// The rest of the group still requires a JWT
+	resp, err := http.Get(base + "/changes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(string(b), "未提供认证信息") {
+		t.Errorf("GET /changes served without a JWT: %s", b)
+	}
+}
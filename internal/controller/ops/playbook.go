@@ -150,4 +150,11 @@ func RegisterOpsRoutes(group *ghttp.RouterGroup) {
 
 	// Audit log
 	group.GET("/audit/log", controller.GetAuditLog)
+
+	// Recurring executions
+	schedules := &ScheduleController{}
+	group.GET("/schedules", schedules.ListSchedules)
+	group.POST("/schedules", schedules.CreateSchedule)
+	group.PATCH("/schedules/:id", schedules.UpdateSchedule)
+	group.DELETE("/schedules/:id", schedules.DeleteSchedule)
 }
@@ -0,0 +1,305 @@
+package ops
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/internal/ops/playbook"
+	"github.com/WyRainBow/ops-portal/internal/store"
+	"github.com/WyRainBow/ops-portal/utility/middleware"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+	"gorm.io/gorm"
+)
+
+// ScheduleController manages recurring playbook executions.
+type ScheduleController struct{}
+
+// ScheduleRequest is the request body for creating or updating a schedule.
+type ScheduleRequest struct {
+	Name       string         `json:"name"`
+	PlaybookID string         `json:"playbook_id"`
+	CronExpr   string         `json:"cron"`
+	Timezone   string         `json:"timezone"`
+	Parameters map[string]any `json:"parameters"`
+	Enabled    *bool          `json:"enabled"`
+}
+
+// ScheduleItem is the API representation of a schedule.
+type ScheduleItem struct {
+	ID              int64          `json:"id"`
+	Name            string         `json:"name"`
+	PlaybookID      string         `json:"playbook_id"`
+	CronExpr        string         `json:"cron"`
+	Timezone        string         `json:"timezone"`
+	Parameters      map[string]any `json:"parameters"`
+	Enabled         bool           `json:"enabled"`
+	CreatedBy       string         `json:"created_by"`
+	NextRunAt       *time.Time     `json:"next_run_at,omitempty"`
+	LastRunAt       *time.Time     `json:"last_run_at,omitempty"`
+	LastExecutionID string         `json:"last_execution_id,omitempty"`
+	LastStatus      string         `json:"last_status,omitempty"`
+}
+
+func toScheduleItem(s store.PlaybookSchedule) ScheduleItem {
+	params := map[string]any{}
+	if len(s.Parameters) > 0 {
+		_ = json.Unmarshal(s.Parameters, &params)
+	}
+	item := ScheduleItem{
+		ID:         s.ID,
+		Name:       s.Name,
+		PlaybookID: s.PlaybookID,
+		CronExpr:   s.CronExpr,
+		Timezone:   s.Timezone,
+		Parameters: params,
+		Enabled:    s.Enabled,
+		CreatedBy:  s.CreatedBy,
+		NextRunAt:  s.NextRunAt,
+		LastRunAt:  s.LastRunAt,
+	}
+	if s.LastExecutionID != nil {
+		item.LastExecutionID = *s.LastExecutionID
+	}
+	if s.LastStatus != nil {
+		item.LastStatus = *s.LastStatus
+	}
+	return item
+}
+
+func writeError(req *ghttp.Request, status int, msg string) {
+	req.Response.WriteJson(g.Map{
+		"success": false,
+		"error":   msg,
+	})
+	req.Response.WriteStatus(status)
+}
+
+// validateSchedule checks the playbook, parameters and cron expression and
+// returns the first activation time.
+func validateSchedule(in *ScheduleRequest) (*time.Time, string) {
+	pb, ok := playbook.GlobalExecutor().Get(in.PlaybookID)
+	if !ok {
+		return nil, "Playbook not found"
+	}
+	if err := pb.ValidateParameters(in.Parameters); err != nil {
+		return nil, err.Error()
+	}
+	next, err := playbook.NextRun(in.CronExpr, in.Timezone, time.Now())
+	if err != nil {
+		return nil, err.Error()
+	}
+	return &next, ""
+}
+
+// ListSchedules lists all schedules.
+// GET /api/ops/schedules
+func (c *ScheduleController) ListSchedules(req *ghttp.Request) {
+	ctx := req.Context()
+	db, err := store.DB(ctx)
+	if err != nil {
+		writeError(req, 500, "db init failed: "+err.Error())
+		return
+	}
+
+	q := db.WithContext(ctx).Model(&store.PlaybookSchedule{})
+	if id := strings.TrimSpace(req.Get("playbook_id").String()); id != "" {
+		q = q.Where("playbook_id = ?", id)
+	}
+	var rows []store.PlaybookSchedule
+	if err := q.Order("id ASC").Find(&rows).Error; err != nil {
+		writeError(req, 500, "db query failed: "+err.Error())
+		return
+	}
+
+	items := make([]ScheduleItem, 0, len(rows))
+	for _, r := range rows {
+		items = append(items, toScheduleItem(r))
+	}
+
+	leader := false
+	if s := playbook.GlobalScheduler(); s != nil {
+		leader = s.IsLeader()
+	}
+	req.Response.WriteJson(g.Map{
+		"success":   true,
+		"schedules": items,
+		"count":     len(items),
+		"leader":    leader,
+	})
+}
+
+// CreateSchedule creates a schedule.
+// POST /api/ops/schedules
+func (c *ScheduleController) CreateSchedule(req *ghttp.Request) {
+	ctx := req.Context()
+
+	var input ScheduleRequest
+	if err := req.Parse(&input); err != nil {
+		writeError(req, 400, err.Error())
+		return
+	}
+	user := middleware.GetUserContext(ctx)
+	if user == nil {
+		writeError(req, 401, "Unauthorized")
+		return
+	}
+	if strings.TrimSpace(input.Timezone) == "" {
+		input.Timezone = "UTC"
+	}
+	if strings.TrimSpace(input.Name) == "" {
+		input.Name = input.PlaybookID
+	}
+	next, msg := validateSchedule(&input)
+	if msg != "" {
+		writeError(req, 400, msg)
+		return
+	}
+
+	params, _ := json.Marshal(input.Parameters)
+	enabled := true
+	if input.Enabled != nil {
+		enabled = *input.Enabled
+	}
+	now := time.Now().UTC()
+	row := store.PlaybookSchedule{
+		Name:       strings.TrimSpace(input.Name),
+		PlaybookID: input.PlaybookID,
+		CronExpr:   strings.TrimSpace(input.CronExpr),
+		Timezone:   input.Timezone,
+		Parameters: params,
+		Enabled:    enabled,
+		CreatedBy:  user.Username,
+		NextRunAt:  next,
+		CreatedAt:  &now,
+		UpdatedAt:  &now,
+	}
+
+	db, err := store.DB(ctx)
+	if err != nil {
+		writeError(req, 500, "db init failed: "+err.Error())
+		return
+	}
+	if err := db.WithContext(ctx).Create(&row).Error; err != nil {
+		writeError(req, 500, "db insert failed: "+err.Error())
+		return
+	}
+
+	req.Response.WriteJson(g.Map{
+		"success":  true,
+		"schedule": toScheduleItem(row),
+	})
+}
+
+// UpdateSchedule updates a schedule. Omitted fields keep their current value.
+// PATCH /api/ops/schedules/:id
+func (c *ScheduleController) UpdateSchedule(req *ghttp.Request) {
+	ctx := req.Context()
+	id := req.Get("id").Int64()
+
+	var input ScheduleRequest
+	if err := req.Parse(&input); err != nil {
+		writeError(req, 400, err.Error())
+		return
+	}
+
+	db, err := store.DB(ctx)
+	if err != nil {
+		writeError(req, 500, "db init failed: "+err.Error())
+		return
+	}
+	var row store.PlaybookSchedule
+	if err := db.WithContext(ctx).First(&row, "id = ?", id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			writeError(req, 404, "Schedule not found")
+			return
+		}
+		writeError(req, 500, "db query failed: "+err.Error())
+		return
+	}
+
+	merged := ScheduleRequest{
+		Name:       row.Name,
+		PlaybookID: row.PlaybookID,
+		CronExpr:   row.CronExpr,
+		Timezone:   row.Timezone,
+		Parameters: map[string]any{},
+	}
+	if len(row.Parameters) > 0 {
+		_ = json.Unmarshal(row.Parameters, &merged.Parameters)
+	}
+	if strings.TrimSpace(input.Name) != "" {
+		merged.Name = strings.TrimSpace(input.Name)
+	}
+	if input.PlaybookID != "" {
+		merged.PlaybookID = input.PlaybookID
+	}
+	if strings.TrimSpace(input.CronExpr) != "" {
+		merged.CronExpr = strings.TrimSpace(input.CronExpr)
+	}
+	if strings.TrimSpace(input.Timezone) != "" {
+		merged.Timezone = strings.TrimSpace(input.Timezone)
+	}
+	if input.Parameters != nil {
+		merged.Parameters = input.Parameters
+	}
+	next, msg := validateSchedule(&merged)
+	if msg != "" {
+		writeError(req, 400, msg)
+		return
+	}
+
+	params, _ := json.Marshal(merged.Parameters)
+	updates := map[string]any{
+		"name":        merged.Name,
+		"playbook_id": merged.PlaybookID,
+		"cron_expr":   merged.CronExpr,
+		"timezone":    merged.Timezone,
+		"parameters":  params,
+		"next_run_at": next,
+		"updated_at":  time.Now().UTC(),
+	}
+	if input.Enabled != nil {
+		updates["enabled"] = *input.Enabled
+	}
+	if err := db.WithContext(ctx).Model(&store.PlaybookSchedule{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		writeError(req, 500, "db update failed: "+err.Error())
+		return
+	}
+	if err := db.WithContext(ctx).First(&row, "id = ?", id).Error; err != nil {
+		writeError(req, 500, "db query failed: "+err.Error())
+		return
+	}
+
+	req.Response.WriteJson(g.Map{
+		"success":  true,
+		"schedule": toScheduleItem(row),
+	})
+}
+
+// DeleteSchedule deletes a schedule.
+// DELETE /api/ops/schedules/:id
+func (c *ScheduleController) DeleteSchedule(req *ghttp.Request) {
+	ctx := req.Context()
+	id := req.Get("id").Int64()
+
+	db, err := store.DB(ctx)
+	if err != nil {
+		writeError(req, 500, "db init failed: "+err.Error())
+		return
+	}
+	res := db.WithContext(ctx).Delete(&store.PlaybookSchedule{}, "id = ?", id)
+	if res.Error != nil {
+		writeError(req, 500, "db delete failed: "+res.Error.Error())
+		return
+	}
+	if res.RowsAffected == 0 {
+		writeError(req, 404, "Schedule not found")
+		return
+	}
+
+	req.Response.WriteJson(g.Map{
+		"success": true,
+	})
+}
@@ -0,0 +1,315 @@
+// Package apilog 查询 Resume-Agent 写入的请求日志、错误日志与 trace span，
+// 供 admin 接口与 Agent 工具共用。
+package apilog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/internal/store"
+	"gorm.io/gorm"
+)
+
+// ErrTraceNotFound is returned when a trace has neither spans nor requests.
+var ErrTraceNotFound = errors.New("trace 不存在")
+
+// RequestLogFilter selects rows of api_request_logs. Zero values are ignored.
+type RequestLogFilter struct {
+	TraceID       string
+	Path          string // substring, case-insensitive
+	Method        string
+	StatusCode    *int64
+	MinStatusCode *int64
+	MaxStatusCode *int64
+	Since         time.Time
+	Until         time.Time
+}
+
+func (f RequestLogFilter) apply(q *gorm.DB) *gorm.DB {
+	if v := strings.TrimSpace(f.TraceID); v != "" {
+		q = q.Where("trace_id = ?", v)
+	}
+	if v := strings.TrimSpace(f.Path); v != "" {
+		q = q.Where("path ILIKE ?", "%"+v+"%")
+	}
+	if v := strings.TrimSpace(f.Method); v != "" {
+		q = q.Where("method = ?", strings.ToUpper(v))
+	}
+	if f.StatusCode != nil {
+		q = q.Where("status_code = ?", *f.StatusCode)
+	}
+	if f.MinStatusCode != nil {
+		q = q.Where("status_code >= ?", *f.MinStatusCode)
+	}
+	if f.MaxStatusCode != nil {
+		q = q.Where("status_code <= ?", *f.MaxStatusCode)
+	}
+	if !f.Since.IsZero() {
+		q = q.Where("created_at >= ?", f.Since)
+	}
+	if !f.Until.IsZero() {
+		q = q.Where("created_at <= ?", f.Until)
+	}
+	return q
+}
+
+// SearchRequestLogs returns a page of matching request logs, newest first,
+// and the total number of matches.
+func SearchRequestLogs(ctx context.Context, db *gorm.DB, f RequestLogFilter, offset, limit int) ([]store.APIRequestLog, int64, error) {
+	q := f.apply(db.WithContext(ctx).Model(&store.APIRequestLog{}))
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var rows []store.APIRequestLog
+	if err := q.Order("created_at DESC").Offset(offset).Limit(limit).Find(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+	return rows, total, nil
+}
+
+// RequestLogStats aggregates the requests matching a filter.
+type RequestLogStats struct {
+	Total        int64   `json:"total" gorm:"column:total"`
+	Errors5xx    int64   `json:"errors_5xx" gorm:"column:errors_5xx"`
+	Errors4xx    int64   `json:"errors_4xx" gorm:"column:errors_4xx"`
+	AvgLatencyMs float64 `json:"avg_latency_ms" gorm:"column:avg_latency_ms"`
+	P95LatencyMs float64 `json:"p95_latency_ms" gorm:"column:p95_latency_ms"`
+	MaxLatencyMs float64 `json:"max_latency_ms" gorm:"column:max_latency_ms"`
+}
+
+// StatsRequestLogs computes counts and latency percentiles for a filter.
+func StatsRequestLogs(ctx context.Context, db *gorm.DB, f RequestLogFilter) (RequestLogStats, error) {
+	var s RequestLogStats
+	err := f.apply(db.WithContext(ctx).Model(&store.APIRequestLog{})).Select(`
+			COUNT(*) AS total,
+			COALESCE(SUM(CASE WHEN status_code >= 500 THEN 1 ELSE 0 END), 0) AS errors_5xx,
+			COALESCE(SUM(CASE WHEN status_code >= 400 AND status_code < 500 THEN 1 ELSE 0 END), 0) AS errors_4xx,
+			COALESCE(AVG(latency_ms), 0) AS avg_latency_ms,
+			COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY latency_ms), 0) AS p95_latency_ms,
+			COALESCE(MAX(latency_ms), 0) AS max_latency_ms
+		`).Scan(&s).Error
+	return s, err
+}
+
+// ErrorLogFilter selects rows of api_error_logs. Zero values are ignored.
+type ErrorLogFilter struct {
+	TraceID string
+	Keyword string // substring of error_message, case-insensitive
+	Since   time.Time
+}
+
+func (f ErrorLogFilter) apply(q *gorm.DB) *gorm.DB {
+	if v := strings.TrimSpace(f.TraceID); v != "" {
+		q = q.Where("trace_id = ?", v)
+	}
+	if v := strings.TrimSpace(f.Keyword); v != "" {
+		q = q.Where("error_message ILIKE ?", "%"+v+"%")
+	}
+	if !f.Since.IsZero() {
+		q = q.Where("created_at >= ?", f.Since)
+	}
+	return q
+}
+
+// SearchErrorLogs returns a page of matching error logs, newest first, and
+// the total number of matches.
+func SearchErrorLogs(ctx context.Context, db *gorm.DB, f ErrorLogFilter, offset, limit int) ([]store.APIErrorLog, int64, error) {
+	q := f.apply(db.WithContext(ctx).Model(&store.APIErrorLog{}))
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var rows []store.APIErrorLog
+	if err := q.Order("created_at DESC").Offset(offset).Limit(limit).Find(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+	return rows, total, nil
+}
+
+// ErrorTypeCount is one group of TopErrorTypes.
+type ErrorTypeCount struct {
+	ErrorType     string     `json:"error_type" gorm:"column:error_type"`
+	Service       string     `json:"service,omitempty" gorm:"column:service"`
+	Count         int64      `json:"count" gorm:"column:count"`
+	FirstSeen     *time.Time `json:"first_seen" gorm:"column:first_seen"`
+	LastSeen      *time.Time `json:"last_seen" gorm:"column:last_seen"`
+	SampleMessage string     `json:"sample_message" gorm:"column:sample_message"`
+	SampleTraceID string     `json:"sample_trace_id" gorm:"column:sample_trace_id"`
+}
+
+// TopErrorTypes groups error logs since the given time by type and service,
+// most frequent first.
+func TopErrorTypes(ctx context.Context, db *gorm.DB, since time.Time, limit int) ([]ErrorTypeCount, error) {
+	var rows []ErrorTypeCount
+	err := db.WithContext(ctx).Table("api_error_logs").
+		Select(`
+			COALESCE(error_type, 'unknown') AS error_type,
+			COALESCE(service, '') AS service,
+			COUNT(*) AS count,
+			MIN(created_at) AS first_seen,
+			MAX(created_at) AS last_seen,
+			(ARRAY_AGG(error_message ORDER BY created_at DESC))[1] AS sample_message,
+			(ARRAY_AGG(trace_id ORDER BY created_at DESC))[1] AS sample_trace_id
+		`).
+		Where("created_at >= ?", since).
+		Group("COALESCE(error_type, 'unknown'), COALESCE(service, '')").
+		Order("count DESC").
+		Limit(limit).
+		Scan(&rows).Error
+	return rows, err
+}
+
+// TraceSummary is one trace in ListTraces.
+type TraceSummary struct {
+	TraceID      string     `gorm:"column:trace_id"`
+	LatestAt     *time.Time `gorm:"column:latest_at"`
+	RequestCount int64      `gorm:"column:request_count"`
+	ErrorCount   int64      `gorm:"column:error_count"`
+	AvgLatencyMs float64    `gorm:"column:avg_latency_ms"`
+}
+
+// ListTraces aggregates request logs per trace, latest first.
+func ListTraces(ctx context.Context, db *gorm.DB, traceID string, offset, limit int) ([]TraceSummary, int64, error) {
+	whereSQL := ""
+	args := []any{}
+	if traceID != "" {
+		whereSQL = "WHERE trace_id = ?"
+		args = append(args, traceID)
+	}
+
+	var total int64
+	countSQL := "SELECT COUNT(*) FROM (SELECT 1 FROM api_request_logs " + whereSQL + " GROUP BY trace_id) t"
+	if err := db.WithContext(ctx).Raw(countSQL, args...).Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	q := db.WithContext(ctx).Table("api_request_logs").
+		Select(`
+			trace_id as trace_id,
+			MAX(created_at) as latest_at,
+			COUNT(*) as request_count,
+			SUM(CASE WHEN status_code >= 500 THEN 1 ELSE 0 END) as error_count,
+			COALESCE(AVG(latency_ms), 0) as avg_latency_ms
+		`).
+		Group("trace_id")
+	if traceID != "" {
+		q = q.Where("trace_id = ?", traceID)
+	}
+
+	var rows []TraceSummary
+	if err := q.Order("MAX(created_at) DESC").Offset(offset).Limit(limit).Scan(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+	return rows, total, nil
+}
+
+// Span is a span of a trace, either stored in api_trace_spans or
+// synthesized from a request log.
+type Span struct {
+	SpanID       string
+	ParentSpanID string
+	Name         string
+	Start        time.Time
+	End          time.Time
+	DurationMs   float64
+	Status       string
+	Tags         map[string]any
+}
+
+// IsError reports whether the span failed.
+func (s Span) IsError() bool {
+	switch strings.ToLower(s.Status) {
+	case "", "ok", "unset", "success":
+	default:
+		return true
+	}
+	if code, ok := s.Tags["status_code"]; ok {
+		switch v := code.(type) {
+		case float64:
+			return v >= 500
+		case int64:
+			return v >= 500
+		case int:
+			return v >= 500
+		}
+	}
+	if v, ok := s.Tags["error"].(bool); ok && v {
+		return true
+	}
+	return false
+}
+
+// TraceSpans loads the spans of a trace ordered by start time. Without stored
+// spans, one synthetic span per request log is returned.
+func TraceSpans(ctx context.Context, db *gorm.DB, traceID string) ([]Span, error) {
+	var spans []store.APITraceSpan
+	if err := db.WithContext(ctx).Where("trace_id = ?", traceID).Order("start_time ASC").Find(&spans).Error; err != nil {
+		return nil, err
+	}
+	if len(spans) > 0 {
+		out := make([]Span, 0, len(spans))
+		for _, s := range spans {
+			parent := ""
+			if s.ParentSpanID != nil {
+				parent = *s.ParentSpanID
+			}
+			tags := map[string]any{}
+			if len(s.Tags) > 0 {
+				_ = json.Unmarshal(s.Tags, &tags)
+			}
+			out = append(out, Span{
+				SpanID:       s.SpanID,
+				ParentSpanID: parent,
+				Name:         s.SpanName,
+				Start:        s.StartTime.UTC(),
+				End:          s.EndTime.UTC(),
+				DurationMs:   s.DurationMs,
+				Status:       s.Status,
+				Tags:         tags,
+			})
+		}
+		return out, nil
+	}
+
+	// Fallback: synthetic spans from requests.
+	var reqRows []store.APIRequestLog
+	if err := db.WithContext(ctx).Where("trace_id = ?", traceID).Order("created_at ASC").Find(&reqRows).Error; err != nil {
+		return nil, err
+	}
+	if len(reqRows) == 0 {
+		return nil, ErrTraceNotFound
+	}
+	out := make([]Span, 0, len(reqRows))
+	for _, r := range reqRows {
+		start := time.Now().UTC()
+		if r.CreatedAt != nil {
+			start = r.CreatedAt.UTC()
+		}
+		status := "ok"
+		if r.StatusCode >= 500 {
+			status = "error"
+		}
+		tags := map[string]any{
+			"status_code": r.StatusCode,
+			"ip":          "",
+			"user_id":     r.UserID,
+		}
+		if r.IP != nil {
+			tags["ip"] = *r.IP
+		}
+		out = append(out, Span{
+			SpanID:     r.RequestID,
+			Name:       r.Method + " " + r.Path,
+			Start:      start,
+			End:        start.Add(time.Duration(r.LatencyMs * float64(time.Millisecond))),
+			DurationMs: r.LatencyMs,
+			Status:     status,
+			Tags:       tags,
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,102 @@
+package apilog
+
+import (
+	"sort"
+	"time"
+)
+
+// SpanNode is a span with its children.
+type SpanNode struct {
+	Span
+	Children []*SpanNode
+	// SelfMs is the time not covered by any child span.
+	SelfMs float64
+}
+
+// BuildTree links spans by parent id. Spans whose parent is missing become
+// roots; roots and children are ordered by start time.
+func BuildTree(spans []Span) []*SpanNode {
+	nodes := make(map[string]*SpanNode, len(spans))
+	ordered := make([]*SpanNode, 0, len(spans))
+	for _, s := range spans {
+		n := &SpanNode{Span: s}
+		ordered = append(ordered, n)
+		if s.SpanID != "" {
+			nodes[s.SpanID] = n
+		}
+	}
+	var roots []*SpanNode
+	for _, n := range ordered {
+		if p, ok := nodes[n.ParentSpanID]; ok && n.ParentSpanID != "" && p != n {
+			p.Children = append(p.Children, n)
+		} else {
+			roots = append(roots, n)
+		}
+	}
+	byStart := func(list []*SpanNode) {
+		sort.SliceStable(list, func(i, j int) bool { return list[i].Start.Before(list[j].Start) })
+	}
+	byStart(roots)
+	for _, n := range ordered {
+		byStart(n.Children)
+		n.SelfMs = selfTime(n)
+	}
+	return roots
+}
+
+// selfTime subtracts the union of the children's intervals, clipped to the
+// parent, from the parent's duration.
+func selfTime(n *SpanNode) float64 {
+	if len(n.Children) == 0 {
+		return n.DurationMs
+	}
+	covered := time.Duration(0)
+	var curStart, curEnd time.Time
+	for _, c := range n.Children { // already ordered by start
+		s, e := c.Start, c.End
+		if s.Before(n.Start) {
+			s = n.Start
+		}
+		if e.After(n.End) {
+			e = n.End
+		}
+		if !e.After(s) {
+			continue
+		}
+		if curEnd.IsZero() || s.After(curEnd) {
+			covered += curEnd.Sub(curStart)
+			curStart, curEnd = s, e
+		} else if e.After(curEnd) {
+			curEnd = e
+		}
+	}
+	covered += curEnd.Sub(curStart)
+	self := n.DurationMs - float64(covered)/float64(time.Millisecond)
+	if self < 0 {
+		return 0
+	}
+	return self
+}
+
+// CriticalPath follows, from the longest root, the child that finishes last
+// at every level: the chain of spans that determined the trace latency.
+func CriticalPath(roots []*SpanNode) []*SpanNode {
+	var cur *SpanNode
+	for _, r := range roots {
+		if cur == nil || r.DurationMs > cur.DurationMs {
+			cur = r
+		}
+	}
+	var path []*SpanNode
+	for cur != nil {
+		path = append(path, cur)
+		var next *SpanNode
+		for _, c := range cur.Children {
+			if next == nil || c.End.After(next.End) {
+				next = c
+			}
+		}
+		cur = next
+	}
+	return path
+}
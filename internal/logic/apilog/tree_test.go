@@ -0,0 +1,57 @@
+package apilog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildTreeAndCriticalPath(t *testing.T) {
+	t0 := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	span := func(id, parent string, startMs, endMs int, status string) Span {
+		return Span{
+			SpanID:       id,
+			ParentSpanID: parent,
+			Name:         id,
+			Start:        t0.Add(time.Duration(startMs) * time.Millisecond),
+			End:          t0.Add(time.Duration(endMs) * time.Millisecond),
+			DurationMs:   float64(endMs - startMs),
+			Status:       status,
+		}
+	}
+	spans := []Span{
+		span("root", "", 0, 100, "ok"),
+		span("auth", "root", 0, 10, "ok"),
+		span("db", "root", 10, 60, "ok"),
+		span("llm", "root", 20, 95, "error"), // overlaps db, finishes last
+		span("llm.retry", "llm", 50, 90, "ok"),
+		span("orphan", "missing", 5, 6, "ok"),
+	}
+
+	roots := BuildTree(spans)
+	if len(roots) != 2 || roots[0].SpanID != "root" || roots[1].SpanID != "orphan" {
+		t.Fatalf("unexpected roots: %+v", roots)
+	}
+	root := roots[0]
+	if len(root.Children) != 3 {
+		t.Fatalf("root has %d children, want 3", len(root.Children))
+	}
+	// children cover [0,95] => 5ms self time
+	if root.SelfMs != 5 {
+		t.Errorf("root self = %v, want 5", root.SelfMs)
+	}
+
+	path := CriticalPath(roots)
+	var ids []string
+	for _, n := range path {
+		ids = append(ids, n.SpanID)
+	}
+	if len(ids) != 3 || ids[0] != "root" || ids[1] != "llm" || ids[2] != "llm.retry" {
+		t.Fatalf("critical path = %v", ids)
+	}
+	if !path[1].IsError() || path[2].IsError() {
+		t.Errorf("unexpected error flags on critical path")
+	}
+	if (Span{Status: "ok", Tags: map[string]any{"status_code": float64(502)}}).IsError() != true {
+		t.Errorf("status_code 502 should be an error")
+	}
+}
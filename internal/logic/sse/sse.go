@@ -2,7 +2,11 @@ package sse
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gogf/gf/v2/container/gmap"
@@ -12,20 +16,22 @@ import (
 
 // Client 表示SSE客户端连接
 type Client struct {
-	Id          string
-	Request     *ghttp.Request
-	messageChan chan string
+	Id      string
+	Request *ghttp.Request
+	mu      sync.Mutex // 工具回调（如审批请求）会在其它 goroutine 中发送事件
 }
 
 // Service SSE服务
 type Service struct {
 	clients *gmap.StrAnyMap // 存储所有客户端连接
+	streams *gmap.StrAnyMap // 可续传的事件流，key 为流 id（会话 id）
 }
 
 // New 创建SSE服务实例
 func New() *Service {
 	return &Service{
 		clients: gmap.NewStrAnyMap(true),
+		streams: gmap.NewStrAnyMap(true),
 	}
 }
 
@@ -39,26 +45,161 @@ func (s *Service) Create(ctx context.Context, r *ghttp.Request) (*Client, error)
 	// 创建新客户端
 	clientId := r.Get("client_id", guid.S()).String()
 	client := &Client{
-		Id:          clientId,
-		Request:     r,
-		messageChan: make(chan string, 100),
+		Id:      clientId,
+		Request: r,
 	}
-	// 发送连接成功消息
-	r.Response.Writefln("id: %s", clientId)
+	// 发送连接成功消息；不带 id，避免覆盖客户端的 Last-Event-ID
+	b, _ := json.Marshal(map[string]string{"status": "connected", "client_id": clientId})
 	r.Response.Writefln("event: connected")
-	r.Response.Writefln("data: {\"status\": \"connected\", \"client_id\": \"%s\"}\n", clientId)
+	r.Response.Writefln("data: %s\n", b)
 	r.Response.Flush()
 	return client, nil
 }
 
-// SendToClient 向指定客户端发送消息
+// writeEvent 按 SSE 格式写出事件，多行数据拆成多个 data 行
+func writeEvent(id, eventType, data string) string {
+	var b strings.Builder
+	if id != "" {
+		b.WriteString("id: " + id + "\n")
+	}
+	b.WriteString("event: " + eventType + "\n")
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// SendToClient 向指定客户端发送消息；不带 id，避免覆盖客户端的 Last-Event-ID
 func (c *Client) SendToClient(eventType, data string) bool {
-	msg := fmt.Sprintf(
-		"id: %d\nevent: %s\ndata: %s\n\n",
-		time.Now().UnixNano(), eventType, data,
-	)
-	// 尝试发送消息，如果缓冲区满则跳过
+	msg := writeEvent("", eventType, data)
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	c.Request.Response.Write(msg)
 	c.Request.Response.Flush()
 	return true
 }
+
+// Event 是事件流中的一条事件，ID 在流内单调递增
+type Event struct {
+	ID    int64
+	Event string
+	Data  string
+}
+
+// streamRetention 是事件流结束后保留以供续传的时间
+const streamRetention = 5 * time.Minute
+
+// Stream 缓存一次运行产生的全部事件，断线的客户端可以凭 Last-Event-ID 续传
+type Stream struct {
+	ID     string
+	mu     sync.Mutex
+	events []Event
+	done   bool
+	notify chan struct{} // 每次追加事件或结束时关闭并替换
+}
+
+// NewStream 为 id 创建新的事件流，替换同 id 的旧流
+func (s *Service) NewStream(id string) *Stream {
+	st := &Stream{ID: id, notify: make(chan struct{})}
+	s.streams.Set(id, st)
+	return st
+}
+
+// Stream 返回 id 的事件流（运行中或结束不久）
+func (s *Service) Stream(id string) (*Stream, bool) {
+	v := s.streams.Get(id)
+	if v == nil {
+		return nil, false
+	}
+	return v.(*Stream), true
+}
+
+// CloseStream 结束事件流，保留 streamRetention 后移除
+func (s *Service) CloseStream(st *Stream) {
+	st.close()
+	time.AfterFunc(streamRetention, func() {
+		// 只移除自己，同 id 可能已开始新的运行
+		s.streams.LockFunc(func(m map[string]any) {
+			if m[st.ID] == st {
+				delete(m, st.ID)
+			}
+		})
+	})
+}
+
+// Publish 追加一条事件，payload 编码为单行 JSON
+func (st *Stream) Publish(eventType string, payload any) Event {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		data, _ = json.Marshal(map[string]string{"error": fmt.Sprintf("encode %s event: %v", eventType, err)})
+	}
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.done {
+		return Event{}
+	}
+	ev := Event{ID: int64(len(st.events) + 1), Event: eventType, Data: string(data)}
+	st.events = append(st.events, ev)
+	close(st.notify)
+	st.notify = make(chan struct{})
+	return ev
+}
+
+func (st *Stream) close() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.done {
+		return
+	}
+	st.done = true
+	close(st.notify)
+}
+
+// since 返回 ID 大于 after 的事件、流是否已结束，以及等待新事件的 channel
+func (st *Stream) since(after int64) ([]Event, bool, <-chan struct{}) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if after < 0 {
+		after = 0
+	}
+	var out []Event
+	if after < int64(len(st.events)) {
+		out = append(out, st.events[after:]...)
+	}
+	return out, st.done, st.notify
+}
+
+// ParseLastEventID 解析 Last-Event-ID，无效时返回 0（从头重放）
+func ParseLastEventID(v string) int64 {
+	id, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+	if err != nil || id < 0 {
+		return 0
+	}
+	return id
+}
+
+// Follow 把流中 ID 大于 after 的事件写给客户端，直到流结束或客户端断开
+func (c *Client) Follow(ctx context.Context, st *Stream, after int64) error {
+	for {
+		events, done, wait := st.since(after)
+		if len(events) > 0 {
+			c.mu.Lock()
+			for _, ev := range events {
+				c.Request.Response.Write(writeEvent(strconv.FormatInt(ev.ID, 10), ev.Event, ev.Data))
+			}
+			c.Request.Response.Flush()
+			c.mu.Unlock()
+			after = events[len(events)-1].ID
+			continue
+		}
+		if done {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-wait:
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package sse
+
+import (
+	"testing"
+)
+
+func TestWriteEventMultiline(t *testing.T) {
+	got := writeEvent("3", "message", "line1\nline2")
+	want := "id: 3\nevent: message\ndata: line1\ndata: line2\n\n"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestStreamResume(t *testing.T) {
+	s := New()
+	st := s.NewStream("sess-1")
+	st.Publish("tool_call", map[string]string{"tool": "query_logs"})
+	st.Publish("message", map[string]string{"content": "a\nb"})
+
+	events, done, wait := st.since(ParseLastEventID("1"))
+	if len(events) != 1 || events[0].ID != 2 || done {
+		t.Fatalf("since(1) = %+v, done=%v", events, done)
+	}
+	if events[0].Data != `{"content":"a\nb"}` {
+		t.Errorf("payload not single-line JSON: %q", events[0].Data)
+	}
+
+	s.CloseStream(st)
+	select {
+	case <-wait:
+	default:
+		t.Error("closing the stream did not wake followers")
+	}
+	if ev := st.Publish("message", "late"); ev.ID != 0 {
+		t.Error("publish after close accepted")
+	}
+	if got, ok := s.Stream("sess-1"); !ok || got != st {
+		t.Error("closed stream not retained for resume")
+	}
+	if events, done, _ := st.since(ParseLastEventID("bogus")); len(events) != 2 || !done {
+		t.Errorf("invalid Last-Event-ID should replay all: %d events, done=%v", len(events), done)
+	}
+}
@@ -24,8 +24,20 @@ type Metric struct {
 	Value       float64
 	Labels      map[string]string
 	Description string
+
+	// Histogram/Summary observations (see Observe).
+	Count        uint64
+	Sum          float64
+	Buckets      []float64 // upper bounds, ascending
+	BucketCounts []uint64  // cumulative counts per bucket
 }
 
+// Default histogram buckets.
+var (
+	DefaultLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+	DefaultSizeBuckets    = []float64{256, 1024, 4096, 16384, 65536, 262144, 1048576}
+)
+
 // MetricsRegistry manages all metrics.
 type MetricsRegistry struct {
 	mu      sync.RWMutex
@@ -47,13 +59,50 @@ func (r *MetricsRegistry) Register(name string, metricType MetricType, descripti
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	r.metrics[name] = &Metric{
+	m := &Metric{
 		Name:        name,
 		Type:        metricType,
 		Value:       0,
 		Labels:      make(map[string]string),
 		Description: description,
 	}
+	if metricType == Histogram {
+		m.Buckets = DefaultLatencyBuckets
+		m.BucketCounts = make([]uint64, len(m.Buckets))
+	}
+	r.metrics[name] = m
+}
+
+// RegisterHistogram registers a histogram with custom buckets.
+func (r *MetricsRegistry) RegisterHistogram(name, description string, buckets []float64) {
+	r.Register(name, Histogram, description)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := r.metrics[name]
+	m.Buckets = buckets
+	m.BucketCounts = make([]uint64, len(buckets))
+}
+
+// Observe records one observation of a histogram or summary metric. Value
+// keeps the last observation.
+func (r *MetricsRegistry) Observe(name string, value float64, labels map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if metric, ok := r.metrics[name]; ok {
+		metric.Value = value
+		metric.Count++
+		metric.Sum += value
+		for i, upper := range metric.Buckets {
+			if value <= upper {
+				metric.BucketCounts[i]++
+			}
+		}
+		if labels != nil {
+			metric.Labels = labels
+		}
+	}
 }
 
 // Increment increments a counter metric.
@@ -97,15 +146,7 @@ func (r *MetricsRegistry) Set(name string, value float64, labels map[string]stri
 
 // Timing records a timing value in seconds.
 func (r *MetricsRegistry) Timing(name string, duration time.Duration, labels map[string]string) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	if metric, ok := r.metrics[name]; ok {
-		metric.Value = duration.Seconds()
-		if labels != nil {
-			metric.Labels = labels
-		}
-	}
+	r.Observe(name, duration.Seconds(), labels)
 }
 
 // Get retrieves a metric value.
@@ -139,6 +180,9 @@ func InitializeStandardMetrics() {
 	r.Register("ops_portal_tool_calls_total", Counter, "Total number of tool calls")
 	r.Register("ops_portal_tool_duration_seconds", Histogram, "Tool execution duration in seconds")
 	r.Register("ops_portal_tool_errors_total", Counter, "Total number of tool errors")
+	r.RegisterHistogram("ops_portal_tool_output_bytes", "Size of tool outputs in bytes", DefaultSizeBuckets)
+	r.Register("ops_portal_tool_cache_hits_total", Counter, "Total number of tool calls served from the result cache")
+	r.Register("ops_portal_tool_cache_misses_total", Counter, "Total number of cacheable tool calls that missed the result cache")
 
 	// Agent metrics
 	r.Register("ops_portal_agent_steps_total", Counter, "Total number of agent steps")
@@ -168,6 +212,10 @@ const (
 	ToolCallsTotal      = "ops_portal_tool_calls_total"
 	ToolDurationSeconds = "ops_portal_tool_duration_seconds"
 	ToolErrorsTotal     = "ops_portal_tool_errors_total"
+	ToolOutputBytes     = "ops_portal_tool_output_bytes"
+
+	ToolCacheHitsTotal   = "ops_portal_tool_cache_hits_total"
+	ToolCacheMissesTotal = "ops_portal_tool_cache_misses_total"
 
 	AgentStepsTotal      = "ops_portal_agent_steps_total"
 	AgentDurationSeconds = "ops_portal_agent_duration_seconds"
@@ -198,12 +246,18 @@ func NewInstrumentedTool(name string) *InstrumentedTool {
 
 // RecordCall records a tool call.
 func (i *InstrumentedTool) RecordCall(labels map[string]string) {
-	Global().Increment(ToolCallsTotal, map[string]string{
-		"tool": i.name,
-	})
+	all := map[string]string{"tool": i.name}
 	for k, v := range labels {
-		Global().metrics[ToolCallsTotal].Labels[k] = v
+		all[k] = v
 	}
+	Global().Increment(ToolCallsTotal, all)
+}
+
+// RecordOutputSize records the size of a tool output in bytes.
+func (i *InstrumentedTool) RecordOutputSize(n int) {
+	Global().Observe(ToolOutputBytes, float64(n), map[string]string{
+		"tool": i.name,
+	})
 }
 
 // RecordSuccess records a successful tool execution.
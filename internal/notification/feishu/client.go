@@ -259,6 +259,8 @@ type DiagnosticReportNotification struct {
 	AlertName       string
 	ExecutionStatus string
 	Summary         string
+	RootCause       string
+	Confidence      float64 // of RootCause, 0-1
 	Recommendations []string
 	Actions         []string
 	ReportLink      string
@@ -283,6 +285,10 @@ func (c *Client) SendDiagnosticReport(ctx context.Context, chatID string, report
 		report.Summary,
 	)
 
+	if report.RootCause != "" {
+		text += fmt.Sprintf("**根因**: %s（置信度 %.0f%%）\n", report.RootCause, report.Confidence*100)
+	}
+
 	if len(report.Recommendations) > 0 {
 		text += "\n**建议**:\n"
 		for i, rec := range report.Recommendations {
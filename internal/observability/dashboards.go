@@ -0,0 +1,309 @@
+package obsgrafana
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/utility/textutil"
+)
+
+// maxProxyResponse caps datasource proxy responses read into memory.
+const maxProxyResponse = 16 * 1024 * 1024
+
+// DatasourceRef references a datasource from a panel or target. Old
+// dashboards store the datasource name as a plain string.
+type DatasourceRef struct {
+	Type string `json:"type,omitempty"`
+	UID  string `json:"uid,omitempty"`
+}
+
+// UnmarshalJSON accepts both {"type","uid"} and a legacy name string.
+func (r *DatasourceRef) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err == nil {
+		*r = DatasourceRef{UID: name}
+		return nil
+	}
+	type plain DatasourceRef
+	var p plain
+	if err := json.Unmarshal(b, &p); err != nil {
+		return err
+	}
+	*r = DatasourceRef(p)
+	return nil
+}
+
+// Target is one query of a panel. Only Prometheus and Loki targets (expr)
+// are executed by the grafana_panel_data tool.
+type Target struct {
+	RefID      string         `json:"refId"`
+	Expr       string         `json:"expr,omitempty"`
+	Hide       bool           `json:"hide,omitempty"`
+	Datasource *DatasourceRef `json:"datasource,omitempty"`
+}
+
+// Panel is a dashboard panel. Panels nested in collapsed rows are flattened.
+type Panel struct {
+	ID         int            `json:"id"`
+	Title      string         `json:"title"`
+	Type       string         `json:"type"`
+	Datasource *DatasourceRef `json:"datasource,omitempty"`
+	Targets    []Target       `json:"targets,omitempty"`
+	Panels     []Panel        `json:"panels,omitempty"` // collapsed row children
+}
+
+// DashboardDetail is a dashboard with its panels and the current values of
+// its template variables.
+type DashboardDetail struct {
+	UID       string            `json:"uid"`
+	Title     string            `json:"title"`
+	URL       string            `json:"url"`
+	Tags      []string          `json:"tags"`
+	Panels    []Panel           `json:"panels"`
+	Variables map[string]string `json:"variables"`
+}
+
+// Datasource is a configured Grafana datasource.
+type Datasource struct {
+	UID       string `json:"uid"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	IsDefault bool   `json:"isDefault"`
+}
+
+// do sends an authenticated request and returns the body of a 2xx response.
+func (c *Client) do(ctx context.Context, method, path string, body any) ([]byte, error) {
+	var rd io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		rd = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, rd)
+	if err != nil {
+		return nil, err
+	}
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxProxyResponse))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		msg, _ := textutil.Truncate(strings.TrimSpace(string(b)), 300)
+		return nil, fmt.Errorf("grafana %s %s failed: %d - %s", method, strings.SplitN(path, "?", 2)[0], resp.StatusCode, msg)
+	}
+	return b, nil
+}
+
+// GetDashboard loads a dashboard by uid.
+func (c *Client) GetDashboard(ctx context.Context, uid string) (*DashboardDetail, error) {
+	b, err := c.do(ctx, http.MethodGet, "/api/dashboards/uid/"+url.PathEscape(uid), nil)
+	if err != nil {
+		return nil, err
+	}
+	var raw struct {
+		Dashboard struct {
+			UID        string   `json:"uid"`
+			Title      string   `json:"title"`
+			Tags       []string `json:"tags"`
+			Panels     []Panel  `json:"panels"`
+			Templating struct {
+				List []struct {
+					Name    string `json:"name"`
+					Current struct {
+						Value json.RawMessage `json:"value"`
+					} `json:"current"`
+				} `json:"list"`
+			} `json:"templating"`
+		} `json:"dashboard"`
+		Meta struct {
+			URL string `json:"url"`
+		} `json:"meta"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("decode dashboard: %v", err)
+	}
+
+	d := &DashboardDetail{
+		UID:       raw.Dashboard.UID,
+		Title:     raw.Dashboard.Title,
+		URL:       c.baseURL + raw.Meta.URL,
+		Tags:      raw.Dashboard.Tags,
+		Variables: map[string]string{},
+	}
+	var flatten func(ps []Panel)
+	flatten = func(ps []Panel) {
+		for _, p := range ps {
+			children := p.Panels
+			p.Panels = nil
+			if p.Type != "row" {
+				d.Panels = append(d.Panels, p)
+			}
+			flatten(children)
+		}
+	}
+	flatten(raw.Dashboard.Panels)
+
+	for _, v := range raw.Dashboard.Templating.List {
+		if val, ok := variableValue(v.Current.Value); ok {
+			d.Variables[v.Name] = val
+		}
+	}
+	return d, nil
+}
+
+// variableValue turns the current value of a template variable into the
+// text substituted into queries; multi-value variables become a regex.
+func variableValue(raw json.RawMessage) (string, bool) {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		if s == "$__all" {
+			return ".*", true
+		}
+		return s, true
+	}
+	var list []string
+	if json.Unmarshal(raw, &list) == nil && len(list) > 0 {
+		for _, v := range list {
+			if v == "$__all" {
+				return ".*", true
+			}
+		}
+		if len(list) == 1 {
+			return list[0], true
+		}
+		return "(" + strings.Join(list, "|") + ")", true
+	}
+	return "", false
+}
+
+// FindPanel returns the panel with the given id, or the first panel whose
+// title contains title (case-insensitive).
+func (d *DashboardDetail) FindPanel(id int, title string) (*Panel, bool) {
+	title = strings.ToLower(strings.TrimSpace(title))
+	for i := range d.Panels {
+		p := &d.Panels[i]
+		if (id > 0 && p.ID == id) || (id <= 0 && title != "" && strings.Contains(strings.ToLower(p.Title), title)) {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// Datasources lists the configured datasources.
+func (c *Client) Datasources(ctx context.Context) ([]Datasource, error) {
+	b, err := c.do(ctx, http.MethodGet, "/api/datasources", nil)
+	if err != nil {
+		return nil, err
+	}
+	var list []Datasource
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, fmt.Errorf("decode datasources: %v", err)
+	}
+	return list, nil
+}
+
+// ResolveDatasource maps a panel/target reference to a datasource. Template
+// variables (e.g. ${DS_PROMETHEUS}) are looked up in vars first; references
+// that still cannot be matched fall back to the default datasource of the
+// same type, then to any datasource of that type.
+func ResolveDatasource(ref DatasourceRef, vars map[string]string, all []Datasource) (Datasource, error) {
+	key := ref.UID
+	if strings.HasPrefix(key, "$") {
+		key = ExpandVariables(key, vars)
+	}
+	if key != "" && !strings.HasPrefix(key, "$") {
+		for _, ds := range all {
+			if ds.UID == key || ds.Name == key {
+				return ds, nil
+			}
+		}
+	}
+	var fallback *Datasource
+	for i, ds := range all {
+		if ref.Type != "" && ds.Type != ref.Type {
+			continue
+		}
+		if ds.IsDefault {
+			return ds, nil
+		}
+		if fallback == nil && ref.Type != "" {
+			fallback = &all[i]
+		}
+	}
+	if fallback != nil {
+		return *fallback, nil
+	}
+	return Datasource{}, fmt.Errorf("datasource %q (type %q) not found", ref.UID, ref.Type)
+}
+
+var variablePattern = regexp.MustCompile(`\$\{(\w+)(?::\w+)?\}|\[\[(\w+)\]\]|\$(\w+)`)
+
+// ExpandVariables substitutes $name, ${name} and [[name]]. Unknown variables
+// are kept, so Grafana built-ins such as $__rate_interval can be handled by
+// the caller.
+func ExpandVariables(expr string, vars map[string]string) string {
+	return variablePattern.ReplaceAllStringFunc(expr, func(m string) string {
+		sub := variablePattern.FindStringSubmatch(m)
+		name := sub[1] + sub[2] + sub[3]
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+// BuiltinVariables returns Grafana's interval variables for a range query.
+func BuiltinVariables(rng, step time.Duration) map[string]string {
+	rate := 4 * step
+	if rate < time.Minute {
+		rate = time.Minute
+	}
+	secs := func(d time.Duration) string { return fmt.Sprintf("%ds", int64(d.Seconds())) }
+	return map[string]string{
+		"__interval":      secs(step),
+		"__rate_interval": secs(rate),
+		"__range":         secs(rng),
+		"__range_s":       fmt.Sprintf("%d", int64(rng.Seconds())),
+	}
+}
+
+// ProxyGet calls the datasource through Grafana's datasource proxy, e.g.
+// path /api/v1/query_range for Prometheus.
+func (c *Client) ProxyGet(ctx context.Context, dsUID, path string, params url.Values) ([]byte, error) {
+	p := "/api/datasources/proxy/uid/" + url.PathEscape(dsUID) + path
+	if len(params) > 0 {
+		p += "?" + params.Encode()
+	}
+	return c.do(ctx, http.MethodGet, p, nil)
+}
+
+// PanelURL links to a single panel of a dashboard.
+func (c *Client) PanelURL(uid string, panelID int, timeRange *TimeRange) string {
+	u := c.DashboardURL(uid, timeRange)
+	sep := "?"
+	if strings.Contains(u, "?") {
+		sep = "&"
+	}
+	return fmt.Sprintf("%s%sviewPanel=%d", u, sep, panelID)
+}
@@ -49,14 +49,17 @@ type Dashboard struct {
 	URL    string `json:"url"`
 }
 
-// SearchDashboards searches for dashboards.
-func (c *Client) SearchDashboards(ctx context.Context, query string) ([]Dashboard, error) {
+// SearchDashboards searches for dashboards, optionally restricted to tags.
+func (c *Client) SearchDashboards(ctx context.Context, query string, tags ...string) ([]Dashboard, error) {
 	u, _ := url.Parse(c.baseURL + "/api/search")
 	q := u.Query()
 	q.Set("type", "dash-db")
 	if query != "" {
 		q.Set("query", query)
 	}
+	for _, t := range tags {
+		q.Add("tag", t)
+	}
 	u.RawQuery = q.Encode()
 
 	req, _ := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
@@ -216,6 +219,10 @@ type Annotation struct {
 	Time   int64             `json:"time"`   // Unix timestamp in milliseconds
 	Tags   []string          `json:"tags"`
 	Data   map[string]string `json:"data,omitempty"`
+
+	DashboardUID string `json:"dashboardUID,omitempty"` // empty: organization-wide annotation
+	PanelID      int    `json:"panelId,omitempty"`
+	TimeEnd      int64  `json:"timeEnd,omitempty"` // set for a region annotation
 }
 
 // CreateAnnotation creates a Grafana annotation.
@@ -0,0 +1,159 @@
+// Package changes records deploys, config changes, commits and restarts so
+// diagnoses can correlate incidents with recent changes.
+package changes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/internal/store"
+	"github.com/WyRainBow/ops-portal/utility/textutil"
+	"gorm.io/gorm/clause"
+)
+
+// Kinds of change events.
+const (
+	KindDeploy   = "deploy"
+	KindConfig   = "config"
+	KindCommit   = "commit"
+	KindRestart  = "restart"
+	KindRollback = "rollback"
+)
+
+// Sources of change events.
+const (
+	SourceAPI        = "api"
+	SourceGitWatcher = "git_watcher"
+	SourcePM2Watcher = "pm2_watcher"
+)
+
+const (
+	maxSummaryLength  = 500
+	maxMetadataLength = 16 * 1024
+)
+
+// ValidKind reports whether k is a known kind.
+func ValidKind(k string) bool {
+	switch k {
+	case KindDeploy, KindConfig, KindCommit, KindRestart, KindRollback:
+		return true
+	}
+	return false
+}
+
+// Event is a change to record.
+type Event struct {
+	Service    string         `json:"service"`
+	Kind       string         `json:"kind"`
+	Source     string         `json:"source,omitempty"`
+	Version    string         `json:"version,omitempty"`
+	Summary    string         `json:"summary,omitempty"`
+	Author     string         `json:"author,omitempty"`
+	URL        string         `json:"url,omitempty"`
+	Metadata   map[string]any `json:"metadata,omitempty"`
+	DedupKey   string         `json:"dedup_key,omitempty"`
+	OccurredAt time.Time      `json:"occurred_at"`
+}
+
+// Record validates and stores ev. Events whose DedupKey was already stored
+// are ignored; created reports whether a row was inserted.
+func Record(ctx context.Context, ev Event) (created bool, err error) {
+	ev.Service = strings.TrimSpace(ev.Service)
+	ev.Kind = strings.ToLower(strings.TrimSpace(ev.Kind))
+	if ev.Service == "" {
+		return false, fmt.Errorf("service is required")
+	}
+	if !ValidKind(ev.Kind) {
+		return false, fmt.Errorf("invalid kind %q (deploy, config, commit, restart, rollback)", ev.Kind)
+	}
+	if ev.Source == "" {
+		ev.Source = SourceAPI
+	}
+	if ev.OccurredAt.IsZero() {
+		ev.OccurredAt = time.Now()
+	}
+	// 按字符截断，字节截断会把中文切成非法 UTF-8，Postgres 拒绝写入
+	ev.Summary, _ = textutil.Truncate(ev.Summary, maxSummaryLength)
+	row := store.ChangeEvent{
+		Service:    ev.Service,
+		Kind:       ev.Kind,
+		Source:     ev.Source,
+		Version:    strings.TrimSpace(ev.Version),
+		Summary:    ev.Summary,
+		Author:     ev.Author,
+		URL:        ev.URL,
+		OccurredAt: ev.OccurredAt.UTC(),
+	}
+	if len(ev.Metadata) > 0 {
+		b, err := json.Marshal(ev.Metadata)
+		if err != nil {
+			return false, fmt.Errorf("invalid metadata: %v", err)
+		}
+		if len(b) > maxMetadataLength {
+			return false, fmt.Errorf("metadata exceeds %d bytes", maxMetadataLength)
+		}
+		row.Metadata = b
+	}
+	if k := strings.TrimSpace(ev.DedupKey); k != "" {
+		row.DedupKey = &k
+	}
+	now := time.Now().UTC()
+	row.CreatedAt = &now
+	return insert(ctx, &row)
+}
+
+// insert stores row unless its dedup key exists; tests replace it.
+var insert = func(ctx context.Context, row *store.ChangeEvent) (bool, error) {
+	db, err := store.DB(ctx)
+	if err != nil {
+		return false, err
+	}
+	res := db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "dedup_key"}},
+		DoNothing: true,
+	}).Create(row)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// Query selects change events. Zero values are ignored.
+type Query struct {
+	Service string
+	Kinds   []string
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+}
+
+// Recent returns matching events, newest first.
+func Recent(ctx context.Context, q Query) ([]store.ChangeEvent, error) {
+	db, err := store.DB(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tx := db.WithContext(ctx).Model(&store.ChangeEvent{})
+	if s := strings.TrimSpace(q.Service); s != "" {
+		tx = tx.Where("service = ?", s)
+	}
+	if len(q.Kinds) > 0 {
+		tx = tx.Where("kind IN ?", q.Kinds)
+	}
+	if !q.Since.IsZero() {
+		tx = tx.Where("occurred_at >= ?", q.Since)
+	}
+	if !q.Until.IsZero() {
+		tx = tx.Where("occurred_at <= ?", q.Until)
+	}
+	limit := q.Limit
+	if limit <= 0 || limit > 500 {
+		limit = 500
+	}
+	var rows []store.ChangeEvent
+	err = tx.Order("occurred_at DESC").Limit(limit).Find(&rows).Error
+	return rows, err
+}
@@ -0,0 +1,43 @@
+package changes
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/WyRainBow/ops-portal/internal/store"
+)
+
+func TestRecord(t *testing.T) {
+	orig := insert
+	t.Cleanup(func() { insert = orig })
+	var got *store.ChangeEvent
+	insert = func(_ context.Context, row *store.ChangeEvent) (bool, error) {
+		got = row
+		return true, nil
+	}
+
+	// 中文摘要超长时按字符截断，结果仍是合法 UTF-8
+	summary := "a" + strings.Repeat("发布", maxSummaryLength)
+	created, err := Record(context.Background(), Event{Service: " resume-backend ", Kind: "Deploy", Summary: summary, DedupKey: "ci-42"})
+	if err != nil || !created {
+		t.Fatalf("Record = %v, %v", created, err)
+	}
+	if !utf8.ValidString(got.Summary) || utf8.RuneCountInString(got.Summary) != maxSummaryLength {
+		t.Errorf("summary not truncated on a rune boundary: %d runes, valid=%v", utf8.RuneCountInString(got.Summary), utf8.ValidString(got.Summary))
+	}
+	if got.Service != "resume-backend" || got.Kind != KindDeploy || got.Source != SourceAPI || got.DedupKey == nil || *got.DedupKey != "ci-42" {
+		t.Errorf("unexpected row: %+v", got)
+	}
+
+	for _, ev := range []Event{
+		{Kind: KindDeploy},
+		{Service: "api", Kind: "migrate"},
+		{Service: "api", Kind: KindConfig, Metadata: map[string]any{"diff": strings.Repeat("x", maxMetadataLength)}},
+	} {
+		if _, err := Record(context.Background(), ev); err == nil {
+			t.Errorf("Record(%+v) should fail", ev.Kind)
+		}
+	}
+}
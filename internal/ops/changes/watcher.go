@@ -0,0 +1,195 @@
+package changes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/internal/ai/errors"
+)
+
+// Watcher polls the git checkout of RESUME_AGENT_ROOT and pm2 and records a
+// change event for every new commit and process restart it observes.
+// Events are deduplicated by commit sha / process start time, so several
+// replicas can watch the same host.
+type Watcher struct {
+	Root     string // git checkout, e.g. /www/wwwroot/Resume-Agent
+	Service  string // service the git checkout belongs to
+	Interval time.Duration
+
+	lastHead string
+}
+
+// commitInfo is the HEAD commit of a checkout.
+type commitInfo struct {
+	SHA     string
+	Author  string
+	Subject string
+	Time    time.Time
+}
+
+// pm2Process is the part of `pm2 jlist` the watcher uses.
+type pm2Process struct {
+	Name   string `json:"name"`
+	PM2Env struct {
+		Status      string `json:"status"`
+		RestartTime int    `json:"restart_time"`
+		PMUptime    int64  `json:"pm_uptime"` // last start, unix ms
+		ExitCode    *int   `json:"exit_code"`
+	} `json:"pm2_env"`
+}
+
+var globalWatcher *Watcher
+
+// InitWatcher starts the watcher unless OPS_PORTAL_CHANGE_WATCHER=false.
+func InitWatcher(ctx context.Context) {
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("OPS_PORTAL_CHANGE_WATCHER")), "false") {
+		errors.Info("changes", "change watcher disabled by OPS_PORTAL_CHANGE_WATCHER")
+		return
+	}
+	root := os.Getenv("RESUME_AGENT_ROOT")
+	if root == "" {
+		root = "/www/wwwroot/Resume-Agent"
+	}
+	service := os.Getenv("OPS_PORTAL_CHANGE_SERVICE")
+	if service == "" {
+		service = "resume-backend"
+	}
+	globalWatcher = &Watcher{Root: root, Service: service, Interval: time.Minute}
+	go globalWatcher.Run(ctx)
+}
+
+// Run polls until ctx is done.
+func (w *Watcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		w.Poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll checks git and pm2 once.
+func (w *Watcher) Poll(ctx context.Context) {
+	if ev, ok := w.gitEvent(ctx); ok {
+		w.record(ctx, ev)
+	}
+	for _, ev := range w.pm2Events(ctx) {
+		w.record(ctx, ev)
+	}
+}
+
+func (w *Watcher) record(ctx context.Context, ev Event) {
+	created, err := Record(ctx, ev)
+	if err != nil {
+		errors.Warn("changes", fmt.Sprintf("record %s event for %s: %v", ev.Kind, ev.Service, err))
+		return
+	}
+	if created {
+		errors.Info("changes", fmt.Sprintf("recorded %s of %s: %s", ev.Kind, ev.Service, ev.Summary))
+	}
+}
+
+func (w *Watcher) gitEvent(ctx context.Context) (Event, bool) {
+	if _, err := os.Stat(filepath.Join(w.Root, ".git")); err != nil {
+		return Event{}, false
+	}
+	out, err := runWatchCmd(ctx, w.Root, "git", "log", "-1", "--format=%H%x1f%an%x1f%s%x1f%cI")
+	if err != nil {
+		return Event{}, false
+	}
+	c, ok := parseGitLog(out)
+	if !ok || c.SHA == w.lastHead {
+		return Event{}, false
+	}
+	// 启动后第一次看到的提交只能以提交时间为准，之后的变化以观察到的时间为准
+	occurred := c.Time
+	if w.lastHead != "" {
+		occurred = time.Now()
+	}
+	w.lastHead = c.SHA
+	return Event{
+		Service:    w.Service,
+		Kind:       KindCommit,
+		Source:     SourceGitWatcher,
+		Version:    c.SHA,
+		Summary:    fmt.Sprintf("HEAD of %s is now %.8s: %s", w.Root, c.SHA, c.Subject),
+		Author:     c.Author,
+		Metadata:   map[string]any{"root": w.Root, "commit_time": c.Time.UTC().Format(time.RFC3339)},
+		DedupKey:   "git:" + w.Root + ":" + c.SHA,
+		OccurredAt: occurred,
+	}, true
+}
+
+// parseGitLog parses `git log -1 --format=%H%x1f%an%x1f%s%x1f%cI`.
+func parseGitLog(out string) (commitInfo, bool) {
+	parts := strings.Split(strings.TrimSpace(out), "\x1f")
+	if len(parts) != 4 || parts[0] == "" {
+		return commitInfo{}, false
+	}
+	t, err := time.Parse(time.RFC3339, parts[3])
+	if err != nil {
+		t = time.Now()
+	}
+	return commitInfo{SHA: parts[0], Author: parts[1], Subject: parts[2], Time: t}, true
+}
+
+func (w *Watcher) pm2Events(ctx context.Context) []Event {
+	out, err := runWatchCmd(ctx, "", "pm2", "jlist")
+	if err != nil {
+		return nil
+	}
+	return pm2RestartEvents(out)
+}
+
+// pm2RestartEvents turns `pm2 jlist` output into one restart event per
+// process start; the start time makes the event idempotent.
+func pm2RestartEvents(out string) []Event {
+	var procs []pm2Process
+	if err := json.Unmarshal([]byte(out), &procs); err != nil {
+		return nil
+	}
+	events := make([]Event, 0, len(procs))
+	for _, p := range procs {
+		if p.Name == "" || p.PM2Env.PMUptime <= 0 || p.PM2Env.RestartTime == 0 {
+			continue // never restarted since pm2 started it
+		}
+		meta := map[string]any{"restart_count": p.PM2Env.RestartTime, "status": p.PM2Env.Status}
+		if p.PM2Env.ExitCode != nil {
+			meta["last_exit_code"] = *p.PM2Env.ExitCode
+		}
+		events = append(events, Event{
+			Service:    p.Name,
+			Kind:       KindRestart,
+			Source:     SourcePM2Watcher,
+			Summary:    fmt.Sprintf("pm2 restarted %s (restart #%d, status %s)", p.Name, p.PM2Env.RestartTime, p.PM2Env.Status),
+			Metadata:   meta,
+			DedupKey:   fmt.Sprintf("pm2:%s:%d", p.Name, p.PM2Env.PMUptime),
+			OccurredAt: time.UnixMilli(p.PM2Env.PMUptime),
+		})
+	}
+	return events
+}
+
+func runWatchCmd(ctx context.Context, dir, name string, args ...string) (string, error) {
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	cmd := exec.CommandContext(cctx, name, args...)
+	cmd.Dir = dir
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return "", err
+	}
+	return stdout.String(), nil
+}
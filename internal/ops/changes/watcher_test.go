@@ -0,0 +1,37 @@
+package changes
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseGitLog(t *testing.T) {
+	c, ok := parseGitLog("abc123\x1fAlice\x1ffix: retry upstream\x1f2026-03-01T10:00:00+08:00\n")
+	if !ok || c.SHA != "abc123" || c.Author != "Alice" || c.Subject != "fix: retry upstream" {
+		t.Fatalf("unexpected commit: %+v", c)
+	}
+	if !c.Time.Equal(time.Date(2026, 3, 1, 2, 0, 0, 0, time.UTC)) {
+		t.Errorf("time = %v", c.Time)
+	}
+	if _, ok := parseGitLog("fatal: not a git repository"); ok {
+		t.Error("expected parse failure")
+	}
+}
+
+func TestPM2RestartEvents(t *testing.T) {
+	out := `[
+		{"name":"resume-backend","pm2_env":{"status":"online","restart_time":3,"pm_uptime":1772330400000,"exit_code":137}},
+		{"name":"worker","pm2_env":{"status":"online","restart_time":0,"pm_uptime":1772330000000}}
+	]`
+	events := pm2RestartEvents(out)
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1", len(events))
+	}
+	ev := events[0]
+	if ev.Service != "resume-backend" || ev.Kind != KindRestart || ev.DedupKey != "pm2:resume-backend:1772330400000" {
+		t.Fatalf("unexpected event: %+v", ev)
+	}
+	if !ev.OccurredAt.Equal(time.UnixMilli(1772330400000)) || ev.Metadata["last_exit_code"] != 137 {
+		t.Errorf("unexpected event: %+v", ev)
+	}
+}
@@ -23,6 +23,7 @@ type Playbook struct {
 	RequireConfirm bool          `json:"require_confirm"`
 	Enabled        bool          `json:"enabled"`
 	Parameters     []Parameter   `json:"parameters"`
+	Verification   *Verification `json:"verification,omitempty"` // Post-execution checks and rollback
 }
 
 // Parameter is a playbook parameter.
@@ -45,6 +46,11 @@ type ExecutionResult struct {
 	Output      string        `json:"output,omitempty"`
 	Error       string        `json:"error,omitempty"`
 	ExitCode    int           `json:"exit_code,omitempty"`
+
+	// VerificationStatus is "pending", "verified", "regressed" or "error" for
+	// playbooks that declare post-execution checks.
+	VerificationStatus string              `json:"verification_status,omitempty"`
+	Verification       *VerificationReport `json:"verification,omitempty"`
 }
 
 // ExecutionRequest is a request to execute a playbook.
@@ -66,6 +72,8 @@ type AuditLog struct {
 	Status      string         `json:"status"`
 	Timestamp   time.Time      `json:"timestamp"`
 	Duration    time.Duration  `json:"duration"`
+
+	VerificationStatus string `json:"verification_status,omitempty"`
 }
 
 // Executor executes playbooks safely.
@@ -103,6 +111,28 @@ func (e *Executor) registerStandardPlaybooks() {
 			Parameters: []Parameter{
 				{Name: "service_name", Type: "string", Required: true, Description: "服务名称"},
 			},
+			Verification: &Verification{
+				Settle: 60 * time.Second,
+				Checks: []VerificationCheck{
+					{
+						Name:       "service_up",
+						Type:       CheckPromQL,
+						Query:      `min(up{job="{service_name}"})`,
+						Comparator: "==",
+						Threshold:  1,
+						AllowEmpty: true,
+					},
+					{
+						Name:       "error_logs",
+						Type:       CheckLokiErrorRate,
+						Query:      `{job="{service_name}"} |~ "(?i)error|panic"`,
+						Window:     time.Minute,
+						Comparator: "<=",
+						Threshold:  50,
+						AllowEmpty: true,
+					},
+				},
+			},
 		},
 		{
 			ID:             "clear-cache",
@@ -229,7 +259,6 @@ func (e *Executor) Execute(ctx context.Context, req *ExecutionRequest) (*Executi
 		Status:      "pending",
 		StartTime:   time.Now(),
 	}
-	e.executions[executionID] = result
 
 	// Log audit entry
 	audit := AuditLog{
@@ -241,7 +270,10 @@ func (e *Executor) Execute(ctx context.Context, req *ExecutionRequest) (*Executi
 		Status:      "pending",
 		Timestamp:   time.Now(),
 	}
+	// Scheduled runs execute concurrently with API requests, so both maps are
+	// only touched under the lock.
 	e.mu.Lock()
+	e.executions[executionID] = result
 	e.auditLog = append(e.auditLog, audit)
 	e.mu.Unlock()
 
@@ -305,6 +337,10 @@ func (e *Executor) executePlaybook(ctx context.Context, pb *Playbook, req *Execu
 		result.ExitCode = 0
 	}
 
+	if result.Status == "success" && pb.Verification != nil && len(pb.Verification.Checks) > 0 {
+		e.scheduleVerification(pb, req, result)
+	}
+
 	// Update audit log
 	e.mu.Lock()
 	for i, log := range e.auditLog {
@@ -325,7 +361,12 @@ func (e *Executor) GetExecution(executionID string) (*ExecutionResult, bool) {
 	defer e.mu.RUnlock()
 
 	result, ok := e.executions[executionID]
-	return result, ok
+	if !ok {
+		return nil, false
+	}
+	// Verification updates the stored result in the background; hand out a copy.
+	cp := *result
+	return &cp, true
 }
 
 // GetAuditLog returns the audit log.
@@ -0,0 +1,307 @@
+package playbook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/internal/ai/errors"
+	"github.com/WyRainBow/ops-portal/internal/store"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// schedulerLeaseName is the lease row shared by all portal replicas.
+const schedulerLeaseName = "playbook-scheduler"
+
+// cronParser accepts standard 5-field expressions and descriptors like @daily.
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// ParseSchedule validates a cron expression and timezone.
+func ParseSchedule(expr, timezone string) (cron.Schedule, *time.Location, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, nil, fmt.Errorf("cron expression is empty")
+	}
+	if strings.HasPrefix(expr, "TZ=") || strings.HasPrefix(expr, "CRON_TZ=") {
+		return nil, nil, fmt.Errorf("use the timezone field instead of a TZ= prefix")
+	}
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+	}
+	sched, err := cronParser.Parse(expr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+	return sched, loc, nil
+}
+
+// NextRun returns the next activation strictly after from, evaluated in the
+// schedule's timezone.
+func NextRun(expr, timezone string, from time.Time) (time.Time, error) {
+	sched, loc, err := ParseSchedule(expr, timezone)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return sched.Next(from.In(loc)).UTC(), nil
+}
+
+// ValidateParameters checks that every required parameter is provided.
+func (pb *Playbook) ValidateParameters(params map[string]any) error {
+	missing := make([]string, 0)
+	for _, p := range pb.Parameters {
+		if !p.Required {
+			continue
+		}
+		if v, ok := params[p.Name]; !ok || fmt.Sprintf("%v", v) == "" {
+			missing = append(missing, p.Name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required parameters: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Scheduler runs persisted playbook schedules. Only the replica holding the
+// scheduler lease triggers executions.
+type Scheduler struct {
+	executor   *Executor
+	instanceID string
+	interval   time.Duration
+	leaseTTL   time.Duration
+
+	mu       sync.Mutex
+	isLeader bool
+	cancel   context.CancelFunc
+}
+
+// NewScheduler creates a scheduler bound to the given executor.
+func NewScheduler(executor *Executor) *Scheduler {
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "ops-portal"
+	}
+	return &Scheduler{
+		executor:   executor,
+		instanceID: fmt.Sprintf("%s-%d", host, os.Getpid()),
+		interval:   15 * time.Second,
+		leaseTTL:   45 * time.Second,
+	}
+}
+
+// Start launches the scheduling loop in the background.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.mu.Unlock()
+		return
+	}
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.mu.Unlock()
+
+	go s.loop(ctx)
+	errors.Info("scheduler", fmt.Sprintf("playbook scheduler started: instance=%s", s.instanceID))
+}
+
+// Stop stops the scheduling loop.
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+		s.cancel = nil
+	}
+}
+
+// IsLeader reports whether this replica currently holds the scheduler lease.
+func (s *Scheduler) IsLeader() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.isLeader
+}
+
+// InstanceID returns the identifier used as lease holder.
+func (s *Scheduler) InstanceID() string {
+	return s.instanceID
+}
+
+func (s *Scheduler) loop(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.tick(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) tick(ctx context.Context) {
+	db, err := store.DB(ctx)
+	if err != nil {
+		errors.Warn("scheduler", "db unavailable: "+err.Error())
+		return
+	}
+
+	leader, err := s.acquireLease(ctx, db)
+	if err != nil {
+		errors.Warn("scheduler", "lease acquisition failed: "+err.Error())
+	}
+	s.mu.Lock()
+	changed := s.isLeader != leader
+	s.isLeader = leader
+	s.mu.Unlock()
+	if changed {
+		errors.Info("scheduler", fmt.Sprintf("leadership changed: instance=%s leader=%v", s.instanceID, leader))
+	}
+	if !leader {
+		return
+	}
+
+	now := time.Now().UTC()
+	var due []store.PlaybookSchedule
+	if err := db.WithContext(ctx).
+		Where("enabled = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", true, now).
+		Order("next_run_at ASC").
+		Find(&due).Error; err != nil {
+		errors.Error("scheduler", "load due schedules failed", err)
+		return
+	}
+
+	for i := range due {
+		sch := due[i]
+		if !s.claim(ctx, db, &sch, now) {
+			continue
+		}
+		go s.run(context.Background(), sch)
+	}
+}
+
+// acquireLease takes or renews the scheduler lease. The upsert only succeeds
+// when the lease is free, expired, or already held by this instance.
+func (s *Scheduler) acquireLease(ctx context.Context, db *gorm.DB) (bool, error) {
+	now := time.Now().UTC()
+	lease := store.SchedulerLease{
+		Name:      schedulerLeaseName,
+		Holder:    s.instanceID,
+		ExpiresAt: now.Add(s.leaseTTL),
+	}
+	res := db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "name"}},
+		Where: clause.Where{Exprs: []clause.Expression{
+			clause.Or(
+				clause.Eq{Column: clause.Column{Table: "ops_scheduler_leases", Name: "holder"}, Value: s.instanceID},
+				clause.Lt{Column: clause.Column{Table: "ops_scheduler_leases", Name: "expires_at"}, Value: now},
+			),
+		}},
+		DoUpdates: clause.AssignmentColumns([]string{"holder", "expires_at"}),
+	}).Create(&lease)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// claim advances next_run_at with an optimistic check so that a schedule is
+// triggered at most once per activation, even across a leadership handover.
+func (s *Scheduler) claim(ctx context.Context, db *gorm.DB, sch *store.PlaybookSchedule, now time.Time) bool {
+	next, err := NextRun(sch.CronExpr, sch.Timezone, now)
+	if err != nil {
+		errors.Error("scheduler", fmt.Sprintf("schedule %d has invalid cron, disabling", sch.ID), err)
+		db.WithContext(ctx).Model(&store.PlaybookSchedule{}).Where("id = ?", sch.ID).Updates(map[string]any{
+			"enabled":    false,
+			"updated_at": now,
+		})
+		return false
+	}
+	res := db.WithContext(ctx).Model(&store.PlaybookSchedule{}).
+		Where("id = ? AND next_run_at = ?", sch.ID, sch.NextRunAt).
+		Updates(map[string]any{
+			"next_run_at": next,
+			"last_run_at": now,
+			"updated_at":  now,
+		})
+	if res.Error != nil {
+		errors.Error("scheduler", fmt.Sprintf("claim schedule %d failed", sch.ID), res.Error)
+		return false
+	}
+	return res.RowsAffected == 1
+}
+
+// run executes a claimed schedule through the regular executor so that the
+// result shows up in execution history and the audit log.
+func (s *Scheduler) run(ctx context.Context, sch store.PlaybookSchedule) {
+	params := map[string]any{}
+	if len(sch.Parameters) > 0 {
+		_ = json.Unmarshal(sch.Parameters, &params)
+	}
+
+	req := &ExecutionRequest{
+		PlaybookID:  sch.PlaybookID,
+		Parameters:  params,
+		Reason:      fmt.Sprintf("scheduled run: %s (schedule #%d, cron %q %s)", sch.Name, sch.ID, sch.CronExpr, sch.Timezone),
+		RequestedBy: fmt.Sprintf("scheduler:%d", sch.ID),
+	}
+
+	status := "failed"
+	executionID := ""
+	result, err := s.executor.Execute(ctx, req)
+	if result != nil {
+		status = result.Status
+		executionID = result.ExecutionID
+	}
+	if err != nil {
+		errors.Error("scheduler", fmt.Sprintf("schedule %d execution failed", sch.ID), err)
+	} else {
+		errors.Info("scheduler", fmt.Sprintf("schedule %d executed: playbook=%s execution=%s status=%s",
+			sch.ID, sch.PlaybookID, executionID, status))
+	}
+
+	db, dbErr := store.DB(ctx)
+	if dbErr != nil {
+		return
+	}
+	updates := map[string]any{
+		"last_status": status,
+		"updated_at":  time.Now().UTC(),
+	}
+	if executionID != "" {
+		updates["last_execution_id"] = executionID
+	}
+	db.WithContext(ctx).Model(&store.PlaybookSchedule{}).Where("id = ?", sch.ID).Updates(updates)
+}
+
+// Global scheduler instance.
+var globalScheduler *Scheduler
+
+// InitScheduler initializes and starts the global scheduler.
+// Set OPS_PORTAL_SCHEDULER_ENABLED=false to keep a replica out of the election.
+func InitScheduler(ctx context.Context) {
+	if globalExecutor == nil {
+		InitExecutor()
+	}
+	globalScheduler = NewScheduler(globalExecutor)
+	if strings.EqualFold(strings.TrimSpace(os.Getenv("OPS_PORTAL_SCHEDULER_ENABLED")), "false") {
+		errors.Info("scheduler", "playbook scheduler disabled by OPS_PORTAL_SCHEDULER_ENABLED")
+		return
+	}
+	globalScheduler.Start(ctx)
+}
+
+// GlobalScheduler returns the global scheduler.
+func GlobalScheduler() *Scheduler {
+	return globalScheduler
+}
@@ -0,0 +1,66 @@
+package playbook
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextRunUsesTimezone(t *testing.T) {
+	// 2026-03-01 00:00 UTC is 08:00 in Asia/Shanghai.
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	next, err := NextRun("0 3 * * *", "Asia/Shanghai", from)
+	if err != nil {
+		t.Fatalf("NextRun returned error: %v", err)
+	}
+	// Next 03:00 Shanghai time is 2026-03-01 19:00 UTC.
+	want := time.Date(2026, 3, 1, 19, 0, 0, 0, time.UTC)
+	if !next.Equal(want) {
+		t.Errorf("NextRun = %v, want %v", next, want)
+	}
+}
+
+func TestNextRunDefaultsToUTC(t *testing.T) {
+	from := time.Date(2026, 3, 1, 8, 30, 0, 0, time.UTC)
+
+	next, err := NextRun("@daily", "", from)
+	if err != nil {
+		t.Fatalf("NextRun returned error: %v", err)
+	}
+	want := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	if !next.Equal(want) {
+		t.Errorf("NextRun = %v, want %v", next, want)
+	}
+}
+
+func TestParseScheduleRejectsInvalidInput(t *testing.T) {
+	cases := []struct {
+		expr string
+		tz   string
+	}{
+		{"", "UTC"},
+		{"not a cron", "UTC"},
+		{"0 3 * * *", "Mars/Olympus"},
+		{"CRON_TZ=Asia/Shanghai 0 3 * * *", "UTC"},
+	}
+	for _, c := range cases {
+		if _, _, err := ParseSchedule(c.expr, c.tz); err == nil {
+			t.Errorf("ParseSchedule(%q, %q) should fail", c.expr, c.tz)
+		}
+	}
+}
+
+func TestValidateParameters(t *testing.T) {
+	e := NewExecutor()
+	pb, ok := e.Get("restart-service")
+	if !ok {
+		t.Fatal("restart-service playbook should be registered")
+	}
+
+	if err := pb.ValidateParameters(map[string]any{}); err == nil {
+		t.Error("expected error for missing service_name")
+	}
+	if err := pb.ValidateParameters(map[string]any{"service_name": "nginx"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,372 @@
+package playbook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/WyRainBow/ops-portal/internal/ai/errors"
+)
+
+// Verification check types.
+const (
+	CheckPromQL        = "promql"
+	CheckHTTP          = "http"
+	CheckLokiErrorRate = "loki_error_rate"
+)
+
+// Verification statuses recorded on an execution.
+const (
+	VerificationPending   = "pending"
+	VerificationVerified  = "verified"
+	VerificationRegressed = "regressed"
+	VerificationError     = "error"
+)
+
+// Verification declares how to check that a playbook run actually helped.
+// Queries and URLs may use the same {param} placeholders as the command.
+type Verification struct {
+	Settle             time.Duration       `json:"settle"` // Wait before evaluating checks
+	Checks             []VerificationCheck `json:"checks"`
+	RollbackPlaybookID string              `json:"rollback_playbook_id,omitempty"`
+	RollbackParameters map[string]any      `json:"rollback_parameters,omitempty"` // Defaults to the original parameters
+}
+
+// VerificationCheck is a single post-execution check.
+type VerificationCheck struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // "promql", "http", "loki_error_rate"
+
+	// promql / loki_error_rate: the query must evaluate to samples that
+	// satisfy "<value> <comparator> <threshold>".
+	Query      string        `json:"query,omitempty"`
+	Comparator string        `json:"comparator,omitempty"` // "<", "<=", ">", ">=", "==", "!="
+	Threshold  float64       `json:"threshold,omitempty"`
+	AllowEmpty bool          `json:"allow_empty,omitempty"` // Treat an empty result as passing
+	Window     time.Duration `json:"window,omitempty"`      // loki_error_rate: range for a bare log selector
+
+	// http: GET the URL and expect the status code (any 2xx when zero).
+	URL          string `json:"url,omitempty"`
+	ExpectStatus int    `json:"expect_status,omitempty"`
+}
+
+// CheckResult is the outcome of a single verification check.
+type CheckResult struct {
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	Passed    bool      `json:"passed"`
+	Observed  string    `json:"observed,omitempty"`
+	Expected  string    `json:"expected,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// VerificationReport is attached to an execution once checks ran.
+type VerificationReport struct {
+	Status              string        `json:"status"`
+	Checks              []CheckResult `json:"checks"`
+	StartedAt           time.Time     `json:"started_at"`
+	FinishedAt          time.Time     `json:"finished_at,omitempty"`
+	RollbackPlaybookID  string        `json:"rollback_playbook_id,omitempty"`
+	RollbackExecutionID string        `json:"rollback_execution_id,omitempty"`
+	RollbackError       string        `json:"rollback_error,omitempty"`
+}
+
+// expand replaces {param} placeholders with execution parameters.
+func expand(s string, params map[string]any) string {
+	for key, value := range params {
+		s = strings.ReplaceAll(s, fmt.Sprintf("{%s}", key), fmt.Sprintf("%v", value))
+	}
+	return s
+}
+
+// scheduleVerification marks the execution as pending verification and runs
+// the checks in the background after the settle period.
+func (e *Executor) scheduleVerification(pb *Playbook, req *ExecutionRequest, result *ExecutionResult) {
+	e.mu.Lock()
+	result.VerificationStatus = VerificationPending
+	result.Verification = &VerificationReport{Status: VerificationPending}
+	e.mu.Unlock()
+
+	go func() {
+		if pb.Verification.Settle > 0 {
+			time.Sleep(pb.Verification.Settle)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		defer cancel()
+		e.verify(ctx, pb, req, result)
+	}()
+}
+
+// verify evaluates all checks, records the outcome and triggers the declared
+// rollback playbook on regression.
+func (e *Executor) verify(ctx context.Context, pb *Playbook, req *ExecutionRequest, result *ExecutionResult) {
+	report := &VerificationReport{
+		Status:    VerificationVerified,
+		StartedAt: time.Now(),
+	}
+	for _, check := range pb.Verification.Checks {
+		cr := runCheck(ctx, check, req.Parameters)
+		report.Checks = append(report.Checks, cr)
+		if cr.Error != "" && report.Status == VerificationVerified {
+			report.Status = VerificationError
+		}
+		if !cr.Passed && cr.Error == "" {
+			report.Status = VerificationRegressed
+		}
+	}
+	report.FinishedAt = time.Now()
+
+	errors.Info("playbook", fmt.Sprintf("verification for %s (%s): %s", result.ExecutionID, pb.ID, report.Status))
+
+	if report.Status == VerificationRegressed && pb.Verification.RollbackPlaybookID != "" {
+		report.RollbackPlaybookID = pb.Verification.RollbackPlaybookID
+		params := pb.Verification.RollbackParameters
+		if params == nil {
+			params = req.Parameters
+		}
+		rb, err := e.Execute(ctx, &ExecutionRequest{
+			PlaybookID:  pb.Verification.RollbackPlaybookID,
+			Parameters:  params,
+			Reason:      fmt.Sprintf("automatic rollback: %s regressed after %s", pb.ID, result.ExecutionID),
+			RequestedBy: "verifier:" + result.ExecutionID,
+		})
+		if rb != nil {
+			report.RollbackExecutionID = rb.ExecutionID
+		}
+		if err != nil {
+			report.RollbackError = err.Error()
+			errors.Error("playbook", "rollback failed for "+result.ExecutionID, err)
+		}
+	}
+
+	e.mu.Lock()
+	result.VerificationStatus = report.Status
+	result.Verification = report
+	for i, log := range e.auditLog {
+		if log.ExecutionID == result.ExecutionID {
+			e.auditLog[i].VerificationStatus = report.Status
+			break
+		}
+	}
+	e.mu.Unlock()
+}
+
+func runCheck(ctx context.Context, check VerificationCheck, params map[string]any) CheckResult {
+	cr := CheckResult{Name: check.Name, Type: check.Type}
+	if cr.Name == "" {
+		cr.Name = check.Type
+	}
+	switch check.Type {
+	case CheckPromQL:
+		query := expand(check.Query, params)
+		values, err := promInstantValues(ctx, query)
+		evaluateSamples(&cr, check, values, err)
+	case CheckLokiErrorRate:
+		query := expand(check.Query, params)
+		if strings.HasPrefix(strings.TrimSpace(query), "{") {
+			window := check.Window
+			if window <= 0 {
+				window = 5 * time.Minute
+			}
+			query = fmt.Sprintf("sum(count_over_time(%s [%ds]))", query, int(window.Seconds()))
+		}
+		values, err := lokiInstantValues(ctx, query)
+		evaluateSamples(&cr, check, values, err)
+	case CheckHTTP:
+		status, err := httpStatus(ctx, expand(check.URL, params))
+		if check.ExpectStatus > 0 {
+			cr.Expected = fmt.Sprintf("status == %d", check.ExpectStatus)
+		} else {
+			cr.Expected = "status 2xx"
+		}
+		if err != nil {
+			cr.Error = err.Error()
+			break
+		}
+		cr.Observed = strconv.Itoa(status)
+		if check.ExpectStatus > 0 {
+			cr.Passed = status == check.ExpectStatus
+		} else {
+			cr.Passed = status/100 == 2
+		}
+	default:
+		cr.Error = "unknown check type: " + check.Type
+	}
+	cr.CheckedAt = time.Now()
+	return cr
+}
+
+func evaluateSamples(cr *CheckResult, check VerificationCheck, values []float64, err error) {
+	comparator := check.Comparator
+	if comparator == "" {
+		comparator = "<="
+	}
+	cr.Expected = fmt.Sprintf("value %s %g", comparator, check.Threshold)
+	if err != nil {
+		cr.Error = err.Error()
+		return
+	}
+	if len(values) == 0 {
+		cr.Observed = "no data"
+		cr.Passed = check.AllowEmpty
+		return
+	}
+	observed := make([]string, 0, len(values))
+	cr.Passed = true
+	for _, v := range values {
+		observed = append(observed, strconv.FormatFloat(v, 'g', -1, 64))
+		ok, cmpErr := compare(v, comparator, check.Threshold)
+		if cmpErr != nil {
+			cr.Error = cmpErr.Error()
+			cr.Passed = false
+			return
+		}
+		if !ok {
+			cr.Passed = false
+		}
+	}
+	cr.Observed = strings.Join(observed, ",")
+}
+
+func compare(v float64, comparator string, threshold float64) (bool, error) {
+	switch comparator {
+	case "<":
+		return v < threshold, nil
+	case "<=":
+		return v <= threshold, nil
+	case ">":
+		return v > threshold, nil
+	case ">=":
+		return v >= threshold, nil
+	case "==":
+		return v == threshold, nil
+	case "!=":
+		return v != threshold, nil
+	default:
+		return false, fmt.Errorf("unknown comparator: %s", comparator)
+	}
+}
+
+var verifyHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+func promInstantValues(ctx context.Context, query string) ([]float64, error) {
+	base := os.Getenv("OBS_PROM_URL")
+	if base == "" {
+		base = "http://127.0.0.1:9090"
+	}
+	return instantValues(ctx, base+"/api/v1/query", query)
+}
+
+func lokiInstantValues(ctx context.Context, query string) ([]float64, error) {
+	base := os.Getenv("OBS_LOKI_URL")
+	if base == "" {
+		base = "http://127.0.0.1:3100"
+	}
+	return instantValues(ctx, base+"/loki/api/v1/query", query)
+}
+
+// instantValues runs an instant query against a Prometheus-compatible API
+// (Prometheus or Loki) and returns the sample values of a vector or scalar.
+func instantValues(ctx context.Context, endpoint, query string) ([]float64, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	q := u.Query()
+	q.Set("query", query)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := verifyHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4*1024*1024))
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("query failed: status=%d body=%s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var parsed struct {
+		Status string `json:"status"`
+		Data   struct {
+			ResultType string          `json:"resultType"`
+			Result     json.RawMessage `json:"result"`
+		} `json:"data"`
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return nil, fmt.Errorf("parse response failed: %w", err)
+	}
+	if parsed.Status != "success" {
+		return nil, fmt.Errorf("query failed: %s", parsed.Error)
+	}
+
+	switch parsed.Data.ResultType {
+	case "scalar":
+		var pair []any
+		if err := json.Unmarshal(parsed.Data.Result, &pair); err != nil {
+			return nil, err
+		}
+		v, err := sampleValue(pair)
+		if err != nil {
+			return nil, err
+		}
+		return []float64{v}, nil
+	case "vector":
+		var vec []struct {
+			Value []any `json:"value"`
+		}
+		if err := json.Unmarshal(parsed.Data.Result, &vec); err != nil {
+			return nil, err
+		}
+		out := make([]float64, 0, len(vec))
+		for _, s := range vec {
+			v, err := sampleValue(s.Value)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unsupported result type: %s", parsed.Data.ResultType)
+	}
+}
+
+// sampleValue decodes a [<ts>, "<value>"] pair.
+func sampleValue(pair []any) (float64, error) {
+	if len(pair) < 2 {
+		return 0, fmt.Errorf("malformed sample")
+	}
+	s, ok := pair[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("malformed sample value")
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func httpStatus(ctx context.Context, target string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := verifyHTTPClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1024*1024))
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,64 @@
+package playbook
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVerifyRegressionTriggersRollback(t *testing.T) {
+	prom := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.URL.Query().Get("query"); got != `up{job="api"}` {
+			t.Errorf("unexpected query %q", got)
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{},"value":[1700000000,"0"]}]}}`))
+	}))
+	defer prom.Close()
+	t.Setenv("OBS_PROM_URL", prom.URL)
+
+	e := NewExecutor()
+	if err := e.Register(&Playbook{ID: "undo", Command: "true", Timeout: 5 * time.Second, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	pb := &Playbook{
+		ID: "deploy",
+		Verification: &Verification{
+			Checks:             []VerificationCheck{{Type: CheckPromQL, Query: `up{job="{service}"}`, Comparator: "==", Threshold: 1}},
+			RollbackPlaybookID: "undo",
+		},
+	}
+	result := &ExecutionResult{ExecutionID: "EXEC-1", Status: "success"}
+	e.executions[result.ExecutionID] = result
+
+	e.verify(context.Background(), pb, &ExecutionRequest{Parameters: map[string]any{"service": "api"}}, result)
+
+	got, _ := e.GetExecution("EXEC-1")
+	if got.VerificationStatus != VerificationRegressed {
+		t.Fatalf("status = %q, want %q", got.VerificationStatus, VerificationRegressed)
+	}
+	if got.Verification.RollbackExecutionID == "" {
+		t.Fatalf("rollback was not triggered: %+v", got.Verification)
+	}
+	rb, ok := e.GetExecution(got.Verification.RollbackExecutionID)
+	if !ok || rb.PlaybookID != "undo" {
+		t.Errorf("unexpected rollback execution: %+v", rb)
+	}
+}
+
+func TestHTTPCheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	cr := runCheck(context.Background(), VerificationCheck{Type: CheckHTTP, URL: srv.URL}, nil)
+	if cr.Passed || cr.Observed != "503" {
+		t.Errorf("expected failed check with 503, got %+v", cr)
+	}
+	cr = runCheck(context.Background(), VerificationCheck{Type: CheckHTTP, URL: srv.URL, ExpectStatus: 503}, nil)
+	if !cr.Passed {
+		t.Errorf("expected check to pass, got %+v", cr)
+	}
+}
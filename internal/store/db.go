@@ -77,3 +77,29 @@ func DB(ctx context.Context) (*gorm.DB, error) {
 	})
 	return dbInst, dbErr
 }
+
+// migrateModels lists the tables owned by ops-portal itself. Tables shared with
+// Resume-Agent (users, members, api_*_logs, ...) are managed there and must not
+// be migrated here.
+var migrateModels = []any{
+	&PlaybookSchedule{},
+	&SchedulerLease{},
+	&ToolOverride{},
+	&HostCommandAudit{},
+	&ChangeEvent{},
+	&LLMUsageDaily{},
+	&ChatSession{},
+	&ChatMessage{},
+	&PromptTemplate{},
+	&AgentRun{},
+	&AgentRunStep{},
+}
+
+// AutoMigrate creates or updates the ops-portal owned tables.
+func AutoMigrate(ctx context.Context) error {
+	db, err := DB(ctx)
+	if err != nil {
+		return err
+	}
+	return db.WithContext(ctx).AutoMigrate(migrateModels...)
+}
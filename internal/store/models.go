@@ -86,3 +86,185 @@ type PermissionAuditLog struct {
 }
 
 func (PermissionAuditLog) TableName() string { return "permission_audit_logs" }
+
+// PlaybookSchedule is a recurring playbook execution owned by ops-portal.
+type PlaybookSchedule struct {
+	ID              int64      `gorm:"column:id;primaryKey;autoIncrement"`
+	Name            string     `gorm:"column:name"`
+	PlaybookID      string     `gorm:"column:playbook_id;index"`
+	CronExpr        string     `gorm:"column:cron_expr"`
+	Timezone        string     `gorm:"column:timezone"`
+	Parameters      []byte     `gorm:"column:parameters;type:jsonb"` // JSONB: fixed parameters passed to every run
+	Enabled         bool       `gorm:"column:enabled"`
+	CreatedBy       string     `gorm:"column:created_by"`
+	NextRunAt       *time.Time `gorm:"column:next_run_at;index"`
+	LastRunAt       *time.Time `gorm:"column:last_run_at"`
+	LastExecutionID *string    `gorm:"column:last_execution_id"`
+	LastStatus      *string    `gorm:"column:last_status"`
+	CreatedAt       *time.Time `gorm:"column:created_at"`
+	UpdatedAt       *time.Time `gorm:"column:updated_at"`
+}
+
+func (PlaybookSchedule) TableName() string { return "ops_playbook_schedules" }
+
+// SchedulerLease is a row-level lease used to elect a single scheduler leader
+// across portal replicas.
+type SchedulerLease struct {
+	Name      string    `gorm:"column:name;primaryKey"`
+	Holder    string    `gorm:"column:holder"`
+	ExpiresAt time.Time `gorm:"column:expires_at"`
+}
+
+func (SchedulerLease) TableName() string { return "ops_scheduler_leases" }
+
+// ToolOverride persists admin changes to a registered AI tool (enable state,
+// agent assignment) so they survive restarts.
+type ToolOverride struct {
+	Name       string     `gorm:"column:name;primaryKey"`
+	Enabled    *bool      `gorm:"column:enabled"`
+	AgentTypes []byte     `gorm:"column:agent_types;type:jsonb"` // JSONB: []string, NULL keeps the registered default
+	UpdatedBy  string     `gorm:"column:updated_by"`
+	UpdatedAt  *time.Time `gorm:"column:updated_at"`
+}
+
+func (ToolOverride) TableName() string { return "ops_tool_overrides" }
+
+// HostCommandAudit records every diagnostic command the agents ran on a host.
+type HostCommandAudit struct {
+	ID          int64      `gorm:"column:id;primaryKey;autoIncrement"`
+	Host        string     `gorm:"column:host;index"`
+	Command     string     `gorm:"column:command"`
+	Argv        string     `gorm:"column:argv"`
+	Username    string     `gorm:"column:username"`
+	RunID       string     `gorm:"column:run_id;index"`
+	ExitCode    int        `gorm:"column:exit_code"`
+	DurationMs  float64    `gorm:"column:duration_ms"`
+	OutputBytes int        `gorm:"column:output_bytes"`
+	Error       string     `gorm:"column:error"`
+	CreatedAt   *time.Time `gorm:"column:created_at;index"`
+}
+
+func (HostCommandAudit) TableName() string { return "ops_host_command_audits" }
+
+// ChangeEvent is a deploy, config change, commit or restart that may explain
+// an incident. DedupKey makes automatically captured events idempotent.
+type ChangeEvent struct {
+	ID         int64      `gorm:"column:id;primaryKey;autoIncrement"`
+	Service    string     `gorm:"column:service;index:idx_change_service_time,priority:1"`
+	Kind       string     `gorm:"column:kind"`   // deploy, config, commit, restart, rollback
+	Source     string     `gorm:"column:source"` // api, git_watcher, pm2_watcher
+	Version    string     `gorm:"column:version"`
+	Summary    string     `gorm:"column:summary"`
+	Author     string     `gorm:"column:author"`
+	URL        string     `gorm:"column:url"`
+	Metadata   []byte     `gorm:"column:metadata;type:jsonb"` // JSONB: free-form details
+	DedupKey   *string    `gorm:"column:dedup_key;uniqueIndex"`
+	OccurredAt time.Time  `gorm:"column:occurred_at;index:idx_change_service_time,priority:2"`
+	CreatedAt  *time.Time `gorm:"column:created_at"`
+}
+
+func (ChangeEvent) TableName() string { return "ops_change_events" }
+
+// LLMUsageDaily aggregates LLM token usage per UTC day, subject and model.
+// Subject is "user:<id>", "incident:<id>", "cli:<name>" or "system".
+type LLMUsageDaily struct {
+	ID               int64      `gorm:"column:id;primaryKey;autoIncrement"`
+	Day              time.Time  `gorm:"column:day;type:date;uniqueIndex:idx_llm_usage_day_subject_model,priority:1"`
+	Subject          string     `gorm:"column:subject;uniqueIndex:idx_llm_usage_day_subject_model,priority:2"`
+	Model            string     `gorm:"column:model;uniqueIndex:idx_llm_usage_day_subject_model,priority:3"`
+	UserID           *int64     `gorm:"column:user_id;index"`
+	Requests         int64      `gorm:"column:requests"`
+	PromptTokens     int64      `gorm:"column:prompt_tokens"`
+	CompletionTokens int64      `gorm:"column:completion_tokens"`
+	TotalTokens      int64      `gorm:"column:total_tokens"`
+	CostUSD          float64    `gorm:"column:cost_usd"`
+	UpdatedAt        *time.Time `gorm:"column:updated_at"`
+}
+
+func (LLMUsageDaily) TableName() string { return "ops_llm_usage_daily" }
+
+// ChatSession is a conversation owned by a user. Summary condenses the
+// messages up to SummarizedUntil (message id) so that only later turns are
+// sent to the model verbatim.
+type ChatSession struct {
+	ID              string     `gorm:"column:id;primaryKey"`
+	UserID          int64      `gorm:"column:user_id;index:idx_chat_session_user_updated,priority:1"`
+	Title           string     `gorm:"column:title"`
+	Summary         string     `gorm:"column:summary;type:text"`
+	SummarizedUntil int64      `gorm:"column:summarized_until"`
+	MessageCount    int64      `gorm:"column:message_count"`
+	CreatedAt       *time.Time `gorm:"column:created_at"`
+	UpdatedAt       *time.Time `gorm:"column:updated_at;index:idx_chat_session_user_updated,priority:2"`
+}
+
+func (ChatSession) TableName() string { return "ops_chat_sessions" }
+
+// ChatMessage is one turn of a ChatSession; Role is user or assistant.
+type ChatMessage struct {
+	ID        int64      `gorm:"column:id;primaryKey;autoIncrement"`
+	SessionID string     `gorm:"column:session_id;index"`
+	Role      string     `gorm:"column:role"`
+	Content   string     `gorm:"column:content;type:text"`
+	Tokens    int        `gorm:"column:tokens"`
+	CreatedAt *time.Time `gorm:"column:created_at"`
+}
+
+func (ChatMessage) TableName() string { return "ops_chat_messages" }
+
+// PromptTemplate is one version of an admin-edited prompt template. The
+// latest version of (Kind, Key) is in effect unless it is disabled; editing
+// inserts a new version so earlier ones stay available for rollback.
+type PromptTemplate struct {
+	ID          int64      `gorm:"column:id;primaryKey;autoIncrement"`
+	Kind        string     `gorm:"column:kind;uniqueIndex:idx_prompt_kind_key_version,priority:1"` // diagnosis, aiops, chat_system
+	Key         string     `gorm:"column:key;uniqueIndex:idx_prompt_kind_key_version,priority:2"`
+	Version     int        `gorm:"column:version;uniqueIndex:idx_prompt_kind_key_version,priority:3"`
+	Description string     `gorm:"column:description"`
+	Match       []byte     `gorm:"column:match;type:jsonb"` // JSONB: alertnames / labels selector
+	Priority    int        `gorm:"column:priority"`
+	Body        string     `gorm:"column:body;type:text"`
+	Disabled    bool       `gorm:"column:disabled"`
+	CreatedBy   string     `gorm:"column:created_by"`
+	CreatedAt   *time.Time `gorm:"column:created_at"`
+}
+
+func (PromptTemplate) TableName() string { return "ops_prompt_templates" }
+
+// AgentRun is a recorded plan-execute-replan run. ReplayOf is set on runs
+// replayed from an earlier recording.
+type AgentRun struct {
+	ID         string     `gorm:"column:id;primaryKey"`
+	Kind       string     `gorm:"column:kind;index"` // diagnosis, aiops, chat_deep, cli, ...
+	Subject    string     `gorm:"column:subject;index"`
+	ReplayOf   string     `gorm:"column:replay_of;index"`
+	Status     string     `gorm:"column:status"` // succeeded, failed
+	Input      string     `gorm:"column:input;type:text"`  // JSON: []*schema.Message
+	Tools      []byte     `gorm:"column:tools;type:jsonb"` // JSON: []*schema.ToolInfo offered to the executor
+	Output     string     `gorm:"column:output;type:text"`
+	Error      string     `gorm:"column:error;type:text"`
+	Steps      int        `gorm:"column:steps"`
+	DurationMs int64      `gorm:"column:duration_ms"`
+	StartedAt  *time.Time `gorm:"column:started_at;index"`
+	FinishedAt *time.Time `gorm:"column:finished_at"`
+}
+
+func (AgentRun) TableName() string { return "ops_agent_runs" }
+
+// AgentRunStep is a model call, tool call or agent event of an AgentRun.
+type AgentRunStep struct {
+	ID         int64      `gorm:"column:id;primaryKey;autoIncrement"`
+	RunID      string     `gorm:"column:run_id;index"`
+	Seq        int        `gorm:"column:seq"`
+	Type       string     `gorm:"column:type"`  // model, tool, event
+	Agent      string     `gorm:"column:agent"` // model role or ADK agent name
+	Name       string     `gorm:"column:name"`  // tool name, message role
+	CallID     string     `gorm:"column:call_id"`
+	Input      string     `gorm:"column:input;type:text"`
+	Output     string     `gorm:"column:output;type:text"`
+	Error      string     `gorm:"column:error;type:text"`
+	DurationMs int64      `gorm:"column:duration_ms"`
+	StartedAt  *time.Time `gorm:"column:started_at"`
+	Truncated  bool       `gorm:"column:truncated"` // input or output was clipped
+}
+
+func (AgentRunStep) TableName() string { return "ops_agent_run_steps" }
@@ -1,8 +1,12 @@
 package main
 
 import (
+	"github.com/WyRainBow/ops-portal/internal/ai/agentrun"
 	"github.com/WyRainBow/ops-portal/internal/ai/alerting"
+	"github.com/WyRainBow/ops-portal/internal/ai/mcp"
 	"github.com/WyRainBow/ops-portal/internal/ai/registry"
+	"github.com/WyRainBow/ops-portal/internal/ai/session"
+	"github.com/WyRainBow/ops-portal/internal/ai/usage"
 	"github.com/WyRainBow/ops-portal/internal/cache"
 	"github.com/WyRainBow/ops-portal/internal/config"
 	"github.com/WyRainBow/ops-portal/internal/controller/admin"
@@ -12,7 +16,10 @@ import (
 	"github.com/WyRainBow/ops-portal/internal/controller/observability"
 	"github.com/WyRainBow/ops-portal/internal/controller/ops"
 	"github.com/WyRainBow/ops-portal/internal/metrics"
+	"github.com/WyRainBow/ops-portal/internal/notification/feishu"
+	"github.com/WyRainBow/ops-portal/internal/ops/changes"
 	"github.com/WyRainBow/ops-portal/internal/ops/playbook"
+	"github.com/WyRainBow/ops-portal/internal/store"
 	"github.com/WyRainBow/ops-portal/utility/common"
 	"github.com/WyRainBow/ops-portal/utility/middleware"
 
@@ -35,6 +42,9 @@ func main() {
 	// Initialize metrics
 	metrics.InitializeStandardMetrics()
 
+	// Record LLM token usage of every model call (quotas, /api/admin/usage)
+	usage.Init()
+
 	// Initialize cache (in-memory by default, Redis if configured)
 	initCache(ctx)
 
@@ -44,8 +54,36 @@ func main() {
 	// Initialize diagnosis service (pre-warm the singleton)
 	_ = alerting.GlobalDiagnosis()
 
-	// Initialize playbook executor
+	// Feishu diagnosis reports (no-op unless FEISHU_* env vars are set)
+	if err := feishu.InitNotifier(); err != nil {
+		g.Log().Warningf(ctx, "Failed to initialize feishu notifier: %v", err)
+	}
+
+	// Create ops-portal owned tables (schedules, leases, ...)
+	if err := store.AutoMigrate(ctx); err != nil {
+		g.Log().Warningf(ctx, "Failed to migrate ops-portal tables: %v", err)
+	}
+
+	// Initialize playbook executor and the leader-elected scheduler
 	playbook.InitExecutor()
+	playbook.InitScheduler(ctx)
+
+	// Record git commits and pm2 restarts as change events
+	changes.InitWatcher(ctx)
+
+	// Evict idle chat sessions from memory (history stays in PostgreSQL)
+	session.StartEvictor(ctx)
+
+	// Delete recorded agent runs older than agent_runs.retention
+	agentrun.StartPurger(ctx)
+
+	// Load persisted tool overrides (enable state / agent types) so they
+	// apply as the tools register
+	if err := registry.LoadOverrides(ctx); err != nil {
+		g.Log().Warningf(ctx, "Failed to load tool overrides: %v", err)
+	}
+	// Pick up overrides changed on other replicas
+	registry.WatchOverrides(ctx)
 
 	// Initialize tool registry
 	// This must be done before any agent that uses tools
@@ -54,6 +92,11 @@ func main() {
 		// Continue anyway - some tools may still be available
 	}
 
+	// Mount tools from external MCP servers (mcp_servers in config)
+	if err := mcp.InitClients(ctx); err != nil {
+		g.Log().Errorf(ctx, "Failed to start MCP clients: %v", err)
+	}
+
 	fileDir, err := g.Cfg().Get(ctx, "file_dir")
 	if err != nil {
 		panic(err)
@@ -91,6 +134,8 @@ func main() {
 		group.Group("/observability", func(obsGroup *ghttp.RouterGroup) {
 			// Webhook endpoint must be public (for Alertmanager)
 			observability.RegisterAlertWebhookRoutes(obsGroup)
+			// Change-event ingestion authenticates with its own token (CI/CD)
+			observability.RegisterChangeEventRoutes(obsGroup)
 
 			// Other observability endpoints require auth
 			obsGroup.Middleware(middleware.JWTAuth(nil))
@@ -106,6 +151,12 @@ func main() {
 			ops.RegisterOpsRoutes(opsGroup)
 		})
 
+		// MCP server (streamable HTTP) - publishes enabled tools, require JWT auth
+		group.Group("/mcp", func(mcpGroup *ghttp.RouterGroup) {
+			mcpGroup.Middleware(middleware.JWTAuth(nil))
+			mcpGroup.ALL("/", mcp.GlobalServer().HTTPHandler())
+		})
+
 		// Knowledge endpoints - require admin or member role
 		group.Group("/knowledge", func(knowledgeGroup *ghttp.RouterGroup) {
 			knowledgeGroup.Middleware(middleware.JWTAuth(nil))
@@ -1,7 +1,9 @@
 package mem
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	"github.com/cloudwego/eino/schema"
 )
@@ -14,30 +16,97 @@ func GetSimpleMemory(id string) *SimpleMemory {
 	defer mu.Unlock()
 	// 如果存在就返回，不存在就创建
 	if mem, ok := SimpleMemoryMap[id]; ok {
+		mem.touch()
 		return mem
 	} else {
 		newMem := &SimpleMemory{
 			ID:            id,
 			Messages:      []*schema.Message{},
 			MaxWindowSize: 6,
+			lastAccess:    time.Now(),
 		}
 		SimpleMemoryMap[id] = newMem
 		return newMem
 	}
 }
 
+// LookupSimpleMemory returns the memory of id without creating it.
+func LookupSimpleMemory(id string) (*SimpleMemory, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	mem, ok := SimpleMemoryMap[id]
+	if ok {
+		mem.touch()
+	}
+	return mem, ok
+}
+
+// DeleteSimpleMemory drops the memory of id.
+func DeleteSimpleMemory(id string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(SimpleMemoryMap, id)
+}
+
+// EvictIdle drops memories not accessed for longer than idle and returns how
+// many were dropped.
+func EvictIdle(idle time.Duration) int {
+	mu.Lock()
+	defer mu.Unlock()
+	cutoff := time.Now().Add(-idle)
+	n := 0
+	for id, mem := range SimpleMemoryMap {
+		if mem.LastAccess().Before(cutoff) {
+			delete(SimpleMemoryMap, id)
+			n++
+		}
+	}
+	return n
+}
+
+// StartEvictor runs EvictIdle every interval until ctx is done.
+func StartEvictor(ctx context.Context, idle, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				EvictIdle(idle)
+			}
+		}
+	}()
+}
+
 type SimpleMemory struct {
 	ID            string            `json:"id"`
 	Messages      []*schema.Message `json:"messages"`
 	MaxWindowSize int
 	mu            sync.Mutex
+	lastAccess    time.Time
+}
+
+func (c *SimpleMemory) touch() {
+	c.mu.Lock()
+	c.lastAccess = time.Now()
+	c.mu.Unlock()
+}
+
+// LastAccess is the last time the memory was read or written.
+func (c *SimpleMemory) LastAccess() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastAccess
 }
 
 func (c *SimpleMemory) SetMessages(msg *schema.Message) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.lastAccess = time.Now()
 	c.Messages = append(c.Messages, msg)
-	if len(c.Messages) > c.MaxWindowSize {
+	if c.MaxWindowSize > 0 && len(c.Messages) > c.MaxWindowSize {
 		// 确保成对丢弃消息，保持对话配对关系
 		// 计算需要丢弃的消息数量（必须是偶数）
 		excess := len(c.Messages) - c.MaxWindowSize
@@ -48,9 +117,20 @@ func (c *SimpleMemory) SetMessages(msg *schema.Message) {
 		c.Messages = c.Messages[excess:]
 	}
 }
-func (c *SimpleMemory) GetMessages() []*schema.Message {
+
+// ReplaceMessages replaces all messages, e.g. after loading a session from
+// the database or summarising older turns.
+func (c *SimpleMemory) ReplaceMessages(msgs []*schema.Message) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.lastAccess = time.Now()
+	c.Messages = append([]*schema.Message(nil), msgs...)
+}
 
-	return c.Messages
+func (c *SimpleMemory) GetMessages() []*schema.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastAccess = time.Now()
+	// 返回副本，避免调用方遍历时与 SetMessages 并发修改
+	return append([]*schema.Message(nil), c.Messages...)
 }
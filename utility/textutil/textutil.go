@@ -0,0 +1,30 @@
+// Package textutil truncates text without splitting UTF-8 runes, so clipped
+// tool output, log lines and stored payloads stay valid for Postgres and JSON.
+package textutil
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Truncate cuts s to at most max runes and reports whether it was cut.
+func Truncate(s string, max int) (string, bool) {
+	if utf8.RuneCountInString(s) <= max {
+		return s, false
+	}
+	return string([]rune(s)[:max]), true
+}
+
+// TruncateBytes makes s valid UTF-8 and cuts it to at most max bytes, backing
+// off to a rune boundary. It reports whether it was cut.
+func TruncateBytes(s string, max int) (string, bool) {
+	s = strings.ToValidUTF8(s, "\uFFFD")
+	if len(s) <= max {
+		return s, false
+	}
+	i := max
+	for i > 0 && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return s[:i], true
+}
@@ -0,0 +1,28 @@
+package textutil
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	if got, cut := Truncate("磁盘已满", 2); got != "磁盘" || !cut {
+		t.Fatalf("Truncate = %q, %v", got, cut)
+	}
+	if got, cut := Truncate("ok", 2); got != "ok" || cut {
+		t.Fatalf("Truncate = %q, %v", got, cut)
+	}
+}
+
+func TestTruncateBytes(t *testing.T) {
+	s := strings.Repeat("磁", 10) // 3 bytes per rune
+	got, cut := TruncateBytes(s, 10)
+	if !cut || got != strings.Repeat("磁", 3) {
+		t.Fatalf("TruncateBytes = %q, %v", got, cut)
+	}
+	got, cut = TruncateBytes("a\xffb", 10)
+	if cut || !utf8.ValidString(got) {
+		t.Fatalf("TruncateBytes = %q, %v; want valid UTF-8", got, cut)
+	}
+}